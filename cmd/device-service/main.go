@@ -4,6 +4,9 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata" // schedules resolve IANA timezones; the runtime image ships without zoneinfo
 
 	"github.com/MichaelGenchev/smart-home-system/internal/app/deviceapp"
 	"github.com/MichaelGenchev/smart-home-system/internal/common/config"
@@ -16,8 +19,16 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Create and initialize the device service application
+	// Create the device service application
 	app := deviceapp.NewApp(cfg)
+
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		os.Exit(runReindex(ctx, app, os.Args[2:]))
+	}
+
+	// Initialize the device service application
 	if err := app.Initialize(context.Background()); err != nil {
 		log.Fatalf("Failed to initialize device service: %v", err)
 	}
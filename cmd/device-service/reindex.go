@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/app/deviceapp"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/reindex"
+)
+
+// Exit codes of the reindex command
+const (
+	exitOK    = 0
+	exitError = 1
+	// exitDrift reports that a check found the projection out of date
+	exitDrift = 2
+)
+
+// runReindex runs "device-service reindex [-mode check|repair|rebuild] [-report file]".
+// The report is written as JSON to stdout, or to the given file.
+func runReindex(ctx context.Context, app *deviceapp.App, args []string) int {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	mode := flags.String("mode", string(reindex.ModeCheck), "check only reports differences, repair fixes them, rebuild rewrites every device")
+	reportPath := flags.String("report", "", "file to write the JSON report to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	reindexMode, err := reindex.ParseMode(*mode)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	report, err := app.Reindex(ctx, reindexMode)
+	if err != nil {
+		log.Printf("Reindex failed: %v", err)
+		return exitError
+	}
+
+	var out io.Writer = os.Stdout
+	if *reportPath != "" {
+		file, err := os.Create(*reportPath)
+		if err != nil {
+			log.Printf("Failed to create report: %v", err)
+			return exitError
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Printf("Failed to write report: %v", err)
+		return exitError
+	}
+
+	log.Printf("Reindex (%s): %d SQL devices, %d Mongo devices, %d missing, %d extra, %d mismatched, %d pending, %d repaired",
+		report.Mode, report.SQLDevices, report.MongoDevices, len(report.Missing), len(report.Extra), len(report.Mismatched), len(report.Pending), report.Repaired)
+	if reindexMode == reindex.ModeCheck && report.Drift() {
+		return exitDrift
+	}
+	return exitOK
+}
@@ -4,30 +4,58 @@ package deviceapp
 import (
 	"context"
 	"database/sql"
+	"expvar"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/MichaelGenchev/smart-home-system/internal/common/config"
+	"github.com/MichaelGenchev/smart-home-system/internal/common/idempotency"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/automation"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/connectivity"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/outbox"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/reindex"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/scheduler"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/service"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/watch"
 	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
 	_ "github.com/lib/pq" // PostgreSQL driver
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 type App struct {
-	cfg           *config.Config
-	sqlDB         *sql.DB
-	mongoClient   *mongo.Client
-	grpcServer    *grpc.Server
-	deviceService *service.DeviceService
+	cfg               *config.Config
+	sqlDB             *sql.DB
+	mongoClient       *mongo.Client
+	grpcServer        *grpc.Server
+	httpServer        *http.Server
+	deviceService     *service.DeviceService
+	deviceTypeService *service.DeviceTypeService
+	roomService       *service.RoomService
+	sceneService      *service.SceneService
+	scheduleService   *service.ScheduleService
+	ruleService       *service.RuleService
+	scheduler         *scheduler.Scheduler
+	sweeper           *connectivity.Sweeper
+	automation        *automation.Engine
+	watchHub          *watch.Hub
+	outboxRelay       *outbox.Relay
+	eventBus          *events.Bus
+	idempotencyStore  *idempotency.SQLStore
+	userConn          *grpc.ClientConn
 }
 
 func NewApp(cfg *config.Config) *App {
@@ -37,6 +65,89 @@ func NewApp(cfg *config.Config) *App {
 }
 
 func (a *App) Initialize(ctx context.Context) error {
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+
+	// Initialize repositories
+	sqlRepo := repository.NewSQLRepository(a.sqlDB)
+	mongoRepo := repository.NewMongoRepository(a.mongoClient.Database(a.cfg.Database.DeviceMongoDB).Collection("devices"))
+	if err := mongoRepo.EnsureIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create MongoDB indexes: %w", err)
+	}
+	telemetryRepo := repository.NewMongoTelemetryRepository(a.mongoClient.Database(a.cfg.Database.DeviceMongoDB).Collection("telemetry"))
+	if err := telemetryRepo.EnsureCollection(ctx, a.cfg.App.TelemetryRetention); err != nil {
+		return fmt.Errorf("failed to create MongoDB telemetry collection: %w", err)
+	}
+	outboxCheckpointRepo := repository.NewSQLOutboxCheckpointRepository(a.sqlDB)
+	combinedRepo := repository.NewCombinedRepository(sqlRepo, mongoRepo, outboxCheckpointRepo)
+	deviceTypeRepo := repository.NewSQLDeviceTypeRepository(a.sqlDB)
+	roomRepo := repository.NewSQLRoomRepository(a.sqlDB)
+	sceneRepo := repository.NewSQLSceneRepository(a.sqlDB)
+	scheduleRepo := repository.NewSQLScheduleRepository(a.sqlDB)
+	ruleRepo := repository.NewSQLRuleRepository(a.sqlDB)
+	deviceEventRepo := repository.NewSQLDeviceEventRepository(a.sqlDB)
+
+	// Initialize the relay that applies device changes to the Mongo read model
+	a.outboxRelay = outbox.NewRelay(deviceEventRepo, outboxCheckpointRepo, mongoRepo, a.cfg.App.OutboxPollInterval)
+
+	// Initialize the bus every device command publishes its domain events to,
+	// whichever service runs it
+	a.eventBus = events.NewBus()
+
+	// Initialize the lookup of the households users share devices in. They
+	// are kept by the user service.
+	memberships := membership.NewCache(membership.NewClient(a.userConn), a.cfg.App.MembershipCacheTTL)
+
+	// Initialize the automation engine. It evaluates rules on every state update.
+	a.automation = automation.NewEngine(ruleRepo, combinedRepo, deviceTypeRepo, a.eventBus, memberships, a.cfg.App.SchedulerInterval)
+	a.eventBus.Subscribe("automation", a.automation, events.TypeDeviceStateChanged)
+
+	// Initialize the hub that feeds WatchDevices from the device event log
+	a.watchHub = watch.NewHub(deviceEventRepo, a.cfg.App.WatchPollInterval)
+
+	// Initialize services
+	a.deviceService = service.NewDeviceService(combinedRepo, deviceTypeRepo, roomRepo, telemetryRepo, a.watchHub, a.eventBus, memberships, []byte(a.cfg.Auth.PageTokenSecret))
+	a.deviceTypeService = service.NewDeviceTypeService(deviceTypeRepo)
+	a.roomService = service.NewRoomService(roomRepo, combinedRepo, memberships)
+	a.sceneService = service.NewSceneService(sceneRepo, combinedRepo, deviceTypeRepo, a.eventBus, memberships)
+	a.scheduleService = service.NewScheduleService(scheduleRepo, combinedRepo, deviceTypeRepo, memberships)
+	a.ruleService = service.NewRuleService(ruleRepo, combinedRepo, deviceTypeRepo, memberships)
+
+	// Initialize scheduler
+	stateUpdater := command.NewUpdateDeviceStateHandler(combinedRepo, deviceTypeRepo, a.eventBus)
+	a.scheduler = scheduler.NewScheduler(scheduleRepo, combinedRepo, memberships, stateUpdater, a.cfg.App.SchedulerInterval)
+
+	// Initialize the sweeper that marks devices offline when their heartbeats stop
+	a.sweeper = connectivity.NewSweeper(combinedRepo, a.eventBus, a.cfg.App.HeartbeatSweepInterval, a.cfg.App.HeartbeatTimeout)
+
+	// Initialize gRPC server. Retries of device creation with the same
+	// idempotency key get the original response.
+	a.idempotencyStore = idempotency.NewSQLStore(a.sqlDB)
+	a.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(idempotency.UnaryServerInterceptor(
+		a.idempotencyStore, a.cfg.App.IdempotencyWindow,
+		proto.DeviceService_CreateDevice_FullMethodName,
+	)))
+	proto.RegisterDeviceServiceServer(a.grpcServer, a.deviceService)
+	proto.RegisterDeviceTypeServiceServer(a.grpcServer, a.deviceTypeService)
+	proto.RegisterRoomServiceServer(a.grpcServer, a.roomService)
+	proto.RegisterSceneServiceServer(a.grpcServer, a.sceneService)
+	proto.RegisterScheduleServiceServer(a.grpcServer, a.scheduleService)
+	proto.RegisterRuleServiceServer(a.grpcServer, a.ruleService)
+
+	// Initialize the HTTP server for metrics, published as expvars
+	expvar.Publish("outbox_relay", expvar.Func(func() interface{} { return a.outboxRelay.Stats() }))
+	expvar.Publish("event_bus", expvar.Func(func() interface{} { return a.eventBus.Stats() }))
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	a.httpServer = &http.Server{Addr: a.cfg.Server.DeviceHTTPAddr, Handler: mux}
+
+	return nil
+}
+
+// connect opens the SQL and MongoDB connections, and the connection to the
+// user service
+func (a *App) connect(ctx context.Context) error {
 	var err error
 
 	// Initialize SQL database connection
@@ -61,19 +172,31 @@ func (a *App) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
-	// Initialize repositories
-	sqlRepo := repository.NewSQLRepository(a.sqlDB)
-	mongoRepo := repository.NewMongoRepository(a.mongoClient.Database(a.cfg.Database.DeviceMongoDB).Collection("devices"))
-	combinedRepo := repository.NewCombinedRepository(sqlRepo, mongoRepo)
+	// Initialize the user service connection. It is established on first use.
+	a.userConn, err = grpc.NewClient(a.cfg.Server.UserGRPCAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("failed to create user service client: %w", err)
+	}
 
-	// Initialize service
-	a.deviceService = service.NewDeviceService(combinedRepo)
+	return nil
+}
 
-	// Initialize gRPC server
-	a.grpcServer = grpc.NewServer()
-	proto.RegisterDeviceServiceServer(a.grpcServer, a.deviceService)
+// Reindex compares the Mongo device projection with SQL and, depending on
+// mode, repairs or rebuilds it. It runs against the databases of a live
+// service; see package reindex.
+func (a *App) Reindex(ctx context.Context, mode reindex.Mode) (*reindex.Report, error) {
+	if err := a.connect(ctx); err != nil {
+		return nil, err
+	}
+	defer a.disconnect(ctx)
 
-	return nil
+	sqlRepo := repository.NewSQLRepository(a.sqlDB)
+	mongoRepo := repository.NewMongoRepository(a.mongoClient.Database(a.cfg.Database.DeviceMongoDB).Collection("devices"))
+	deviceEventRepo := repository.NewSQLDeviceEventRepository(a.sqlDB)
+	outboxCheckpointRepo := repository.NewSQLOutboxCheckpointRepository(a.sqlDB)
+
+	reconciler := reindex.NewReconciler(sqlRepo, mongoRepo, deviceEventRepo, outboxCheckpointRepo)
+	return reconciler.Run(ctx, mode)
 }
 
 func (a *App) Run() error {
@@ -90,6 +213,45 @@ func (a *App) Run() error {
 		}
 	}()
 
+	go func() {
+		log.Printf("Starting HTTP server on %s", a.cfg.Server.DeviceHTTPAddr)
+		if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server error: %v", err)
+		}
+	}()
+
+	// Start scheduler, time-triggered automation, the device watch hub, the
+	// outbox relay, the connectivity sweeper and the purge of expired
+	// idempotency keys
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	background.Add(6)
+	go func() {
+		defer background.Done()
+		log.Printf("Starting scheduler with interval %s", a.cfg.App.SchedulerInterval)
+		a.scheduler.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		a.automation.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		a.watchHub.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		a.outboxRelay.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		a.sweeper.Run(backgroundCtx)
+	}()
+	go func() {
+		defer background.Done()
+		idempotency.Purge(backgroundCtx, a.idempotencyStore, time.Hour)
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -97,12 +259,38 @@ func (a *App) Run() error {
 	log.Println("Shutting down server...")
 
 	// Graceful shutdown
+	stopBackground()
+	background.Wait()
+	log.Println("Scheduler stopped")
+
+	// End open watches, GracefulStop would otherwise wait for them
+	a.watchHub.Close()
 	a.grpcServer.GracefulStop()
 	log.Println("gRPC server stopped")
 
+	// Let rules triggered by the last requests, and asynchronous event
+	// subscribers, finish before closing the databases
+	a.automation.Wait()
+	a.eventBus.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Failed to stop HTTP server: %v", err)
+	}
+
+	a.disconnect(ctx)
+
+	return nil
+}
+
+// disconnect closes the SQL, MongoDB and user service connections
+func (a *App) disconnect(ctx context.Context) {
+	if err := a.userConn.Close(); err != nil {
+		log.Printf("Failed to close user service connection: %v", err)
+	}
+
 	if err := a.mongoClient.Disconnect(ctx); err != nil {
 		log.Printf("Failed to disconnect MongoDB: %v", err)
 	} else {
@@ -114,6 +302,4 @@ func (a *App) Run() error {
 	} else {
 		log.Println("PostgreSQL connection closed")
 	}
-
-	return nil
 }
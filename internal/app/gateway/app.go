@@ -44,6 +44,21 @@ func (a *App) Run() error {
 	mux.Handle("/api/v1/users/", middleware.Chain(
 		http.HandlerFunc(userHandler.ServeHTTP),
 		middleware.RateLimit,
+		middleware.IdempotencyKey,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+
+	//Household and invitation routes
+	householdHandler := handlers.NewHouseholdHandler(a.userConn)
+	mux.Handle("/api/v1/households/", middleware.Chain(
+		http.HandlerFunc(householdHandler.ServeHTTP),
+		middleware.RateLimit,
+		middleware.IdempotencyKey,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+	mux.Handle("/api/v1/invitations/", middleware.Chain(
+		http.HandlerFunc(householdHandler.ServeInvitations),
+		middleware.RateLimit,
 		middleware.Auth(a.config.Auth.JWTSecret),
 	))
 
@@ -52,9 +67,67 @@ func (a *App) Run() error {
 	mux.Handle("/api/v1/devices/", middleware.Chain(
 		http.HandlerFunc(deviceHandler.ServeHTTP),
 		middleware.RateLimit,
+		middleware.IdempotencyKey,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+
+	//A batch counts once against the rate limit, however many devices it updates
+	mux.Handle("/api/v1/devices:batchUpdate", middleware.Chain(
+		http.HandlerFunc(deviceHandler.BatchUpdateDeviceStates),
+		middleware.RateLimit,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+
+	//Device type routes. Every user reads the registry, administrators change it.
+	deviceTypeHandler := handlers.NewDeviceTypeHandler(a.deviceConn)
+	mux.Handle("/api/v1/device-types/", middleware.Chain(
+		http.HandlerFunc(deviceTypeHandler.ServeHTTP),
+		middleware.AdminWrites(a.config.Auth.AdminUserIDs),
+		middleware.RateLimit,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+
+	//Room routes
+	roomHandler := handlers.NewRoomHandler(a.deviceConn)
+	mux.Handle("/api/v1/rooms/", middleware.Chain(
+		http.HandlerFunc(roomHandler.ServeHTTP),
+		middleware.RateLimit,
 		middleware.Auth(a.config.Auth.JWTSecret),
 	))
 
+	//Scene routes
+	sceneHandler := handlers.NewSceneHandler(a.deviceConn)
+	mux.Handle("/api/v1/scenes/", middleware.Chain(
+		http.HandlerFunc(sceneHandler.ServeHTTP),
+		middleware.RateLimit,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+
+	//Schedule routes
+	scheduleHandler := handlers.NewScheduleHandler(a.deviceConn)
+	mux.Handle("/api/v1/schedules/", middleware.Chain(
+		http.HandlerFunc(scheduleHandler.ServeHTTP),
+		middleware.RateLimit,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+
+	//Rule routes
+	ruleHandler := handlers.NewRuleHandler(a.deviceConn)
+	mux.Handle("/api/v1/rules/", middleware.Chain(
+		http.HandlerFunc(ruleHandler.ServeHTTP),
+		middleware.RateLimit,
+		middleware.Auth(a.config.Auth.JWTSecret),
+	))
+
+	//Event routes. Streams authenticate with StreamAuth, which also takes the
+	//token from the query string for EventSource and WebSocket clients.
+	eventHandler := handlers.NewEventHandler(a.deviceConn, a.config.Auth.AllowedOrigins)
+	mux.Handle("/api/v1/events", middleware.Chain(
+		http.HandlerFunc(eventHandler.ServeHTTP),
+		middleware.RateLimit,
+		middleware.StreamAuth(a.config.Auth.JWTSecret),
+	))
+
 	return http.ListenAndServe(":"+a.config.Server.GatewayPort, mux)
 }
 
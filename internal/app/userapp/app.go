@@ -10,8 +10,10 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/MichaelGenchev/smart-home-system/internal/common/config"
+	"github.com/MichaelGenchev/smart-home-system/internal/common/idempotency"
 	grpcServer "github.com/MichaelGenchev/smart-home-system/internal/user/grpc"
 	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
 	"github.com/MichaelGenchev/smart-home-system/internal/user/service"
@@ -22,10 +24,12 @@ import (
 )
 
 type App struct {
-	cfg         *config.Config
-	sqlDB       *sql.DB
-	grpcServer  *grpc.Server
-	userService *grpcServer.GRPCServer
+	cfg              *config.Config
+	sqlDB            *sql.DB
+	grpcServer       *grpc.Server
+	userService      *grpcServer.GRPCServer
+	householdService *grpcServer.HouseholdGRPCServer
+	idempotencyStore *idempotency.SQLStore
 }
 
 func NewApp(cfg *config.Config) *App {
@@ -56,10 +60,20 @@ func (a *App) Initialize(ctx context.Context) error {
 
 	// Initialize grpc server
 	a.userService = grpcServer.NewGRPCServer(userservice)
-
-	// Initialize gRPC server
-	a.grpcServer = grpc.NewServer()
+	a.householdService = grpcServer.NewHouseholdGRPCServer(
+		service.NewHouseholdService(repository.NewPostgresHouseholdRepository(a.sqlDB), sqlRepo),
+	)
+
+	// Initialize gRPC server. Retries of user and household creation with
+	// the same idempotency key get the original response.
+	a.idempotencyStore = idempotency.NewSQLStore(a.sqlDB)
+	a.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(idempotency.UnaryServerInterceptor(
+		a.idempotencyStore, a.cfg.App.IdempotencyWindow,
+		proto.UserService_CreateUser_FullMethodName,
+		proto.HouseholdService_CreateHousehold_FullMethodName,
+	)))
 	proto.RegisterUserServiceServer(a.grpcServer, a.userService)
+	proto.RegisterHouseholdServiceServer(a.grpcServer, a.householdService)
 
 	return nil
 }
@@ -78,6 +92,14 @@ func (a *App) Run() error {
 		}
 	}()
 
+	// Start the purge of expired idempotency keys
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	purged := make(chan struct{})
+	go func() {
+		defer close(purged)
+		idempotency.Purge(purgeCtx, a.idempotencyStore, time.Hour)
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -85,6 +107,8 @@ func (a *App) Run() error {
 	log.Println("Shutting down server...")
 
 	// Graceful shutdown
+	stopPurge()
+	<-purged
 	a.grpcServer.GracefulStop()
 	log.Println("gRPC server stopped")
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -30,12 +31,41 @@ type DatabaseConfig struct {
 }
 
 type AppConfig struct {
-	Debug           bool
-	ShutdownTimeout time.Duration
+	Debug             bool
+	ShutdownTimeout   time.Duration
+	SchedulerInterval time.Duration
+	// WatchPollInterval is how often the device event log is polled for WatchDevices
+	WatchPollInterval time.Duration
+	// OutboxPollInterval is how often the outbox relay looks for device events
+	// to apply to the read model
+	OutboxPollInterval time.Duration
+	// IdempotencyWindow is how long the response to a call made with an
+	// idempotency key is replayed to retries
+	IdempotencyWindow time.Duration
+	// MembershipCacheTTL is how long the device service remembers the
+	// households of a user before asking the user service again
+	MembershipCacheTTL time.Duration
+	// HeartbeatSweepInterval is how often devices that stopped sending
+	// heartbeats are looked for
+	HeartbeatSweepInterval time.Duration
+	// HeartbeatTimeout is how long a device stays online after its last
+	// heartbeat, unless its device type sets its own timeout
+	HeartbeatTimeout time.Duration
+	// TelemetryRetention is how long sensor readings are kept; zero keeps
+	// them forever
+	TelemetryRetention time.Duration
 }
 
 type AuthConfig struct {
 	JWTSecret string
+	// PageTokenSecret signs the page tokens of device lists. Every instance
+	// of the device service needs the same one.
+	PageTokenSecret string
+	// AllowedOrigins lists the origins of the pages, besides the gateway's
+	// own, that may open WebSockets to the gateway
+	AllowedOrigins []string
+	// AdminUserIDs lists the users who may change the device type registry
+	AdminUserIDs []string
 }
 
 func Load() (*Config, error) {
@@ -53,11 +83,22 @@ func Load() (*Config, error) {
 			DeviceMongoDB:     getEnv("DEVICE_MONGO_DB", "devicedb"),
 		},
 		App: AppConfig{
-			Debug:           getEnvAsBool("DEBUG", false),
-			ShutdownTimeout: getEnvAsDuration("SHUTDOWN_TIMEOUT", 5*time.Second),
+			Debug:                  getEnvAsBool("DEBUG", false),
+			ShutdownTimeout:        getEnvAsDuration("SHUTDOWN_TIMEOUT", 5*time.Second),
+			SchedulerInterval:      getEnvAsDuration("SCHEDULER_INTERVAL", 15*time.Second),
+			WatchPollInterval:      getEnvAsDuration("WATCH_POLL_INTERVAL", 500*time.Millisecond),
+			OutboxPollInterval:     getEnvAsDuration("OUTBOX_POLL_INTERVAL", 200*time.Millisecond),
+			IdempotencyWindow:      getEnvAsDuration("IDEMPOTENCY_WINDOW", 24*time.Hour),
+			MembershipCacheTTL:     getEnvAsDuration("MEMBERSHIP_CACHE_TTL", 30*time.Second),
+			HeartbeatSweepInterval: getEnvAsDuration("HEARTBEAT_SWEEP_INTERVAL", 30*time.Second),
+			HeartbeatTimeout:       getEnvAsDuration("HEARTBEAT_TIMEOUT", 5*time.Minute),
+			TelemetryRetention:     getEnvAsDuration("TELEMETRY_RETENTION", 90*24*time.Hour),
 		},
 		Auth: AuthConfig{
-			JWTSecret: getEnv("JWT_SECRET", "your-secret-key"),
+			JWTSecret:       getEnv("JWT_SECRET", "your-secret-key"),
+			PageTokenSecret: getEnv("PAGE_TOKEN_SECRET", "your-page-token-secret"),
+			AllowedOrigins:  getEnvAsList("ALLOWED_ORIGINS", nil),
+			AdminUserIDs:    getEnvAsList("ADMIN_USER_IDS", nil),
 		},
 	}
 
@@ -99,6 +140,21 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvAsList reads a comma-separated list, ignoring empty entries
+func getEnvAsList(key string, defaultValue []string) []string {
+	valStr := getEnv(key, "")
+	var list []string
+	for _, val := range strings.Split(valStr, ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			list = append(list, val)
+		}
+	}
+	if list == nil {
+		return defaultValue
+	}
+	return list
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	valStr := getEnv(key, "")
 	if val, err := time.ParseDuration(valStr); err == nil {
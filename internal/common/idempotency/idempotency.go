@@ -0,0 +1,198 @@
+// Package idempotency lets clients retry gRPC calls that create something
+// without creating it twice.
+//
+// A client sends the same key, as the idempotency-key metadata, with every
+// attempt of a call. The first attempt runs and its response is stored for the
+// key; later attempts get that response replayed instead of running again. An
+// attempt with the same key but a different request is rejected, and so is one
+// that arrives while the first is still running. Keys of different users do
+// not collide.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// MetadataKey is the gRPC metadata the key travels in
+	MetadataKey = "idempotency-key"
+	// MaxKeyLength bounds the length of a key
+	MaxKeyLength = 255
+	// claimTimeout is how long a request may run before another attempt with
+	// the same key may take over its claim, e.g. after a crash
+	claimTimeout = time.Minute
+	// maxClaimAttempts bounds how often claiming is retried when the record
+	// found for a key disappears before it could be read
+	maxClaimAttempts = 3
+)
+
+// ErrClaimLost is returned when a claim was taken over before its request completed
+var ErrClaimLost = errors.New("idempotency key claim was taken over")
+
+// Record is what is stored for a key
+type Record struct {
+	// Fingerprint identifies the request the key was first used with
+	Fingerprint string
+	// Response is the serialized response, or nil while the request runs
+	Response []byte
+}
+
+// Store keeps the records of keys for a window after they were first used.
+// Keys are scoped, e.g. to a gRPC method.
+type Store interface {
+	// Claim reserves a key for a request with the given fingerprint and
+	// returns a token identifying the claim. If the key is taken, no token but
+	// the record of the request that took it is returned.
+	Claim(ctx context.Context, scope, key, fingerprint string, window time.Duration) (string, *Record, error)
+	// Complete stores the response of a claimed request. It returns
+	// ErrClaimLost if the claim was taken over.
+	Complete(ctx context.Context, scope, key, token string, response []byte) error
+	// Release gives up a claim, so that the request may be tried again
+	Release(ctx context.Context, scope, key, token string) error
+	// DeleteExpired removes the records whose window has passed
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// KeyFromContext returns the key of an incoming gRPC call, if any
+func KeyFromContext(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// UnaryServerInterceptor makes the given methods, named as in
+// grpc.UnaryServerInfo.FullMethod, idempotent for calls that carry a key.
+// Responses are kept for window. Calls without a key, and calls of other
+// methods, are passed through.
+func UnaryServerInterceptor(store Store, window time.Duration, methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := KeyFromContext(ctx)
+		if key == "" || !slices.Contains(methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		if len(key) > MaxKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key must not be longer than %d characters", MaxKeyLength)
+		}
+		message, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		fingerprint, err := fingerprintOf(message)
+		if err != nil {
+			return nil, err
+		}
+
+		// Keys are per user, so that one user cannot replay another's response
+		scope := info.FullMethod
+		if userID := identity.UserIDFromIncomingContext(ctx); userID != "" {
+			scope += " " + userID
+		}
+		token, record, err := claim(ctx, store, scope, key, fingerprint, window)
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return replay(record, fingerprint)
+		}
+
+		// The outcome is recorded even if the client gave up waiting for it
+		storeCtx := context.WithoutCancel(ctx)
+		resp, err := handler(ctx, req)
+		if err != nil {
+			if releaseErr := store.Release(storeCtx, scope, key, token); releaseErr != nil {
+				log.Printf("Failed to release idempotency key for %s: %v", info.FullMethod, releaseErr)
+			}
+			return nil, err
+		}
+		response, err := marshalResponse(resp)
+		if err == nil {
+			err = store.Complete(storeCtx, scope, key, token, response)
+		}
+		if err != nil {
+			log.Printf("Failed to store the response for an idempotency key of %s: %v", info.FullMethod, err)
+		}
+		return resp, nil
+	}
+}
+
+// claim claims a key, retrying when the record of a taken key was released
+// before it could be read
+func claim(ctx context.Context, store Store, scope, key, fingerprint string, window time.Duration) (string, *Record, error) {
+	for attempt := 0; attempt < maxClaimAttempts; attempt++ {
+		token, record, err := store.Claim(ctx, scope, key, fingerprint, window)
+		if err != nil || token != "" || record != nil {
+			return token, record, err
+		}
+	}
+	return "", nil, status.Error(codes.Aborted, "a request with this idempotency key is in progress, retry later")
+}
+
+// replay returns the response of the request that first used a key
+func replay(record *Record, fingerprint string) (interface{}, error) {
+	if record.Fingerprint != fingerprint {
+		return nil, status.Error(codes.InvalidArgument, "idempotency key was already used with a different request")
+	}
+	if record.Response == nil {
+		return nil, status.Error(codes.Aborted, "a request with this idempotency key is in progress, retry later")
+	}
+	var response anypb.Any
+	if err := proto.Unmarshal(record.Response, &response); err != nil {
+		return nil, err
+	}
+	return response.UnmarshalNew()
+}
+
+func marshalResponse(resp interface{}) ([]byte, error) {
+	message, ok := resp.(proto.Message)
+	if !ok {
+		return nil, errors.New("response is not a protocol buffer message")
+	}
+	response, err := anypb.New(message)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(response)
+}
+
+// fingerprintOf hashes a request, so that records hold no request fields
+func fingerprintOf(req proto.Message) (string, error) {
+	raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Purge deletes expired records every interval until ctx is cancelled
+func Purge(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := store.DeleteExpired(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to delete expired idempotency keys: %v", err)
+		}
+	}
+}
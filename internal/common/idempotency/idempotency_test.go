@@ -0,0 +1,166 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// memoryStore keeps records in memory. They never expire.
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+	tokens  map[string]string
+	next    int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]*Record{}, tokens: map[string]string{}}
+}
+
+func (s *memoryStore) Claim(ctx context.Context, scope, key, fingerprint string, window time.Duration) (string, *Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[scope+key]; ok {
+		copied := *record
+		return "", &copied, nil
+	}
+	s.next++
+	token := strconv.Itoa(s.next)
+	s.records[scope+key] = &Record{Fingerprint: fingerprint}
+	s.tokens[scope+key] = token
+	return token, nil, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, scope, key, token string, response []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens[scope+key] != token {
+		return ErrClaimLost
+	}
+	s.records[scope+key].Response = response
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, scope, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens[scope+key] == token {
+		delete(s.records, scope+key)
+		delete(s.tokens, scope+key)
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+var createDevice = &grpc.UnaryServerInfo{FullMethod: proto.DeviceService_CreateDevice_FullMethodName}
+
+func withKey(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, key))
+}
+
+// creator is a CreateDevice handler that counts its calls and fails while err is set
+type creator struct {
+	calls int
+	err   error
+}
+
+func (c *creator) handle(ctx context.Context, req interface{}) (interface{}, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &proto.Device{Id: "device-" + strconv.Itoa(c.calls), Name: req.(*proto.CreateDeviceRequest).Name}, nil
+}
+
+func TestRetriesReplayTheFirstResponse(t *testing.T) {
+	interceptor := UnaryServerInterceptor(newMemoryStore(), time.Hour, proto.DeviceService_CreateDevice_FullMethodName)
+	handler := &creator{}
+	req := &proto.CreateDeviceRequest{Name: "Lamp", Type: "switch", UserId: "alice"}
+
+	first, err := interceptor(withKey("k1"), req, createDevice, handler.handle)
+	require.NoError(t, err)
+	retry, err := interceptor(withKey("k1"), req, createDevice, handler.handle)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, handler.calls)
+	assert.Equal(t, "device-1", retry.(*proto.Device).Id)
+	assert.Equal(t, first.(*proto.Device).Name, retry.(*proto.Device).Name)
+
+	// Other keys, and calls without one, run
+	_, err = interceptor(withKey("k2"), req, createDevice, handler.handle)
+	require.NoError(t, err)
+	_, err = interceptor(context.Background(), req, createDevice, handler.handle)
+	require.NoError(t, err)
+	assert.Equal(t, 3, handler.calls)
+}
+
+func TestKeyReusedWithDifferentRequestIsRejected(t *testing.T) {
+	interceptor := UnaryServerInterceptor(newMemoryStore(), time.Hour, proto.DeviceService_CreateDevice_FullMethodName)
+	handler := &creator{}
+
+	_, err := interceptor(withKey("k1"), &proto.CreateDeviceRequest{Name: "Lamp", UserId: "alice"}, createDevice, handler.handle)
+	require.NoError(t, err)
+	_, err = interceptor(withKey("k1"), &proto.CreateDeviceRequest{Name: "Fan", UserId: "alice"}, createDevice, handler.handle)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 1, handler.calls)
+}
+
+func TestFailedCallsCanBeRetried(t *testing.T) {
+	store := newMemoryStore()
+	interceptor := UnaryServerInterceptor(store, time.Hour, proto.DeviceService_CreateDevice_FullMethodName)
+	handler := &creator{err: errors.New("database unavailable")}
+	req := &proto.CreateDeviceRequest{Name: "Lamp", UserId: "alice"}
+
+	_, err := interceptor(withKey("k1"), req, createDevice, handler.handle)
+	require.Error(t, err)
+
+	handler.err = nil
+	resp, err := interceptor(withKey("k1"), req, createDevice, handler.handle)
+	require.NoError(t, err)
+	assert.Equal(t, "device-2", resp.(*proto.Device).Id)
+}
+
+func TestRetryWhileFirstCallRunsIsAborted(t *testing.T) {
+	store := newMemoryStore()
+	interceptor := UnaryServerInterceptor(store, time.Hour, proto.DeviceService_CreateDevice_FullMethodName)
+	req := &proto.CreateDeviceRequest{Name: "Lamp", UserId: "alice"}
+
+	var err error
+	_, _ = interceptor(withKey("k1"), req, createDevice, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, err = interceptor(withKey("k1"), req, createDevice, (&creator{}).handle)
+		return &proto.Device{Id: "device-1"}, nil
+	})
+
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestKeysOfDifferentUsersDoNotCollide(t *testing.T) {
+	interceptor := UnaryServerInterceptor(newMemoryStore(), time.Hour, proto.DeviceService_CreateDevice_FullMethodName)
+	handler := &creator{}
+	req := &proto.CreateDeviceRequest{Name: "Lamp", Type: "switch"}
+
+	alice, err := interceptor(identity.NewIncomingContext(withKey("k1"), "alice"), req, createDevice, handler.handle)
+	require.NoError(t, err)
+	bob, err := interceptor(identity.NewIncomingContext(withKey("k1"), "bob"), req, createDevice, handler.handle)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, handler.calls)
+	assert.NotEqual(t, alice.(*proto.Device).Id, bob.(*proto.Device).Id)
+}
@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SQLStore keeps records in the idempotency_keys table of a service's database
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Claim(ctx context.Context, scope, key, fingerprint string, window time.Duration) (string, *Record, error) {
+	// A record whose window has passed, or whose request has been running for
+	// longer than claimTimeout, is claimed over
+	token := uuid.NewString()
+	query := `
+		INSERT INTO idempotency_keys (scope, key, fingerprint, token, claimed_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW() + $5 * INTERVAL '1 millisecond')
+		ON CONFLICT (scope, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, token = EXCLUDED.token, response = NULL,
+			claimed_at = EXCLUDED.claimed_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+			OR (idempotency_keys.response IS NULL AND idempotency_keys.claimed_at < NOW() - $6 * INTERVAL '1 millisecond')
+		RETURNING token
+	`
+	var claimed string
+	err := s.db.QueryRowContext(ctx, query, scope, key, fingerprint, token, window.Milliseconds(), claimTimeout.Milliseconds()).Scan(&claimed)
+	if err == nil {
+		return claimed, nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", nil, err
+	}
+
+	var record Record
+	query = `SELECT fingerprint, response FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	err = s.db.QueryRowContext(ctx, query, scope, key).Scan(&record.Fingerprint, &record.Response)
+	if err == sql.ErrNoRows {
+		// Released in the meantime
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return "", &record, nil
+}
+
+func (s *SQLStore) Complete(ctx context.Context, scope, key, token string, response []byte) error {
+	query := `UPDATE idempotency_keys SET response = $4 WHERE scope = $1 AND key = $2 AND token = $3`
+	result, err := s.db.ExecContext(ctx, query, scope, key, token, response)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+func (s *SQLStore) Release(ctx context.Context, scope, key, token string) error {
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND token = $3`
+	_, err := s.db.ExecContext(ctx, query, scope, key, token)
+	return err
+}
+
+func (s *SQLStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
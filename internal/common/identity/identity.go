@@ -0,0 +1,43 @@
+// Package identity carries the ID of the authenticated user from the gateway
+// to the gRPC services it calls.
+//
+// The gateway authenticates requests and forwards the subject of their token
+// as the x-user-id metadata. Services trust that metadata, so they must only
+// be reachable through the gateway.
+package identity
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata the user ID travels in
+const MetadataKey = "x-user-id"
+
+// NewOutgoingContext makes the gRPC calls made with the returned context act
+// on behalf of userID
+func NewOutgoingContext(ctx context.Context, userID string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, userID)
+}
+
+// NewIncomingContext returns a context of a gRPC call made on behalf of
+// userID, as a service would receive it. Other incoming metadata is kept.
+func NewIncomingContext(ctx context.Context, userID string) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+	md.Set(MetadataKey, userID)
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// UserIDFromIncomingContext returns the user an incoming gRPC call was made on
+// behalf of, or "" if it carries none
+func UserIDFromIncomingContext(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,81 @@
+package automation
+
+import (
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// matchesStateTrigger reports whether a state change fires a device_state
+// trigger. Writing an attribute's current value again is not a change.
+func matchesStateTrigger(trigger models.RuleTrigger, change events.DeviceStateChanged) bool {
+	for name, value := range change.Patch {
+		if trigger.Attribute != "" && name != trigger.Attribute {
+			continue
+		}
+		if previous, ok := change.Previous[name]; ok && valuesEqual(previous, value) {
+			continue
+		}
+		if trigger.Value != nil && !valuesEqual(*trigger.Value, value) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// conditionHolds evaluates a condition against a device's state. A missing
+// attribute never satisfies a condition.
+func conditionHolds(condition models.RuleCondition, state map[string]models.StateValue) bool {
+	value, ok := state[condition.Attribute]
+	if !ok || value.Type != condition.Value.Type {
+		return false
+	}
+
+	switch condition.Operator {
+	case models.OperatorEqual:
+		return valuesEqual(value, condition.Value)
+	case models.OperatorNotEqual:
+		return !valuesEqual(value, condition.Value)
+	}
+
+	actual, expected := number(value), number(condition.Value)
+	switch condition.Operator {
+	case models.OperatorGreater:
+		return actual > expected
+	case models.OperatorGreaterOrEqual:
+		return actual >= expected
+	case models.OperatorLess:
+		return actual < expected
+	case models.OperatorLessOrEqual:
+		return actual <= expected
+	default:
+		return false
+	}
+}
+
+func valuesEqual(a, b models.StateValue) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case models.ValueTypeBool:
+		return a.Bool == b.Bool
+	case models.ValueTypeInt:
+		return a.Int == b.Int
+	case models.ValueTypeFloat:
+		return a.Float == b.Float
+	case models.ValueTypeEnum:
+		return a.Enum == b.Enum
+	case models.ValueTypeColor:
+		return a.Color != nil && b.Color != nil && *a.Color == *b.Color
+	default:
+		return false
+	}
+}
+
+func number(value models.StateValue) float64 {
+	if value.Type == models.ValueTypeInt {
+		return float64(value.Int)
+	}
+	return value.Float
+}
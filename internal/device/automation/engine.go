@@ -0,0 +1,276 @@
+// Package automation evaluates user-defined rules and applies their actions.
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// maxChainLength bounds how many rules may run as a consequence of a single
+// external event, on top of refusing to run a rule twice in the same chain
+const maxChainLength = 8
+
+// chainKey carries the IDs of the rules that led to the current update
+type chainKey struct{}
+
+func chainFrom(ctx context.Context) []string {
+	chain, _ := ctx.Value(chainKey{}).([]string)
+	return chain
+}
+
+func withRule(ctx context.Context, ruleID string) context.Context {
+	chain := chainFrom(ctx)
+	return context.WithValue(ctx, chainKey{}, append(slices.Clip(chain), ruleID))
+}
+
+// Engine fires rules on device state changes, on their cron schedule and
+// after other rules. It is meant to be subscribed synchronously to the
+// DeviceStateChanged events of the bus every UpdateDeviceStateHandler
+// publishes to, so that the rules that led to an update reach it with the
+// context.
+//
+// Rules that trigger each other are cut off: the rules that led to an update
+// travel with its context, and a rule that already ran in the chain, or a
+// chain longer than maxChainLength, is recorded as loop_prevented instead of
+// running again.
+//
+// A rule only runs while its owner may still view the devices it watches and
+// control those it acts on; otherwise the execution fails without touching
+// any device.
+type Engine struct {
+	repo        repository.RuleRepository
+	deviceRepo  repository.CommandDeviceRepository
+	memberships membership.Source
+	updater     *command.UpdateDeviceStateHandler
+	interval    time.Duration
+	now         func() time.Time
+	wg          sync.WaitGroup
+}
+
+// NewEngine creates the engine. The device updates made by rule actions
+// publish their events to publisher. The households of rule owners are looked
+// up in memberships; without memberships, rules only touch their owner's own
+// devices.
+func NewEngine(repo repository.RuleRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, publisher events.Publisher, memberships membership.Source, interval time.Duration) *Engine {
+	e := &Engine{
+		repo:        repo,
+		deviceRepo:  deviceRepo,
+		memberships: memberships,
+		interval:    interval,
+		now:         time.Now,
+	}
+	// Actions go through the regular command path, publishing to the bus the
+	// engine subscribes to so that they can trigger further rules
+	e.updater = command.NewUpdateDeviceStateHandler(deviceRepo, typeRepo, publisher)
+	return e
+}
+
+// HandleEvent evaluates the rules triggered by a state change in the
+// background, so that the update that caused it is not held up
+func (e *Engine) HandleEvent(ctx context.Context, event events.Event) error {
+	change, ok := event.(events.DeviceStateChanged)
+	if !ok {
+		return nil
+	}
+	ctx = context.WithoutCancel(ctx)
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		if err := e.onStateChange(ctx, change); err != nil {
+			log.Printf("Failed to evaluate rules for device %s: %v", change.Device.ID, err)
+		}
+	}()
+	return nil
+}
+
+// Wait blocks until rule evaluations started by state changes have finished
+func (e *Engine) Wait() {
+	e.wg.Wait()
+}
+
+// Run fires time-triggered rules every interval until ctx is cancelled
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Tick(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Automation tick failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick fires every time-triggered rule that is due now. A rule is only fired
+// by the instance that manages to advance its next run.
+func (e *Engine) Tick(ctx context.Context) error {
+	now := e.now()
+	rules, err := e.repo.ListDueRules(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		next, err := command.NextRuleRun(rule, now)
+		if err != nil {
+			log.Printf("Rule %s has an invalid schedule: %v", rule.ID, err)
+		}
+		claimed, err := e.repo.AdvanceRuleNextRun(ctx, rule.ID, *rule.NextRunAt, next)
+		if err != nil {
+			return err
+		}
+		if claimed {
+			e.fire(ctx, rule, "time")
+		}
+	}
+	return nil
+}
+
+func (e *Engine) onStateChange(ctx context.Context, change events.DeviceStateChanged) error {
+	rules, err := e.repo.ListTriggeredRules(ctx, models.TriggerDeviceState, change.Device.ID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if matchesStateTrigger(rule.Trigger, change) {
+			e.fire(ctx, rule, fmt.Sprintf("device %s state", change.Device.ID))
+		}
+	}
+	return nil
+}
+
+// fire runs a triggered rule and records the execution. Rules triggered by
+// this one run afterwards, in the same chain.
+func (e *Engine) fire(ctx context.Context, rule *models.Rule, trigger string) {
+	chain := chainFrom(ctx)
+	execution := &models.RuleExecution{
+		RuleID:     rule.ID,
+		Trigger:    trigger,
+		Depth:      len(chain),
+		ExecutedAt: e.now(),
+	}
+	defer func() {
+		if err := e.repo.RecordRuleExecution(ctx, execution); err != nil {
+			log.Printf("Failed to record execution of rule %s: %v", rule.ID, err)
+		}
+	}()
+
+	if slices.Contains(chain, rule.ID) || len(chain) >= maxChainLength {
+		execution.Status = models.RuleExecutionLoopPrevented
+		execution.Error = "rule chain " + strings.Join(append(slices.Clip(chain), rule.ID), " -> ")
+		return
+	}
+
+	devices, err := e.authorize(ctx, rule)
+	if err != nil {
+		execution.Status = models.RuleExecutionFailed
+		execution.Error = err.Error()
+		return
+	}
+	met, err := e.conditionsMet(rule, devices)
+	if err != nil {
+		execution.Status = models.RuleExecutionFailed
+		execution.Error = err.Error()
+		return
+	}
+	if !met {
+		execution.Status = models.RuleExecutionConditionsUnmet
+		return
+	}
+
+	ctx = withRule(ctx, rule.ID)
+	if err := e.applyActions(ctx, rule); err != nil {
+		execution.Status = models.RuleExecutionFailed
+		execution.Error = err.Error()
+		return
+	}
+	execution.Status = models.RuleExecutionSucceeded
+
+	followers, err := e.repo.ListTriggeredRules(ctx, models.TriggerRule, rule.ID)
+	if err != nil {
+		log.Printf("Failed to list rules triggered by rule %s: %v", rule.ID, err)
+		return
+	}
+	for _, follower := range followers {
+		e.fire(ctx, follower, "rule "+rule.ID)
+	}
+}
+
+// authorize checks that the rule's owner may still view the devices the rule
+// watches and control those it acts on, as the rule service checked when the
+// rule was saved. It returns the devices by ID.
+func (e *Engine) authorize(ctx context.Context, rule *models.Rule) (map[string]*models.Device, error) {
+	devices := make(map[string]*models.Device)
+	check := func(what, deviceID string, allowed func(models.HouseholdRole) bool) error {
+		device, ok := devices[deviceID]
+		if !ok {
+			var err error
+			if device, err = e.deviceRepo.LoadDevice(ctx, deviceID); err != nil {
+				return fmt.Errorf("%s device %s: %w", what, deviceID, err)
+			}
+			devices[deviceID] = device
+		}
+		if err := membership.Authorize(ctx, e.memberships, rule.UserID, device, allowed); err != nil {
+			return fmt.Errorf("%s device %s: %w", what, deviceID, err)
+		}
+		return nil
+	}
+
+	if rule.Trigger.Type == models.TriggerDeviceState {
+		if err := check("trigger", rule.Trigger.DeviceID, models.HouseholdRole.Valid); err != nil {
+			return nil, err
+		}
+	}
+	for _, condition := range rule.Conditions {
+		if err := check("condition", condition.DeviceID, models.HouseholdRole.Valid); err != nil {
+			return nil, err
+		}
+	}
+	for _, action := range rule.Actions {
+		if err := check("action", action.DeviceID, models.HouseholdRole.CanControlDevices); err != nil {
+			return nil, err
+		}
+	}
+	return devices, nil
+}
+
+// conditionsMet evaluates the conditions against the devices as authorized
+func (e *Engine) conditionsMet(rule *models.Rule, devices map[string]*models.Device) (bool, error) {
+	for _, condition := range rule.Conditions {
+		device, ok := devices[condition.DeviceID]
+		if !ok {
+			return false, fmt.Errorf("condition on device %s: %w", condition.DeviceID, repository.ErrDeviceNotFound)
+		}
+		if !conditionHolds(condition, device.State) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// applyActions applies every action, even when an earlier one fails
+func (e *Engine) applyActions(ctx context.Context, rule *models.Rule) error {
+	var errs []error
+	for _, action := range rule.Actions {
+		cmd := command.UpdateDeviceStateCommand{ID: action.DeviceID, State: action.State}
+		if _, err := e.updater.Handle(ctx, cmd); err != nil {
+			errs = append(errs, fmt.Errorf("action on device %s: %w", action.DeviceID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,241 @@
+package automation
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDeviceRepository keeps devices in memory
+type fakeDeviceRepository struct {
+	repository.CommandDeviceRepository
+	mu      sync.Mutex
+	devices map[string]*models.Device
+}
+
+func (r *fakeDeviceRepository) LoadDevice(ctx context.Context, id string) (*models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[id]
+	if !ok {
+		return nil, repository.ErrDeviceNotFound
+	}
+	copied := *device
+	copied.State = make(map[string]models.StateValue, len(device.State))
+	for name, value := range device.State {
+		copied.State[name] = value
+	}
+	return &copied, nil
+}
+
+func (r *fakeDeviceRepository) UpdateDeviceState(ctx context.Context, id string, state map[string]models.StateValue, expectedVersion int64) (*models.Device, error) {
+	r.mu.Lock()
+	for name, value := range state {
+		r.devices[id].State[name] = value
+	}
+	r.mu.Unlock()
+	return r.LoadDevice(ctx, id)
+}
+
+type fakeDeviceTypeRepository struct {
+	repository.DeviceTypeRepository
+}
+
+func (fakeDeviceTypeRepository) GetDeviceType(ctx context.Context, id string) (*models.DeviceType, error) {
+	return &models.DeviceType{
+		ID:           id,
+		Capabilities: []models.Capability{{Name: "power", Type: models.ValueTypeBool}},
+	}, nil
+}
+
+// fakeRuleRepository serves a fixed set of rules and collects executions
+type fakeRuleRepository struct {
+	repository.RuleRepository
+	rules      []*models.Rule
+	mu         sync.Mutex
+	executions []*models.RuleExecution
+}
+
+func (r *fakeRuleRepository) ListTriggeredRules(ctx context.Context, triggerType models.TriggerType, sourceID string) ([]*models.Rule, error) {
+	var rules []*models.Rule
+	for _, rule := range r.rules {
+		trigger := rule.Trigger
+		if trigger.Type == triggerType && (trigger.DeviceID == sourceID || trigger.RuleID == sourceID) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (r *fakeRuleRepository) RecordRuleExecution(ctx context.Context, execution *models.RuleExecution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executions = append(r.executions, execution)
+	return nil
+}
+
+func power(on bool) map[string]models.StateValue {
+	return map[string]models.StateValue{"power": {Type: models.ValueTypeBool, Bool: on}}
+}
+
+func powerTrigger(deviceID string, on bool) models.RuleTrigger {
+	value := models.StateValue{Type: models.ValueTypeBool, Bool: on}
+	return models.RuleTrigger{Type: models.TriggerDeviceState, DeviceID: deviceID, Attribute: "power", Value: &value}
+}
+
+func newTestEngine(rules []*models.Rule) (*Engine, *events.Bus, *fakeDeviceRepository, *fakeRuleRepository) {
+	devices := &fakeDeviceRepository{devices: map[string]*models.Device{
+		"lamp": {ID: "lamp", Type: "switch", State: power(false)},
+		"tv":   {ID: "tv", Type: "switch", State: power(false)},
+	}}
+	rulesRepo := &fakeRuleRepository{rules: rules}
+	bus := events.NewBus()
+	engine := NewEngine(rulesRepo, devices, fakeDeviceTypeRepository{}, bus, nil, time.Minute)
+	bus.Subscribe("automation", engine, events.TypeDeviceStateChanged)
+	return engine, bus, devices, rulesRepo
+}
+
+func TestRuleFiresOnMatchingStateChange(t *testing.T) {
+	rule := &models.Rule{ID: "tv-follows-lamp", Enabled: true, Trigger: powerTrigger("lamp", true), Actions: []models.RuleAction{{DeviceID: "tv", State: power(true)}}}
+	engine, bus, devices, rules := newTestEngine([]*models.Rule{rule})
+
+	updater := command.NewUpdateDeviceStateHandler(devices, fakeDeviceTypeRepository{}, bus)
+	_, err := updater.Handle(context.Background(), command.UpdateDeviceStateCommand{ID: "lamp", State: power(true)})
+	require.NoError(t, err)
+	engine.Wait()
+
+	tv, _ := devices.LoadDevice(context.Background(), "tv")
+	assert.True(t, tv.State["power"].Bool)
+	require.Len(t, rules.executions, 1)
+	assert.Equal(t, models.RuleExecutionSucceeded, rules.executions[0].Status)
+	assert.Equal(t, "device lamp state", rules.executions[0].Trigger)
+}
+
+func TestRuleSkippedWhenConditionsUnmet(t *testing.T) {
+	rule := &models.Rule{
+		ID:      "only-with-tv",
+		Enabled: true,
+		Trigger: powerTrigger("lamp", true),
+		Conditions: []models.RuleCondition{
+			{DeviceID: "tv", Attribute: "power", Operator: models.OperatorEqual, Value: models.StateValue{Type: models.ValueTypeBool, Bool: true}},
+		},
+		Actions: []models.RuleAction{{DeviceID: "tv", State: power(false)}},
+	}
+	engine, bus, devices, rules := newTestEngine([]*models.Rule{rule})
+
+	updater := command.NewUpdateDeviceStateHandler(devices, fakeDeviceTypeRepository{}, bus)
+	_, err := updater.Handle(context.Background(), command.UpdateDeviceStateCommand{ID: "lamp", State: power(true)})
+	require.NoError(t, err)
+	engine.Wait()
+
+	require.Len(t, rules.executions, 1)
+	assert.Equal(t, models.RuleExecutionConditionsUnmet, rules.executions[0].Status)
+}
+
+func TestRulesTriggeringEachOtherAreCutOff(t *testing.T) {
+	// lamp on -> tv on -> lamp off -> lamp on -> ... forever without protection
+	rules := []*models.Rule{
+		{ID: "a", Enabled: true, Trigger: powerTrigger("lamp", true), Actions: []models.RuleAction{{DeviceID: "tv", State: power(true)}}},
+		{ID: "b", Enabled: true, Trigger: powerTrigger("tv", true), Actions: []models.RuleAction{{DeviceID: "lamp", State: power(false)}}},
+		{ID: "c", Enabled: true, Trigger: powerTrigger("lamp", false), Actions: []models.RuleAction{{DeviceID: "lamp", State: power(true)}}},
+	}
+	engine, bus, devices, ruleRepo := newTestEngine(rules)
+
+	updater := command.NewUpdateDeviceStateHandler(devices, fakeDeviceTypeRepository{}, bus)
+	_, err := updater.Handle(context.Background(), command.UpdateDeviceStateCommand{ID: "lamp", State: power(true)})
+	require.NoError(t, err)
+
+	// Each update in the chain evaluates rules in the background; Wait covers
+	// evaluations started from within other evaluations too
+	engine.Wait()
+
+	var statuses []models.RuleExecutionStatus
+	var depths []int
+	for _, execution := range ruleRepo.executions {
+		statuses = append(statuses, execution.Status)
+		depths = append(depths, execution.Depth)
+	}
+	assert.Equal(t, []models.RuleExecutionStatus{
+		models.RuleExecutionSucceeded,
+		models.RuleExecutionSucceeded,
+		models.RuleExecutionSucceeded,
+		models.RuleExecutionLoopPrevented,
+	}, statuses)
+	assert.Equal(t, []int{0, 1, 2, 3}, depths)
+	assert.Equal(t, "rule chain a -> b -> c -> a", ruleRepo.executions[3].Error)
+}
+
+// fakeMemberships gives every user a fixed role in each of their households
+type fakeMemberships map[string]map[string]models.HouseholdRole
+
+func (f fakeMemberships) Households(ctx context.Context, userID string) (map[string]models.HouseholdRole, error) {
+	return f[userID], nil
+}
+
+func TestRuleFailsOnceTheOwnerLeftTheHousehold(t *testing.T) {
+	devices := &fakeDeviceRepository{devices: map[string]*models.Device{
+		"lamp": {ID: "lamp", Type: "switch", UserID: "bob", HouseholdID: "home", State: power(false)},
+		"tv":   {ID: "tv", Type: "switch", UserID: "bob", HouseholdID: "home", State: power(false)},
+	}}
+	rule := &models.Rule{ID: "tv-follows-lamp", UserID: "alice", Enabled: true, Trigger: powerTrigger("lamp", true), Actions: []models.RuleAction{{DeviceID: "tv", State: power(true)}}}
+	rules := &fakeRuleRepository{rules: []*models.Rule{rule}}
+	memberships := fakeMemberships{"alice": {"home": models.HouseholdRoleGuest}}
+	bus := events.NewBus()
+	engine := NewEngine(rules, devices, fakeDeviceTypeRepository{}, bus, memberships, time.Minute)
+	bus.Subscribe("automation", engine, events.TypeDeviceStateChanged)
+	updater := command.NewUpdateDeviceStateHandler(devices, fakeDeviceTypeRepository{}, bus)
+
+	// Members control the devices of their household through their rules
+	_, err := updater.Handle(context.Background(), command.UpdateDeviceStateCommand{ID: "lamp", State: power(true)})
+	require.NoError(t, err)
+	engine.Wait()
+	require.Len(t, rules.executions, 1)
+	assert.Equal(t, models.RuleExecutionSucceeded, rules.executions[0].Status)
+
+	// Once alice left, her rule no longer touches the tv
+	delete(memberships, "alice")
+	for _, id := range []string{"lamp", "tv"} {
+		_, err = devices.UpdateDeviceState(context.Background(), id, power(false), 0)
+		require.NoError(t, err)
+	}
+	_, err = updater.Handle(context.Background(), command.UpdateDeviceStateCommand{ID: "lamp", State: power(true)})
+	require.NoError(t, err)
+	engine.Wait()
+
+	tv, _ := devices.LoadDevice(context.Background(), "tv")
+	assert.False(t, tv.State["power"].Bool)
+	require.Len(t, rules.executions, 2)
+	assert.Equal(t, models.RuleExecutionFailed, rules.executions[1].Status)
+	assert.Equal(t, "trigger device lamp: not a member of household home", rules.executions[1].Error)
+}
+
+func TestConditionHolds(t *testing.T) {
+	state := map[string]models.StateValue{
+		"temperature": {Type: models.ValueTypeFloat, Float: 21.5},
+		"mode":        {Type: models.ValueTypeEnum, Enum: "heat"},
+	}
+	tests := map[string]struct {
+		condition models.RuleCondition
+		want      bool
+	}{
+		"greater":           {models.RuleCondition{Attribute: "temperature", Operator: models.OperatorGreater, Value: models.StateValue{Type: models.ValueTypeFloat, Float: 20}}, true},
+		"less or equal":     {models.RuleCondition{Attribute: "temperature", Operator: models.OperatorLessOrEqual, Value: models.StateValue{Type: models.ValueTypeFloat, Float: 21}}, false},
+		"enum equal":        {models.RuleCondition{Attribute: "mode", Operator: models.OperatorEqual, Value: models.StateValue{Type: models.ValueTypeEnum, Enum: "heat"}}, true},
+		"enum not equal":    {models.RuleCondition{Attribute: "mode", Operator: models.OperatorNotEqual, Value: models.StateValue{Type: models.ValueTypeEnum, Enum: "heat"}}, false},
+		"missing attribute": {models.RuleCondition{Attribute: "power", Operator: models.OperatorNotEqual, Value: models.StateValue{Type: models.ValueTypeBool}}, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, conditionHolds(tt.condition, state))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type ActivateSceneHandler struct {
+	repo         repository.SceneRepository
+	stateHandler *UpdateDeviceStateHandler
+}
+
+func NewActivateSceneHandler(repo repository.SceneRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, publisher events.Publisher) *ActivateSceneHandler {
+	return &ActivateSceneHandler{
+		repo:         repo,
+		stateHandler: NewUpdateDeviceStateHandler(deviceRepo, typeRepo, publisher),
+	}
+}
+
+// ActivateSceneCommand applies a scene. The targets on the devices in Denied
+// are not applied, and fail with the error given there.
+type ActivateSceneCommand struct {
+	ID     string
+	Denied map[string]error
+}
+
+// SceneTargetResult is the outcome of applying a scene to one of its devices.
+// Device holds the updated device on success; Err is set on failure.
+type SceneTargetResult struct {
+	DeviceID string
+	Device   *models.Device
+	Err      error
+}
+
+// Handle applies every target of the scene through the regular device state
+// command, so each update is validated against the device's current type. A
+// failing target does not stop the others; the outcome of each is reported
+// in the order the scene defines them.
+func (h *ActivateSceneHandler) Handle(ctx context.Context, cmd ActivateSceneCommand) ([]SceneTargetResult, error) {
+	scene, err := h.repo.GetScene(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SceneTargetResult, len(scene.Targets))
+	for i, target := range scene.Targets {
+		if err, ok := cmd.Denied[target.DeviceID]; ok {
+			results[i] = SceneTargetResult{DeviceID: target.DeviceID, Err: err}
+			continue
+		}
+		device, err := h.stateHandler.Handle(ctx, UpdateDeviceStateCommand{ID: target.DeviceID, State: target.State})
+		results[i] = SceneTargetResult{DeviceID: target.DeviceID, Device: device, Err: err}
+	}
+	return results, nil
+}
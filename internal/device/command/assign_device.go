@@ -0,0 +1,74 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type AssignDeviceHandler struct {
+	repo       repository.RoomRepository
+	deviceRepo repository.CommandDeviceRepository
+}
+
+func NewAssignDeviceHandler(repo repository.RoomRepository, deviceRepo repository.CommandDeviceRepository) *AssignDeviceHandler {
+	return &AssignDeviceHandler{repo: repo, deviceRepo: deviceRepo}
+}
+
+// AssignDeviceCommand puts a device into a room of its household. A device
+// that is already in another room is moved, so it never belongs to more than
+// one.
+type AssignDeviceCommand struct {
+	RoomID   string
+	DeviceID string
+}
+
+func (h *AssignDeviceHandler) Handle(ctx context.Context, cmd AssignDeviceCommand) (*models.Room, error) {
+	room, err := h.repo.GetRoom(ctx, cmd.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	device, err := h.deviceRepo.LoadDevice(ctx, cmd.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.HouseholdID != room.HouseholdID {
+		return nil, ErrRoomHouseholdMismatch
+	}
+	if device.RoomID != room.ID {
+		if _, err := h.deviceRepo.SetDeviceRoom(ctx, device.ID, room.ID); err != nil {
+			return nil, err
+		}
+	}
+	return h.repo.GetRoom(ctx, room.ID)
+}
+
+type UnassignDeviceHandler struct {
+	repo       repository.RoomRepository
+	deviceRepo repository.CommandDeviceRepository
+}
+
+func NewUnassignDeviceHandler(repo repository.RoomRepository, deviceRepo repository.CommandDeviceRepository) *UnassignDeviceHandler {
+	return &UnassignDeviceHandler{repo: repo, deviceRepo: deviceRepo}
+}
+
+type UnassignDeviceCommand struct {
+	RoomID   string
+	DeviceID string
+}
+
+func (h *UnassignDeviceHandler) Handle(ctx context.Context, cmd UnassignDeviceCommand) (*models.Room, error) {
+	device, err := h.deviceRepo.LoadDevice(ctx, cmd.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.RoomID != cmd.RoomID {
+		return nil, ErrDeviceNotInRoom
+	}
+
+	if _, err := h.deviceRepo.SetDeviceRoom(ctx, device.ID, ""); err != nil {
+		return nil, err
+	}
+	return h.repo.GetRoom(ctx, cmd.RoomID)
+}
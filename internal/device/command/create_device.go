@@ -3,35 +3,88 @@ package command
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 )
 
 type CreateDeviceHandler struct {
-	repo repository.CommandDeviceRepository
+	repo     repository.CommandDeviceRepository
+	typeRepo repository.DeviceTypeRepository
+	events   events.Publisher
 }
 
-func NewCreateDeviceHandler(repo repository.CommandDeviceRepository) *CreateDeviceHandler {
-	return &CreateDeviceHandler{repo: repo}
+// NewCreateDeviceHandler creates the handler. It publishes a DeviceCreated
+// event for every device to publisher, which may be nil.
+func NewCreateDeviceHandler(repo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, publisher events.Publisher) *CreateDeviceHandler {
+	return &CreateDeviceHandler{repo: repo, typeRepo: typeRepo, events: publisher}
 }
 
+// CreateDeviceCommand creates a device for UserID or, when HouseholdID is
+// set, for that household with UserID as its creator
 type CreateDeviceCommand struct {
-	Name   string
-	Type   string
-	UserID string
+	Name        string
+	Type        string
+	UserID      string
+	HouseholdID string
+	Labels      map[string]string
 }
 
 func (h *CreateDeviceHandler) Handle(ctx context.Context, cmd CreateDeviceCommand) (*models.Device, error) {
+	if err := labels.Validate(cmd.Labels); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDevice, err)
+	}
+	deviceType, err := loadDeviceType(ctx, h.typeRepo, cmd.Type)
+	if err != nil {
+		return nil, err
+	}
+
 	device := &models.Device{
-		Name:      cmd.Name,
-		Type:      cmd.Type,
-		UserID:    cmd.UserID,
-		State:     "off", // Default state
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Name:        cmd.Name,
+		Type:        cmd.Type,
+		UserID:      cmd.UserID,
+		HouseholdID: cmd.HouseholdID,
+		Labels:      cmd.Labels,
+		State:       defaultState(deviceType),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
-	return h.repo.CreateDevice(ctx, device)
+	created, err := h.repo.CreateDevice(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	if h.events != nil {
+		h.events.Publish(ctx, events.DeviceCreated{Device: created})
+	}
+	return created, nil
+}
+
+// defaultState is the state a freshly created device starts in, built from
+// the defaults declared by its type's capabilities
+func defaultState(deviceType *models.DeviceType) map[string]models.StateValue {
+	state := make(map[string]models.StateValue)
+	for _, capability := range deviceType.Capabilities {
+		if capability.Default != nil {
+			state[capability.Name] = *capability.Default
+		}
+	}
+	return state
+}
+
+// loadDeviceType looks a type up in the registry, reporting a missing type as ErrUnknownDeviceType
+func loadDeviceType(ctx context.Context, typeRepo repository.DeviceTypeRepository, id string) (*models.DeviceType, error) {
+	deviceType, err := typeRepo.GetDeviceType(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrDeviceTypeNotFound) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownDeviceType, id)
+		}
+		return nil, err
+	}
+	return deviceType, nil
 }
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// ErrInvalidDeviceType is returned when a device type declares a malformed capability schema
+var ErrInvalidDeviceType = errors.New("invalid device type")
+
+var deviceTypeIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type CreateDeviceTypeHandler struct {
+	repo repository.DeviceTypeRepository
+}
+
+func NewCreateDeviceTypeHandler(repo repository.DeviceTypeRepository) *CreateDeviceTypeHandler {
+	return &CreateDeviceTypeHandler{repo: repo}
+}
+
+type CreateDeviceTypeCommand struct {
+	ID               string
+	Name             string
+	Description      string
+	Capabilities     []models.Capability
+	HeartbeatTimeout time.Duration
+}
+
+func (h *CreateDeviceTypeHandler) Handle(ctx context.Context, cmd CreateDeviceTypeCommand) (*models.DeviceType, error) {
+	deviceType := &models.DeviceType{
+		ID:               cmd.ID,
+		Name:             cmd.Name,
+		Description:      cmd.Description,
+		Capabilities:     cmd.Capabilities,
+		HeartbeatTimeout: cmd.HeartbeatTimeout,
+	}
+	if err := validateDeviceType(deviceType); err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.CreateDeviceType(ctx, deviceType); err != nil {
+		return nil, err
+	}
+	return deviceType, nil
+}
+
+func validateDeviceType(deviceType *models.DeviceType) error {
+	if !deviceTypeIDPattern.MatchString(deviceType.ID) {
+		return fmt.Errorf("%w: invalid id %q", ErrInvalidDeviceType, deviceType.ID)
+	}
+	if deviceType.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidDeviceType)
+	}
+	if deviceType.HeartbeatTimeout < 0 {
+		return fmt.Errorf("%w: heartbeat timeout must not be negative", ErrInvalidDeviceType)
+	}
+
+	seen := make(map[string]bool, len(deviceType.Capabilities))
+	for _, capability := range deviceType.Capabilities {
+		if !models.AttributeNamePattern.MatchString(capability.Name) {
+			return fmt.Errorf("%w: invalid capability name %q", ErrInvalidDeviceType, capability.Name)
+		}
+		if seen[capability.Name] {
+			return fmt.Errorf("%w: capability %q declared twice", ErrInvalidDeviceType, capability.Name)
+		}
+		seen[capability.Name] = true
+
+		if err := validateCapability(capability); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCapability(capability models.Capability) error {
+	switch capability.Type {
+	case models.ValueTypeBool, models.ValueTypeColor:
+	case models.ValueTypeInt, models.ValueTypeFloat:
+		if capability.Min != nil && capability.Max != nil && *capability.Min > *capability.Max {
+			return fmt.Errorf("%w: capability %q has min above max", ErrInvalidDeviceType, capability.Name)
+		}
+	case models.ValueTypeEnum:
+		if len(capability.Values) == 0 {
+			return fmt.Errorf("%w: enum capability %q declares no values", ErrInvalidDeviceType, capability.Name)
+		}
+	default:
+		return fmt.Errorf("%w: capability %q has unknown type %q", ErrInvalidDeviceType, capability.Name, capability.Type)
+	}
+
+	if capability.Default != nil {
+		if err := validateValue(capability.Name, *capability.Default); err != nil {
+			return fmt.Errorf("%w: default of capability %q: %v", ErrInvalidDeviceType, capability.Name, err)
+		}
+		if err := checkCapability(&capability, *capability.Default); err != nil {
+			return fmt.Errorf("%w: default of capability %q: %v", ErrInvalidDeviceType, capability.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+var (
+	// ErrInvalidRoom is returned when a room is created or renamed with invalid fields
+	ErrInvalidRoom = errors.New("invalid room")
+	// ErrDeviceNotInRoom is returned when unassigning a device from a room it is not in
+	ErrDeviceNotInRoom = errors.New("device is not in this room")
+	// ErrRoomHouseholdMismatch is returned when a device is put in a room of
+	// another household, or a personal device in a household room
+	ErrRoomHouseholdMismatch = errors.New("device and room belong to different households")
+)
+
+type CreateRoomHandler struct {
+	repo repository.RoomRepository
+}
+
+func NewCreateRoomHandler(repo repository.RoomRepository) *CreateRoomHandler {
+	return &CreateRoomHandler{repo: repo}
+}
+
+// CreateRoomCommand creates a room of UserID, or of HouseholdID when set, in
+// which case UserID is the member who created it
+type CreateRoomCommand struct {
+	Name        string
+	UserID      string
+	HouseholdID string
+}
+
+func (h *CreateRoomHandler) Handle(ctx context.Context, cmd CreateRoomCommand) (*models.Room, error) {
+	if strings.TrimSpace(cmd.Name) == "" || cmd.UserID == "" {
+		return nil, ErrInvalidRoom
+	}
+
+	room := &models.Room{
+		Name:        cmd.Name,
+		UserID:      cmd.UserID,
+		HouseholdID: cmd.HouseholdID,
+		DeviceIDs:   []string{},
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := h.repo.CreateRoom(ctx, room); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
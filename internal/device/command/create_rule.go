@@ -0,0 +1,54 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type CreateRuleHandler struct {
+	repo       repository.RuleRepository
+	deviceRepo repository.CommandDeviceRepository
+	typeRepo   repository.DeviceTypeRepository
+}
+
+func NewCreateRuleHandler(repo repository.RuleRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository) *CreateRuleHandler {
+	return &CreateRuleHandler{repo: repo, deviceRepo: deviceRepo, typeRepo: typeRepo}
+}
+
+// CreateRuleCommand creates an enabled rule
+type CreateRuleCommand struct {
+	Name       string
+	UserID     string
+	Trigger    models.RuleTrigger
+	Conditions []models.RuleCondition
+	Actions    []models.RuleAction
+}
+
+func (h *CreateRuleHandler) Handle(ctx context.Context, cmd CreateRuleCommand) (*models.Rule, error) {
+	now := time.Now()
+	rule := &models.Rule{
+		Name:       cmd.Name,
+		UserID:     cmd.UserID,
+		Enabled:    true,
+		Trigger:    cmd.Trigger,
+		Conditions: cmd.Conditions,
+		Actions:    cmd.Actions,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := newRuleValidator(h.deviceRepo, h.typeRepo, h.repo).validate(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if rule.NextRunAt, err = NextRuleRun(rule, now); err != nil {
+		return nil, err
+	}
+	if err := h.repo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
@@ -0,0 +1,87 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+var (
+	// ErrInvalidScene is returned when a scene is created or updated with invalid fields
+	ErrInvalidScene = errors.New("invalid scene")
+)
+
+type CreateSceneHandler struct {
+	repo       repository.SceneRepository
+	deviceRepo repository.CommandDeviceRepository
+	typeRepo   repository.DeviceTypeRepository
+}
+
+func NewCreateSceneHandler(repo repository.SceneRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository) *CreateSceneHandler {
+	return &CreateSceneHandler{repo: repo, deviceRepo: deviceRepo, typeRepo: typeRepo}
+}
+
+type CreateSceneCommand struct {
+	Name    string
+	UserID  string
+	Targets []models.SceneTarget
+}
+
+func (h *CreateSceneHandler) Handle(ctx context.Context, cmd CreateSceneCommand) (*models.Scene, error) {
+	if strings.TrimSpace(cmd.Name) == "" || cmd.UserID == "" {
+		return nil, ErrInvalidScene
+	}
+	if err := validateSceneTargets(ctx, h.deviceRepo, h.typeRepo, cmd.Targets); err != nil {
+		return nil, err
+	}
+
+	scene := &models.Scene{
+		Name:      cmd.Name,
+		UserID:    cmd.UserID,
+		Targets:   cmd.Targets,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := h.repo.CreateScene(ctx, scene); err != nil {
+		return nil, err
+	}
+	return scene, nil
+}
+
+// validateSceneTargets checks that a scene has at least one target, that every
+// target is a distinct device, and that each state patch is valid for the
+// device's type. Whether the owner may control the devices is up to the caller.
+func validateSceneTargets(ctx context.Context, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, targets []models.SceneTarget) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: a scene needs at least one target", ErrInvalidScene)
+	}
+
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if seen[target.DeviceID] {
+			return fmt.Errorf("%w: device %s is targeted more than once", ErrInvalidScene, target.DeviceID)
+		}
+		seen[target.DeviceID] = true
+
+		if err := validateState(target.State); err != nil {
+			return fmt.Errorf("device %s: %w", target.DeviceID, err)
+		}
+		device, err := deviceRepo.LoadDevice(ctx, target.DeviceID)
+		if err != nil {
+			return fmt.Errorf("device %s: %w", target.DeviceID, err)
+		}
+		deviceType, err := loadDeviceType(ctx, typeRepo, device.Type)
+		if err != nil {
+			return fmt.Errorf("device %s: %w", target.DeviceID, err)
+		}
+		if err := validateStateForType(deviceType, target.State); err != nil {
+			return fmt.Errorf("device %s: %w", target.DeviceID, err)
+		}
+	}
+	return nil
+}
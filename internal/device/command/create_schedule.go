@@ -0,0 +1,87 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type CreateScheduleHandler struct {
+	repo       repository.ScheduleRepository
+	deviceRepo repository.CommandDeviceRepository
+	typeRepo   repository.DeviceTypeRepository
+}
+
+func NewCreateScheduleHandler(repo repository.ScheduleRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository) *CreateScheduleHandler {
+	return &CreateScheduleHandler{repo: repo, deviceRepo: deviceRepo, typeRepo: typeRepo}
+}
+
+// CreateScheduleCommand creates a schedule of UserID that applies Action to a
+// device. Exactly one of Cron and RunAt must be set; RunAt is a wall-clock time in
+// RunAtLayout, interpreted in Timezone (UTC when empty).
+type CreateScheduleCommand struct {
+	UserID   string
+	DeviceID string
+	Action   map[string]models.StateValue
+	Cron     string
+	RunAt    string
+	Timezone string
+}
+
+func (h *CreateScheduleHandler) Handle(ctx context.Context, cmd CreateScheduleCommand) (*models.Schedule, error) {
+	if cmd.UserID == "" {
+		return nil, ErrInvalidSchedule
+	}
+	device, err := loadScheduleDevice(ctx, h.deviceRepo, h.typeRepo, cmd.DeviceID, cmd.Action)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule := &models.Schedule{
+		DeviceID:  device.ID,
+		UserID:    cmd.UserID,
+		Action:    cmd.Action,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	spec := scheduleSpec{Cron: cmd.Cron, RunAt: cmd.RunAt, Timezone: cmd.Timezone}
+	if err := spec.apply(schedule, now); err != nil {
+		return nil, err
+	}
+	if schedule.NextRunAt, err = NextRun(schedule, now); err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.CreateSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// loadScheduleDevice loads the device a schedule targets and checks that the
+// action is a valid state patch for its type
+func loadScheduleDevice(ctx context.Context, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, deviceID string, action map[string]models.StateValue) (*models.Device, error) {
+	if len(action) == 0 {
+		return nil, ErrInvalidSchedule
+	}
+	if err := validateState(action); err != nil {
+		return nil, err
+	}
+
+	device, err := deviceRepo.LoadDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	deviceType, err := loadDeviceType(ctx, typeRepo, device.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateStateForType(deviceType, action); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
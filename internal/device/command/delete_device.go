@@ -0,0 +1,36 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type DeleteDeviceHandler struct {
+	repo   repository.CommandDeviceRepository
+	events events.Publisher
+}
+
+// NewDeleteDeviceHandler creates the handler. It publishes a DeviceDeleted
+// event for every device to publisher, which may be nil.
+func NewDeleteDeviceHandler(repo repository.CommandDeviceRepository, publisher events.Publisher) *DeleteDeviceHandler {
+	return &DeleteDeviceHandler{repo: repo, events: publisher}
+}
+
+type DeleteDeviceCommand struct {
+	ID string
+}
+
+// Handle deletes a device and returns it as it was
+func (h *DeleteDeviceHandler) Handle(ctx context.Context, cmd DeleteDeviceCommand) (*models.Device, error) {
+	device, err := h.repo.DeleteDevice(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if h.events != nil {
+		h.events.Publish(ctx, events.DeviceDeleted{Device: device})
+	}
+	return device, nil
+}
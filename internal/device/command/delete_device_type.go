@@ -0,0 +1,23 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+)
+
+type DeleteDeviceTypeHandler struct {
+	repo repository.DeviceTypeRepository
+}
+
+func NewDeleteDeviceTypeHandler(repo repository.DeviceTypeRepository) *DeleteDeviceTypeHandler {
+	return &DeleteDeviceTypeHandler{repo: repo}
+}
+
+type DeleteDeviceTypeCommand struct {
+	ID string
+}
+
+func (h *DeleteDeviceTypeHandler) Handle(ctx context.Context, cmd DeleteDeviceTypeCommand) error {
+	return h.repo.DeleteDeviceType(ctx, cmd.ID)
+}
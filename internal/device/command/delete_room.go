@@ -0,0 +1,35 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+)
+
+type DeleteRoomHandler struct {
+	repo       repository.RoomRepository
+	deviceRepo repository.CommandDeviceRepository
+}
+
+func NewDeleteRoomHandler(repo repository.RoomRepository, deviceRepo repository.CommandDeviceRepository) *DeleteRoomHandler {
+	return &DeleteRoomHandler{repo: repo, deviceRepo: deviceRepo}
+}
+
+type DeleteRoomCommand struct {
+	ID string
+}
+
+// Handle releases the room's devices through the device repository, so the
+// read model forgets the room as well, and then deletes the room.
+func (h *DeleteRoomHandler) Handle(ctx context.Context, cmd DeleteRoomCommand) error {
+	room, err := h.repo.GetRoom(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+	for _, deviceID := range room.DeviceIDs {
+		if _, err := h.deviceRepo.SetDeviceRoom(ctx, deviceID, ""); err != nil {
+			return err
+		}
+	}
+	return h.repo.DeleteRoom(ctx, cmd.ID)
+}
@@ -0,0 +1,23 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+)
+
+type DeleteRuleHandler struct {
+	repo repository.RuleRepository
+}
+
+func NewDeleteRuleHandler(repo repository.RuleRepository) *DeleteRuleHandler {
+	return &DeleteRuleHandler{repo: repo}
+}
+
+type DeleteRuleCommand struct {
+	ID string
+}
+
+func (h *DeleteRuleHandler) Handle(ctx context.Context, cmd DeleteRuleCommand) error {
+	return h.repo.DeleteRule(ctx, cmd.ID)
+}
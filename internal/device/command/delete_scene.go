@@ -0,0 +1,23 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+)
+
+type DeleteSceneHandler struct {
+	repo repository.SceneRepository
+}
+
+func NewDeleteSceneHandler(repo repository.SceneRepository) *DeleteSceneHandler {
+	return &DeleteSceneHandler{repo: repo}
+}
+
+type DeleteSceneCommand struct {
+	ID string
+}
+
+func (h *DeleteSceneHandler) Handle(ctx context.Context, cmd DeleteSceneCommand) error {
+	return h.repo.DeleteScene(ctx, cmd.ID)
+}
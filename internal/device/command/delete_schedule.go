@@ -0,0 +1,23 @@
+package command
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+)
+
+type DeleteScheduleHandler struct {
+	repo repository.ScheduleRepository
+}
+
+func NewDeleteScheduleHandler(repo repository.ScheduleRepository) *DeleteScheduleHandler {
+	return &DeleteScheduleHandler{repo: repo}
+}
+
+type DeleteScheduleCommand struct {
+	ID string
+}
+
+func (h *DeleteScheduleHandler) Handle(ctx context.Context, cmd DeleteScheduleCommand) error {
+	return h.repo.DeleteSchedule(ctx, cmd.ID)
+}
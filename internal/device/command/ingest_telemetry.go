@@ -0,0 +1,85 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const (
+	// MaxTelemetryPoints is the largest number of readings ingested at once
+	MaxTelemetryPoints = 1000
+	// maxTelemetryClockSkew is how far ahead of the server clock a reading
+	// may be timestamped
+	maxTelemetryClockSkew = 5 * time.Minute
+)
+
+// ErrInvalidTelemetry is returned for readings that cannot be stored
+var ErrInvalidTelemetry = errors.New("invalid telemetry")
+
+type IngestTelemetryHandler struct {
+	repo repository.TelemetryRepository
+	now  func() time.Time
+}
+
+func NewIngestTelemetryHandler(repo repository.TelemetryRepository) *IngestTelemetryHandler {
+	return &IngestTelemetryHandler{repo: repo, now: time.Now}
+}
+
+// IngestTelemetryCommand stores the readings of sensors. Readings without a
+// timestamp were taken now.
+type IngestTelemetryCommand struct {
+	Points []*models.TelemetryPoint
+}
+
+// Handle validates and stores readings. Nothing is stored if any reading is
+// invalid. It returns the readings as stored.
+func (h *IngestTelemetryHandler) Handle(ctx context.Context, cmd IngestTelemetryCommand) ([]*models.TelemetryPoint, error) {
+	if len(cmd.Points) == 0 {
+		return nil, fmt.Errorf("%w: no readings given", ErrInvalidTelemetry)
+	}
+	if len(cmd.Points) > MaxTelemetryPoints {
+		return nil, fmt.Errorf("%w: at most %d readings are ingested at once, got %d", ErrInvalidTelemetry, MaxTelemetryPoints, len(cmd.Points))
+	}
+
+	now := h.now().UTC()
+	points := make([]*models.TelemetryPoint, len(cmd.Points))
+	for i, point := range cmd.Points {
+		stored := *point
+		if stored.Timestamp.IsZero() {
+			stored.Timestamp = now
+		}
+		if err := validateTelemetryPoint(&stored, now); err != nil {
+			return nil, err
+		}
+		points[i] = &stored
+	}
+
+	if err := h.repo.InsertTelemetry(ctx, points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// validateTelemetryPoint checks a reading. Readings may be backfilled, but
+// not timestamped in the future beyond the clock skew of a device.
+func validateTelemetryPoint(point *models.TelemetryPoint, now time.Time) error {
+	if point.DeviceID == "" {
+		return fmt.Errorf("%w: a reading needs a device", ErrInvalidTelemetry)
+	}
+	if !models.AttributeNamePattern.MatchString(point.Metric) {
+		return fmt.Errorf("%w: invalid metric name %q", ErrInvalidTelemetry, point.Metric)
+	}
+	if math.IsNaN(point.Value) || math.IsInf(point.Value, 0) {
+		return fmt.Errorf("%w: metric %q of device %s is not a finite number", ErrInvalidTelemetry, point.Metric, point.DeviceID)
+	}
+	if point.Timestamp.After(now.Add(maxTelemetryClockSkew)) {
+		return fmt.Errorf("%w: reading of metric %q of device %s is timestamped in the future", ErrInvalidTelemetry, point.Metric, point.DeviceID)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type RecordHeartbeatHandler struct {
+	repo   repository.CommandDeviceRepository
+	events events.Publisher
+	now    func() time.Time
+}
+
+// NewRecordHeartbeatHandler creates the handler. It publishes a
+// DeviceConnectivityChanged event to publisher, which may be nil, when a
+// heartbeat brings a device online.
+func NewRecordHeartbeatHandler(repo repository.CommandDeviceRepository, publisher events.Publisher) *RecordHeartbeatHandler {
+	return &RecordHeartbeatHandler{repo: repo, events: publisher, now: time.Now}
+}
+
+type RecordHeartbeatCommand struct {
+	ID string
+}
+
+// Handle records that a device was seen now and marks it online. The time is
+// taken from the server clock, so that device clocks cannot keep a device
+// online.
+func (h *RecordHeartbeatHandler) Handle(ctx context.Context, cmd RecordHeartbeatCommand) (*models.Device, error) {
+	device, previous, err := h.repo.RecordHeartbeat(ctx, cmd.ID, h.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if h.events != nil && previous != device.Connectivity {
+		h.events.Publish(ctx, events.DeviceConnectivityChanged{Device: device, Previous: previous})
+	}
+	return device, nil
+}
@@ -0,0 +1,189 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+var (
+	// ErrInvalidRule is returned when a rule is created or updated with invalid fields
+	ErrInvalidRule = errors.New("invalid rule")
+	// ErrRuleOwnerMismatch is returned when a rule is triggered by a rule of another user
+	ErrRuleOwnerMismatch = errors.New("rule refers to a rule of another user")
+)
+
+// ruleValidator checks a rule against the devices and rules it refers to,
+// loading each device and its type once
+type ruleValidator struct {
+	deviceRepo repository.CommandDeviceRepository
+	typeRepo   repository.DeviceTypeRepository
+	ruleRepo   repository.RuleRepository
+	types      map[string]*models.DeviceType
+}
+
+func newRuleValidator(deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, ruleRepo repository.RuleRepository) *ruleValidator {
+	return &ruleValidator{
+		deviceRepo: deviceRepo,
+		typeRepo:   typeRepo,
+		ruleRepo:   ruleRepo,
+		types:      make(map[string]*models.DeviceType),
+	}
+}
+
+// validate checks the rule and normalizes its trigger, dropping fields that
+// do not apply to the trigger type
+func (v *ruleValidator) validate(ctx context.Context, rule *models.Rule) error {
+	if strings.TrimSpace(rule.Name) == "" || rule.UserID == "" {
+		return ErrInvalidRule
+	}
+	if err := v.validateTrigger(ctx, rule); err != nil {
+		return err
+	}
+	for _, condition := range rule.Conditions {
+		if err := v.validateCondition(ctx, condition); err != nil {
+			return err
+		}
+	}
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("%w: a rule needs at least one action", ErrInvalidRule)
+	}
+	for _, action := range rule.Actions {
+		if err := validateState(action.State); err != nil {
+			return fmt.Errorf("action on device %s: %w", action.DeviceID, err)
+		}
+		deviceType, err := v.deviceType(ctx, action.DeviceID)
+		if err != nil {
+			return err
+		}
+		if err := validateStateForType(deviceType, action.State); err != nil {
+			return fmt.Errorf("action on device %s: %w", action.DeviceID, err)
+		}
+	}
+	return nil
+}
+
+func (v *ruleValidator) validateTrigger(ctx context.Context, rule *models.Rule) error {
+	trigger := rule.Trigger
+	switch trigger.Type {
+	case models.TriggerDeviceState:
+		deviceType, err := v.deviceType(ctx, trigger.DeviceID)
+		if err != nil {
+			return err
+		}
+		if trigger.Attribute == "" {
+			if trigger.Value != nil {
+				return fmt.Errorf("%w: a trigger value needs an attribute", ErrInvalidRule)
+			}
+		} else {
+			capability := findCapability(deviceType, trigger.Attribute)
+			if capability == nil {
+				return fmt.Errorf("%w: device type %q has no attribute %q", ErrInvalidRule, deviceType.ID, trigger.Attribute)
+			}
+			if trigger.Value != nil {
+				if err := validateValue(trigger.Attribute, *trigger.Value); err != nil {
+					return err
+				}
+				if err := checkCapability(capability, *trigger.Value); err != nil {
+					return err
+				}
+			}
+		}
+		rule.Trigger = models.RuleTrigger{
+			Type:      trigger.Type,
+			DeviceID:  trigger.DeviceID,
+			Attribute: trigger.Attribute,
+			Value:     trigger.Value,
+		}
+	case models.TriggerTime:
+		if trigger.Timezone == "" {
+			trigger.Timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(trigger.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidRule, trigger.Timezone)
+		}
+		if _, err := parseCron(trigger.Cron); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		rule.Trigger = models.RuleTrigger{Type: trigger.Type, Cron: trigger.Cron, Timezone: trigger.Timezone}
+	case models.TriggerRule:
+		if trigger.RuleID == "" || trigger.RuleID == rule.ID {
+			return fmt.Errorf("%w: a rule trigger must name another rule", ErrInvalidRule)
+		}
+		source, err := v.ruleRepo.GetRule(ctx, trigger.RuleID)
+		if err != nil {
+			return err
+		}
+		if source.UserID != rule.UserID {
+			return ErrRuleOwnerMismatch
+		}
+		rule.Trigger = models.RuleTrigger{Type: trigger.Type, RuleID: trigger.RuleID}
+	default:
+		return fmt.Errorf("%w: unknown trigger type %q", ErrInvalidRule, trigger.Type)
+	}
+	return nil
+}
+
+func (v *ruleValidator) validateCondition(ctx context.Context, condition models.RuleCondition) error {
+	deviceType, err := v.deviceType(ctx, condition.DeviceID)
+	if err != nil {
+		return err
+	}
+	capability := findCapability(deviceType, condition.Attribute)
+	if capability == nil {
+		return fmt.Errorf("%w: device type %q has no attribute %q", ErrInvalidRule, deviceType.ID, condition.Attribute)
+	}
+	if err := validateValue(condition.Attribute, condition.Value); err != nil {
+		return err
+	}
+	if condition.Value.Type != capability.Type {
+		return fmt.Errorf("%w: attribute %q must be of type %q", ErrInvalidState, capability.Name, capability.Type)
+	}
+
+	switch condition.Operator {
+	case models.OperatorEqual, models.OperatorNotEqual:
+	case models.OperatorGreater, models.OperatorGreaterOrEqual, models.OperatorLess, models.OperatorLessOrEqual:
+		if capability.Type != models.ValueTypeInt && capability.Type != models.ValueTypeFloat {
+			return fmt.Errorf("%w: operator %q needs a numeric attribute", ErrInvalidRule, condition.Operator)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidRule, condition.Operator)
+	}
+	return nil
+}
+
+// deviceType loads a device a rule refers to and returns its type. Whether
+// the rule's owner may use the device is up to the caller.
+func (v *ruleValidator) deviceType(ctx context.Context, deviceID string) (*models.DeviceType, error) {
+	device, err := v.deviceRepo.LoadDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("device %s: %w", deviceID, err)
+	}
+	if deviceType, ok := v.types[device.Type]; ok {
+		return deviceType, nil
+	}
+	deviceType, err := loadDeviceType(ctx, v.typeRepo, device.Type)
+	if err != nil {
+		return nil, err
+	}
+	v.types[device.Type] = deviceType
+	return deviceType, nil
+}
+
+// NextRuleRun returns when a time-triggered rule fires next, or nil for other
+// triggers and disabled rules
+func NextRuleRun(rule *models.Rule, after time.Time) (*time.Time, error) {
+	if !rule.Enabled || rule.Trigger.Type != models.TriggerTime {
+		return nil, nil
+	}
+	next, err := NextCronRun(rule.Trigger.Cron, rule.Trigger.Timezone, after)
+	if err != nil || next.IsZero() {
+		return nil, err
+	}
+	return &next, nil
+}
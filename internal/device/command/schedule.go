@@ -0,0 +1,114 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrInvalidSchedule is returned when a schedule is created or updated with invalid fields
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// RunAtLayout is the wall-clock format of one-shot schedule times, which are
+// interpreted in the schedule's timezone
+const RunAtLayout = "2006-01-02T15:04:05"
+
+// scheduleSpec holds the user-supplied timing fields shared by create and update
+type scheduleSpec struct {
+	Cron     string
+	RunAt    string
+	Timezone string
+}
+
+// apply validates the timing fields and copies them onto the schedule
+func (spec scheduleSpec) apply(schedule *models.Schedule, now time.Time) error {
+	if spec.Timezone == "" {
+		spec.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(spec.Timezone)
+	if err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, spec.Timezone)
+	}
+
+	switch {
+	case spec.Cron != "" && spec.RunAt != "":
+		return fmt.Errorf("%w: cron and run_at are mutually exclusive", ErrInvalidSchedule)
+	case spec.Cron != "":
+		if _, err := parseCron(spec.Cron); err != nil {
+			return err
+		}
+		schedule.Time = time.Time{}
+	case spec.RunAt != "":
+		runAt, err := time.ParseInLocation(RunAtLayout, spec.RunAt, loc)
+		if err != nil {
+			return fmt.Errorf("%w: run_at must use the format %s", ErrInvalidSchedule, RunAtLayout)
+		}
+		if !runAt.After(now) {
+			return fmt.Errorf("%w: run_at is in the past", ErrInvalidSchedule)
+		}
+		schedule.Time = runAt.UTC()
+	default:
+		return fmt.Errorf("%w: either cron or run_at is required", ErrInvalidSchedule)
+	}
+
+	schedule.Cron = spec.Cron
+	schedule.Timezone = spec.Timezone
+	return nil
+}
+
+// parseCron parses a standard five-field cron expression or descriptor such
+// as @daily. The timezone comes from the schedule, so TZ prefixes are rejected.
+func parseCron(expr string) (cron.Schedule, error) {
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, fmt.Errorf("%w: use the timezone field instead of a TZ prefix", ErrInvalidSchedule)
+	}
+	parsed, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return parsed, nil
+}
+
+// NextRun returns the first time after the given instant at which the schedule
+// should fire, or nil when it never fires again. Runs that were missed before
+// after are skipped rather than caught up on.
+func NextRun(schedule *models.Schedule, after time.Time) (*time.Time, error) {
+	if !schedule.Enabled {
+		return nil, nil
+	}
+	if !schedule.Repeats() {
+		if !schedule.Time.After(after) {
+			return nil, nil
+		}
+		next := schedule.Time.UTC()
+		return &next, nil
+	}
+
+	next, err := NextCronRun(schedule.Cron, schedule.Timezone, after)
+	if err != nil || next.IsZero() {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// NextCronRun returns the first occurrence of a cron expression after the
+// given instant, evaluated in timezone, or the zero time if there is none
+func NextCronRun(expr, timezone string, after time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	parsed, err := parseCron(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := parsed.Next(after.In(loc))
+	if next.IsZero() {
+		return next, nil
+	}
+	return next.UTC(), nil
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+var (
+	// ErrInvalidState is returned when a state patch contains a malformed attribute
+	ErrInvalidState = errors.New("invalid device state")
+	// ErrUnknownDeviceType is returned when a device refers to a type missing from the registry
+	ErrUnknownDeviceType = errors.New("unknown device type")
+)
+
+func validateState(state map[string]models.StateValue) error {
+	if len(state) == 0 {
+		return fmt.Errorf("%w: no attributes given", ErrInvalidState)
+	}
+	for name, value := range state {
+		if !models.AttributeNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: invalid attribute name %q", ErrInvalidState, name)
+		}
+		if err := validateValue(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(name string, value models.StateValue) error {
+	switch value.Type {
+	case models.ValueTypeBool, models.ValueTypeInt, models.ValueTypeFloat:
+	case models.ValueTypeEnum:
+		if value.Enum == "" {
+			return fmt.Errorf("%w: attribute %q has an empty enum value", ErrInvalidState, name)
+		}
+	case models.ValueTypeColor:
+		if value.Color == nil {
+			return fmt.Errorf("%w: attribute %q is missing a color value", ErrInvalidState, name)
+		}
+	default:
+		return fmt.Errorf("%w: attribute %q has unknown type %q", ErrInvalidState, name, value.Type)
+	}
+	return nil
+}
+
+// validateStateForType checks every attribute against the capabilities declared by the device type
+func validateStateForType(deviceType *models.DeviceType, state map[string]models.StateValue) error {
+	for name, value := range state {
+		capability := findCapability(deviceType, name)
+		if capability == nil {
+			return fmt.Errorf("%w: device type %q has no attribute %q", ErrInvalidState, deviceType.ID, name)
+		}
+		if err := checkCapability(capability, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkCapability(capability *models.Capability, value models.StateValue) error {
+	if value.Type != capability.Type {
+		return fmt.Errorf("%w: attribute %q must be of type %q", ErrInvalidState, capability.Name, capability.Type)
+	}
+
+	var number float64
+	switch value.Type {
+	case models.ValueTypeInt:
+		number = float64(value.Int)
+	case models.ValueTypeFloat:
+		number = value.Float
+	case models.ValueTypeEnum:
+		if !slices.Contains(capability.Values, value.Enum) {
+			return fmt.Errorf("%w: attribute %q must be one of %v", ErrInvalidState, capability.Name, capability.Values)
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	if capability.Min != nil && number < *capability.Min {
+		return fmt.Errorf("%w: attribute %q must be at least %v", ErrInvalidState, capability.Name, *capability.Min)
+	}
+	if capability.Max != nil && number > *capability.Max {
+		return fmt.Errorf("%w: attribute %q must be at most %v", ErrInvalidState, capability.Name, *capability.Max)
+	}
+	return nil
+}
+
+func findCapability(deviceType *models.DeviceType, name string) *models.Capability {
+	for i := range deviceType.Capabilities {
+		if deviceType.Capabilities[i].Name == name {
+			return &deviceType.Capabilities[i]
+		}
+	}
+	return nil
+}
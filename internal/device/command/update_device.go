@@ -2,24 +2,69 @@ package command
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 )
 
 type UpdateDeviceStateHandler struct {
-	repo repository.CommandDeviceRepository
+	repo     repository.CommandDeviceRepository
+	typeRepo repository.DeviceTypeRepository
+	events   events.Publisher
 }
 
-func NewUpdateDeviceStateHandler(repo repository.CommandDeviceRepository) *UpdateDeviceStateHandler {
-	return &UpdateDeviceStateHandler{repo: repo}
+// NewUpdateDeviceStateHandler creates the handler. It publishes a
+// DeviceStateChanged event after every update to publisher, which may be nil.
+func NewUpdateDeviceStateHandler(repo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, publisher events.Publisher) *UpdateDeviceStateHandler {
+	return &UpdateDeviceStateHandler{repo: repo, typeRepo: typeRepo, events: publisher}
 }
 
+// UpdateDeviceStateCommand patches a device's state: only the attributes in
+// State are changed. A non-zero ExpectedVersion makes the update fail with
+// repository.ErrVersionMismatch unless the device is at that version.
 type UpdateDeviceStateCommand struct {
-	ID    string
-	State string
+	ID              string
+	State           map[string]models.StateValue
+	ExpectedVersion int64
 }
 
 func (h *UpdateDeviceStateHandler) Handle(ctx context.Context, cmd UpdateDeviceStateCommand) (*models.Device, error) {
-	return h.repo.UpdateDeviceState(ctx, cmd.ID, cmd.State)
+	if err := validateState(cmd.State); err != nil {
+		return nil, err
+	}
+
+	device, err := h.repo.LoadDevice(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(device, cmd.ExpectedVersion); err != nil {
+		return nil, err
+	}
+	deviceType, err := loadDeviceType(ctx, h.typeRepo, device.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateStateForType(deviceType, cmd.State); err != nil {
+		return nil, err
+	}
+
+	updated, err := h.repo.UpdateDeviceState(ctx, cmd.ID, cmd.State, cmd.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if h.events != nil {
+		h.events.Publish(ctx, events.DeviceStateChanged{Device: updated, Previous: device.State, Patch: cmd.State})
+	}
+	return updated, nil
+}
+
+// checkVersion fails early when a device loaded for an update is not at the
+// version the update expects. The repository checks again when writing.
+func checkVersion(device *models.Device, expectedVersion int64) error {
+	if expectedVersion != 0 && device.Version != expectedVersion {
+		return fmt.Errorf("%w: device %s is at version %d", repository.ErrVersionMismatch, device.ID, device.Version)
+	}
+	return nil
 }
@@ -0,0 +1,160 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// ErrInvalidDevice is returned when a device is updated with invalid fields
+var ErrInvalidDevice = errors.New("invalid device")
+
+// Fields of a device that UpdateDeviceCommand can change, named as in field masks
+const (
+	DeviceFieldName        = "name"
+	DeviceFieldType        = "type"
+	DeviceFieldRoomID      = "room_id"
+	DeviceFieldHouseholdID = "household_id"
+	DeviceFieldLabels      = "labels"
+)
+
+type UpdateDeviceHandler struct {
+	repo     repository.CommandDeviceRepository
+	typeRepo repository.DeviceTypeRepository
+	roomRepo repository.RoomRepository
+}
+
+func NewUpdateDeviceHandler(repo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, roomRepo repository.RoomRepository) *UpdateDeviceHandler {
+	return &UpdateDeviceHandler{repo: repo, typeRepo: typeRepo, roomRepo: roomRepo}
+}
+
+// UpdateDeviceCommand changes the fields of a device listed in Fields. When
+// Fields is empty, every field with a non-empty value but HouseholdID is
+// changed. An empty RoomID takes the device out of its room, an empty
+// HouseholdID gives it back to the user who created it. The room must belong
+// to the device's household; a device moved to another household leaves its
+// room. Labels replace all labels of the device. A non-zero ExpectedVersion
+// makes the update fail with repository.ErrVersionMismatch unless the device
+// is at that version.
+type UpdateDeviceCommand struct {
+	ID              string
+	Name            string
+	Type            string
+	RoomID          string
+	HouseholdID     string
+	Labels          map[string]string
+	Fields          []string
+	ExpectedVersion int64
+}
+
+// fields returns the set of fields to change
+func (cmd UpdateDeviceCommand) fields() (map[string]bool, error) {
+	fields := make(map[string]bool)
+	if len(cmd.Fields) == 0 {
+		fields[DeviceFieldName] = cmd.Name != ""
+		fields[DeviceFieldType] = cmd.Type != ""
+		fields[DeviceFieldRoomID] = cmd.RoomID != ""
+		fields[DeviceFieldLabels] = len(cmd.Labels) > 0
+		return fields, nil
+	}
+	for _, field := range cmd.Fields {
+		switch field {
+		case DeviceFieldName, DeviceFieldType, DeviceFieldRoomID, DeviceFieldHouseholdID, DeviceFieldLabels:
+			fields[field] = true
+		default:
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidDevice, field)
+		}
+	}
+	return fields, nil
+}
+
+func (h *UpdateDeviceHandler) Handle(ctx context.Context, cmd UpdateDeviceCommand) (*models.Device, error) {
+	fields, err := cmd.fields()
+	if err != nil {
+		return nil, err
+	}
+	if !fields[DeviceFieldName] && !fields[DeviceFieldType] && !fields[DeviceFieldRoomID] && !fields[DeviceFieldHouseholdID] && !fields[DeviceFieldLabels] {
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidDevice)
+	}
+	if fields[DeviceFieldName] && strings.TrimSpace(cmd.Name) == "" {
+		return nil, fmt.Errorf("%w: name must not be empty", ErrInvalidDevice)
+	}
+	if fields[DeviceFieldType] && cmd.Type == "" {
+		return nil, fmt.Errorf("%w: type must not be empty", ErrInvalidDevice)
+	}
+	if fields[DeviceFieldLabels] {
+		if err := labels.Validate(cmd.Labels); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDevice, err)
+		}
+	}
+
+	device, err := h.repo.LoadDevice(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(device, cmd.ExpectedVersion); err != nil {
+		return nil, err
+	}
+
+	if fields[DeviceFieldName] {
+		device.Name = cmd.Name
+	}
+	if fields[DeviceFieldType] && cmd.Type != device.Type {
+		deviceType, err := loadDeviceType(ctx, h.typeRepo, cmd.Type)
+		if err != nil {
+			return nil, err
+		}
+		device.Type = cmd.Type
+		device.State = retypeState(deviceType, device.State)
+	}
+	householdChanged := fields[DeviceFieldHouseholdID] && cmd.HouseholdID != device.HouseholdID
+	if fields[DeviceFieldHouseholdID] {
+		device.HouseholdID = cmd.HouseholdID
+	}
+	roomChanged := fields[DeviceFieldRoomID] && cmd.RoomID != device.RoomID
+	if fields[DeviceFieldRoomID] {
+		device.RoomID = cmd.RoomID
+	}
+	if device.RoomID != "" && (roomChanged || householdChanged) {
+		room, err := h.roomRepo.GetRoom(ctx, device.RoomID)
+		if err != nil {
+			return nil, err
+		}
+		if room.HouseholdID != device.HouseholdID {
+			// A device given to another household leaves its room, unless
+			// the update asked to keep it there
+			if fields[DeviceFieldRoomID] {
+				return nil, ErrRoomHouseholdMismatch
+			}
+			device.RoomID = ""
+		}
+	}
+	if fields[DeviceFieldLabels] {
+		device.Labels = cmd.Labels
+	}
+
+	// The repository only writes the device if it is still at the version
+	// loaded, so concurrent updates cannot overwrite each other
+	device.UpdatedAt = time.Now()
+	return h.repo.UpdateDevice(ctx, device)
+}
+
+// retypeState carries a state over to a new device type: attributes the new
+// type declares with the same value type are kept, the others start from the
+// new type's defaults
+func retypeState(deviceType *models.DeviceType, state map[string]models.StateValue) map[string]models.StateValue {
+	retyped := defaultState(deviceType)
+	for name, value := range state {
+		capability := findCapability(deviceType, name)
+		if capability != nil && checkCapability(capability, value) == nil {
+			retyped[name] = value
+		}
+	}
+	return retyped
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// ErrNotApplied is the outcome of the updates of an all-or-nothing batch that
+// were not applied because another update of the batch failed
+var ErrNotApplied = errors.New("not applied: another update of the batch failed")
+
+type UpdateDeviceStatesHandler struct {
+	repo     repository.CommandDeviceRepository
+	typeRepo repository.DeviceTypeRepository
+	events   events.Publisher
+}
+
+// NewUpdateDeviceStatesHandler creates the handler. It publishes a
+// DeviceStateChanged event for every device once the batch is applied to
+// publisher, which may be nil.
+func NewUpdateDeviceStatesHandler(repo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, publisher events.Publisher) *UpdateDeviceStatesHandler {
+	return &UpdateDeviceStatesHandler{repo: repo, typeRepo: typeRepo, events: publisher}
+}
+
+// UpdateDeviceStatesCommand patches the states of several devices at once:
+// all of them, or none if any update fails
+type UpdateDeviceStatesCommand struct {
+	Updates []UpdateDeviceStateCommand
+}
+
+// Handle validates every update as UpdateDeviceStateHandler does and applies
+// them together. The update that failed is reported as a
+// *repository.StateUpdateError.
+func (h *UpdateDeviceStatesHandler) Handle(ctx context.Context, cmd UpdateDeviceStatesCommand) ([]*models.Device, error) {
+	previous := make([]*models.Device, len(cmd.Updates))
+	updates := make([]repository.DeviceStateUpdate, len(cmd.Updates))
+	for i, update := range cmd.Updates {
+		device, err := h.validate(ctx, update)
+		if err != nil {
+			return nil, &repository.StateUpdateError{Index: i, Err: err}
+		}
+		previous[i] = device
+		updates[i] = repository.DeviceStateUpdate{ID: update.ID, State: update.State, ExpectedVersion: update.ExpectedVersion}
+	}
+
+	updated, err := h.repo.UpdateDeviceStates(ctx, updates)
+	if err != nil {
+		return nil, err
+	}
+	if h.events != nil {
+		for i, device := range updated {
+			h.events.Publish(ctx, events.DeviceStateChanged{Device: device, Previous: previous[i].State, Patch: cmd.Updates[i].State})
+		}
+	}
+	return updated, nil
+}
+
+// validate checks an update against the device it is for, and returns the
+// device as it is before the update
+func (h *UpdateDeviceStatesHandler) validate(ctx context.Context, cmd UpdateDeviceStateCommand) (*models.Device, error) {
+	if err := validateState(cmd.State); err != nil {
+		return nil, err
+	}
+	device, err := h.repo.LoadDevice(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(device, cmd.ExpectedVersion); err != nil {
+		return nil, err
+	}
+	deviceType, err := loadDeviceType(ctx, h.typeRepo, device.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateStateForType(deviceType, cmd.State); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
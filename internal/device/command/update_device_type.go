@@ -0,0 +1,46 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type UpdateDeviceTypeHandler struct {
+	repo repository.DeviceTypeRepository
+}
+
+func NewUpdateDeviceTypeHandler(repo repository.DeviceTypeRepository) *UpdateDeviceTypeHandler {
+	return &UpdateDeviceTypeHandler{repo: repo}
+}
+
+// UpdateDeviceTypeCommand replaces the name, description, capabilities and
+// heartbeat timeout of a type. Existing device states are checked against the
+// new schema on their next update.
+type UpdateDeviceTypeCommand struct {
+	ID               string
+	Name             string
+	Description      string
+	Capabilities     []models.Capability
+	HeartbeatTimeout time.Duration
+}
+
+func (h *UpdateDeviceTypeHandler) Handle(ctx context.Context, cmd UpdateDeviceTypeCommand) (*models.DeviceType, error) {
+	deviceType := &models.DeviceType{
+		ID:               cmd.ID,
+		Name:             cmd.Name,
+		Description:      cmd.Description,
+		Capabilities:     cmd.Capabilities,
+		HeartbeatTimeout: cmd.HeartbeatTimeout,
+	}
+	if err := validateDeviceType(deviceType); err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.UpdateDeviceType(ctx, deviceType); err != nil {
+		return nil, err
+	}
+	return deviceType, nil
+}
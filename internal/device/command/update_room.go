@@ -0,0 +1,41 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type UpdateRoomHandler struct {
+	repo repository.RoomRepository
+}
+
+func NewUpdateRoomHandler(repo repository.RoomRepository) *UpdateRoomHandler {
+	return &UpdateRoomHandler{repo: repo}
+}
+
+type UpdateRoomCommand struct {
+	ID   string
+	Name string
+}
+
+func (h *UpdateRoomHandler) Handle(ctx context.Context, cmd UpdateRoomCommand) (*models.Room, error) {
+	if strings.TrimSpace(cmd.Name) == "" {
+		return nil, ErrInvalidRoom
+	}
+
+	room, err := h.repo.GetRoom(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	room.Name = cmd.Name
+	room.UpdatedAt = time.Now()
+
+	if err := h.repo.UpdateRoom(ctx, room); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
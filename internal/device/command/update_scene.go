@@ -0,0 +1,49 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type UpdateSceneHandler struct {
+	repo       repository.SceneRepository
+	deviceRepo repository.CommandDeviceRepository
+	typeRepo   repository.DeviceTypeRepository
+}
+
+func NewUpdateSceneHandler(repo repository.SceneRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository) *UpdateSceneHandler {
+	return &UpdateSceneHandler{repo: repo, deviceRepo: deviceRepo, typeRepo: typeRepo}
+}
+
+// UpdateSceneCommand renames a scene and replaces its targets
+type UpdateSceneCommand struct {
+	ID      string
+	Name    string
+	Targets []models.SceneTarget
+}
+
+func (h *UpdateSceneHandler) Handle(ctx context.Context, cmd UpdateSceneCommand) (*models.Scene, error) {
+	if strings.TrimSpace(cmd.Name) == "" {
+		return nil, ErrInvalidScene
+	}
+
+	scene, err := h.repo.GetScene(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSceneTargets(ctx, h.deviceRepo, h.typeRepo, cmd.Targets); err != nil {
+		return nil, err
+	}
+	scene.Name = cmd.Name
+	scene.Targets = cmd.Targets
+	scene.UpdatedAt = time.Now()
+
+	if err := h.repo.UpdateScene(ctx, scene); err != nil {
+		return nil, err
+	}
+	return scene, nil
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"context"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type UpdateScheduleHandler struct {
+	repo       repository.ScheduleRepository
+	deviceRepo repository.CommandDeviceRepository
+	typeRepo   repository.DeviceTypeRepository
+}
+
+func NewUpdateScheduleHandler(repo repository.ScheduleRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository) *UpdateScheduleHandler {
+	return &UpdateScheduleHandler{repo: repo, deviceRepo: deviceRepo, typeRepo: typeRepo}
+}
+
+// UpdateScheduleCommand replaces a schedule's action, timing and enabled flag.
+// The target device cannot be changed.
+type UpdateScheduleCommand struct {
+	ID       string
+	Action   map[string]models.StateValue
+	Cron     string
+	RunAt    string
+	Timezone string
+	Enabled  bool
+}
+
+func (h *UpdateScheduleHandler) Handle(ctx context.Context, cmd UpdateScheduleCommand) (*models.Schedule, error) {
+	schedule, err := h.repo.GetSchedule(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := loadScheduleDevice(ctx, h.deviceRepo, h.typeRepo, schedule.DeviceID, cmd.Action); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	spec := scheduleSpec{Cron: cmd.Cron, RunAt: cmd.RunAt, Timezone: cmd.Timezone}
+	if err := spec.apply(schedule, now); err != nil {
+		return nil, err
+	}
+	schedule.Action = cmd.Action
+	schedule.Enabled = cmd.Enabled
+	schedule.UpdatedAt = now
+	if schedule.NextRunAt, err = NextRun(schedule, now); err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.UpdateSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
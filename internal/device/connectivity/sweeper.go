@@ -0,0 +1,75 @@
+// Package connectivity marks devices offline when their heartbeats stop.
+package connectivity
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const defaultBatchSize = 100
+
+// Sweeper periodically marks the online devices that missed their heartbeat
+// timeout offline. Several instances may run against the same database: a
+// device is marked by one of them.
+type Sweeper struct {
+	repo           repository.ConnectivityRepository
+	events         events.Publisher
+	interval       time.Duration
+	defaultTimeout time.Duration
+	batchSize      int
+	now            func() time.Time
+}
+
+// NewSweeper creates a sweeper that applies defaultTimeout to the devices of
+// types without a heartbeat timeout. It publishes a DeviceConnectivityChanged
+// event for every device marked offline to publisher, which may be nil.
+func NewSweeper(repo repository.ConnectivityRepository, publisher events.Publisher, interval, defaultTimeout time.Duration) *Sweeper {
+	return &Sweeper{
+		repo:           repo,
+		events:         publisher,
+		interval:       interval,
+		defaultTimeout: defaultTimeout,
+		batchSize:      defaultBatchSize,
+		now:            time.Now,
+	}
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Tick(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Connectivity sweep failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick marks every device whose heartbeat timeout has passed offline
+func (s *Sweeper) Tick(ctx context.Context) error {
+	for {
+		devices, err := s.repo.MarkDevicesOffline(ctx, s.now(), s.defaultTimeout, s.batchSize)
+		if err != nil {
+			return err
+		}
+		if s.events != nil {
+			for _, device := range devices {
+				s.events.Publish(ctx, events.DeviceConnectivityChanged{Device: device, Previous: models.ConnectivityOnline})
+			}
+		}
+		if len(devices) < s.batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package connectivity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockConnectivityRepository struct {
+	repository.ConnectivityRepository
+	mock.Mock
+}
+
+func (m *mockConnectivityRepository) MarkDevicesOffline(ctx context.Context, now time.Time, defaultTimeout time.Duration, limit int) ([]*models.Device, error) {
+	args := m.Called(ctx, now, defaultTimeout, limit)
+	return args.Get(0).([]*models.Device), args.Error(1)
+}
+
+type recordingPublisher struct {
+	published []events.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event events.Event) {
+	p.published = append(p.published, event)
+}
+
+func TestTickMarksDevicesOfflineInBatches(t *testing.T) {
+	repo := new(mockConnectivityRepository)
+	publisher := new(recordingPublisher)
+	now := time.Date(2024, 7, 3, 5, 0, 0, 0, time.UTC)
+	sweeper := NewSweeper(repo, publisher, time.Minute, 5*time.Minute)
+	sweeper.now = func() time.Time { return now }
+	sweeper.batchSize = 2
+
+	lamp := &models.Device{ID: "lamp", Connectivity: models.ConnectivityOffline}
+	fan := &models.Device{ID: "fan", Connectivity: models.ConnectivityOffline}
+	heater := &models.Device{ID: "heater", Connectivity: models.ConnectivityOffline}
+	repo.On("MarkDevicesOffline", mock.Anything, now, 5*time.Minute, 2).Return([]*models.Device{lamp, fan}, nil).Once()
+	repo.On("MarkDevicesOffline", mock.Anything, now, 5*time.Minute, 2).Return([]*models.Device{heater}, nil).Once()
+
+	err := sweeper.Tick(context.Background())
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+	assert.Equal(t, []events.Event{
+		events.DeviceConnectivityChanged{Device: lamp, Previous: models.ConnectivityOnline},
+		events.DeviceConnectivityChanged{Device: fan, Previous: models.ConnectivityOnline},
+		events.DeviceConnectivityChanged{Device: heater, Previous: models.ConnectivityOnline},
+	}, publisher.published)
+}
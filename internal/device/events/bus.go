@@ -0,0 +1,185 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+)
+
+// asyncBuffer is how many events an asynchronous subscriber may fall behind
+// before further events are dropped for it
+const asyncBuffer = 1024
+
+// Handler receives the events a subscriber subscribed to
+type Handler interface {
+	HandleEvent(ctx context.Context, event Event) error
+}
+
+// HandlerFunc adapts a function to a Handler
+type HandlerFunc func(ctx context.Context, event Event) error
+
+func (f HandlerFunc) HandleEvent(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Publisher is what commands publish their events to
+type Publisher interface {
+	Publish(ctx context.Context, event Event)
+}
+
+// SubscriberStats counts the deliveries to a subscriber
+type SubscriberStats struct {
+	Async     bool  `json:"async"`
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+	Dropped   int64 `json:"dropped"`
+}
+
+type delivery struct {
+	ctx   context.Context
+	event Event
+}
+
+type subscriber struct {
+	name    string
+	handler Handler
+	types   []Type
+	// queue is nil for synchronous subscribers
+	queue chan delivery
+	stats SubscriberStats
+}
+
+func (s *subscriber) wants(event Event) bool {
+	return len(s.types) == 0 || slices.Contains(s.types, event.Type())
+}
+
+// Bus delivers events to its subscribers. Subscribers are isolated from each
+// other: an error or panic in one is logged and counted, and the others, as
+// well as the command that published the event, carry on.
+//
+// Synchronous subscribers run in the publishing goroutine, in the order they
+// subscribed, and get the context of the command. Asynchronous subscribers run
+// in a goroutine of their own, one event at a time in publish order, with a
+// context that is not cancelled when the command returns.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers []*subscriber
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a synchronous subscriber for the given event types, or
+// for all events if none are given
+func (b *Bus) Subscribe(name string, handler Handler, types ...Type) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, &subscriber{name: name, handler: handler, types: types})
+}
+
+// SubscribeAsync registers an asynchronous subscriber for the given event
+// types, or for all events if none are given. Events that arrive while the
+// subscriber is asyncBuffer events behind are dropped for it.
+func (b *Bus) SubscribeAsync(name string, handler Handler, types ...Type) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := &subscriber{name: name, handler: handler, types: types, queue: make(chan delivery, asyncBuffer)}
+	sub.stats.Async = true
+	b.subscribers = append(b.subscribers, sub)
+	if b.closed {
+		close(sub.queue)
+		return
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for d := range sub.queue {
+			b.deliver(d.ctx, sub, d.event)
+		}
+	}()
+}
+
+// Publish delivers an event to every subscriber that wants it. It returns once
+// the synchronous subscribers have handled it.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.Lock()
+	subscribers := slices.Clone(b.subscribers)
+	closed := b.closed
+	for _, sub := range subscribers {
+		if sub.queue == nil || !sub.wants(event) {
+			continue
+		}
+		if closed {
+			sub.stats.Dropped++
+			continue
+		}
+		select {
+		case sub.queue <- delivery{ctx: context.WithoutCancel(ctx), event: event}:
+		default:
+			sub.stats.Dropped++
+			log.Printf("Event subscriber %s is falling behind, dropped %s event", sub.name, event.Type())
+		}
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subscribers {
+		if sub.queue == nil && sub.wants(event) {
+			b.deliver(ctx, sub, event)
+		}
+	}
+}
+
+// deliver runs a subscriber's handler, containing its errors and panics
+func (b *Bus) deliver(ctx context.Context, sub *subscriber, event Event) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return sub.handler.HandleEvent(ctx, event)
+	}()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		sub.stats.Failed++
+		log.Printf("Event subscriber %s failed to handle %s event: %v", sub.name, event.Type(), err)
+		return
+	}
+	sub.stats.Delivered++
+}
+
+// Close stops the asynchronous subscribers once they have handled the events
+// already queued for them. Events published afterwards only reach
+// synchronous subscribers.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, sub := range b.subscribers {
+			if sub.queue != nil {
+				close(sub.queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// Stats returns the delivery counts of every subscriber, by name
+func (b *Bus) Stats() map[string]SubscriberStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]SubscriberStats, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		stats[sub.name] = sub.stats
+	}
+	return stats
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder remembers the types of the events it handled
+type recorder struct {
+	types []Type
+}
+
+func (r *recorder) HandleEvent(ctx context.Context, event Event) error {
+	r.types = append(r.types, event.Type())
+	return nil
+}
+
+func TestPublishDeliversToSubscribedTypes(t *testing.T) {
+	bus := NewBus()
+	all := &recorder{}
+	deletes := &recorder{}
+	bus.Subscribe("all", all)
+	bus.Subscribe("deletes", deletes, TypeDeviceDeleted)
+
+	device := &models.Device{ID: "lamp"}
+	bus.Publish(context.Background(), DeviceCreated{Device: device})
+	bus.Publish(context.Background(), DeviceStateChanged{Device: device})
+	bus.Publish(context.Background(), DeviceDeleted{Device: device})
+
+	assert.Equal(t, []Type{TypeDeviceCreated, TypeDeviceStateChanged, TypeDeviceDeleted}, all.types)
+	assert.Equal(t, []Type{TypeDeviceDeleted}, deletes.types)
+}
+
+func TestFailingSubscribersDoNotAffectOthers(t *testing.T) {
+	bus := NewBus()
+	bus.Subscribe("failing", HandlerFunc(func(ctx context.Context, event Event) error {
+		return errors.New("unavailable")
+	}))
+	bus.Subscribe("panicking", HandlerFunc(func(ctx context.Context, event Event) error {
+		panic("bug")
+	}))
+	sync := &recorder{}
+	async := &recorder{}
+	bus.Subscribe("sync", sync)
+	bus.SubscribeAsync("async", async)
+
+	bus.Publish(context.Background(), DeviceCreated{Device: &models.Device{ID: "lamp"}})
+	bus.Close()
+
+	assert.Equal(t, []Type{TypeDeviceCreated}, sync.types)
+	assert.Equal(t, []Type{TypeDeviceCreated}, async.types)
+	stats := bus.Stats()
+	assert.Equal(t, SubscriberStats{Failed: 1}, stats["failing"])
+	assert.Equal(t, SubscriberStats{Failed: 1}, stats["panicking"])
+	assert.Equal(t, SubscriberStats{Async: true, Delivered: 1}, stats["async"])
+}
+
+func TestAsyncSubscribersOutliveTheCommandContext(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	var errs []error
+	bus.SubscribeAsync("slow", HandlerFunc(func(ctx context.Context, event Event) error {
+		<-release
+		errs = append(errs, ctx.Err())
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bus.Publish(ctx, DeviceDeleted{Device: &models.Device{ID: "lamp"}})
+	cancel()
+	close(release)
+	bus.Close()
+
+	assert.Equal(t, []error{nil}, errs)
+	bus.Publish(context.Background(), DeviceDeleted{Device: &models.Device{ID: "lamp"}})
+	assert.Equal(t, int64(1), bus.Stats()["slow"].Dropped)
+}
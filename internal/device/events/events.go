@@ -0,0 +1,60 @@
+// Package events publishes domain events of the device commands to
+// subscribers in the same process.
+//
+// Events are published after a command succeeded, so a subscriber cannot fail
+// or undo it. They are not persisted: subscribers that must not miss a change,
+// across restarts or replicas, read the device event log instead.
+package events
+
+import (
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// Type identifies a kind of event, for subscribing to some kinds only
+type Type string
+
+const (
+	TypeDeviceCreated      Type = "device.created"
+	TypeDeviceStateChanged Type = "device.state_changed"
+	TypeDeviceDeleted      Type = "device.deleted"
+
+	TypeDeviceConnectivityChanged Type = "device.connectivity_changed"
+)
+
+// Event is a domain event. Subscribers switch on the concrete type.
+type Event interface {
+	Type() Type
+}
+
+// DeviceCreated is published after a device was created
+type DeviceCreated struct {
+	Device *models.Device
+}
+
+func (DeviceCreated) Type() Type { return TypeDeviceCreated }
+
+// DeviceStateChanged is published after a device state update
+type DeviceStateChanged struct {
+	Device   *models.Device               // the device after the update
+	Previous map[string]models.StateValue // the state before the update
+	Patch    map[string]models.StateValue // the attributes that were written
+}
+
+func (DeviceStateChanged) Type() Type { return TypeDeviceStateChanged }
+
+// DeviceDeleted is published after a device was deleted, with the device as
+// it was
+type DeviceDeleted struct {
+	Device *models.Device
+}
+
+func (DeviceDeleted) Type() Type { return TypeDeviceDeleted }
+
+// DeviceConnectivityChanged is published after a device came online with a
+// heartbeat, or was marked offline once its heartbeats stopped
+type DeviceConnectivityChanged struct {
+	Device   *models.Device      // the device, with its new connectivity
+	Previous models.Connectivity // the connectivity before the change
+}
+
+func (DeviceConnectivityChanged) Type() Type { return TypeDeviceConnectivityChanged }
@@ -0,0 +1,157 @@
+// Package labels validates the key/value labels of devices and parses the
+// label selectors that pick devices by them, in the syntax of Kubernetes:
+//
+//	floor=2,kind!=outdoor
+//	floor in (1,2),!broken
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxLabels is the largest number of labels of a device
+	MaxLabels = 64
+	maxLength = 63
+)
+
+var (
+	// Keys may not contain dots, which would address nested fields in the
+	// read model
+	keyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_/-]*[A-Za-z0-9])?$`)
+	valuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9_.-]*[A-Za-z0-9])?)?$`)
+)
+
+// Validate checks the labels of a device
+func Validate(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("a device has at most %d labels, got %d", MaxLabels, len(labels))
+	}
+	for key, value := range labels {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+		if err := validateValue(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateKey(key string) error {
+	if len(key) > maxLength || !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: keys are up to %d letters, digits, '-', '_' and '/', starting and ending with a letter or digit", key, maxLength)
+	}
+	return nil
+}
+
+func validateValue(value string) error {
+	if len(value) > maxLength || !valuePattern.MatchString(value) {
+		return fmt.Errorf("invalid label value %q: values are empty or up to %d letters, digits, '-', '_' and '.', starting and ending with a letter or digit", value, maxLength)
+	}
+	return nil
+}
+
+// Operator is the comparison of a Requirement
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is a condition on one label. Equals and NotEquals have a single
+// value, In and NotIn at least one, Exists and DoesNotExist none. NotEquals
+// and NotIn also match devices without the label.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector selects the devices whose labels meet all of its requirements.
+// The empty selector selects every device.
+type Selector []Requirement
+
+var setPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// Parse parses a selector: requirements separated by commas, each one of
+// "key=value" (or "key==value"), "key!=value", "key in (v1,v2)",
+// "key notin (v1,v2)", "key" (the label exists) and "!key" (it does not).
+func Parse(selector string) (Selector, error) {
+	var parsed Selector
+	for _, term := range splitTerms(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("invalid label selector %q: empty requirement", selector)
+		}
+		requirement, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, requirement)
+	}
+	return parsed, nil
+}
+
+// splitTerms splits a selector at the commas outside of value sets
+func splitTerms(selector string) []string {
+	if strings.TrimSpace(selector) == "" {
+		return nil
+	}
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	var requirement Requirement
+	if match := setPattern.FindStringSubmatch(term); match != nil {
+		if strings.TrimSpace(match[3]) == "" {
+			return Requirement{}, fmt.Errorf("invalid label selector %q: empty set of values", term)
+		}
+		requirement = Requirement{Key: match[1], Operator: Operator(match[2])}
+		for _, value := range strings.Split(match[3], ",") {
+			requirement.Values = append(requirement.Values, strings.TrimSpace(value))
+		}
+	} else if key, value, ok := strings.Cut(term, "!="); ok {
+		requirement = Requirement{Key: strings.TrimSpace(key), Operator: NotEquals, Values: []string{strings.TrimSpace(value)}}
+	} else if key, value, ok := strings.Cut(term, "=="); ok {
+		requirement = Requirement{Key: strings.TrimSpace(key), Operator: Equals, Values: []string{strings.TrimSpace(value)}}
+	} else if key, value, ok := strings.Cut(term, "="); ok {
+		requirement = Requirement{Key: strings.TrimSpace(key), Operator: Equals, Values: []string{strings.TrimSpace(value)}}
+	} else if key, ok := strings.CutPrefix(term, "!"); ok {
+		requirement = Requirement{Key: strings.TrimSpace(key), Operator: DoesNotExist}
+	} else {
+		requirement = Requirement{Key: term, Operator: Exists}
+	}
+
+	if err := validateKey(requirement.Key); err != nil {
+		return Requirement{}, err
+	}
+	for _, value := range requirement.Values {
+		if err := validateValue(value); err != nil {
+			return Requirement{}, err
+		}
+	}
+	return requirement, nil
+}
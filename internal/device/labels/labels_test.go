@@ -0,0 +1,46 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	selector, err := Parse("floor=2, kind!=outdoor,room in (kitchen, hall),zone notin (a),battery,!broken,env==prod")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Selector{
+		{Key: "floor", Operator: Equals, Values: []string{"2"}},
+		{Key: "kind", Operator: NotEquals, Values: []string{"outdoor"}},
+		{Key: "room", Operator: In, Values: []string{"kitchen", "hall"}},
+		{Key: "zone", Operator: NotIn, Values: []string{"a"}},
+		{Key: "battery", Operator: Exists},
+		{Key: "broken", Operator: DoesNotExist},
+		{Key: "env", Operator: Equals, Values: []string{"prod"}},
+	}, selector)
+
+	empty, err := Parse("  ")
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestParseRejectsInvalidSelectors(t *testing.T) {
+	for _, selector := range []string{
+		"floor=2,",
+		"floor in ()",
+		"floor.level=2",
+		"floor=two words",
+		"$where=1",
+		"=2",
+	} {
+		_, err := Parse(selector)
+		assert.Error(t, err, selector)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(map[string]string{"floor": "2", "kind": "outdoor", "example/owner": "", "model": "v1.2"}))
+	assert.Error(t, Validate(map[string]string{"site.zone": "a"}))
+	assert.Error(t, Validate(map[string]string{"kind": "-outdoor"}))
+}
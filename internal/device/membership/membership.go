@@ -0,0 +1,139 @@
+// Package membership tells the device service which households a user is a
+// member of, and with which role. Households are kept by the user service.
+package membership
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc"
+)
+
+// maxCacheEntries bounds the users a Cache remembers before it drops the
+// expired entries
+const maxCacheEntries = 10000
+
+// Source looks up memberships
+type Source interface {
+	// Households returns the role of a user in each household they are a
+	// member of, by household ID
+	Households(ctx context.Context, userID string) (map[string]models.HouseholdRole, error)
+}
+
+// DeniedError is returned when a user may not access a device or household
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return e.Reason
+}
+
+// Role returns the role of a user in a household, failing with a DeniedError
+// unless the role is allowed. Without a source, users are in no household.
+func Role(ctx context.Context, source Source, userID, householdID string, allowed func(models.HouseholdRole) bool) (models.HouseholdRole, error) {
+	var roles map[string]models.HouseholdRole
+	if source != nil {
+		var err error
+		if roles, err = source.Households(ctx, userID); err != nil {
+			return "", fmt.Errorf("looking up households: %w", err)
+		}
+	}
+	role, ok := roles[householdID]
+	if !ok {
+		return "", &DeniedError{Reason: fmt.Sprintf("not a member of household %s", householdID)}
+	}
+	if !allowed(role) {
+		return "", &DeniedError{Reason: fmt.Sprintf("the %s role does not allow this in household %s", role, householdID)}
+	}
+	return role, nil
+}
+
+// Authorize checks that a user may access a device as allowed. Users own the
+// devices outside of households; the devices of a household are shared with
+// its members by role.
+func Authorize(ctx context.Context, source Source, userID string, device *models.Device, allowed func(models.HouseholdRole) bool) error {
+	if device.HouseholdID == "" {
+		if device.UserID != userID {
+			return &DeniedError{Reason: fmt.Sprintf("device %s belongs to another user", device.ID)}
+		}
+		return nil
+	}
+	_, err := Role(ctx, source, userID, device.HouseholdID, allowed)
+	return err
+}
+
+// Client reads memberships from the HouseholdService of the user service
+type Client struct {
+	client proto.HouseholdServiceClient
+}
+
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{client: proto.NewHouseholdServiceClient(conn)}
+}
+
+func (c *Client) Households(ctx context.Context, userID string) (map[string]models.HouseholdRole, error) {
+	resp, err := c.client.ListHouseholds(identity.NewOutgoingContext(ctx, userID), &proto.ListHouseholdsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[string]models.HouseholdRole, len(resp.Households))
+	for _, household := range resp.Households {
+		roles[household.Id] = models.HouseholdRole(household.Role)
+	}
+	return roles, nil
+}
+
+// Cache remembers the memberships read from a source for ttl, so that not
+// every device request has to ask the user service. Changes to memberships
+// therefore take up to ttl to apply.
+type Cache struct {
+	source Source
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	roles   map[string]models.HouseholdRole
+	expires time.Time
+}
+
+func NewCache(source Source, ttl time.Duration) *Cache {
+	return &Cache{source: source, ttl: ttl, now: time.Now, entries: make(map[string]cacheEntry)}
+}
+
+func (c *Cache) Households(ctx context.Context, userID string) (map[string]models.HouseholdRole, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.roles, nil
+	}
+
+	roles, err := c.source.Households(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxCacheEntries {
+		for id, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[userID] = cacheEntry{roles: roles, expires: now.Add(c.ttl)}
+	return roles, nil
+}
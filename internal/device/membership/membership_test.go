@@ -0,0 +1,67 @@
+package membership
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSource counts the lookups that reach it
+type countingSource struct {
+	calls int
+}
+
+func (s *countingSource) Households(ctx context.Context, userID string) (map[string]models.HouseholdRole, error) {
+	s.calls++
+	return map[string]models.HouseholdRole{"home": models.HouseholdRoleOwner}, nil
+}
+
+func TestCacheRemembersMembershipsForTTL(t *testing.T) {
+	source := &countingSource{}
+	cache := NewCache(source, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	roles, err := cache.Households(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.HouseholdRoleOwner, roles["home"])
+	_, err = cache.Households(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1, source.calls)
+
+	// Other users are looked up separately
+	_, err = cache.Households(context.Background(), "bob")
+	require.NoError(t, err)
+	assert.Equal(t, 2, source.calls)
+
+	// Once the entry expires, the source is asked again
+	now = now.Add(time.Minute)
+	_, err = cache.Households(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3, source.calls)
+}
+
+func TestAuthorize(t *testing.T) {
+	source := &countingSource{}
+	own := &models.Device{ID: "lamp", UserID: "alice"}
+	shared := &models.Device{ID: "tv", UserID: "bob", HouseholdID: "home"}
+	elsewhere := &models.Device{ID: "heater", UserID: "bob", HouseholdID: "cabin"}
+
+	assert.NoError(t, Authorize(context.Background(), source, "alice", own, models.HouseholdRole.CanManageDevices))
+	assert.NoError(t, Authorize(context.Background(), source, "alice", shared, models.HouseholdRole.CanManageDevices))
+
+	var denied *DeniedError
+	err := Authorize(context.Background(), source, "bob", own, models.HouseholdRole.Valid)
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, "device lamp belongs to another user", denied.Reason)
+	err = Authorize(context.Background(), source, "alice", elsewhere, models.HouseholdRole.Valid)
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, "not a member of household cabin", denied.Reason)
+
+	// Without a source, users are in no household
+	assert.ErrorAs(t, Authorize(context.Background(), nil, "alice", shared, models.HouseholdRole.Valid), &denied)
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS devices (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    state      TEXT NOT NULL DEFAULT 'off',
+    user_id    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_devices_user_id ON devices (user_id);
@@ -0,0 +1,5 @@
+ALTER TABLE devices ALTER COLUMN state DROP DEFAULT;
+ALTER TABLE devices ALTER COLUMN state TYPE TEXT USING (
+    CASE WHEN (state -> 'power' ->> 'bool')::boolean THEN 'on' ELSE 'off' END
+);
+ALTER TABLE devices ALTER COLUMN state SET DEFAULT 'off';
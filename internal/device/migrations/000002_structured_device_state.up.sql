@@ -0,0 +1,7 @@
+-- Device state becomes a map of typed attributes. The old on/off string is
+-- carried over as the "power" attribute.
+ALTER TABLE devices ALTER COLUMN state DROP DEFAULT;
+ALTER TABLE devices ALTER COLUMN state TYPE JSONB USING jsonb_build_object(
+    'power', jsonb_build_object('type', 'bool', 'bool', lower(state) = 'on')
+);
+ALTER TABLE devices ALTER COLUMN state SET DEFAULT '{}'::jsonb;
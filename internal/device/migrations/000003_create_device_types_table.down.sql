@@ -0,0 +1,2 @@
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS fk_devices_type;
+DROP TABLE IF EXISTS device_types;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS device_types (
+    id           TEXT PRIMARY KEY,
+    name         TEXT NOT NULL,
+    description  TEXT NOT NULL DEFAULT '',
+    capabilities JSONB NOT NULL DEFAULT '[]'::jsonb
+);
+
+INSERT INTO device_types (id, name, description, capabilities) VALUES
+    ('switch', 'Switch', 'On/off switch or smart plug',
+     '[{"name": "power", "type": "bool", "default": {"type": "bool"}}]'),
+    ('dimmer', 'Dimmable light', 'Light with adjustable brightness',
+     '[{"name": "power", "type": "bool", "default": {"type": "bool"}},
+       {"name": "brightness", "type": "int", "min": 0, "max": 100, "default": {"type": "int", "int": 100}}]'),
+    ('color_light', 'Color light', 'Dimmable RGB light',
+     '[{"name": "power", "type": "bool", "default": {"type": "bool"}},
+       {"name": "brightness", "type": "int", "min": 0, "max": 100, "default": {"type": "int", "int": 100}},
+       {"name": "color", "type": "color", "default": {"type": "color", "color": {"r": 255, "g": 255, "b": 255}}}]'),
+    ('thermostat', 'Thermostat', 'Heating and cooling controller',
+     '[{"name": "power", "type": "bool", "default": {"type": "bool"}},
+       {"name": "setpoint", "type": "float", "min": 5, "max": 35, "default": {"type": "float", "float": 21}},
+       {"name": "mode", "type": "enum", "values": ["heat", "cool", "auto"], "default": {"type": "enum", "enum": "auto"}}]')
+ON CONFLICT (id) DO NOTHING;
+
+-- Register every type already in use so existing devices stay valid. Their
+-- state was migrated to a single "power" attribute.
+INSERT INTO device_types (id, name, capabilities)
+SELECT DISTINCT type, type, '[{"name": "power", "type": "bool", "default": {"type": "bool"}}]'::jsonb
+FROM devices
+ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE devices ADD CONSTRAINT fk_devices_type FOREIGN KEY (type) REFERENCES device_types (id);
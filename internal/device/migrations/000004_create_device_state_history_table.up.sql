@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS device_state_history (
+    id          BIGSERIAL PRIMARY KEY,
+    device_id   UUID NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    state       JSONB NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_state_history_device_time
+    ON device_state_history (device_id, recorded_at DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE devices DROP COLUMN IF EXISTS room_id;
+DROP TABLE IF EXISTS rooms;
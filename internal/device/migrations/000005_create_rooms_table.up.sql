@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS rooms (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rooms_user_id ON rooms (user_id);
+
+-- A single nullable column keeps every device in at most one room
+ALTER TABLE devices ADD COLUMN room_id UUID REFERENCES rooms (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_devices_room_id ON devices (room_id);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS schedules (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id    UUID NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL,
+    action       JSONB NOT NULL,
+    cron         TEXT NOT NULL DEFAULT '',
+    run_at       TIMESTAMPTZ,
+    timezone     TEXT NOT NULL DEFAULT 'UTC',
+    enabled      BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at  TIMESTAMPTZ,
+    last_run_at  TIMESTAMPTZ,
+    -- Set while a scheduler instance is executing the schedule so that
+    -- concurrent instances do not fire it twice
+    locked_until TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_user_id ON schedules (user_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_device_id ON schedules (device_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_due ON schedules (next_run_at) WHERE enabled;
+
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id          BIGSERIAL PRIMARY KEY,
+    schedule_id UUID NOT NULL REFERENCES schedules (id) ON DELETE CASCADE,
+    device_id   UUID NOT NULL,
+    ran_at      TIMESTAMPTZ NOT NULL,
+    success     BOOLEAN NOT NULL,
+    error       TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule_time
+    ON schedule_runs (schedule_id, ran_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scene_targets;
+DROP TABLE IF EXISTS scenes;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS scenes (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scenes_user_id ON scenes (user_id);
+
+-- Deleting a device drops it from every scene it was part of
+CREATE TABLE IF NOT EXISTS scene_targets (
+    scene_id  UUID NOT NULL REFERENCES scenes (id) ON DELETE CASCADE,
+    device_id UUID NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    position  INT NOT NULL,
+    state     JSONB NOT NULL,
+    PRIMARY KEY (scene_id, device_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scene_targets_device_id ON scene_targets (device_id);
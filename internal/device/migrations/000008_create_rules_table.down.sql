@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rule_executions;
+DROP TABLE IF EXISTS rules;
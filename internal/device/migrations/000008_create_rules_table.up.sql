@@ -0,0 +1,34 @@
+-- Triggers, conditions and actions are stored as documents. Devices they refer
+-- to are checked when a rule is saved and again when it runs.
+CREATE TABLE IF NOT EXISTS rules (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name        TEXT NOT NULL,
+    user_id     TEXT NOT NULL,
+    enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+    trigger     JSONB NOT NULL,
+    conditions  JSONB NOT NULL DEFAULT '[]',
+    actions     JSONB NOT NULL,
+    next_run_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rules_user_id ON rules (user_id);
+CREATE INDEX IF NOT EXISTS idx_rules_trigger_device
+    ON rules ((trigger->>'device_id')) WHERE trigger->>'type' = 'device_state';
+CREATE INDEX IF NOT EXISTS idx_rules_trigger_rule
+    ON rules ((trigger->>'rule_id')) WHERE trigger->>'type' = 'rule';
+CREATE INDEX IF NOT EXISTS idx_rules_due ON rules (next_run_at) WHERE enabled;
+
+CREATE TABLE IF NOT EXISTS rule_executions (
+    id          BIGSERIAL PRIMARY KEY,
+    rule_id     UUID NOT NULL REFERENCES rules (id) ON DELETE CASCADE,
+    trigger     TEXT NOT NULL,
+    depth       INT NOT NULL,
+    status      TEXT NOT NULL,
+    error       TEXT NOT NULL DEFAULT '',
+    executed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_executions_rule_time
+    ON rule_executions (rule_id, executed_at DESC);
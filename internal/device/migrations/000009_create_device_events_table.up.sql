@@ -0,0 +1,16 @@
+-- device_events is the change log of devices, written in the same transaction
+-- as the change. Readers order it by (tx_id, seq) and only read transactions
+-- older than every running one, so a transaction that commits late can never
+-- land behind a reader's position.
+CREATE TABLE IF NOT EXISTS device_events (
+    seq         BIGSERIAL PRIMARY KEY,
+    tx_id       XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    device_id   UUID NOT NULL,
+    user_id     TEXT NOT NULL,
+    type        TEXT NOT NULL,
+    device      JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_events_position ON device_events (tx_id, seq);
+CREATE INDEX IF NOT EXISTS idx_device_events_user_position ON device_events (user_id, tx_id, seq);
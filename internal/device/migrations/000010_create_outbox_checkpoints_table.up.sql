@@ -0,0 +1,11 @@
+-- outbox_checkpoints records how far a relay has applied the device event log
+-- to a projection. Each checkpoint is leased to one instance at a time; a
+-- lease that is not renewed expires and another instance takes over.
+CREATE TABLE IF NOT EXISTS outbox_checkpoints (
+    name        TEXT PRIMARY KEY,
+    tx_id       XID8 NOT NULL DEFAULT '0',
+    seq         BIGINT NOT NULL DEFAULT 0,
+    owner       TEXT NOT NULL,
+    lease_until TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- version counts the writes to a device, starting at 1. Clients pass the
+-- version they read to make an update fail if another write came in between.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
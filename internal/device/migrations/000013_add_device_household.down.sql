@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_device_events_household_position;
+ALTER TABLE device_events DROP COLUMN IF EXISTS household_id;
+
+DROP INDEX IF EXISTS idx_devices_household_id;
+ALTER TABLE devices DROP COLUMN IF EXISTS household_id;
@@ -0,0 +1,9 @@
+-- Devices may belong to a household of the user service, whose members share
+-- them. Devices without one belong to their user alone. The event log carries
+-- the household too, so that watchers can be given the events of their
+-- households' devices.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS household_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_devices_household_id ON devices (household_id);
+
+ALTER TABLE device_events ADD COLUMN IF NOT EXISTS household_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_device_events_household_position ON device_events (household_id, tx_id, seq);
@@ -0,0 +1,4 @@
+-- Labels are key/value pairs that group devices. The GIN index serves the
+-- equality and existence conditions of label selectors.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_devices_labels ON devices USING GIN (labels);
@@ -0,0 +1,5 @@
+ALTER TABLE device_types DROP COLUMN IF EXISTS heartbeat_timeout_seconds;
+
+DROP INDEX IF EXISTS idx_devices_online_last_seen_at;
+ALTER TABLE devices DROP COLUMN IF EXISTS connectivity;
+ALTER TABLE devices DROP COLUMN IF EXISTS last_seen_at;
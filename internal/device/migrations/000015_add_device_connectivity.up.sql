@@ -0,0 +1,9 @@
+-- Devices report heartbeats. The device service marks online devices offline
+-- once their heartbeats stop for longer than the heartbeat timeout of their
+-- type; a timeout of 0 stands for the service's default. The partial index
+-- serves that sweep.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS connectivity TEXT NOT NULL DEFAULT 'unknown';
+CREATE INDEX IF NOT EXISTS idx_devices_online_last_seen_at ON devices (last_seen_at) WHERE connectivity = 'online';
+
+ALTER TABLE device_types ADD COLUMN IF NOT EXISTS heartbeat_timeout_seconds INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_rooms_household_id;
+ALTER TABLE rooms DROP COLUMN IF EXISTS household_id;
@@ -0,0 +1,10 @@
+-- Rooms of a household are shared by its members, so that a household device
+-- sits in the same room for everyone. Devices may only be in a room of their
+-- own household: those that are not are taken out of their room. Run
+-- `device-service reindex -mode repair` afterwards so the read model follows.
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS household_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_rooms_household_id ON rooms (household_id);
+
+UPDATE devices d SET room_id = NULL
+FROM rooms r
+WHERE d.room_id = r.id AND d.household_id IS DISTINCT FROM r.household_id;
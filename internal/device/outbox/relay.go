@@ -0,0 +1,270 @@
+// Package outbox applies the device event log to the Mongo read model. The log
+// is written in the same transaction as every device change, which makes it
+// the outbox of the SQL write model: a change that committed is in the log,
+// and the relay keeps applying it until the projection has it.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBatchSize = 500
+	defaultWorkers   = 4
+	defaultLease     = 30 * time.Second
+	maxBackoff       = 30 * time.Second
+)
+
+// Projection is a read model kept up to date from the device event log
+type Projection interface {
+	// ApplyDeviceEvent applies an event to the read model. Applying events
+	// again, in order, must leave the read model as applying them once did.
+	ApplyDeviceEvent(ctx context.Context, event *models.DeviceEvent) error
+}
+
+// Stats describes the progress of a relay. Lag is only measured by the
+// instance holding the checkpoint lease; the others report Leader false.
+type Stats struct {
+	Leader              bool                 `json:"leader"`
+	Position            models.EventPosition `json:"position"`
+	LagSeconds          float64              `json:"lag_seconds"`
+	AppliedTotal        int64                `json:"applied_total"`
+	FailedPassesTotal   int64                `json:"failed_passes_total"`
+	ConsecutiveFailures int                  `json:"consecutive_failures"`
+	LastError           string               `json:"last_error,omitempty"`
+}
+
+// Relay applies the device event log to a projection. Every instance of the
+// service runs one, but only the instance holding the checkpoint lease applies
+// events, in log order per device. A pass that fails leaves the checkpoint
+// where it was and is retried with backoff.
+type Relay struct {
+	events      repository.DeviceEventRepository
+	checkpoints repository.OutboxCheckpointRepository
+	projection  Projection
+	owner       string
+	interval    time.Duration
+	lease       time.Duration
+	batchSize   int
+	workers     int
+
+	mu            sync.Mutex
+	stats         Stats
+	oldestPending time.Time
+}
+
+func NewRelay(events repository.DeviceEventRepository, checkpoints repository.OutboxCheckpointRepository, projection Projection, interval time.Duration) *Relay {
+	owner := uuid.NewString()
+	if hostname, err := os.Hostname(); err == nil {
+		owner = hostname + "/" + owner
+	}
+	return &Relay{
+		events:      events,
+		checkpoints: checkpoints,
+		projection:  projection,
+		owner:       owner,
+		interval:    interval,
+		lease:       defaultLease,
+		batchSize:   defaultBatchSize,
+		workers:     defaultWorkers,
+	}
+}
+
+// Run applies new events every interval until ctx is cancelled, backing off
+// while passes fail. On the way out it hands the lease over to the other
+// instances.
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	defer r.release()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		wait := r.interval
+		if err := r.Tick(ctx); err != nil && ctx.Err() == nil {
+			wait = r.backoff()
+			log.Printf("Outbox relay pass failed, retrying in %s: %v", wait, err)
+		}
+		timer.Reset(wait)
+	}
+}
+
+// Tick applies the events written since the checkpoint, if this relay holds
+// or can take the lease
+func (r *Relay) Tick(ctx context.Context) error {
+	for {
+		more, err := r.pass(ctx)
+		if err != nil {
+			r.recordFailure(err)
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// pass applies one batch of events and reports whether more are waiting. Each
+// pass renews the lease, and gives up well before it would expire, so that a
+// relay that lost its lease is never still applying events next to the new
+// holder.
+func (r *Relay) pass(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.lease/2)
+	defer cancel()
+
+	position, ok, err := r.checkpoints.AcquireOutboxCheckpoint(ctx, repository.DeviceProjectionCheckpoint, r.owner, r.lease)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		r.recordFollower()
+		return false, nil
+	}
+
+	events, err := r.events.ListDeviceEvents(ctx, position, repository.Owners{}, r.batchSize)
+	if err != nil {
+		return false, err
+	}
+	if len(events) == 0 {
+		r.recordApplied(position, 0, false)
+		return false, nil
+	}
+	r.recordPending(events[0].OccurredAt)
+
+	if err := r.apply(ctx, events); err != nil {
+		return false, err
+	}
+	position = events[len(events)-1].Position
+	if err := r.checkpoints.SaveOutboxCheckpoint(ctx, repository.DeviceProjectionCheckpoint, r.owner, position); err != nil {
+		return false, err
+	}
+
+	more := len(events) == r.batchSize
+	r.recordApplied(position, len(events), more)
+	return more, nil
+}
+
+// apply applies a batch of events. Events are spread over workers by device,
+// so the events of a device are applied one after the other, in log order.
+func (r *Relay) apply(ctx context.Context, events []*models.DeviceEvent) error {
+	lanes := make([][]*models.DeviceEvent, r.workers)
+	for _, event := range events {
+		lane := laneOf(event.Device.ID, r.workers)
+		lanes[lane] = append(lanes[lane], event)
+	}
+
+	errs := make(chan error, len(lanes))
+	var wg sync.WaitGroup
+	for _, lane := range lanes {
+		if len(lane) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(lane []*models.DeviceEvent) {
+			defer wg.Done()
+			for _, event := range lane {
+				if err := r.projection.ApplyDeviceEvent(ctx, event); err != nil {
+					errs <- fmt.Errorf("failed to apply %s event of device %s: %w", event.Type, event.Device.ID, err)
+					return
+				}
+			}
+		}(lane)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func laneOf(deviceID string, lanes int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(deviceID))
+	return int(hash.Sum32() % uint32(lanes))
+}
+
+func (r *Relay) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.checkpoints.ReleaseOutboxCheckpoint(ctx, repository.DeviceProjectionCheckpoint, r.owner); err != nil {
+		log.Printf("Failed to release outbox checkpoint: %v", err)
+	}
+}
+
+// backoff returns how long to wait after the current run of failed passes
+func (r *Relay) backoff() time.Duration {
+	r.mu.Lock()
+	failures := r.stats.ConsecutiveFailures
+	r.mu.Unlock()
+
+	wait := r.interval
+	for i := 1; i < failures && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// Stats returns the progress of the relay
+func (r *Relay) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	if !r.oldestPending.IsZero() {
+		stats.LagSeconds = time.Since(r.oldestPending).Seconds()
+	}
+	return stats
+}
+
+func (r *Relay) recordFollower() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Leader = false
+	r.stats.ConsecutiveFailures = 0
+	r.stats.LastError = ""
+	r.oldestPending = time.Time{}
+}
+
+// recordPending notes when the oldest event not yet applied occurred
+func (r *Relay) recordPending(occurredAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Leader = true
+	r.oldestPending = occurredAt
+}
+
+func (r *Relay) recordApplied(position models.EventPosition, applied int, more bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Leader = true
+	r.stats.Position = position
+	r.stats.AppliedTotal += int64(applied)
+	r.stats.ConsecutiveFailures = 0
+	r.stats.LastError = ""
+	if !more {
+		r.oldestPending = time.Time{}
+	}
+}
+
+func (r *Relay) recordFailure(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.FailedPassesTotal++
+	r.stats.ConsecutiveFailures++
+	r.stats.LastError = err.Error()
+}
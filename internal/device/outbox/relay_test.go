@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEventRepository is an in-memory device event log
+type fakeEventRepository struct {
+	repository.DeviceEventRepository
+	events []*models.DeviceEvent
+}
+
+func (r *fakeEventRepository) append(eventType models.DeviceEventType, device models.Device, occurredAt time.Time) {
+	seq := int64(len(r.events) + 1)
+	r.events = append(r.events, &models.DeviceEvent{
+		Position:   models.EventPosition{TxID: uint64(seq), Seq: seq},
+		Type:       eventType,
+		Device:     device,
+		OccurredAt: occurredAt,
+	})
+}
+
+func (r *fakeEventRepository) ListDeviceEvents(ctx context.Context, after models.EventPosition, owners repository.Owners, limit int) ([]*models.DeviceEvent, error) {
+	var events []*models.DeviceEvent
+	for _, event := range r.events {
+		if event.Position.After(after) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// fakeCheckpoints keeps checkpoints in memory. Leases never expire.
+type fakeCheckpoints struct {
+	mu        sync.Mutex
+	owners    map[string]string
+	positions map[string]models.EventPosition
+}
+
+func newFakeCheckpoints() *fakeCheckpoints {
+	return &fakeCheckpoints{owners: map[string]string{}, positions: map[string]models.EventPosition{}}
+}
+
+func (c *fakeCheckpoints) AcquireOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (models.EventPosition, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current, ok := c.owners[name]; ok && current != owner {
+		return models.EventPosition{}, false, nil
+	}
+	c.owners[name] = owner
+	return c.positions[name], true, nil
+}
+
+func (c *fakeCheckpoints) GetOutboxCheckpoint(ctx context.Context, name string) (models.EventPosition, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.positions[name], nil
+}
+
+func (c *fakeCheckpoints) SaveOutboxCheckpoint(ctx context.Context, name, owner string, position models.EventPosition) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.owners[name] != owner {
+		return repository.ErrLeaseLost
+	}
+	c.positions[name] = position
+	return nil
+}
+
+func (c *fakeCheckpoints) TakeOverOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (models.EventPosition, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.owners[name] = owner
+	return c.positions[name], nil
+}
+
+func (c *fakeCheckpoints) ReleaseOutboxCheckpoint(ctx context.Context, name, owner string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.owners[name] == owner {
+		delete(c.owners, name)
+	}
+	return nil
+}
+
+// fakeProjection records the events applied to each device. It fails the
+// first failures calls.
+type fakeProjection struct {
+	mu       sync.Mutex
+	failures int
+	applied  map[string][]int64
+}
+
+func (p *fakeProjection) ApplyDeviceEvent(ctx context.Context, event *models.DeviceEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("mongo unavailable")
+	}
+	if p.applied == nil {
+		p.applied = map[string][]int64{}
+	}
+	p.applied[event.Device.ID] = append(p.applied[event.Device.ID], event.Position.Seq)
+	return nil
+}
+
+func TestRelayAppliesEventsInOrderPerDevice(t *testing.T) {
+	events := &fakeEventRepository{}
+	for i := 0; i < 30; i++ {
+		device := models.Device{ID: fmt.Sprintf("device-%d", i%3)}
+		events.append(models.DeviceEventUpdated, device, time.Now())
+	}
+	checkpoints := newFakeCheckpoints()
+	projection := &fakeProjection{}
+	relay := NewRelay(events, checkpoints, projection, time.Second)
+	relay.batchSize = 7
+
+	require.NoError(t, relay.Tick(context.Background()))
+
+	for i := 0; i < 3; i++ {
+		var want []int64
+		for seq := int64(i + 1); seq <= 30; seq += 3 {
+			want = append(want, seq)
+		}
+		assert.Equal(t, want, projection.applied[fmt.Sprintf("device-%d", i)])
+	}
+	assert.Equal(t, models.EventPosition{TxID: 30, Seq: 30}, checkpoints.positions[repository.DeviceProjectionCheckpoint])
+
+	stats := relay.Stats()
+	assert.True(t, stats.Leader)
+	assert.Equal(t, int64(30), stats.AppliedTotal)
+	assert.Zero(t, stats.LagSeconds)
+
+	// Another instance waits for the lease instead of applying the events again
+	follower := NewRelay(events, checkpoints, projection, time.Second)
+	events.append(models.DeviceEventDeleted, models.Device{ID: "device-0"}, time.Now())
+	require.NoError(t, follower.Tick(context.Background()))
+	assert.False(t, follower.Stats().Leader)
+	assert.Len(t, projection.applied["device-0"], 10)
+}
+
+func TestRelayRetriesFailedPassFromCheckpoint(t *testing.T) {
+	events := &fakeEventRepository{}
+	events.append(models.DeviceEventCreated, models.Device{ID: "lamp"}, time.Now().Add(-time.Minute))
+	events.append(models.DeviceEventUpdated, models.Device{ID: "lamp"}, time.Now())
+	checkpoints := newFakeCheckpoints()
+	projection := &fakeProjection{failures: 1}
+	relay := NewRelay(events, checkpoints, projection, 100*time.Millisecond)
+
+	require.Error(t, relay.Tick(context.Background()))
+	stats := relay.Stats()
+	assert.Equal(t, int64(1), stats.FailedPassesTotal)
+	assert.Equal(t, 1, stats.ConsecutiveFailures)
+	assert.GreaterOrEqual(t, stats.LagSeconds, 60.0)
+	assert.Equal(t, models.EventPosition{}, checkpoints.positions[repository.DeviceProjectionCheckpoint])
+
+	require.NoError(t, relay.Tick(context.Background()))
+	assert.Equal(t, []int64{1, 2}, projection.applied["lamp"])
+	stats = relay.Stats()
+	assert.Zero(t, stats.ConsecutiveFailures)
+	assert.Zero(t, stats.LagSeconds)
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(nil, nil, nil, time.Second)
+	for failures, want := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second} {
+		relay.stats.ConsecutiveFailures = failures
+		assert.Equal(t, want, relay.backoff())
+	}
+	relay.stats.ConsecutiveFailures = 50
+	assert.Equal(t, maxBackoff, relay.backoff())
+}
@@ -2,11 +2,16 @@ package query
 
 import (
 	"context"
+	"errors"
 
 	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 )
 
+// ErrInvalidConsistency is returned for a consistency that is not "eventual"
+// or "strong", or a malformed consistency token
+var ErrInvalidConsistency = errors.New("invalid consistency")
+
 type GetDeviceHandler struct {
 	repo repository.QueryDeviceRepository
 }
@@ -16,9 +21,10 @@ func NewGetDeviceHandler(repo repository.QueryDeviceRepository) *GetDeviceHandle
 }
 
 func (h *GetDeviceHandler) Handle(ctx context.Context, query GetDeviceQuery) (*models.Device, error) {
-	return h.repo.GetDevice(ctx, query.ID)
+	return h.repo.GetDevice(ctx, query.ID, query.Consistency)
 }
 
 type GetDeviceQuery struct {
-	ID string
+	ID          string
+	Consistency repository.Consistency
 }
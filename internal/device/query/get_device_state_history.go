@@ -0,0 +1,56 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const (
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 500
+)
+
+// ErrInvalidTimeRange is returned when a history query ends before it starts
+var ErrInvalidTimeRange = errors.New("invalid time range: from is after to")
+
+type GetDeviceStateHistoryHandler struct {
+	repo repository.QueryDeviceRepository
+}
+
+func NewGetDeviceStateHistoryHandler(repo repository.QueryDeviceRepository) *GetDeviceStateHistoryHandler {
+	return &GetDeviceStateHistoryHandler{repo: repo}
+}
+
+// GetDeviceStateHistoryQuery selects the history of a device between From and
+// To. A zero To means now.
+type GetDeviceStateHistoryQuery struct {
+	DeviceID string
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+func (h *GetDeviceStateHistoryHandler) Handle(ctx context.Context, query GetDeviceStateHistoryQuery) ([]*models.DeviceState, int, error) {
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.After(query.To) {
+		return nil, 0, ErrInvalidTimeRange
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultHistoryPageSize
+	}
+	if query.PageSize > maxHistoryPageSize {
+		query.PageSize = maxHistoryPageSize
+	}
+
+	return h.repo.ListDeviceStateHistory(ctx, query.DeviceID, query.From, query.To, query.Page, query.PageSize)
+}
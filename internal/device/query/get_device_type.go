@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type GetDeviceTypeHandler struct {
+	repo repository.DeviceTypeRepository
+}
+
+func NewGetDeviceTypeHandler(repo repository.DeviceTypeRepository) *GetDeviceTypeHandler {
+	return &GetDeviceTypeHandler{repo: repo}
+}
+
+func (h *GetDeviceTypeHandler) Handle(ctx context.Context, query GetDeviceTypeQuery) (*models.DeviceType, error) {
+	return h.repo.GetDeviceType(ctx, query.ID)
+}
+
+type GetDeviceTypeQuery struct {
+	ID string
+}
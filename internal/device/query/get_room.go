@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type GetRoomHandler struct {
+	repo repository.RoomRepository
+}
+
+func NewGetRoomHandler(repo repository.RoomRepository) *GetRoomHandler {
+	return &GetRoomHandler{repo: repo}
+}
+
+func (h *GetRoomHandler) Handle(ctx context.Context, query GetRoomQuery) (*models.Room, error) {
+	return h.repo.GetRoom(ctx, query.ID)
+}
+
+type GetRoomQuery struct {
+	ID string
+}
@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type GetRuleHandler struct {
+	repo repository.RuleRepository
+}
+
+func NewGetRuleHandler(repo repository.RuleRepository) *GetRuleHandler {
+	return &GetRuleHandler{repo: repo}
+}
+
+func (h *GetRuleHandler) Handle(ctx context.Context, query GetRuleQuery) (*models.Rule, error) {
+	return h.repo.GetRule(ctx, query.ID)
+}
+
+type GetRuleQuery struct {
+	ID string
+}
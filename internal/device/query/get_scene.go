@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type GetSceneHandler struct {
+	repo repository.SceneRepository
+}
+
+func NewGetSceneHandler(repo repository.SceneRepository) *GetSceneHandler {
+	return &GetSceneHandler{repo: repo}
+}
+
+func (h *GetSceneHandler) Handle(ctx context.Context, query GetSceneQuery) (*models.Scene, error) {
+	return h.repo.GetScene(ctx, query.ID)
+}
+
+type GetSceneQuery struct {
+	ID string
+}
@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type GetScheduleHandler struct {
+	repo repository.ScheduleRepository
+}
+
+func NewGetScheduleHandler(repo repository.ScheduleRepository) *GetScheduleHandler {
+	return &GetScheduleHandler{repo: repo}
+}
+
+func (h *GetScheduleHandler) Handle(ctx context.Context, query GetScheduleQuery) (*models.Schedule, error) {
+	return h.repo.GetSchedule(ctx, query.ID)
+}
+
+type GetScheduleQuery struct {
+	ID string
+}
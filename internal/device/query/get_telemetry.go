@@ -0,0 +1,100 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const (
+	// defaultTelemetryRange is how far back a telemetry query without a start goes
+	defaultTelemetryRange = 24 * time.Hour
+	defaultTelemetryLimit = 1000
+	maxTelemetryLimit     = 10000
+	minTelemetryInterval  = time.Second
+	maxTelemetryBuckets   = 10000
+)
+
+// ErrInvalidTelemetryQuery is returned when a telemetry query names no metric
+// or asks for buckets that cannot be computed
+var ErrInvalidTelemetryQuery = errors.New("invalid telemetry query")
+
+type GetTelemetryHandler struct {
+	repo repository.TelemetryRepository
+}
+
+func NewGetTelemetryHandler(repo repository.TelemetryRepository) *GetTelemetryHandler {
+	return &GetTelemetryHandler{repo: repo}
+}
+
+// GetTelemetryQuery selects the readings of a metric of a device between From
+// and To. A zero To means now, and a zero From a day before To. With an
+// Interval, the readings are summarized in buckets of that length; otherwise
+// up to Limit readings are returned.
+type GetTelemetryQuery struct {
+	DeviceID string
+	Metric   string
+	From     time.Time
+	To       time.Time
+	Interval time.Duration
+	Limit    int
+}
+
+// TelemetryResult holds either the readings or the buckets of a query.
+// Truncated is set when more readings than the limit fall in the range.
+type TelemetryResult struct {
+	Points    []*models.TelemetryPoint
+	Buckets   []*models.TelemetryBucket
+	Truncated bool
+}
+
+func (h *GetTelemetryHandler) Handle(ctx context.Context, query GetTelemetryQuery) (*TelemetryResult, error) {
+	if query.Metric == "" {
+		return nil, fmt.Errorf("%w: a metric is required", ErrInvalidTelemetryQuery)
+	}
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-defaultTelemetryRange)
+	}
+	if query.From.After(query.To) {
+		return nil, ErrInvalidTimeRange
+	}
+	series := repository.TelemetryRange{DeviceID: query.DeviceID, Metric: query.Metric, From: query.From, To: query.To}
+
+	if query.Interval != 0 {
+		if query.Interval < minTelemetryInterval {
+			return nil, fmt.Errorf("%w: the interval must be at least %s", ErrInvalidTelemetryQuery, minTelemetryInterval)
+		}
+		if query.To.Sub(query.From)/query.Interval > maxTelemetryBuckets {
+			return nil, fmt.Errorf("%w: the range spans more than %d intervals", ErrInvalidTelemetryQuery, maxTelemetryBuckets)
+		}
+		buckets, err := h.repo.AggregateTelemetry(ctx, series, query.Interval)
+		if err != nil {
+			return nil, err
+		}
+		return &TelemetryResult{Buckets: buckets}, nil
+	}
+
+	if query.Limit < 1 {
+		query.Limit = defaultTelemetryLimit
+	}
+	if query.Limit > maxTelemetryLimit {
+		query.Limit = maxTelemetryLimit
+	}
+	// One reading beyond the limit tells whether the range holds more
+	points, err := h.repo.ListTelemetry(ctx, series, query.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	result := &TelemetryResult{Points: points}
+	if len(points) > query.Limit {
+		result.Points, result.Truncated = points[:query.Limit], true
+	}
+	return result, nil
+}
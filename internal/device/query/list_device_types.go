@@ -0,0 +1,22 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type ListDeviceTypesHandler struct {
+	repo repository.DeviceTypeRepository
+}
+
+func NewListDeviceTypesHandler(repo repository.DeviceTypeRepository) *ListDeviceTypesHandler {
+	return &ListDeviceTypesHandler{repo: repo}
+}
+
+type ListDeviceTypesQuery struct{}
+
+func (h *ListDeviceTypesHandler) Handle(ctx context.Context, _ ListDeviceTypesQuery) ([]*models.DeviceType, error) {
+	return h.repo.ListDeviceTypes(ctx)
+}
@@ -2,25 +2,181 @@ package query
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 )
 
+const (
+	defaultDevicePageSize = 50
+	maxDevicePageSize     = 500
+)
+
+// ErrInvalidDeviceFilter is returned when a device list query has a filter or
+// order that cannot be applied
+var ErrInvalidDeviceFilter = errors.New("invalid device filter")
+
 type ListDevicesHandler struct {
-	repo repository.QueryDeviceRepository
+	repo   repository.QueryDeviceRepository
+	tokens *PageTokens
 }
 
-func NewListDevicesHandler(repo repository.QueryDeviceRepository) *ListDevicesHandler {
-	return &ListDevicesHandler{repo: repo}
+// NewListDevicesHandler creates the handler. It signs page tokens with tokens.
+func NewListDevicesHandler(repo repository.QueryDeviceRepository, tokens *PageTokens) *ListDevicesHandler {
+	return &ListDevicesHandler{repo: repo, tokens: tokens}
 }
 
+// ListDevicesQuery selects the devices of Owners that match every filter that
+// is set, in the order of Sort. The first page is followed by the pages of
+// the tokens returned with each page.
 type ListDevicesQuery struct {
-	UserID   string
-	Page     int
-	PageSize int
+	Owners        repository.Owners
+	RoomID        string
+	Type          string
+	NameContains  string
+	State         map[string]models.StateValue
+	LabelSelector string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	Sort          repository.DeviceSort
+	Consistency   repository.Consistency
+	PageToken     string
+	PageSize      int
+	// IncludeTotal asks for the number of devices that match
+	IncludeTotal bool
+	// Page selects a page by number instead of a token.
+	//
+	// Deprecated: pages by number shift when devices are written while a
+	// client pages through them. The total is always counted for them.
+	Page int
+}
+
+// DeviceList is a page of devices
+type DeviceList struct {
+	Devices []*models.Device
+	// Total is the number of devices that match, if it was asked for
+	Total int
+	// NextPageToken selects the following page. It is empty on the last page.
+	NextPageToken string
+}
+
+func (h *ListDevicesHandler) Handle(ctx context.Context, query ListDevicesQuery) (*DeviceList, error) {
+	if err := validateTimeRange("created", query.CreatedAfter, query.CreatedBefore); err != nil {
+		return nil, err
+	}
+	if err := validateTimeRange("updated", query.UpdatedAfter, query.UpdatedBefore); err != nil {
+		return nil, err
+	}
+	for name := range query.State {
+		if !models.AttributeNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%w: invalid state attribute name %q", ErrInvalidDeviceFilter, name)
+		}
+	}
+	selector, err := labels.Parse(query.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDeviceFilter, err)
+	}
+	if query.Sort.Field != "" && !query.Sort.Field.Valid() {
+		return nil, fmt.Errorf("%w: devices cannot be sorted by %q", ErrInvalidDeviceFilter, query.Sort.Field)
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultDevicePageSize
+	}
+	if query.PageSize > maxDevicePageSize {
+		query.PageSize = maxDevicePageSize
+	}
+
+	filter := repository.DeviceFilter{
+		Owners:        query.Owners,
+		RoomID:        query.RoomID,
+		Type:          query.Type,
+		NameContains:  query.NameContains,
+		State:         query.State,
+		Labels:        selector,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		UpdatedAfter:  query.UpdatedAfter,
+		UpdatedBefore: query.UpdatedBefore,
+	}
+	key := queryKey(filter, query.Sort)
+
+	// One device more than asked for tells whether another page follows
+	page := repository.DevicePage{Limit: query.PageSize + 1, CountTotal: query.IncludeTotal}
+	switch {
+	case query.PageToken != "" && query.Page > 0:
+		return nil, fmt.Errorf("%w: page and page_token cannot be combined", ErrInvalidPageToken)
+	case query.PageToken != "":
+		after, err := h.tokens.Decode(key, query.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		page.After = &after
+	case query.Page > 0:
+		page.Offset = (query.Page - 1) * query.PageSize
+		page.CountTotal = true
+	}
+
+	devices, total, err := h.repo.ListDevices(ctx, filter, query.Sort, query.Consistency, page)
+	if err != nil {
+		return nil, err
+	}
+	list := &DeviceList{Devices: devices, Total: total}
+	if len(devices) > query.PageSize {
+		list.Devices = devices[:query.PageSize]
+		list.NextPageToken = h.tokens.Encode(key, repository.CursorOf(list.Devices[query.PageSize-1], query.Sort))
+	}
+	return list, nil
+}
+
+// queryKey identifies the filters and order of a device list query, so that
+// page tokens are only accepted for the query they were issued for. The
+// owners are left out: they are derived from the caller on every request
+// and may change as they join and leave households.
+func queryKey(filter repository.DeviceFilter, sort repository.DeviceSort) []byte {
+	filter.Owners = repository.Owners{}
+	encoded, _ := json.Marshal(struct {
+		Filter repository.DeviceFilter
+		Sort   repository.DeviceSort
+	}{filter, sort})
+	sum := sha256.Sum256(encoded)
+	return sum[:8]
+}
+
+// ParseDeviceSort parses an order such as "name" or "updated_at desc". An
+// empty order is the default one.
+func ParseDeviceSort(orderBy string) (repository.DeviceSort, error) {
+	fields := strings.Fields(orderBy)
+	if len(fields) == 0 {
+		return repository.DeviceSort{}, nil
+	}
+	sort := repository.DeviceSort{Field: repository.DeviceSortField(fields[0])}
+	if !sort.Field.Valid() {
+		return repository.DeviceSort{}, fmt.Errorf("%w: devices cannot be sorted by %q, expected created_at, updated_at or name", ErrInvalidDeviceFilter, fields[0])
+	}
+	switch {
+	case len(fields) == 1:
+	case len(fields) == 2 && strings.EqualFold(fields[1], "asc"):
+	case len(fields) == 2 && strings.EqualFold(fields[1], "desc"):
+		sort.Descending = true
+	default:
+		return repository.DeviceSort{}, fmt.Errorf("%w: invalid order %q, expected a field optionally followed by asc or desc", ErrInvalidDeviceFilter, orderBy)
+	}
+	return sort, nil
 }
 
-func (h *ListDevicesHandler) Handle(ctx context.Context, query ListDevicesQuery) ([]*models.Device, int, error) {
-	return h.repo.ListDevices(ctx, query.UserID, query.Page, query.PageSize)
+// validateTimeRange checks that a range of the named time does not end before it starts
+func validateTimeRange(name string, after, before time.Time) error {
+	if !after.IsZero() && !before.IsZero() && after.After(before) {
+		return fmt.Errorf("%w: %s_after is after %s_before", ErrInvalidDeviceFilter, name, name)
+	}
+	return nil
 }
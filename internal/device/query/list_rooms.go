@@ -0,0 +1,25 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type ListRoomsHandler struct {
+	repo repository.RoomRepository
+}
+
+func NewListRoomsHandler(repo repository.RoomRepository) *ListRoomsHandler {
+	return &ListRoomsHandler{repo: repo}
+}
+
+// ListRoomsQuery lists the rooms of the given owners; zero Owners lists every room
+type ListRoomsQuery struct {
+	Owners repository.Owners
+}
+
+func (h *ListRoomsHandler) Handle(ctx context.Context, query ListRoomsQuery) ([]*models.Room, error) {
+	return h.repo.ListRooms(ctx, query.Owners)
+}
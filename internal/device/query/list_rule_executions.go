@@ -0,0 +1,44 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const (
+	defaultRuleExecutionsPageSize = 50
+	maxRuleExecutionsPageSize     = 500
+)
+
+type ListRuleExecutionsHandler struct {
+	repo repository.RuleRepository
+}
+
+func NewListRuleExecutionsHandler(repo repository.RuleRepository) *ListRuleExecutionsHandler {
+	return &ListRuleExecutionsHandler{repo: repo}
+}
+
+type ListRuleExecutionsQuery struct {
+	RuleID   string
+	Page     int
+	PageSize int
+}
+
+func (h *ListRuleExecutionsHandler) Handle(ctx context.Context, query ListRuleExecutionsQuery) ([]*models.RuleExecution, int, error) {
+	if _, err := h.repo.GetRule(ctx, query.RuleID); err != nil {
+		return nil, 0, err
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultRuleExecutionsPageSize
+	}
+	if query.PageSize > maxRuleExecutionsPageSize {
+		query.PageSize = maxRuleExecutionsPageSize
+	}
+
+	return h.repo.ListRuleExecutions(ctx, query.RuleID, query.Page, query.PageSize)
+}
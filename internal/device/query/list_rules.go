@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type ListRulesHandler struct {
+	repo repository.RuleRepository
+}
+
+func NewListRulesHandler(repo repository.RuleRepository) *ListRulesHandler {
+	return &ListRulesHandler{repo: repo}
+}
+
+type ListRulesQuery struct {
+	UserID string
+}
+
+func (h *ListRulesHandler) Handle(ctx context.Context, query ListRulesQuery) ([]*models.Rule, error) {
+	return h.repo.ListRules(ctx, query.UserID)
+}
@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type ListScenesHandler struct {
+	repo repository.SceneRepository
+}
+
+func NewListScenesHandler(repo repository.SceneRepository) *ListScenesHandler {
+	return &ListScenesHandler{repo: repo}
+}
+
+type ListScenesQuery struct {
+	UserID string
+}
+
+func (h *ListScenesHandler) Handle(ctx context.Context, query ListScenesQuery) ([]*models.Scene, error) {
+	return h.repo.ListScenes(ctx, query.UserID)
+}
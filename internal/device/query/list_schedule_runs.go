@@ -0,0 +1,44 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const (
+	defaultScheduleRunsPageSize = 50
+	maxScheduleRunsPageSize     = 500
+)
+
+type ListScheduleRunsHandler struct {
+	repo repository.ScheduleRepository
+}
+
+func NewListScheduleRunsHandler(repo repository.ScheduleRepository) *ListScheduleRunsHandler {
+	return &ListScheduleRunsHandler{repo: repo}
+}
+
+type ListScheduleRunsQuery struct {
+	ScheduleID string
+	Page       int
+	PageSize   int
+}
+
+func (h *ListScheduleRunsHandler) Handle(ctx context.Context, query ListScheduleRunsQuery) ([]*models.ScheduleRun, int, error) {
+	if _, err := h.repo.GetSchedule(ctx, query.ScheduleID); err != nil {
+		return nil, 0, err
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultScheduleRunsPageSize
+	}
+	if query.PageSize > maxScheduleRunsPageSize {
+		query.PageSize = maxScheduleRunsPageSize
+	}
+
+	return h.repo.ListScheduleRuns(ctx, query.ScheduleID, query.Page, query.PageSize)
+}
@@ -0,0 +1,26 @@
+package query
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type ListSchedulesHandler struct {
+	repo repository.ScheduleRepository
+}
+
+func NewListSchedulesHandler(repo repository.ScheduleRepository) *ListSchedulesHandler {
+	return &ListSchedulesHandler{repo: repo}
+}
+
+type ListSchedulesQuery struct {
+	UserID   string
+	DeviceID string
+}
+
+func (h *ListSchedulesHandler) Handle(ctx context.Context, query ListSchedulesQuery) ([]*models.Schedule, error) {
+	filter := repository.ScheduleFilter{UserID: query.UserID, DeviceID: query.DeviceID}
+	return h.repo.ListSchedules(ctx, filter)
+}
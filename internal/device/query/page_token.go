@@ -0,0 +1,73 @@
+package query
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+)
+
+// ErrInvalidPageToken is returned for page tokens that were not issued by
+// PageTokens with the same key, or that were issued for another query
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// PageTokens issues the tokens of device list pages. A token holds the
+// position of the last device of a page, and is signed so that clients
+// cannot forge positions. Every instance of the service must use the same
+// key to accept each other's tokens.
+type PageTokens struct {
+	key []byte
+}
+
+func NewPageTokens(key []byte) *PageTokens {
+	return &PageTokens{key: key}
+}
+
+// pageTokenPayload is the content of a page token
+type pageTokenPayload struct {
+	// Query identifies the filters and order the token was issued for
+	Query []byte    `json:"q"`
+	Time  time.Time `json:"t"`
+	Name  string    `json:"n,omitempty"`
+	ID    string    `json:"id"`
+}
+
+// Encode returns the token of the page after cursor in a query
+func (p *PageTokens) Encode(query []byte, cursor repository.DeviceCursor) string {
+	payload, _ := json.Marshal(pageTokenPayload{Query: query, Time: cursor.Time, Name: cursor.Name, ID: cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload))
+}
+
+// Decode returns the cursor a token of a query holds
+func (p *PageTokens) Decode(query []byte, token string) (repository.DeviceCursor, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return repository.DeviceCursor{}, ErrInvalidPageToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return repository.DeviceCursor{}, ErrInvalidPageToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, p.sign(payload)) {
+		return repository.DeviceCursor{}, ErrInvalidPageToken
+	}
+
+	var decoded pageTokenPayload
+	if err := json.Unmarshal(payload, &decoded); err != nil || !bytes.Equal(decoded.Query, query) {
+		return repository.DeviceCursor{}, ErrInvalidPageToken
+	}
+	return repository.DeviceCursor{Time: decoded.Time, Name: decoded.Name, ID: decoded.ID}, nil
+}
+
+func (p *PageTokens) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
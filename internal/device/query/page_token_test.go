@@ -0,0 +1,99 @@
+package query
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	tokens := NewPageTokens([]byte("secret"))
+	key := queryKey(repository.DeviceFilter{Type: "light"}, repository.DeviceSort{})
+	cursor := repository.DeviceCursor{Time: time.Date(2024, 7, 3, 12, 0, 0, 123000000, time.UTC), ID: "lamp"}
+
+	decoded, err := tokens.Decode(key, tokens.Encode(key, cursor))
+
+	assert.NoError(t, err)
+	assert.True(t, cursor.Time.Equal(decoded.Time))
+	assert.Equal(t, cursor.ID, decoded.ID)
+}
+
+func TestPageTokensAreBoundToTheirQuery(t *testing.T) {
+	tokens := NewPageTokens([]byte("secret"))
+	filter := repository.DeviceFilter{Type: "light", Owners: repository.Owners{UserID: "alice"}}
+	sort := repository.DeviceSort{Field: repository.SortByName}
+	token := tokens.Encode(queryKey(filter, sort), repository.DeviceCursor{Name: "Lamp", ID: "lamp"})
+
+	// Owners are derived from the caller and may change between pages
+	otherOwners := filter
+	otherOwners.Owners = repository.Owners{UserID: "alice", HouseholdIDs: []string{"home"}}
+	_, err := tokens.Decode(queryKey(otherOwners, sort), token)
+	assert.NoError(t, err)
+
+	otherFilter := filter
+	otherFilter.Type = "switch"
+	_, err = tokens.Decode(queryKey(otherFilter, sort), token)
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+
+	_, err = tokens.Decode(queryKey(filter, repository.DeviceSort{Field: repository.SortByName, Descending: true}), token)
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+	_, err = tokens.Decode(queryKey(filter, repository.DeviceSort{Field: repository.SortByCreatedAt}), token)
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+}
+
+func TestTamperedPageTokensAreRejected(t *testing.T) {
+	tokens := NewPageTokens([]byte("secret"))
+	key := queryKey(repository.DeviceFilter{}, repository.DeviceSort{})
+	token := tokens.Encode(key, repository.DeviceCursor{Time: time.Now(), ID: "lamp"})
+	encodedPayload, signature, _ := strings.Cut(token, ".")
+
+	// A client moving the cursor has to sign the new position
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	assert.NoError(t, err)
+	forged := strings.Replace(string(payload), `"id":"lamp"`, `"id":"zzzz"`, 1)
+	assert.NotEqual(t, string(payload), forged)
+
+	for _, tampered := range []string{
+		base64.RawURLEncoding.EncodeToString([]byte(forged)) + "." + signature,
+		encodedPayload + "." + signature[1:],
+		encodedPayload,
+		"not a token",
+		NewPageTokens([]byte("other secret")).Encode(key, repository.DeviceCursor{ID: "lamp"}),
+	} {
+		_, err := tokens.Decode(key, tampered)
+		assert.ErrorIs(t, err, ErrInvalidPageToken, tampered)
+	}
+}
+
+func TestCursorTimesHaveMillisecondPrecision(t *testing.T) {
+	// SQL keeps microseconds, the read model milliseconds
+	createdAt := time.Date(2024, 7, 3, 12, 0, 0, 123456000, time.UTC)
+	device := &models.Device{ID: "lamp", CreatedAt: createdAt}
+
+	cursor := repository.CursorOf(device, repository.DeviceSort{})
+
+	assert.Equal(t, time.Date(2024, 7, 3, 12, 0, 0, 123000000, time.UTC), cursor.Time)
+}
+
+func TestParseDeviceSort(t *testing.T) {
+	for orderBy, want := range map[string]repository.DeviceSort{
+		"":                {},
+		"name":            {Field: repository.SortByName},
+		"created_at asc":  {Field: repository.SortByCreatedAt},
+		"updated_at DESC": {Field: repository.SortByUpdatedAt, Descending: true},
+	} {
+		sort, err := ParseDeviceSort(orderBy)
+		assert.NoError(t, err, orderBy)
+		assert.Equal(t, want, sort, orderBy)
+	}
+
+	for _, orderBy := range []string{"state", "name sideways", "name desc extra"} {
+		_, err := ParseDeviceSort(orderBy)
+		assert.ErrorIs(t, err, ErrInvalidDeviceFilter, orderBy)
+	}
+}
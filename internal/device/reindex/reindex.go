@@ -0,0 +1,374 @@
+// Package reindex compares the Mongo device projection with the SQL write
+// model and repairs or rebuilds it.
+//
+// It is safe to run against a live system. While it runs it holds the lease of
+// the outbox relay's checkpoint, so relays keep applying events but cannot move
+// the checkpoint. Once the lease is released they replay every event since,
+// which brings any device that changed during the run up to date again.
+package reindex
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBatchSize = 500
+	lease            = time.Minute
+)
+
+// Mode selects what a run does about the differences it finds
+type Mode string
+
+const (
+	// ModeCheck only reports differences
+	ModeCheck Mode = "check"
+	// ModeRepair rewrites missing and mismatched documents and removes extra ones
+	ModeRepair Mode = "repair"
+	// ModeRebuild rewrites every document from the write model, and removes
+	// extra ones
+	ModeRebuild Mode = "rebuild"
+)
+
+// ParseMode validates a mode given on the command line
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case ModeCheck, ModeRepair, ModeRebuild:
+		return Mode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown mode %q, expected check, repair or rebuild", mode)
+	}
+}
+
+// DeviceScanner lists devices in ID order
+type DeviceScanner interface {
+	// ScanDevices returns up to limit devices with an ID greater than afterID
+	ScanDevices(ctx context.Context, afterID string, limit int) ([]*models.Device, error)
+}
+
+// Source is the write model the projection is compared with
+type Source interface {
+	DeviceScanner
+	LoadDevice(ctx context.Context, id string) (*models.Device, error)
+}
+
+// Projection is the read model being checked and repaired
+type Projection interface {
+	DeviceScanner
+	ReplaceDevice(ctx context.Context, device *models.Device) error
+	RemoveDevice(ctx context.Context, id string) error
+}
+
+// Mismatch is a device whose document differs from the write model
+type Mismatch struct {
+	ID     string   `json:"id"`
+	Fields []string `json:"fields"`
+}
+
+// Report is the outcome of a run. Missing devices are in the write model only,
+// extra ones in the read model only. Pending devices differ as well, but have
+// events the relay has yet to apply, so they are not counted as drift and
+// left to the relay.
+type Report struct {
+	Mode         Mode       `json:"mode"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   time.Time  `json:"finished_at"`
+	SQLDevices   int        `json:"sql_devices"`
+	MongoDevices int        `json:"mongo_devices"`
+	Missing      []string   `json:"missing"`
+	Extra        []string   `json:"extra"`
+	Mismatched   []Mismatch `json:"mismatched"`
+	Pending      []string   `json:"pending"`
+	Repaired     int        `json:"repaired"`
+}
+
+// Drift reports whether the projection differed from the write model
+func (r *Report) Drift() bool {
+	return len(r.Missing) > 0 || len(r.Extra) > 0 || len(r.Mismatched) > 0
+}
+
+// Reconciler compares and repairs the device projection
+type Reconciler struct {
+	source      Source
+	projection  Projection
+	events      repository.DeviceEventRepository
+	checkpoints repository.OutboxCheckpointRepository
+	owner       string
+	batchSize   int
+}
+
+func NewReconciler(source Source, projection Projection, events repository.DeviceEventRepository, checkpoints repository.OutboxCheckpointRepository) *Reconciler {
+	owner := "reindex/" + uuid.NewString()
+	if hostname, err := os.Hostname(); err == nil {
+		owner = "reindex/" + hostname + "/" + uuid.NewString()
+	}
+	return &Reconciler{
+		source:      source,
+		projection:  projection,
+		events:      events,
+		checkpoints: checkpoints,
+		owner:       owner,
+		batchSize:   defaultBatchSize,
+	}
+}
+
+// Run compares both stores and, depending on mode, repairs the projection
+func (r *Reconciler) Run(ctx context.Context, mode Mode) (*Report, error) {
+	report := &Report{
+		Mode:       mode,
+		StartedAt:  time.Now().UTC(),
+		Missing:    []string{},
+		Extra:      []string{},
+		Mismatched: []Mismatch{},
+		Pending:    []string{},
+	}
+
+	checkpoint, err := r.checkpoints.TakeOverOutboxCheckpoint(ctx, repository.DeviceProjectionCheckpoint, r.owner, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over the outbox checkpoint: %w", err)
+	}
+	defer r.checkpoints.ReleaseOutboxCheckpoint(context.Background(), repository.DeviceProjectionCheckpoint, r.owner)
+
+	// Differences found by the scan, in ID order. In rebuild mode every
+	// device of the write model is rewritten, so matching ones are kept too.
+	var differing []string
+	var rewrite []string
+	sqlDevices := &pager{scanner: r.source, batchSize: r.batchSize, renew: r.renewLease}
+	mongoDevices := &pager{scanner: r.projection, batchSize: r.batchSize}
+	for {
+		sqlDevice, err := sqlDevices.peek(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SQL devices: %w", err)
+		}
+		mongoDevice, err := mongoDevices.peek(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan Mongo devices: %w", err)
+		}
+		if sqlDevice == nil && mongoDevice == nil {
+			break
+		}
+
+		switch {
+		case mongoDevice == nil || (sqlDevice != nil && sqlDevice.ID < mongoDevice.ID):
+			report.SQLDevices++
+			report.Missing = append(report.Missing, sqlDevice.ID)
+			differing = append(differing, sqlDevice.ID)
+			sqlDevices.pop()
+		case sqlDevice == nil || mongoDevice.ID < sqlDevice.ID:
+			report.MongoDevices++
+			report.Extra = append(report.Extra, mongoDevice.ID)
+			differing = append(differing, mongoDevice.ID)
+			mongoDevices.pop()
+		default:
+			report.SQLDevices++
+			report.MongoDevices++
+			if fields := diffDevices(sqlDevice, mongoDevice); len(fields) > 0 {
+				report.Mismatched = append(report.Mismatched, Mismatch{ID: sqlDevice.ID, Fields: fields})
+				differing = append(differing, sqlDevice.ID)
+			} else if mode == ModeRebuild {
+				rewrite = append(rewrite, sqlDevice.ID)
+			}
+			sqlDevices.pop()
+			mongoDevices.pop()
+		}
+	}
+
+	// Devices changed since the checkpoint are brought up to date by the relay
+	pending, err := r.pendingDevices(ctx, checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending device events: %w", err)
+	}
+	if len(pending) > 0 {
+		report.Missing = withoutPending(report.Missing, pending)
+		report.Extra = withoutPending(report.Extra, pending)
+		mismatched := report.Mismatched[:0]
+		for _, mismatch := range report.Mismatched {
+			if !pending[mismatch.ID] {
+				mismatched = append(mismatched, mismatch)
+			}
+		}
+		report.Mismatched = mismatched
+		for _, id := range differing {
+			if pending[id] {
+				report.Pending = append(report.Pending, id)
+			}
+		}
+		differing = withoutPending(differing, pending)
+		rewrite = withoutPending(rewrite, pending)
+	}
+
+	if mode != ModeCheck {
+		for _, id := range append(differing, rewrite...) {
+			if err := r.repair(ctx, id); err != nil {
+				return nil, fmt.Errorf("failed to repair device %s: %w", id, err)
+			}
+			report.Repaired++
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// repair copies a device from the write model as it is now, or removes it
+// from the projection if it no longer exists
+func (r *Reconciler) repair(ctx context.Context, id string) error {
+	if err := r.renewLease(ctx); err != nil {
+		return err
+	}
+	device, err := r.source.LoadDevice(ctx, id)
+	if err == repository.ErrDeviceNotFound {
+		return r.projection.RemoveDevice(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	return r.projection.ReplaceDevice(ctx, device)
+}
+
+// renewLease keeps the relays from moving the checkpoint during long runs
+func (r *Reconciler) renewLease(ctx context.Context) error {
+	_, ok, err := r.checkpoints.AcquireOutboxCheckpoint(ctx, repository.DeviceProjectionCheckpoint, r.owner, lease)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return repository.ErrLeaseLost
+	}
+	return nil
+}
+
+// pendingDevices returns the devices with events after the checkpoint
+func (r *Reconciler) pendingDevices(ctx context.Context, checkpoint models.EventPosition) (map[string]bool, error) {
+	pending := make(map[string]bool)
+	position := checkpoint
+	for {
+		events, err := r.events.ListDeviceEvents(ctx, position, repository.Owners{}, r.batchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			pending[event.Device.ID] = true
+		}
+		if len(events) < r.batchSize {
+			return pending, nil
+		}
+		position = events[len(events)-1].Position
+	}
+}
+
+func withoutPending(ids []string, pending map[string]bool) []string {
+	kept := ids[:0]
+	for _, id := range ids {
+		if !pending[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// diffDevices returns the fields in which the projection differs from the
+// write model. Timestamps are compared at the millisecond precision Mongo
+// stores.
+func diffDevices(want, got *models.Device) []string {
+	var fields []string
+	if want.Name != got.Name {
+		fields = append(fields, "name")
+	}
+	if want.Type != got.Type {
+		fields = append(fields, "type")
+	}
+	if !sameState(want.State, got.State) {
+		fields = append(fields, "state")
+	}
+	if want.UserID != got.UserID {
+		fields = append(fields, "user_id")
+	}
+	if want.RoomID != got.RoomID {
+		fields = append(fields, "room_id")
+	}
+	if want.HouseholdID != got.HouseholdID {
+		fields = append(fields, "household_id")
+	}
+	if !sameTime(want.CreatedAt, got.CreatedAt) {
+		fields = append(fields, "created_at")
+	}
+	if !sameTime(want.UpdatedAt, got.UpdatedAt) {
+		fields = append(fields, "updated_at")
+	}
+	if want.Version != got.Version {
+		fields = append(fields, "version")
+	}
+	// last_seen_at is only projected with changes of connectivity, so the
+	// projection trails the write model by design
+	if connectivity(want) != connectivity(got) {
+		fields = append(fields, "connectivity")
+	}
+	return fields
+}
+
+// connectivity reads devices projected before connectivity was tracked as
+// unknown
+func connectivity(device *models.Device) models.Connectivity {
+	if device.Connectivity == "" {
+		return models.ConnectivityUnknown
+	}
+	return device.Connectivity
+}
+
+func sameState(a, b map[string]models.StateValue) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func sameTime(a, b time.Time) bool {
+	return a.Truncate(time.Millisecond).Equal(b.Truncate(time.Millisecond))
+}
+
+// pager walks a DeviceScanner one device at a time
+type pager struct {
+	scanner   DeviceScanner
+	batchSize int
+	// renew is called before every page, if set
+	renew  func(ctx context.Context) error
+	buffer []*models.Device
+	after  string
+	done   bool
+}
+
+// peek returns the next device without consuming it, or nil at the end
+func (p *pager) peek(ctx context.Context) (*models.Device, error) {
+	if len(p.buffer) == 0 && !p.done {
+		if p.renew != nil {
+			if err := p.renew(ctx); err != nil {
+				return nil, err
+			}
+		}
+		devices, err := p.scanner.ScanDevices(ctx, p.after, p.batchSize)
+		if err != nil {
+			return nil, err
+		}
+		p.buffer = devices
+		p.done = len(devices) < p.batchSize
+		if len(devices) > 0 {
+			p.after = devices[len(devices)-1].ID
+		}
+	}
+	if len(p.buffer) == 0 {
+		return nil, nil
+	}
+	return p.buffer[0], nil
+}
+
+func (p *pager) pop() {
+	p.buffer = p.buffer[1:]
+}
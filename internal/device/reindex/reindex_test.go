@@ -0,0 +1,165 @@
+package reindex
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps devices in memory, for either side of the comparison
+type fakeStore struct {
+	devices map[string]*models.Device
+}
+
+func newFakeStore(devices ...*models.Device) *fakeStore {
+	store := &fakeStore{devices: map[string]*models.Device{}}
+	for _, device := range devices {
+		copied := *device
+		store.devices[device.ID] = &copied
+	}
+	return store
+}
+
+func (s *fakeStore) ScanDevices(ctx context.Context, afterID string, limit int) ([]*models.Device, error) {
+	var ids []string
+	for id := range s.devices {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	var devices []*models.Device
+	for _, id := range ids {
+		if len(devices) == limit {
+			break
+		}
+		devices = append(devices, s.devices[id])
+	}
+	return devices, nil
+}
+
+func (s *fakeStore) LoadDevice(ctx context.Context, id string) (*models.Device, error) {
+	device, ok := s.devices[id]
+	if !ok {
+		return nil, repository.ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+func (s *fakeStore) ReplaceDevice(ctx context.Context, device *models.Device) error {
+	copied := *device
+	s.devices[device.ID] = &copied
+	return nil
+}
+
+func (s *fakeStore) RemoveDevice(ctx context.Context, id string) error {
+	delete(s.devices, id)
+	return nil
+}
+
+// fakeEvents is a device event log holding the given events after position 0
+type fakeEvents struct {
+	repository.DeviceEventRepository
+	events []*models.DeviceEvent
+}
+
+func (e *fakeEvents) ListDeviceEvents(ctx context.Context, after models.EventPosition, owners repository.Owners, limit int) ([]*models.DeviceEvent, error) {
+	var events []*models.DeviceEvent
+	for _, event := range e.events {
+		if event.Position.After(after) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// fakeCheckpoints records who holds the checkpoint lease
+type fakeCheckpoints struct {
+	repository.OutboxCheckpointRepository
+	owner    string
+	released bool
+}
+
+func (c *fakeCheckpoints) TakeOverOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (models.EventPosition, error) {
+	c.owner = owner
+	return models.EventPosition{}, nil
+}
+
+func (c *fakeCheckpoints) AcquireOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (models.EventPosition, bool, error) {
+	return models.EventPosition{}, c.owner == owner, nil
+}
+
+func (c *fakeCheckpoints) ReleaseOutboxCheckpoint(ctx context.Context, name, owner string) error {
+	c.released = name == repository.DeviceProjectionCheckpoint && c.owner == owner
+	return nil
+}
+
+func device(id, name string) *models.Device {
+	return &models.Device{
+		ID:        id,
+		Name:      name,
+		Type:      "switch",
+		State:     map[string]models.StateValue{"power": {Type: models.ValueTypeBool, Bool: true}},
+		UserID:    "alice",
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// fixture has one device of each kind: in sync, missing, extra, mismatched,
+// and mismatched but with an event the relay has yet to apply
+func fixture() (*fakeStore, *fakeStore, *fakeEvents) {
+	source := newFakeStore(device("a", "Lamp"), device("b", "Fan"), device("d", "Kettle"), device("e", "Heater"))
+	projection := newFakeStore(device("a", "Lamp"), device("c", "Gone"), device("d", "Old kettle"), device("e", "Old heater"))
+	// Mongo keeps milliseconds only
+	projection.devices["a"].UpdatedAt = projection.devices["a"].UpdatedAt.Add(400 * time.Microsecond)
+	events := &fakeEvents{events: []*models.DeviceEvent{
+		{Position: models.EventPosition{TxID: 1, Seq: 1}, Type: models.DeviceEventUpdated, Device: *device("e", "Heater")},
+	}}
+	return source, projection, events
+}
+
+func TestRunCheckReportsDrift(t *testing.T) {
+	source, projection, events := fixture()
+	checkpoints := &fakeCheckpoints{}
+	reconciler := NewReconciler(source, projection, events, checkpoints)
+	reconciler.batchSize = 2
+
+	report, err := reconciler.Run(context.Background(), ModeCheck)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, report.SQLDevices)
+	assert.Equal(t, 4, report.MongoDevices)
+	assert.Equal(t, []string{"b"}, report.Missing)
+	assert.Equal(t, []string{"c"}, report.Extra)
+	assert.Equal(t, []Mismatch{{ID: "d", Fields: []string{"name"}}}, report.Mismatched)
+	assert.Equal(t, []string{"e"}, report.Pending)
+	assert.Zero(t, report.Repaired)
+	assert.True(t, report.Drift())
+	assert.Equal(t, "Old kettle", projection.devices["d"].Name)
+	assert.True(t, checkpoints.released)
+}
+
+func TestRunRepairLeavesPendingDevicesToRelay(t *testing.T) {
+	source, projection, events := fixture()
+	reconciler := NewReconciler(source, projection, events, &fakeCheckpoints{})
+
+	report, err := reconciler.Run(context.Background(), ModeRepair)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Repaired)
+
+	assert.Equal(t, "Fan", projection.devices["b"].Name)
+	assert.NotContains(t, projection.devices, "c")
+	assert.Equal(t, "Kettle", projection.devices["d"].Name)
+	assert.Equal(t, "Old heater", projection.devices["e"].Name)
+
+	report, err = NewReconciler(source, projection, &fakeEvents{}, &fakeCheckpoints{}).Run(context.Background(), ModeCheck)
+	require.NoError(t, err)
+	assert.Equal(t, []Mismatch{{ID: "e", Fields: []string{"name"}}}, report.Mismatched)
+}
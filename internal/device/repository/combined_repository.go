@@ -2,68 +2,116 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
+// CombinedRepository writes devices to SQL, the source of truth, and reads them
+// from the Mongo projection. Every SQL write records a device event in the same
+// transaction; outbox.Relay applies those events to the projection, so a write
+// reaches Mongo even if Mongo was unavailable when it was made. Queries that
+// need to see writes the relay has not applied yet read SQL instead.
 type CombinedRepository struct {
-	sqlRepo   *SQLRepository
-	nosqlRepo *MongoRepository
+	sqlRepo     *SQLRepository
+	nosqlRepo   *MongoRepository
+	checkpoints OutboxCheckpointRepository
 }
 
-func NewCombinedRepository(sqlRepo *SQLRepository, nosqlRepo *MongoRepository) *CombinedRepository {
+func NewCombinedRepository(sqlRepo *SQLRepository, nosqlRepo *MongoRepository, checkpoints OutboxCheckpointRepository) *CombinedRepository {
 	return &CombinedRepository{
-		sqlRepo:   sqlRepo,
-		nosqlRepo: nosqlRepo,
+		sqlRepo:     sqlRepo,
+		nosqlRepo:   nosqlRepo,
+		checkpoints: checkpoints,
 	}
 }
 
+func (r *CombinedRepository) LoadDevice(ctx context.Context, id string) (*models.Device, error) {
+	// Commands validate against SQL, the source of truth
+	return r.sqlRepo.LoadDevice(ctx, id)
+}
+
 func (r *CombinedRepository) CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
-	// Write to SQL
-	createdDevice, err := r.sqlRepo.CreateDevice(ctx, device)
+	return r.sqlRepo.CreateDevice(ctx, device)
+}
+
+func (r *CombinedRepository) GetDevice(ctx context.Context, id string, consistency Consistency) (*models.Device, error) {
+	readSQL, err := r.needsWriteModel(ctx, consistency)
 	if err != nil {
 		return nil, err
 	}
+	if readSQL {
+		return r.sqlRepo.LoadDevice(ctx, id)
+	}
 
-	// Also write to NoSQL for read optimization
-	_, err = r.nosqlRepo.collection.InsertOne(ctx, createdDevice)
-	if err != nil {
-		// Log error but don't fail the operation
-		// In a production system, you might want to implement a retry mechanism or compensating transaction
-		// TODO: Implement error logging
+	// Read from NoSQL
+	device, err := r.nosqlRepo.GetDevice(ctx, id)
+	if err == ErrDeviceNotFound {
+		// The relay may not have applied the device yet, so a client that
+		// just created it still finds it
+		return r.sqlRepo.LoadDevice(ctx, id)
 	}
+	return device, err
+}
 
-	return createdDevice, nil
+func (r *CombinedRepository) UpdateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
+	return r.sqlRepo.UpdateDevice(ctx, device)
 }
 
-func (r *CombinedRepository) GetDevice(ctx context.Context, id string) (*models.Device, error) {
-	// Read from NoSQL
-	return r.nosqlRepo.GetDevice(ctx, id)
+func (r *CombinedRepository) UpdateDeviceState(ctx context.Context, id string, state map[string]models.StateValue, expectedVersion int64) (*models.Device, error) {
+	return r.sqlRepo.UpdateDeviceState(ctx, id, state, expectedVersion)
+}
+
+func (r *CombinedRepository) UpdateDeviceStates(ctx context.Context, updates []DeviceStateUpdate) ([]*models.Device, error) {
+	return r.sqlRepo.UpdateDeviceStates(ctx, updates)
+}
+
+func (r *CombinedRepository) RecordHeartbeat(ctx context.Context, id string, at time.Time) (*models.Device, models.Connectivity, error) {
+	return r.sqlRepo.RecordHeartbeat(ctx, id, at)
+}
+
+func (r *CombinedRepository) MarkDevicesOffline(ctx context.Context, now time.Time, defaultTimeout time.Duration, limit int) ([]*models.Device, error) {
+	return r.sqlRepo.MarkDevicesOffline(ctx, now, defaultTimeout, limit)
+}
+
+func (r *CombinedRepository) SetDeviceRoom(ctx context.Context, id string, roomID string) (*models.Device, error) {
+	return r.sqlRepo.SetDeviceRoom(ctx, id, roomID)
 }
 
-func (r *CombinedRepository) UpdateDeviceState(ctx context.Context, id string, state string) (*models.Device, error) {
-	// Update in SQL
-	updatedDevice, err := r.sqlRepo.UpdateDeviceState(ctx, id, state)
+func (r *CombinedRepository) DeleteDevice(ctx context.Context, id string) (*models.Device, error) {
+	return r.sqlRepo.DeleteDevice(ctx, id)
+}
+
+func (r *CombinedRepository) ListDevices(ctx context.Context, filter DeviceFilter, sort DeviceSort, consistency Consistency, page DevicePage) ([]*models.Device, int, error) {
+	readSQL, err := r.needsWriteModel(ctx, consistency)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	if readSQL {
+		return r.sqlRepo.ListDevices(ctx, filter, sort, page)
 	}
 
-	// Also update in NoSQL
-	_, err = r.nosqlRepo.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": bson.M{"state": state}},
-	)
+	// Read from NoSQL
+	return r.nosqlRepo.ListDevices(ctx, filter, sort, page)
+}
+
+// needsWriteModel reports whether a query has to read SQL because the read
+// model may not reflect the writes it asks for
+func (r *CombinedRepository) needsWriteModel(ctx context.Context, consistency Consistency) (bool, error) {
+	if consistency.Strong {
+		return true, nil
+	}
+	if consistency.After == nil {
+		return false, nil
+	}
+	applied, err := r.checkpoints.GetOutboxCheckpoint(ctx, DeviceProjectionCheckpoint)
 	if err != nil {
-		// Log error but don't fail the operation
-		// TODO: Implement error logging
+		return false, err
 	}
-
-	return updatedDevice, nil
+	return consistency.After.After(applied), nil
 }
 
-func (r *CombinedRepository) ListDevices(ctx context.Context, userID string, page, pageSize int) ([]*models.Device, int, error) {
-	// Read from NoSQL
-	return r.nosqlRepo.ListDevices(ctx, userID, page, pageSize)
+func (r *CombinedRepository) ListDeviceStateHistory(ctx context.Context, deviceID string, from, to time.Time, page, pageSize int) ([]*models.DeviceState, int, error) {
+	// History is only kept in SQL
+	return r.sqlRepo.ListDeviceStateHistory(ctx, deviceID, from, to, page, pageSize)
 }
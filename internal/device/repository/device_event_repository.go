@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// SQLDeviceEventRepository reads the device event log written by SQLRepository
+type SQLDeviceEventRepository struct {
+	db *sql.DB
+}
+
+func NewSQLDeviceEventRepository(db *sql.DB) *SQLDeviceEventRepository {
+	return &SQLDeviceEventRepository{db: db}
+}
+
+// visibleEvents restricts a query to events of transactions older than every
+// running transaction. Transactions that commit later get a larger tx_id, so
+// they always sort after the events a reader has already seen.
+const visibleEvents = `tx_id < pg_snapshot_xmin(pg_current_snapshot())`
+
+func (r *SQLDeviceEventRepository) DeviceEventHead(ctx context.Context) (models.EventPosition, error) {
+	query := `
+		SELECT tx_id::text, seq FROM device_events
+		WHERE ` + visibleEvents + `
+		ORDER BY tx_id DESC, seq DESC
+		LIMIT 1
+	`
+	var position models.EventPosition
+	var txID string
+	err := r.db.QueryRowContext(ctx, query).Scan(&txID, &position.Seq)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.EventPosition{}, nil
+		}
+		return models.EventPosition{}, err
+	}
+	position.TxID, err = strconv.ParseUint(txID, 10, 64)
+	return position, err
+}
+
+func (r *SQLDeviceEventRepository) ListDeviceEvents(ctx context.Context, after models.EventPosition, owners Owners, limit int) ([]*models.DeviceEvent, error) {
+	args := []interface{}{strconv.FormatUint(after.TxID, 10), after.Seq, limit}
+	ownersFilter := ownersCondition(owners, &args)
+
+	query := `
+		SELECT tx_id::text, seq, type, device, occurred_at FROM device_events
+		WHERE (tx_id, seq) > ($1::xid8, $2) AND ` + visibleEvents + ` AND ` + ownersFilter + `
+		ORDER BY tx_id, seq
+		LIMIT $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.DeviceEvent
+	for rows.Next() {
+		var event models.DeviceEvent
+		var txID string
+		var rawDevice []byte
+		if err := rows.Scan(&txID, &event.Position.Seq, &event.Type, &rawDevice, &event.OccurredAt); err != nil {
+			return nil, err
+		}
+		if event.Position.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(rawDevice, &event.Device); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// insertDeviceEvent appends a change to the device event log as part of tx,
+// and sets the device's WritePosition to the position of the event
+func insertDeviceEvent(ctx context.Context, tx *sql.Tx, eventType models.DeviceEventType, device *models.Device) error {
+	snapshot, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO device_events (device_id, user_id, household_id, type, device) VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING tx_id::text, seq
+	`
+	var position models.EventPosition
+	var txID string
+	err = tx.QueryRowContext(ctx, query, device.ID, device.UserID, device.HouseholdID, eventType, snapshot).Scan(&txID, &position.Seq)
+	if err != nil {
+		return err
+	}
+	if position.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+		return err
+	}
+	device.WritePosition = &position
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/lib/pq"
+)
+
+type SQLDeviceTypeRepository struct {
+	db *sql.DB
+}
+
+func NewSQLDeviceTypeRepository(db *sql.DB) *SQLDeviceTypeRepository {
+	return &SQLDeviceTypeRepository{db: db}
+}
+
+func (r *SQLDeviceTypeRepository) CreateDeviceType(ctx context.Context, deviceType *models.DeviceType) error {
+	capabilities, err := json.Marshal(deviceType.Capabilities)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO device_types (id, name, description, capabilities, heartbeat_timeout_seconds) VALUES ($1, $2, $3, $4, $5)`
+	_, err = r.db.ExecContext(ctx, query, deviceType.ID, deviceType.Name, deviceType.Description, capabilities, int(deviceType.HeartbeatTimeout.Seconds()))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDeviceTypeExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *SQLDeviceTypeRepository) GetDeviceType(ctx context.Context, id string) (*models.DeviceType, error) {
+	query := `SELECT id, name, description, capabilities, heartbeat_timeout_seconds FROM device_types WHERE id = $1`
+	deviceType, err := scanDeviceType(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeviceTypeNotFound
+		}
+		return nil, err
+	}
+	return deviceType, nil
+}
+
+func (r *SQLDeviceTypeRepository) UpdateDeviceType(ctx context.Context, deviceType *models.DeviceType) error {
+	capabilities, err := json.Marshal(deviceType.Capabilities)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE device_types SET name = $2, description = $3, capabilities = $4, heartbeat_timeout_seconds = $5 WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, deviceType.ID, deviceType.Name, deviceType.Description, capabilities, int(deviceType.HeartbeatTimeout.Seconds()))
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrDeviceTypeNotFound
+	}
+	return nil
+}
+
+// DeleteDeviceType removes a type. Types still referenced by devices are
+// protected by a foreign key and yield ErrDeviceTypeInUse.
+func (r *SQLDeviceTypeRepository) DeleteDeviceType(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM device_types WHERE id = $1`, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrDeviceTypeInUse
+		}
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrDeviceTypeNotFound
+	}
+	return nil
+}
+
+func (r *SQLDeviceTypeRepository) ListDeviceTypes(ctx context.Context) ([]*models.DeviceType, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, description, capabilities, heartbeat_timeout_seconds FROM device_types ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deviceTypes []*models.DeviceType
+	for rows.Next() {
+		deviceType, err := scanDeviceType(rows)
+		if err != nil {
+			return nil, err
+		}
+		deviceTypes = append(deviceTypes, deviceType)
+	}
+	return deviceTypes, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDeviceType(row rowScanner) (*models.DeviceType, error) {
+	var deviceType models.DeviceType
+	var capabilities []byte
+	var heartbeatTimeoutSeconds int
+	if err := row.Scan(&deviceType.ID, &deviceType.Name, &deviceType.Description, &capabilities, &heartbeatTimeoutSeconds); err != nil {
+		return nil, err
+	}
+	deviceType.HeartbeatTimeout = time.Duration(heartbeatTimeoutSeconds) * time.Second
+	if err := json.Unmarshal(capabilities, &deviceType.Capabilities); err != nil {
+		return nil, err
+	}
+	return &deviceType, nil
+}
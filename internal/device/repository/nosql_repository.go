@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,6 +14,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// MongoRepository is the Mongo read model of devices. It is only written by
+// applying the device event log, see outbox.Relay, and by package reindex;
+// commands write SQL.
 type MongoRepository struct {
 	collection *mongo.Collection
 }
@@ -18,52 +25,85 @@ func NewMongoRepository(collection *mongo.Collection) *MongoRepository {
 	return &MongoRepository{collection: collection}
 }
 
-func (r *MongoRepository) CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
-	if device.ID == "" {
-		device.ID = primitive.NewObjectID().Hex()
-	}
-	_, err := r.collection.InsertOne(ctx, device)
+// GetDevice reads a device from the read model. Devices are stored under the ID
+// assigned by the write model, so id is matched as is.
+func (r *MongoRepository) GetDevice(ctx context.Context, id string) (*models.Device, error) {
+	var device models.Device
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&device)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrDeviceNotFound
+		}
 		return nil, err
 	}
-	return device, nil
+	return &device, nil
 }
 
-func (r *MongoRepository) GetDevice(ctx context.Context, id string) (*models.Device, error) {
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return nil, err
+// ApplyDeviceEvent brings the read model up to date with an event of the device
+// event log. Events carry the whole device, so applying one again is harmless.
+func (r *MongoRepository) ApplyDeviceEvent(ctx context.Context, event *models.DeviceEvent) error {
+	if event.Type == models.DeviceEventDeleted {
+		return r.RemoveDevice(ctx, event.Device.ID)
 	}
+	return r.ReplaceDevice(ctx, &event.Device)
+}
 
-	var device models.Device
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&device)
-	if err != nil {
-		return nil, err
-	}
-	return &device, nil
+// ReplaceDevice stores a device in the read model as given, inserting it if
+// it is missing
+func (r *MongoRepository) ReplaceDevice(ctx context.Context, device *models.Device) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": device.ID}, device, options.Replace().SetUpsert(true))
+	return err
 }
 
-func (r *MongoRepository) UpdateDeviceState(ctx context.Context, id string, state string) (*models.Device, error) {
-	objectID, err := primitive.ObjectIDFromHex(id)
+// RemoveDevice removes a device from the read model, if it is there
+func (r *MongoRepository) RemoveDevice(ctx context.Context, id string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// ScanDevices returns up to limit devices with an ID greater than afterID, in
+// ID order
+func (r *MongoRepository) ScanDevices(ctx context.Context, afterID string, limit int) ([]*models.Device, error) {
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$gt": afterID}}, opts)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	update := bson.M{"$set": bson.M{"state": state}}
-	var device models.Device
-	err = r.collection.FindOneAndUpdate(ctx, bson.M{"_id": objectID}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&device)
-	if err != nil {
+	var devices []*models.Device
+	if err := cursor.All(ctx, &devices); err != nil {
 		return nil, err
 	}
-	return &device, nil
+	return devices, nil
 }
 
-func (r *MongoRepository) ListDevices(ctx context.Context, userID string, page, pageSize int) ([]*models.Device, int, error) {
-	skip := (page - 1) * pageSize
-	limit := int64(pageSize)
+func (r *MongoRepository) ListDevices(ctx context.Context, deviceFilter DeviceFilter, sort DeviceSort, page DevicePage) ([]*models.Device, int, error) {
+	filter := devicesFilter(deviceFilter)
+
+	var total int64
+	if page.CountTotal {
+		var err error
+		if total, err = r.collection.CountDocuments(ctx, filter); err != nil {
+			return nil, 0, err
+		}
+	}
 
-	filter := bson.M{"user_id": userID}
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(limit)
+	direction, after := 1, "$gt"
+	if sort.Descending {
+		direction, after = -1, "$lt"
+	}
+	field := string(sort.field())
+	if page.After != nil {
+		value := page.After.value(sort)
+		and, _ := filter["$and"].(bson.A)
+		filter["$and"] = append(and, bson.M{"$or": bson.A{
+			bson.M{field: bson.M{after: value}},
+			bson.M{field: value, "_id": bson.M{after: page.After.ID}},
+		}})
+	}
+	order := bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}
+	opts := options.Find().SetSort(order).SetSkip(int64(page.Offset)).SetLimit(int64(page.Limit))
 
 	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
@@ -75,11 +115,113 @@ func (r *MongoRepository) ListDevices(ctx context.Context, userID string, page,
 	if err = cursor.All(ctx, &devices); err != nil {
 		return nil, 0, err
 	}
+	return devices, int(total), nil
+}
+
+// EnsureIndexes creates the indexes ListDevices relies on, if they are
+// missing. Every query selects the devices of one user and their households,
+// so each sort order is indexed after the owner fields; the other filters
+// then only scan the devices of those owners.
+func (r *MongoRepository) EnsureIndexes(ctx context.Context) error {
+	var indexes []mongo.IndexModel
+	for _, field := range []DeviceSortField{SortByCreatedAt, SortByUpdatedAt, SortByName} {
+		indexes = append(indexes,
+			mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "household_id", Value: 1}, {Key: string(field), Value: 1}, {Key: "_id", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "household_id", Value: 1}, {Key: string(field), Value: 1}, {Key: "_id", Value: 1}}},
+		)
+	}
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
 
-	total, err := r.collection.CountDocuments(ctx, filter)
-	if err != nil {
-		return nil, 0, err
+// devicesFilter builds the filter that selects the devices of a DeviceFilter
+func devicesFilter(deviceFilter DeviceFilter) bson.M {
+	filter := ownersFilter(deviceFilter.Owners)
+	if deviceFilter.RoomID != "" {
+		filter["room_id"] = deviceFilter.RoomID
+	}
+	if deviceFilter.Type != "" {
+		filter["type"] = deviceFilter.Type
+	}
+	if deviceFilter.NameContains != "" {
+		filter["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(deviceFilter.NameContains), Options: "i"}
+	}
+	for name, value := range deviceFilter.State {
+		attribute := "state." + name
+		filter[attribute+".type"] = value.Type
+		for _, field := range stateFields(value) {
+			// A missing field matches null
+			var condition interface{} = field.value
+			if field.zero {
+				condition = bson.M{"$in": bson.A{field.value, nil}}
+			}
+			filter[attribute+"."+strings.Join(field.path, ".")] = condition
+		}
+	}
+	// Requirements are combined with $and, as several may be on the same label
+	var requirements bson.A
+	for _, requirement := range deviceFilter.Labels {
+		requirements = append(requirements, bson.M{"labels." + requirement.Key: labelFilter(requirement)})
+	}
+	if requirements != nil {
+		filter["$and"] = requirements
+	}
+	if created := timeRange(deviceFilter.CreatedAfter, deviceFilter.CreatedBefore); created != nil {
+		filter["created_at"] = created
+	}
+	if updated := timeRange(deviceFilter.UpdatedAfter, deviceFilter.UpdatedBefore); updated != nil {
+		filter["updated_at"] = updated
 	}
+	return filter
+}
 
-	return devices, int(total), nil
+// labelFilter builds the condition a label must meet for a requirement.
+// Like $ne and $nin, it matches devices without the label for NotEquals and
+// NotIn.
+func labelFilter(requirement labels.Requirement) bson.M {
+	switch requirement.Operator {
+	case labels.Equals:
+		return bson.M{"$eq": requirement.Values[0]}
+	case labels.NotEquals:
+		return bson.M{"$ne": requirement.Values[0]}
+	case labels.In:
+		return bson.M{"$in": requirement.Values}
+	case labels.NotIn:
+		return bson.M{"$nin": requirement.Values}
+	case labels.DoesNotExist:
+		return bson.M{"$exists": false}
+	default: // labels.Exists
+		return bson.M{"$exists": true}
+	}
+}
+
+// timeRange builds the condition that matches times from after until before,
+// or nil if both are zero
+func timeRange(after, before time.Time) bson.M {
+	if after.IsZero() && before.IsZero() {
+		return nil
+	}
+	condition := bson.M{}
+	if !after.IsZero() {
+		condition["$gte"] = after
+	}
+	if !before.IsZero() {
+		condition["$lt"] = before
+	}
+	return condition
+}
+
+// ownersFilter builds the filter that selects the devices of owners
+func ownersFilter(owners Owners) bson.M {
+	if owners.IsZero() {
+		return bson.M{}
+	}
+	householdIDs := owners.HouseholdIDs
+	if householdIDs == nil {
+		householdIDs = []string{}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"household_id": nil, "user_id": owners.UserID},
+		bson.M{"household_id": bson.M{"$in": householdIDs}},
+	}}
 }
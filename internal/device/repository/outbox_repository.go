@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+// SQLOutboxCheckpointRepository stores relay checkpoints next to the device
+// event log they point into
+type SQLOutboxCheckpointRepository struct {
+	db *sql.DB
+}
+
+func NewSQLOutboxCheckpointRepository(db *sql.DB) *SQLOutboxCheckpointRepository {
+	return &SQLOutboxCheckpointRepository{db: db}
+}
+
+func (r *SQLOutboxCheckpointRepository) GetOutboxCheckpoint(ctx context.Context, name string) (models.EventPosition, error) {
+	query := `SELECT tx_id::text, seq FROM outbox_checkpoints WHERE name = $1`
+	var position models.EventPosition
+	var txID string
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&txID, &position.Seq)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.EventPosition{}, nil
+		}
+		return models.EventPosition{}, err
+	}
+	position.TxID, err = strconv.ParseUint(txID, 10, 64)
+	return position, err
+}
+
+func (r *SQLOutboxCheckpointRepository) AcquireOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (models.EventPosition, bool, error) {
+	// A new checkpoint starts at the beginning of the log. Replaying events
+	// the projection already has is harmless, missing some is not.
+	query := `
+		INSERT INTO outbox_checkpoints (name, owner, lease_until)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+		SET owner = EXCLUDED.owner, lease_until = EXCLUDED.lease_until
+		WHERE outbox_checkpoints.owner = EXCLUDED.owner OR outbox_checkpoints.lease_until < NOW()
+		RETURNING tx_id::text, seq
+	`
+	var position models.EventPosition
+	var txID string
+	err := r.db.QueryRowContext(ctx, query, name, owner, lease.Milliseconds()).Scan(&txID, &position.Seq)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.EventPosition{}, false, nil
+		}
+		return models.EventPosition{}, false, err
+	}
+	position.TxID, err = strconv.ParseUint(txID, 10, 64)
+	if err != nil {
+		return models.EventPosition{}, false, err
+	}
+	return position, true, nil
+}
+
+func (r *SQLOutboxCheckpointRepository) SaveOutboxCheckpoint(ctx context.Context, name, owner string, position models.EventPosition) error {
+	query := `
+		UPDATE outbox_checkpoints
+		SET tx_id = $3::xid8, seq = $4, updated_at = NOW()
+		WHERE name = $1 AND owner = $2 AND lease_until >= NOW()
+	`
+	result, err := r.db.ExecContext(ctx, query, name, owner, strconv.FormatUint(position.TxID, 10), position.Seq)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *SQLOutboxCheckpointRepository) TakeOverOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (models.EventPosition, error) {
+	query := `
+		INSERT INTO outbox_checkpoints (name, owner, lease_until)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+		SET owner = EXCLUDED.owner, lease_until = EXCLUDED.lease_until
+		RETURNING tx_id::text, seq
+	`
+	var position models.EventPosition
+	var txID string
+	err := r.db.QueryRowContext(ctx, query, name, owner, lease.Milliseconds()).Scan(&txID, &position.Seq)
+	if err != nil {
+		return models.EventPosition{}, err
+	}
+	position.TxID, err = strconv.ParseUint(txID, 10, 64)
+	return position, err
+}
+
+func (r *SQLOutboxCheckpointRepository) ReleaseOutboxCheckpoint(ctx context.Context, name, owner string) error {
+	query := `UPDATE outbox_checkpoints SET lease_until = NOW() WHERE name = $1 AND owner = $2`
+	_, err := r.db.ExecContext(ctx, query, name, owner)
+	return err
+}
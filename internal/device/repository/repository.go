@@ -2,21 +2,385 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 )
 
+var (
+	ErrDeviceNotFound     = errors.New("device not found")
+	ErrDeviceTypeNotFound = errors.New("device type not found")
+	ErrDeviceTypeExists   = errors.New("device type already exists")
+	ErrDeviceTypeInUse    = errors.New("device type is in use")
+	ErrRoomNotFound       = errors.New("room not found")
+	ErrScheduleNotFound   = errors.New("schedule not found")
+	ErrSceneNotFound      = errors.New("scene not found")
+	ErrRuleNotFound       = errors.New("rule not found")
+	ErrLeaseLost          = errors.New("lease is held by another owner")
+	// ErrVersionMismatch is returned when a device was written since the
+	// version an update expected
+	ErrVersionMismatch = errors.New("device version mismatch")
+)
+
 type DeviceRepository interface {
 	CommandDeviceRepository
 	QueryDeviceRepository
 }
 
 type CommandDeviceRepository interface {
+	// LoadDevice reads a device from the write model
+	LoadDevice(ctx context.Context, id string) (*models.Device, error)
 	CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error)
-	UpdateDeviceState(ctx context.Context, id string, state string) (*models.Device, error)
+	// UpdateDevice stores the name, type, state and room of a device loaded
+	// with LoadDevice, returning ErrVersionMismatch if it was written since
+	UpdateDevice(ctx context.Context, device *models.Device) (*models.Device, error)
+	// UpdateDeviceState patches the state of a device. A non-zero
+	// expectedVersion makes it return ErrVersionMismatch unless the device is
+	// at that version.
+	UpdateDeviceState(ctx context.Context, id string, state map[string]models.StateValue, expectedVersion int64) (*models.Device, error)
+	// UpdateDeviceStates applies several state updates like UpdateDeviceState,
+	// either all of them or, if one fails with a StateUpdateError, none
+	UpdateDeviceStates(ctx context.Context, updates []DeviceStateUpdate) ([]*models.Device, error)
+	SetDeviceRoom(ctx context.Context, id string, roomID string) (*models.Device, error)
+	// DeleteDevice removes a device and returns it as it was
+	DeleteDevice(ctx context.Context, id string) (*models.Device, error)
+	// RecordHeartbeat records that a device was seen at a time and marks it
+	// online, without counting as a write to its version. It returns the
+	// device and its connectivity before; going online is recorded in the
+	// device event log.
+	RecordHeartbeat(ctx context.Context, id string, at time.Time) (*models.Device, models.Connectivity, error)
+}
+
+// ConnectivityRepository finds the devices whose heartbeats stopped
+type ConnectivityRepository interface {
+	// MarkDevicesOffline marks up to limit online devices offline, whose last
+	// heartbeat is older than the heartbeat timeout of their type, or than
+	// defaultTimeout for types without one. Going offline is recorded in the
+	// device event log.
+	MarkDevicesOffline(ctx context.Context, now time.Time, defaultTimeout time.Duration, limit int) ([]*models.Device, error)
+}
+
+// DeviceStateUpdate is an update of UpdateDeviceStates
+type DeviceStateUpdate struct {
+	ID              string
+	State           map[string]models.StateValue
+	ExpectedVersion int64
+}
+
+// StateUpdateError reports the update of UpdateDeviceStates, by index, that
+// kept the others from being applied
+type StateUpdateError struct {
+	Index int
+	Err   error
+}
+
+func (e *StateUpdateError) Error() string {
+	return fmt.Sprintf("state update %d: %v", e.Index, e.Err)
+}
+
+func (e *StateUpdateError) Unwrap() error {
+	return e.Err
 }
 
 type QueryDeviceRepository interface {
-	GetDevice(ctx context.Context, id string) (*models.Device, error)
-	ListDevices(ctx context.Context, userID string, page, pageSize int) ([]*models.Device, int, error)
+	GetDevice(ctx context.Context, id string, consistency Consistency) (*models.Device, error)
+	// ListDevices returns a page of the devices that match filter, in the
+	// order of sort, and their total number if the page asks for it
+	ListDevices(ctx context.Context, filter DeviceFilter, sort DeviceSort, consistency Consistency, page DevicePage) ([]*models.Device, int, error)
+	ListDeviceStateHistory(ctx context.Context, deviceID string, from, to time.Time, page, pageSize int) ([]*models.DeviceState, int, error)
+}
+
+// Consistency selects how up to date a device query must be. The zero value
+// allows reads from the read model, which may lag behind writes.
+type Consistency struct {
+	// Strong reads the write model, which has every committed write
+	Strong bool
+	// After, if set, is the position of a write the read must reflect. The
+	// read model is used once it has applied that write.
+	After *models.EventPosition
+}
+
+// Owners selects devices by who owns them: the devices UserID owns alone,
+// outside of any household, and the devices of the households in
+// HouseholdIDs. The zero value selects every device.
+type Owners struct {
+	UserID       string
+	HouseholdIDs []string
+}
+
+// IsZero reports whether o selects every device
+func (o Owners) IsZero() bool {
+	return o.UserID == "" && len(o.HouseholdIDs) == 0
+}
+
+// Owns reports whether o selects a device
+func (o Owners) Owns(device *models.Device) bool {
+	if o.IsZero() {
+		return true
+	}
+	if device.HouseholdID != "" {
+		return slices.Contains(o.HouseholdIDs, device.HouseholdID)
+	}
+	return o.UserID != "" && device.UserID == o.UserID
+}
+
+// DeviceFilter narrows down ListDevices. Empty fields match everything.
+type DeviceFilter struct {
+	Owners Owners
+	RoomID string
+	Type   string
+	// NameContains matches the devices whose name contains it, ignoring case
+	NameContains string
+	// State matches the devices whose state attributes have these values
+	State map[string]models.StateValue
+	// Labels matches the devices whose labels meet the selector
+	Labels labels.Selector
+	// The time ranges include their start and exclude their end
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+}
+
+// stateField is a field of an encoded state value that holds the value itself
+type stateField struct {
+	path    []string // below the attribute, e.g. color, r
+	value   interface{}
+	zero    bool   // the value is zero, so encodings omit the field
+	sqlType string // what the SQL filter casts the field to
+	sqlZero string // the SQL literal of the zero value
+}
+
+// stateFields returns the fields a state filter compares for a value of its
+// type. Encodings omit zero fields, while rows written before may hold them,
+// so values are compared field by field with a missing field standing for
+// its zero value, instead of as whole documents.
+func stateFields(value models.StateValue) []stateField {
+	switch value.Type {
+	case models.ValueTypeBool:
+		return []stateField{{[]string{"bool"}, value.Bool, !value.Bool, "boolean", "false"}}
+	case models.ValueTypeInt:
+		return []stateField{{[]string{"int"}, value.Int, value.Int == 0, "bigint", "0"}}
+	case models.ValueTypeFloat:
+		return []stateField{{[]string{"float"}, value.Float, value.Float == 0, "double precision", "0"}}
+	case models.ValueTypeEnum:
+		return []stateField{{[]string{"enum"}, value.Enum, value.Enum == "", "text", "''"}}
+	case models.ValueTypeColor:
+		var color models.Color
+		if value.Color != nil {
+			color = *value.Color
+		}
+		var fields []stateField
+		for _, component := range []struct {
+			name  string
+			value uint8
+		}{{"r", color.R}, {"g", color.G}, {"b", color.B}} {
+			fields = append(fields, stateField{[]string{"color", component.name}, int(component.value), component.value == 0, "integer", "0"})
+		}
+		return fields
+	}
+	return nil
+}
+
+// DeviceSortField is a field ListDevices can order devices by
+type DeviceSortField string
+
+const (
+	SortByCreatedAt DeviceSortField = "created_at"
+	SortByUpdatedAt DeviceSortField = "updated_at"
+	SortByName      DeviceSortField = "name"
+)
+
+// Valid reports whether f is a known sort field
+func (f DeviceSortField) Valid() bool {
+	switch f {
+	case SortByCreatedAt, SortByUpdatedAt, SortByName:
+		return true
+	}
+	return false
+}
+
+// DeviceSort orders ListDevices. Devices that compare equal are ordered by
+// ID. The zero value orders by creation time, oldest first.
+type DeviceSort struct {
+	Field      DeviceSortField
+	Descending bool
+}
+
+// field returns the field to sort by, defaulting to the creation time
+func (s DeviceSort) field() DeviceSortField {
+	if s.Field == "" {
+		return SortByCreatedAt
+	}
+	return s.Field
+}
+
+// DevicePage selects a page of ListDevices. A page starts After a device, so
+// that devices written while a client pages through the list do not shift
+// the following pages; an Offset is only supported for old clients.
+type DevicePage struct {
+	After  *DeviceCursor
+	Offset int
+	Limit  int
+	// CountTotal asks for the number of devices that match the filter
+	CountTotal bool
+}
+
+// DeviceCursor is the position of a device in the order of a DeviceSort: the
+// value of the sort field, Time or Name, and its ID. Times are ordered at
+// millisecond precision, the precision of the read model, so that a cursor
+// taken from either model is at the same position in the other.
+type DeviceCursor struct {
+	Time time.Time
+	Name string
+	ID   string
+}
+
+// CursorOf returns the position of a device in the order of sort
+func CursorOf(device *models.Device, sort DeviceSort) DeviceCursor {
+	cursor := DeviceCursor{ID: device.ID}
+	switch sort.field() {
+	case SortByCreatedAt:
+		cursor.Time = device.CreatedAt.Truncate(time.Millisecond)
+	case SortByUpdatedAt:
+		cursor.Time = device.UpdatedAt.Truncate(time.Millisecond)
+	case SortByName:
+		cursor.Name = device.Name
+	}
+	return cursor
+}
+
+// value returns the value of the sort field at the cursor
+func (c DeviceCursor) value(sort DeviceSort) interface{} {
+	if sort.field() == SortByName {
+		return c.Name
+	}
+	return c.Time
+}
+
+// DeviceEventRepository reads the device event log. Only events that can no
+// longer be preceded by a running transaction are returned, so a reader that
+// moves its position forward never skips an event.
+type DeviceEventRepository interface {
+	// DeviceEventHead returns the position of the newest readable event
+	DeviceEventHead(ctx context.Context) (models.EventPosition, error)
+	// ListDeviceEvents returns up to limit events of the devices of owners
+	// after a position, oldest first
+	ListDeviceEvents(ctx context.Context, after models.EventPosition, owners Owners, limit int) ([]*models.DeviceEvent, error)
+}
+
+// DeviceProjectionCheckpoint names the checkpoint of the relay that applies the
+// device event log to the Mongo read model
+const DeviceProjectionCheckpoint = "mongo_devices"
+
+// OutboxCheckpointRepository stores how far a relay has applied the device
+// event log. A checkpoint is leased to one owner at a time, so the instances
+// of the service take turns instead of applying the same events concurrently.
+type OutboxCheckpointRepository interface {
+	// GetOutboxCheckpoint returns the position of a checkpoint, the start of
+	// the log if it does not exist yet
+	GetOutboxCheckpoint(ctx context.Context, name string) (models.EventPosition, error)
+	// AcquireOutboxCheckpoint leases a checkpoint to owner, or renews the
+	// owner's lease, and returns its position. ok is false while the lease is
+	// held by another owner.
+	AcquireOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (position models.EventPosition, ok bool, err error)
+	// SaveOutboxCheckpoint moves a checkpoint to position. It fails with
+	// ErrLeaseLost when owner no longer holds the lease.
+	SaveOutboxCheckpoint(ctx context.Context, name, owner string, position models.EventPosition) error
+	// TakeOverOutboxCheckpoint leases a checkpoint to owner even while another
+	// owner holds it. The previous owner can no longer save the checkpoint.
+	TakeOverOutboxCheckpoint(ctx context.Context, name, owner string, lease time.Duration) (models.EventPosition, error)
+	// ReleaseOutboxCheckpoint ends owner's lease, so that another owner can
+	// acquire the checkpoint right away
+	ReleaseOutboxCheckpoint(ctx context.Context, name, owner string) error
+}
+
+// TelemetryRepository stores the readings of sensors
+type TelemetryRepository interface {
+	// InsertTelemetry stores readings
+	InsertTelemetry(ctx context.Context, points []*models.TelemetryPoint) error
+	// ListTelemetry returns up to limit readings in a range, oldest first
+	ListTelemetry(ctx context.Context, series TelemetryRange, limit int) ([]*models.TelemetryPoint, error)
+	// AggregateTelemetry summarizes the readings in a range per interval,
+	// oldest first. Buckets start at multiples of interval since the Unix
+	// epoch; intervals without readings have none.
+	AggregateTelemetry(ctx context.Context, series TelemetryRange, interval time.Duration) ([]*models.TelemetryBucket, error)
+}
+
+// TelemetryRange selects the readings of a metric of a device from From,
+// inclusive, to To, exclusive
+type TelemetryRange struct {
+	DeviceID string
+	Metric   string
+	From     time.Time
+	To       time.Time
+}
+
+type DeviceTypeRepository interface {
+	CreateDeviceType(ctx context.Context, deviceType *models.DeviceType) error
+	GetDeviceType(ctx context.Context, id string) (*models.DeviceType, error)
+	UpdateDeviceType(ctx context.Context, deviceType *models.DeviceType) error
+	DeleteDeviceType(ctx context.Context, id string) error
+	ListDeviceTypes(ctx context.Context) ([]*models.DeviceType, error)
+}
+
+type RoomRepository interface {
+	CreateRoom(ctx context.Context, room *models.Room) error
+	GetRoom(ctx context.Context, id string) (*models.Room, error)
+	UpdateRoom(ctx context.Context, room *models.Room) error
+	DeleteRoom(ctx context.Context, id string) error
+	ListRooms(ctx context.Context, owners Owners) ([]*models.Room, error)
+}
+
+type SceneRepository interface {
+	CreateScene(ctx context.Context, scene *models.Scene) error
+	GetScene(ctx context.Context, id string) (*models.Scene, error)
+	UpdateScene(ctx context.Context, scene *models.Scene) error
+	DeleteScene(ctx context.Context, id string) error
+	ListScenes(ctx context.Context, userID string) ([]*models.Scene, error)
+}
+
+type ScheduleRepository interface {
+	CreateSchedule(ctx context.Context, schedule *models.Schedule) error
+	GetSchedule(ctx context.Context, id string) (*models.Schedule, error)
+	UpdateSchedule(ctx context.Context, schedule *models.Schedule) error
+	DeleteSchedule(ctx context.Context, id string) error
+	ListSchedules(ctx context.Context, filter ScheduleFilter) ([]*models.Schedule, error)
+	// ClaimDueSchedules locks up to limit enabled schedules whose next run is
+	// at or before now for the given lease, so that no other scheduler
+	// instance picks them up while they are being executed
+	ClaimDueSchedules(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.Schedule, error)
+	// CompleteScheduleRun records a run and stores the schedule's new
+	// next_run_at, last_run_at and enabled values, releasing its lock
+	CompleteScheduleRun(ctx context.Context, schedule *models.Schedule, run *models.ScheduleRun) error
+	ListScheduleRuns(ctx context.Context, scheduleID string, page, pageSize int) ([]*models.ScheduleRun, int, error)
+}
+
+// ScheduleFilter narrows down ListSchedules. Empty fields match everything.
+type ScheduleFilter struct {
+	UserID   string
+	DeviceID string
+}
+
+type RuleRepository interface {
+	CreateRule(ctx context.Context, rule *models.Rule) error
+	GetRule(ctx context.Context, id string) (*models.Rule, error)
+	UpdateRule(ctx context.Context, rule *models.Rule) error
+	DeleteRule(ctx context.Context, id string) error
+	ListRules(ctx context.Context, userID string) ([]*models.Rule, error)
+	// ListTriggeredRules returns the enabled rules with the given trigger type
+	// whose source is sourceID: a device ID for device_state triggers and a
+	// rule ID for rule triggers
+	ListTriggeredRules(ctx context.Context, triggerType models.TriggerType, sourceID string) ([]*models.Rule, error)
+	// ListDueRules returns the enabled time-triggered rules due at now
+	ListDueRules(ctx context.Context, now time.Time) ([]*models.Rule, error)
+	// AdvanceRuleNextRun moves a rule's next run from previous to next. It
+	// reports false if another instance advanced the rule first.
+	AdvanceRuleNextRun(ctx context.Context, id string, previous time.Time, next *time.Time) (bool, error)
+	RecordRuleExecution(ctx context.Context, execution *models.RuleExecution) error
+	ListRuleExecutions(ctx context.Context, ruleID string, page, pageSize int) ([]*models.RuleExecution, int, error)
 }
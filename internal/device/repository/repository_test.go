@@ -3,10 +3,15 @@ package repository
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // MockRepository is a mock implementation of the DeviceRepository interface
@@ -24,13 +29,13 @@ func (m *MockRepository) GetDevice(ctx context.Context, id string) (*models.Devi
 	return args.Get(0).(*models.Device), args.Error(1)
 }
 
-func (m *MockRepository) UpdateDeviceState(ctx context.Context, id string, state string) (*models.Device, error) {
+func (m *MockRepository) UpdateDeviceState(ctx context.Context, id string, state map[string]models.StateValue) (*models.Device, error) {
 	args := m.Called(ctx, id, state)
 	return args.Get(0).(*models.Device), args.Error(1)
 }
 
-func (m *MockRepository) ListDevices(ctx context.Context, userID string, page, pageSize int) ([]*models.Device, int, error) {
-	args := m.Called(ctx, userID, page, pageSize)
+func (m *MockRepository) ListDevices(ctx context.Context, filter DeviceFilter, page, pageSize int) ([]*models.Device, int, error) {
+	args := m.Called(ctx, filter, page, pageSize)
 	return args.Get(0).([]*models.Device), args.Int(1), args.Error(2)
 }
 
@@ -39,7 +44,7 @@ func TestCreateDevice(t *testing.T) {
 	device := &models.Device{
 		Name:   "Test Device",
 		Type:   "Sensor",
-		State:  "Off",
+		State:  powerState(false),
 		UserID: "user123",
 	}
 
@@ -58,7 +63,7 @@ func TestGetDevice(t *testing.T) {
 		ID:     "device123",
 		Name:   "Test Device",
 		Type:   "Sensor",
-		State:  "Off",
+		State:  powerState(false),
 		UserID: "user123",
 	}
 
@@ -77,32 +82,126 @@ func TestUpdateDeviceState(t *testing.T) {
 		ID:     "device123",
 		Name:   "Test Device",
 		Type:   "Sensor",
-		State:  "On",
+		State:  powerState(true),
 		UserID: "user123",
 	}
 
-	mockRepo.On("UpdateDeviceState", mock.Anything, "device123", "On").Return(device, nil)
+	mockRepo.On("UpdateDeviceState", mock.Anything, "device123", powerState(true)).Return(device, nil)
 
-	updatedDevice, err := mockRepo.UpdateDeviceState(context.Background(), "device123", "On")
+	updatedDevice, err := mockRepo.UpdateDeviceState(context.Background(), "device123", powerState(true))
 
 	assert.NoError(t, err)
-	assert.Equal(t, "On", updatedDevice.State)
+	assert.Equal(t, powerState(true), updatedDevice.State)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestListDevices(t *testing.T) {
 	mockRepo := new(MockRepository)
 	devices := []*models.Device{
-		{ID: "device1", Name: "Device 1", Type: "Sensor", State: "Off", UserID: "user123"},
-		{ID: "device2", Name: "Device 2", Type: "Actuator", State: "On", UserID: "user123"},
+		{ID: "device1", Name: "Device 1", Type: "Sensor", State: powerState(false), UserID: "user123"},
+		{ID: "device2", Name: "Device 2", Type: "Actuator", State: powerState(true), UserID: "user123"},
 	}
 
-	mockRepo.On("ListDevices", mock.Anything, "user123", 1, 10).Return(devices, 2, nil)
+	mockRepo.On("ListDevices", mock.Anything, DeviceFilter{Owners: Owners{UserID: "user123"}}, 1, 10).Return(devices, 2, nil)
 
-	listedDevices, total, err := mockRepo.ListDevices(context.Background(), "user123", 1, 10)
+	listedDevices, total, err := mockRepo.ListDevices(context.Background(), DeviceFilter{Owners: Owners{UserID: "user123"}}, 1, 10)
 
 	assert.NoError(t, err)
 	assert.Equal(t, devices, listedDevices)
 	assert.Equal(t, 2, total)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestDeviceFilterQueriesOfBothModels(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := DeviceFilter{
+		Owners:       Owners{UserID: "user123"},
+		Type:         "light",
+		NameContains: "50%_off.",
+		State:        powerState(true),
+		UpdatedAfter: after,
+	}
+
+	mongoFilter := devicesFilter(filter)
+	assert.Equal(t, "light", mongoFilter["type"])
+	assert.Equal(t, primitive.Regex{Pattern: `50%_off\.`, Options: "i"}, mongoFilter["name"])
+	assert.Equal(t, models.ValueTypeBool, mongoFilter["state.power.type"])
+	assert.Equal(t, true, mongoFilter["state.power.bool"])
+	assert.Equal(t, bson.M{"$gte": after}, mongoFilter["updated_at"])
+	assert.NotContains(t, mongoFilter, "created_at")
+
+	var args []interface{}
+	conditions, err := deviceConditions(filter, &args)
+	assert.NoError(t, err)
+	assert.Equal(t, `((household_id IS NULL AND user_id = $1) OR household_id = ANY($2::text[]))`+
+		` AND type = $3 AND name ILIKE '%' || $4 || '%'`+
+		` AND state -> $5 ->> 'type' = $6 AND COALESCE((state -> $5 #>> '{bool}')::boolean, false) = $7`+
+		` AND updated_at >= $8`, conditions)
+	assert.Equal(t, []interface{}{"light", `50\%\_off.`, "power", "bool", true, after}, args[2:])
+}
+
+func TestStateFilterTreatsMissingFieldsAsZero(t *testing.T) {
+	// power=false is encoded without its bool field, which rows written
+	// before may hold
+	filter := DeviceFilter{State: map[string]models.StateValue{
+		"power": {Type: models.ValueTypeBool},
+		"color": {Type: models.ValueTypeColor, Color: &models.Color{R: 255}},
+	}}
+
+	mongoFilter := devicesFilter(filter)
+	assert.Equal(t, bson.M{"$in": bson.A{false, nil}}, mongoFilter["state.power.bool"])
+	assert.Equal(t, 255, mongoFilter["state.color.color.r"])
+	assert.Equal(t, bson.M{"$in": bson.A{0, nil}}, mongoFilter["state.color.color.g"])
+
+	var args []interface{}
+	conditions, err := deviceConditions(DeviceFilter{State: powerState(false)}, &args)
+	assert.NoError(t, err)
+	assert.Equal(t, `TRUE AND state -> $1 ->> 'type' = $2 AND COALESCE((state -> $1 #>> '{bool}')::boolean, false) = $3`, conditions)
+	assert.Equal(t, []interface{}{"power", "bool", false}, args)
+}
+
+func TestLabelSelectorQueriesOfBothModels(t *testing.T) {
+	selector, err := labels.Parse("floor=2,kind!=outdoor,room in (hall),!broken,floor")
+	assert.NoError(t, err)
+	filter := DeviceFilter{Labels: selector}
+
+	mongoFilter := devicesFilter(filter)
+	assert.Equal(t, bson.A{
+		bson.M{"labels.floor": bson.M{"$eq": "2"}},
+		bson.M{"labels.kind": bson.M{"$ne": "outdoor"}},
+		bson.M{"labels.room": bson.M{"$in": []string{"hall"}}},
+		bson.M{"labels.broken": bson.M{"$exists": false}},
+		bson.M{"labels.floor": bson.M{"$exists": true}},
+	}, mongoFilter["$and"])
+
+	var args []interface{}
+	conditions, err := deviceConditions(filter, &args)
+	assert.NoError(t, err)
+	assert.Equal(t, `TRUE AND labels @> $1::jsonb AND labels ->> $2 IS DISTINCT FROM $3`+
+		` AND labels ->> $4 = ANY($5::text[]) AND NOT labels ? $6 AND labels ? $7`, conditions)
+	assert.Equal(t, []interface{}{`{"floor":"2"}`, "kind", "outdoor", "room", pq.Array([]string{"hall"}), "broken", "floor"}, args)
+}
+
+func TestTelemetryBucketPipeline(t *testing.T) {
+	from := time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC)
+	series := TelemetryRange{DeviceID: "thermometer", Metric: "temperature", From: from, To: from.Add(time.Hour)}
+
+	pipeline := telemetryBucketPipeline(series, 5*time.Minute)
+
+	assert.Equal(t, bson.M{
+		"meta.device_id": "thermometer",
+		"meta.metric":    "temperature",
+		"timestamp":      bson.M{"$gte": from, "$lt": from.Add(time.Hour)},
+	}, pipeline[0][0].Value)
+	// Buckets start at multiples of the interval in milliseconds since the epoch
+	millis := bson.M{"$toLong": "$timestamp"}
+	group := pipeline[1][0].Value.(bson.M)
+	assert.Equal(t, bson.M{"$subtract": bson.A{millis, bson.M{"$mod": bson.A{millis, int64(300000)}}}}, group["_id"])
+	assert.Equal(t, bson.M{"$avg": "$value"}, group["avg"])
+}
+
+func powerState(on bool) map[string]models.StateValue {
+	return map[string]models.StateValue{
+		"power": {Type: models.ValueTypeBool, Bool: on},
+	}
+}
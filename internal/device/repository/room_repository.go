@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/lib/pq"
+)
+
+type SQLRoomRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRoomRepository(db *sql.DB) *SQLRoomRepository {
+	return &SQLRoomRepository{db: db}
+}
+
+// roomColumns selects a room together with the IDs of the devices it contains
+const roomColumns = `
+	r.id, r.name, r.user_id, COALESCE(r.household_id, ''), r.created_at, r.updated_at,
+	COALESCE(ARRAY(SELECT d.id::text FROM devices d WHERE d.room_id = r.id ORDER BY d.name), '{}')
+`
+
+func scanRoom(row rowScanner) (*models.Room, error) {
+	var room models.Room
+	var deviceIDs pq.StringArray
+	err := row.Scan(&room.ID, &room.Name, &room.UserID, &room.HouseholdID, &room.CreatedAt, &room.UpdatedAt, &deviceIDs)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRoomNotFound
+		}
+		return nil, err
+	}
+	room.DeviceIDs = deviceIDs
+	return &room, nil
+}
+
+func (r *SQLRoomRepository) CreateRoom(ctx context.Context, room *models.Room) error {
+	query := `INSERT INTO rooms (name, user_id, household_id, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5) RETURNING id`
+	return r.db.QueryRowContext(ctx, query, room.Name, room.UserID, room.HouseholdID, room.CreatedAt, room.UpdatedAt).Scan(&room.ID)
+}
+
+func (r *SQLRoomRepository) GetRoom(ctx context.Context, id string) (*models.Room, error) {
+	query := `SELECT ` + roomColumns + ` FROM rooms r WHERE r.id = $1`
+	return scanRoom(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *SQLRoomRepository) UpdateRoom(ctx context.Context, room *models.Room) error {
+	query := `UPDATE rooms SET name = $2, updated_at = $3 WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, room.ID, room.Name, room.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRoomNotFound
+	}
+	return nil
+}
+
+// DeleteRoom removes a room. Devices still assigned to it are released by the
+// ON DELETE SET NULL constraint on devices.room_id.
+func (r *SQLRoomRepository) DeleteRoom(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM rooms WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRoomNotFound
+	}
+	return nil
+}
+
+// ListRooms lists the rooms of the given owners: their personal rooms and
+// those of their households.
+func (r *SQLRoomRepository) ListRooms(ctx context.Context, owners Owners) ([]*models.Room, error) {
+	var args []interface{}
+	query := `SELECT ` + roomColumns + ` FROM rooms r WHERE ` + ownersCondition(owners, &args) + ` ORDER BY r.name`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []*models.Room
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type SQLRuleRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRuleRepository(db *sql.DB) *SQLRuleRepository {
+	return &SQLRuleRepository{db: db}
+}
+
+// ruleColumns lists the columns read by scanRule, in order
+const ruleColumns = `id, name, user_id, enabled, trigger, conditions, actions, next_run_at, created_at, updated_at`
+
+func scanRule(row rowScanner) (*models.Rule, error) {
+	var rule models.Rule
+	var rawTrigger, rawConditions, rawActions []byte
+	var nextRunAt sql.NullTime
+	err := row.Scan(
+		&rule.ID, &rule.Name, &rule.UserID, &rule.Enabled, &rawTrigger, &rawConditions, &rawActions,
+		&nextRunAt, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRuleNotFound
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(rawTrigger, &rule.Trigger); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawConditions, &rule.Conditions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawActions, &rule.Actions); err != nil {
+		return nil, err
+	}
+	if nextRunAt.Valid {
+		rule.NextRunAt = &nextRunAt.Time
+	}
+	return &rule, nil
+}
+
+// marshalRule encodes the document columns of a rule
+func marshalRule(rule *models.Rule) (trigger, conditions, actions []byte, err error) {
+	if trigger, err = json.Marshal(rule.Trigger); err != nil {
+		return nil, nil, nil, err
+	}
+	ruleConditions := rule.Conditions
+	if ruleConditions == nil {
+		ruleConditions = []models.RuleCondition{}
+	}
+	if conditions, err = json.Marshal(ruleConditions); err != nil {
+		return nil, nil, nil, err
+	}
+	if actions, err = json.Marshal(rule.Actions); err != nil {
+		return nil, nil, nil, err
+	}
+	return trigger, conditions, actions, nil
+}
+
+func (r *SQLRuleRepository) CreateRule(ctx context.Context, rule *models.Rule) error {
+	trigger, conditions, actions, err := marshalRule(rule)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO rules (name, user_id, enabled, trigger, conditions, actions, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		rule.Name, rule.UserID, rule.Enabled, trigger, conditions, actions,
+		nullTimePtr(rule.NextRunAt), rule.CreatedAt, rule.UpdatedAt,
+	).Scan(&rule.ID)
+}
+
+func (r *SQLRuleRepository) GetRule(ctx context.Context, id string) (*models.Rule, error) {
+	query := `SELECT ` + ruleColumns + ` FROM rules WHERE id = $1`
+	return scanRule(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *SQLRuleRepository) UpdateRule(ctx context.Context, rule *models.Rule) error {
+	trigger, conditions, actions, err := marshalRule(rule)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE rules
+		SET name = $2, enabled = $3, trigger = $4, conditions = $5, actions = $6, next_run_at = $7, updated_at = $8
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query,
+		rule.ID, rule.Name, rule.Enabled, trigger, conditions, actions, nullTimePtr(rule.NextRunAt), rule.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *SQLRuleRepository) DeleteRule(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func (r *SQLRuleRepository) ListRules(ctx context.Context, userID string) ([]*models.Rule, error) {
+	query := `SELECT ` + ruleColumns + ` FROM rules WHERE user_id = $1 ORDER BY name`
+	return r.queryRules(ctx, query, userID)
+}
+
+func (r *SQLRuleRepository) ListTriggeredRules(ctx context.Context, triggerType models.TriggerType, sourceID string) ([]*models.Rule, error) {
+	var query string
+	switch triggerType {
+	case models.TriggerDeviceState:
+		query = `SELECT ` + ruleColumns + ` FROM rules WHERE enabled AND trigger->>'type' = 'device_state' AND trigger->>'device_id' = $1`
+	case models.TriggerRule:
+		query = `SELECT ` + ruleColumns + ` FROM rules WHERE enabled AND trigger->>'type' = 'rule' AND trigger->>'rule_id' = $1`
+	default:
+		return nil, nil
+	}
+	return r.queryRules(ctx, query+` ORDER BY created_at`, sourceID)
+}
+
+func (r *SQLRuleRepository) ListDueRules(ctx context.Context, now time.Time) ([]*models.Rule, error) {
+	query := `
+		SELECT ` + ruleColumns + ` FROM rules
+		WHERE enabled AND trigger->>'type' = 'time' AND next_run_at <= $1
+		ORDER BY next_run_at
+	`
+	return r.queryRules(ctx, query, now)
+}
+
+func (r *SQLRuleRepository) AdvanceRuleNextRun(ctx context.Context, id string, previous time.Time, next *time.Time) (bool, error) {
+	query := `UPDATE rules SET next_run_at = $3 WHERE id = $1 AND next_run_at = $2`
+	result, err := r.db.ExecContext(ctx, query, id, previous, nullTimePtr(next))
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *SQLRuleRepository) RecordRuleExecution(ctx context.Context, execution *models.RuleExecution) error {
+	query := `
+		INSERT INTO rule_executions (rule_id, trigger, depth, status, error, executed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id::text
+	`
+	return r.db.QueryRowContext(ctx, query,
+		execution.RuleID, execution.Trigger, execution.Depth, execution.Status, execution.Error, execution.ExecutedAt,
+	).Scan(&execution.ID)
+}
+
+// ListRuleExecutions returns the execution log of a rule, newest first
+func (r *SQLRuleRepository) ListRuleExecutions(ctx context.Context, ruleID string, page, pageSize int) ([]*models.RuleExecution, int, error) {
+	query := `
+		SELECT id::text, rule_id, trigger, depth, status, error, executed_at
+		FROM rule_executions
+		WHERE rule_id = $1
+		ORDER BY executed_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, ruleID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var executions []*models.RuleExecution
+	for rows.Next() {
+		var execution models.RuleExecution
+		err := rows.Scan(
+			&execution.ID, &execution.RuleID, &execution.Trigger, &execution.Depth,
+			&execution.Status, &execution.Error, &execution.ExecutedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		executions = append(executions, &execution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM rule_executions WHERE rule_id = $1`
+	if err := r.db.QueryRowContext(ctx, countQuery, ruleID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	return executions, total, nil
+}
+
+func (r *SQLRuleRepository) queryRules(ctx context.Context, query string, args ...interface{}) ([]*models.Rule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*models.Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
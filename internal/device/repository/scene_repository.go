@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type SQLSceneRepository struct {
+	db *sql.DB
+}
+
+func NewSQLSceneRepository(db *sql.DB) *SQLSceneRepository {
+	return &SQLSceneRepository{db: db}
+}
+
+// sceneColumns selects a scene together with its targets as a JSON array, in
+// the order they were defined
+const sceneColumns = `
+	s.id, s.name, s.user_id, s.created_at, s.updated_at,
+	COALESCE((
+		SELECT json_agg(json_build_object('device_id', t.device_id, 'state', t.state) ORDER BY t.position)
+		FROM scene_targets t WHERE t.scene_id = s.id
+	), '[]')
+`
+
+func scanScene(row rowScanner) (*models.Scene, error) {
+	var scene models.Scene
+	var rawTargets []byte
+	err := row.Scan(&scene.ID, &scene.Name, &scene.UserID, &scene.CreatedAt, &scene.UpdatedAt, &rawTargets)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSceneNotFound
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(rawTargets, &scene.Targets); err != nil {
+		return nil, err
+	}
+	return &scene, nil
+}
+
+func (r *SQLSceneRepository) CreateScene(ctx context.Context, scene *models.Scene) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO scenes (name, user_id, created_at, updated_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	err = tx.QueryRowContext(ctx, query, scene.Name, scene.UserID, scene.CreatedAt, scene.UpdatedAt).Scan(&scene.ID)
+	if err != nil {
+		return err
+	}
+	if err := insertSceneTargets(ctx, tx, scene); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLSceneRepository) GetScene(ctx context.Context, id string) (*models.Scene, error) {
+	query := `SELECT ` + sceneColumns + ` FROM scenes s WHERE s.id = $1`
+	return scanScene(r.db.QueryRowContext(ctx, query, id))
+}
+
+// UpdateScene renames a scene and replaces its targets
+func (r *SQLSceneRepository) UpdateScene(ctx context.Context, scene *models.Scene) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE scenes SET name = $2, updated_at = $3 WHERE id = $1`, scene.ID, scene.Name, scene.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSceneNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scene_targets WHERE scene_id = $1`, scene.ID); err != nil {
+		return err
+	}
+	if err := insertSceneTargets(ctx, tx, scene); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLSceneRepository) DeleteScene(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM scenes WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSceneNotFound
+	}
+	return nil
+}
+
+func (r *SQLSceneRepository) ListScenes(ctx context.Context, userID string) ([]*models.Scene, error) {
+	query := `SELECT ` + sceneColumns + ` FROM scenes s WHERE s.user_id = $1 ORDER BY s.name`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scenes []*models.Scene
+	for rows.Next() {
+		scene, err := scanScene(rows)
+		if err != nil {
+			return nil, err
+		}
+		scenes = append(scenes, scene)
+	}
+	return scenes, rows.Err()
+}
+
+func insertSceneTargets(ctx context.Context, tx *sql.Tx, scene *models.Scene) error {
+	query := `INSERT INTO scene_targets (scene_id, device_id, position, state) VALUES ($1, $2, $3, $4)`
+	for i, target := range scene.Targets {
+		state, err := json.Marshal(target.State)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, scene.ID, target.DeviceID, i, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+type SQLScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewSQLScheduleRepository(db *sql.DB) *SQLScheduleRepository {
+	return &SQLScheduleRepository{db: db}
+}
+
+// scheduleColumns lists the columns read by scanSchedule, in order
+const scheduleColumns = `id, device_id, user_id, action, cron, run_at, timezone, enabled, next_run_at, last_run_at, created_at, updated_at`
+
+func scanSchedule(row rowScanner) (*models.Schedule, error) {
+	var schedule models.Schedule
+	var rawAction []byte
+	var runAt, nextRunAt, lastRunAt sql.NullTime
+	err := row.Scan(
+		&schedule.ID, &schedule.DeviceID, &schedule.UserID, &rawAction, &schedule.Cron, &runAt,
+		&schedule.Timezone, &schedule.Enabled, &nextRunAt, &lastRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(rawAction, &schedule.Action); err != nil {
+		return nil, err
+	}
+	if runAt.Valid {
+		schedule.Time = runAt.Time
+	}
+	if nextRunAt.Valid {
+		schedule.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+	return &schedule, nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func nullTimePtr(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return nullTime(*t)
+}
+
+func (r *SQLScheduleRepository) CreateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	action, err := json.Marshal(schedule.Action)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO schedules (device_id, user_id, action, cron, run_at, timezone, enabled, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		schedule.DeviceID, schedule.UserID, action, schedule.Cron, nullTime(schedule.Time), schedule.Timezone,
+		schedule.Enabled, nullTimePtr(schedule.NextRunAt), schedule.CreatedAt, schedule.UpdatedAt,
+	).Scan(&schedule.ID)
+}
+
+func (r *SQLScheduleRepository) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE id = $1`
+	return scanSchedule(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *SQLScheduleRepository) UpdateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	action, err := json.Marshal(schedule.Action)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE schedules
+		SET action = $2, cron = $3, run_at = $4, timezone = $5, enabled = $6, next_run_at = $7, updated_at = $8
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query,
+		schedule.ID, action, schedule.Cron, nullTime(schedule.Time), schedule.Timezone,
+		schedule.Enabled, nullTimePtr(schedule.NextRunAt), schedule.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (r *SQLScheduleRepository) DeleteSchedule(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (r *SQLScheduleRepository) ListSchedules(ctx context.Context, filter ScheduleFilter) ([]*models.Schedule, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, "user_id = $1")
+	}
+	if filter.DeviceID != "" {
+		args = append(args, filter.DeviceID)
+		conditions = append(conditions, "device_id = $"+strconv.Itoa(len(args)))
+	}
+
+	query := `SELECT ` + scheduleColumns + ` FROM schedules`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSchedules(rows)
+}
+
+func (r *SQLScheduleRepository) ClaimDueSchedules(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.Schedule, error) {
+	query := `
+		UPDATE schedules SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM schedules
+			WHERE enabled AND next_run_at <= $1 AND (locked_until IS NULL OR locked_until < $1)
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + scheduleColumns
+	rows, err := r.db.QueryContext(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSchedules(rows)
+}
+
+func (r *SQLScheduleRepository) CompleteScheduleRun(ctx context.Context, schedule *models.Schedule, run *models.ScheduleRun) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	runQuery := `
+		INSERT INTO schedule_runs (schedule_id, device_id, ran_at, success, error)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id::text
+	`
+	err = tx.QueryRowContext(ctx, runQuery, run.ScheduleID, run.DeviceID, run.RanAt, run.Success, run.Error).Scan(&run.ID)
+	if err != nil {
+		return err
+	}
+
+	scheduleQuery := `
+		UPDATE schedules
+		SET enabled = $2, next_run_at = $3, last_run_at = $4, locked_until = NULL
+		WHERE id = $1
+	`
+	_, err = tx.ExecContext(ctx, scheduleQuery,
+		schedule.ID, schedule.Enabled, nullTimePtr(schedule.NextRunAt), nullTimePtr(schedule.LastRunAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListScheduleRuns returns the runs of a schedule, newest first
+func (r *SQLScheduleRepository) ListScheduleRuns(ctx context.Context, scheduleID string, page, pageSize int) ([]*models.ScheduleRun, int, error) {
+	query := `
+		SELECT id::text, schedule_id, device_id, ran_at, success, error
+		FROM schedule_runs
+		WHERE schedule_id = $1
+		ORDER BY ran_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, scheduleID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var runs []*models.ScheduleRun
+	for rows.Next() {
+		var run models.ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.DeviceID, &run.RanAt, &run.Success, &run.Error); err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM schedule_runs WHERE schedule_id = $1`
+	if err := r.db.QueryRowContext(ctx, countQuery, scheduleID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+func scanSchedules(rows *sql.Rows) ([]*models.Schedule, error) {
+	var schedules []*models.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
@@ -3,8 +3,16 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/lib/pq"
 )
 
 type SQLRepository struct {
@@ -15,21 +23,564 @@ func NewSQLRepository(db *sql.DB) *SQLRepository {
 	return &SQLRepository{db: db}
 }
 
+// deviceColumns lists the columns read by scanDevice, in order
+const deviceColumns = `id, name, type, state, user_id, room_id, created_at, updated_at, version, household_id, labels, last_seen_at, connectivity`
+
+func scanDevice(row rowScanner) (*models.Device, error) {
+	var device models.Device
+	var rawState, rawLabels []byte
+	var roomID, householdID sql.NullString
+	var lastSeenAt sql.NullTime
+	err := row.Scan(
+		&device.ID, &device.Name, &device.Type, &rawState, &device.UserID, &roomID, &device.CreatedAt, &device.UpdatedAt, &device.Version, &householdID, &rawLabels,
+		&lastSeenAt, &device.Connectivity,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(rawState, &device.State); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawLabels, &device.Labels); err != nil {
+		return nil, err
+	}
+	// Devices without labels have none, as in the read model
+	if len(device.Labels) == 0 {
+		device.Labels = nil
+	}
+	device.RoomID = roomID.String
+	device.HouseholdID = householdID.String
+	if lastSeenAt.Valid {
+		device.LastSeenAt = &lastSeenAt.Time
+	}
+	return &device, nil
+}
+
+func (r *SQLRepository) LoadDevice(ctx context.Context, id string) (*models.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE id = $1`
+	return scanDevice(r.db.QueryRowContext(ctx, query, id))
+}
+
+// CreateDevice inserts a device and records it in the device event log
 func (r *SQLRepository) CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
-	query := `INSERT INTO devices (name, type, state, user_id) VALUES ($1, $2, $3, $4) RETURNING id`
-	err := r.db.QueryRowContext(ctx, query, device.Name, device.Type, device.State, device.UserID).Scan(&device.ID)
+	state, err := json.Marshal(device.State)
+	if err != nil {
+		return nil, err
+	}
+	rawLabels, err := marshalLabels(device.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO devices (name, type, state, user_id, household_id, created_at, updated_at, labels)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+		RETURNING id, version
+	`
+	err = tx.QueryRowContext(ctx, query,
+		device.Name, device.Type, state, device.UserID, device.HouseholdID, device.CreatedAt, device.UpdatedAt, rawLabels,
+	).Scan(&device.ID, &device.Version)
 	if err != nil {
 		return nil, err
 	}
+	if err := insertDeviceEvent(ctx, tx, models.DeviceEventCreated, device); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return device, nil
 }
 
-func (r *SQLRepository) UpdateDeviceState(ctx context.Context, id string, state string) (*models.Device, error) {
-	query := `UPDATE devices SET state = $1 WHERE id = $2 RETURNING id, name, type, state, user_id`
-	var device models.Device
-	err := r.db.QueryRowContext(ctx, query, state, id).Scan(&device.ID, &device.Name, &device.Type, &device.State, &device.UserID)
+// UpdateDevice stores a device read with LoadDevice, unless it was written
+// since: the update only applies while the device is still at device.Version.
+// Its name, type, state, room, household and labels are written.
+func (r *SQLRepository) UpdateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
+	state, err := json.Marshal(device.State)
 	if err != nil {
 		return nil, err
 	}
-	return &device, nil
+	rawLabels, err := marshalLabels(device.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE devices
+		SET name = $2, type = $3, state = $4, room_id = NULLIF($5, '')::uuid, household_id = NULLIF($8, ''),
+			labels = $9, updated_at = $6, version = version + 1
+		WHERE id = $1 AND version = $7
+		RETURNING ` + deviceColumns
+	return r.updateDevice(ctx, query, device.ID, device.Name, device.Type, state, device.RoomID, device.UpdatedAt, device.Version, device.HouseholdID, rawLabels)
+}
+
+// UpdateDeviceState merges the given attributes into the stored state, leaving
+// attributes that are not part of the patch untouched. The resulting state is
+// appended to the device's state history and the device event log in the
+// same transaction. A non-zero expectedVersion makes the update fail with
+// ErrVersionMismatch unless the device is at that version.
+func (r *SQLRepository) UpdateDeviceState(ctx context.Context, id string, state map[string]models.StateValue, expectedVersion int64) (*models.Device, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	device, err := updateDeviceState(ctx, tx, DeviceStateUpdate{ID: id, State: state, ExpectedVersion: expectedVersion})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// UpdateDeviceStates applies several state updates in one transaction. The
+// devices are locked in ID order, so that batches updating the same devices
+// concurrently cannot deadlock.
+func (r *SQLRepository) UpdateDeviceStates(ctx context.Context, updates []DeviceStateUpdate) ([]*models.Device, error) {
+	order := make([]int, len(updates))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return strings.Compare(updates[a].ID, updates[b].ID) })
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	devices := make([]*models.Device, len(updates))
+	for _, i := range order {
+		if devices[i], err = updateDeviceState(ctx, tx, updates[i]); err != nil {
+			return nil, &StateUpdateError{Index: i, Err: err}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RecordHeartbeat moves last_seen_at forward and marks the device online.
+// Only a change of connectivity is recorded in the device event log, so that
+// heartbeats do not flood it.
+func (r *SQLRepository) RecordHeartbeat(ctx context.Context, id string, at time.Time) (*models.Device, models.Connectivity, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var previous models.Connectivity
+	err = tx.QueryRowContext(ctx, `SELECT connectivity FROM devices WHERE id = $1 FOR UPDATE`, id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+		UPDATE devices SET last_seen_at = GREATEST(last_seen_at, $2), connectivity = $3
+		WHERE id = $1
+		RETURNING ` + deviceColumns
+	device, err := scanDevice(tx.QueryRowContext(ctx, query, id, at, models.ConnectivityOnline))
+	if err != nil {
+		return nil, "", err
+	}
+	if previous != models.ConnectivityOnline {
+		if err := insertDeviceEvent(ctx, tx, models.DeviceEventConnectivityChanged, device); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return device, previous, nil
+}
+
+// MarkDevicesOffline marks the devices whose heartbeats stopped offline, the
+// longest silent first. Devices locked by a concurrent heartbeat, or by
+// another instance marking them, are skipped.
+func (r *SQLRepository) MarkDevicesOffline(ctx context.Context, now time.Time, defaultTimeout time.Duration, limit int) ([]*models.Device, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE devices SET connectivity = $1
+		WHERE id IN (
+			SELECT d.id FROM devices d JOIN device_types t ON t.id = d.type
+			WHERE d.connectivity = $2
+				AND d.last_seen_at < $3 - make_interval(secs => COALESCE(NULLIF(t.heartbeat_timeout_seconds, 0), $4::double precision))
+			ORDER BY d.last_seen_at
+			LIMIT $5
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING ` + deviceColumns
+	rows, err := tx.QueryContext(ctx, query, models.ConnectivityOffline, models.ConnectivityOnline, now, defaultTimeout.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	var devices []*models.Device
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, device := range devices {
+		if err := insertDeviceEvent(ctx, tx, models.DeviceEventConnectivityChanged, device); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// updateDeviceState patches the state of a device in tx, recording it in
+// the state history and the device event log
+func updateDeviceState(ctx context.Context, tx *sql.Tx, update DeviceStateUpdate) (*models.Device, error) {
+	patch, err := json.Marshal(update.State)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE devices SET state = state || $1::jsonb, updated_at = NOW(), version = version + 1
+		WHERE id = $2 AND ($3::bigint = 0 OR version = $3::bigint)
+		RETURNING ` + deviceColumns
+	device, err := scanDevice(tx.QueryRowContext(ctx, query, patch, update.ID, update.ExpectedVersion))
+	if err == ErrDeviceNotFound {
+		return nil, missingOrChanged(ctx, tx, update.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rawState, err := json.Marshal(device.State)
+	if err != nil {
+		return nil, err
+	}
+
+	historyQuery := `INSERT INTO device_state_history (device_id, state, recorded_at) VALUES ($1, $2, NOW())`
+	if _, err := tx.ExecContext(ctx, historyQuery, device.ID, rawState); err != nil {
+		return nil, err
+	}
+	if err := insertDeviceEvent(ctx, tx, models.DeviceEventUpdated, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// SetDeviceRoom assigns a device to a room, or removes it from its room when roomID is empty
+func (r *SQLRepository) SetDeviceRoom(ctx context.Context, id string, roomID string) (*models.Device, error) {
+	query := `UPDATE devices SET room_id = NULLIF($2, '')::uuid, updated_at = NOW(), version = version + 1 WHERE id = $1 RETURNING ` + deviceColumns
+	return r.updateDevice(ctx, query, id, roomID)
+}
+
+// updateDevice runs an UPDATE of the device with ID id, given as $1, returning
+// the device's columns. The updated device is recorded in the device event log
+// in the same transaction.
+func (r *SQLRepository) updateDevice(ctx context.Context, query string, id string, args ...interface{}) (*models.Device, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	device, err := scanDevice(tx.QueryRowContext(ctx, query, append([]interface{}{id}, args...)...))
+	if err == ErrDeviceNotFound {
+		return nil, missingOrChanged(ctx, tx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := insertDeviceEvent(ctx, tx, models.DeviceEventUpdated, device); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// missingOrChanged tells why an UPDATE of a device matched no row: either the
+// device does not exist, or it is no longer at the version the update expected
+func missingOrChanged(ctx context.Context, tx *sql.Tx, id string) error {
+	var version int64
+	err := tx.QueryRowContext(ctx, `SELECT version FROM devices WHERE id = $1`, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return ErrDeviceNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: device %s is at version %d", ErrVersionMismatch, id, version)
+}
+
+// DeleteDevice removes a device. Its state history, schedules and scene
+// targets are removed with it by the foreign keys, and since room membership
+// is stored on the device it leaves its room as well. The device is returned
+// as it was before being deleted.
+func (r *SQLRepository) DeleteDevice(ctx context.Context, id string) (*models.Device, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM devices WHERE id = $1 RETURNING ` + deviceColumns
+	device, err := scanDevice(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := insertDeviceEvent(ctx, tx, models.DeviceEventDeleted, device); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// ListDevices lists devices from the write model, for reads that cannot wait
+// for the read model. Devices are ordered by the sort field, creation time by
+// default, with the ID breaking ties. A page after a cursor continues past
+// that device in this order, an offset page skips devices; the total is only
+// counted when the page asks for it. Times are compared in milliseconds, as
+// in the read model, see DeviceCursor.
+func (r *SQLRepository) ListDevices(ctx context.Context, filter DeviceFilter, sort DeviceSort, page DevicePage) ([]*models.Device, int, error) {
+	var args []interface{}
+	conditions, err := deviceConditions(filter, &args)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if page.CountTotal {
+		if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM devices WHERE `+conditions, args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	direction, after := ` ASC`, ` > `
+	if sort.Descending {
+		direction, after = ` DESC`, ` < `
+	}
+	field := string(sort.field())
+	if field != string(SortByName) {
+		field = `date_trunc('milliseconds', ` + field + `)`
+	}
+	if page.After != nil {
+		args = append(args, page.After.value(sort), page.After.ID)
+		conditions += ` AND (` + field + `, id)` + after + `($` + strconv.Itoa(len(args)-1) + `, $` + strconv.Itoa(len(args)) + `::uuid)`
+	}
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE ` + conditions +
+		` ORDER BY ` + field + direction + `, id` + direction +
+		` LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
+	rows, err := r.db.QueryContext(ctx, query, append(args, page.Limit, page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var devices []*models.Device
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, total, rows.Err()
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// deviceConditions builds the condition that selects the devices of a filter,
+// appending its arguments to args
+func deviceConditions(filter DeviceFilter, args *[]interface{}) (string, error) {
+	conditions := ownersCondition(filter.Owners, args)
+	arg := func(value interface{}) string {
+		*args = append(*args, value)
+		return `$` + strconv.Itoa(len(*args))
+	}
+
+	if filter.RoomID != "" {
+		conditions += ` AND room_id = ` + arg(filter.RoomID)
+	}
+	if filter.Type != "" {
+		conditions += ` AND type = ` + arg(filter.Type)
+	}
+	if filter.NameContains != "" {
+		conditions += ` AND name ILIKE '%' || ` + arg(likeEscaper.Replace(filter.NameContains)) + ` || '%'`
+	}
+	for name, value := range filter.State {
+		attribute := `state -> ` + arg(name)
+		conditions += ` AND ` + attribute + ` ->> 'type' = ` + arg(string(value.Type))
+		for _, field := range stateFields(value) {
+			path := `'{` + strings.Join(field.path, ",") + `}'`
+			conditions += ` AND COALESCE((` + attribute + ` #>> ` + path + `)::` + field.sqlType + `, ` + field.sqlZero + `) = ` + arg(field.value)
+		}
+	}
+	for _, requirement := range filter.Labels {
+		condition, err := labelCondition(requirement, arg)
+		if err != nil {
+			return "", err
+		}
+		conditions += ` AND ` + condition
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions += ` AND created_at >= ` + arg(filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions += ` AND created_at < ` + arg(filter.CreatedBefore)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		conditions += ` AND updated_at >= ` + arg(filter.UpdatedAfter)
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		conditions += ` AND updated_at < ` + arg(filter.UpdatedBefore)
+	}
+	return conditions, nil
+}
+
+// labelCondition builds the condition of a label requirement. Equality and
+// existence are tested with the operators the GIN index on labels serves.
+func labelCondition(requirement labels.Requirement, arg func(interface{}) string) (string, error) {
+	switch requirement.Operator {
+	case labels.Equals:
+		raw, err := json.Marshal(map[string]string{requirement.Key: requirement.Values[0]})
+		if err != nil {
+			return "", err
+		}
+		return `labels @> ` + arg(string(raw)) + `::jsonb`, nil
+	case labels.NotEquals:
+		return `labels ->> ` + arg(requirement.Key) + ` IS DISTINCT FROM ` + arg(requirement.Values[0]), nil
+	case labels.In:
+		return `labels ->> ` + arg(requirement.Key) + ` = ANY(` + arg(pq.Array(requirement.Values)) + `::text[])`, nil
+	case labels.NotIn:
+		return `COALESCE(labels ->> ` + arg(requirement.Key) + ` <> ALL(` + arg(pq.Array(requirement.Values)) + `::text[]), TRUE)`, nil
+	case labels.Exists:
+		return `labels ? ` + arg(requirement.Key), nil
+	case labels.DoesNotExist:
+		return `NOT labels ? ` + arg(requirement.Key), nil
+	default:
+		return "", fmt.Errorf("unknown label operator %q", requirement.Operator)
+	}
+}
+
+// marshalLabels encodes labels for the labels column, which holds an empty
+// object for devices without labels
+func marshalLabels(deviceLabels map[string]string) ([]byte, error) {
+	if deviceLabels == nil {
+		deviceLabels = map[string]string{}
+	}
+	return json.Marshal(deviceLabels)
+}
+
+// ownersCondition builds the condition that selects the devices, or device
+// events, of owners, appending its arguments to args
+func ownersCondition(owners Owners, args *[]interface{}) string {
+	if owners.IsZero() {
+		return `TRUE`
+	}
+	*args = append(*args, owners.UserID, pq.Array(owners.HouseholdIDs))
+	userArg, householdsArg := strconv.Itoa(len(*args)-1), strconv.Itoa(len(*args))
+	return `((household_id IS NULL AND user_id = $` + userArg + `) OR household_id = ANY($` + householdsArg + `::text[]))`
+}
+
+// ScanDevices returns up to limit devices with an ID greater than afterID, in
+// ID order. The order of UUIDs is that of their canonical text, so it matches
+// the order of the same IDs in the read model.
+func (r *SQLRepository) ScanDevices(ctx context.Context, afterID string, limit int) ([]*models.Device, error) {
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE id > $1 ORDER BY id LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*models.Device
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// ListDeviceStateHistory returns the states a device went through in [from, to], newest first
+func (r *SQLRepository) ListDeviceStateHistory(ctx context.Context, deviceID string, from, to time.Time, page, pageSize int) ([]*models.DeviceState, int, error) {
+	query := `
+		SELECT device_id, state, recorded_at
+		FROM device_state_history
+		WHERE device_id = $1 AND recorded_at BETWEEN $2 AND $3
+		ORDER BY recorded_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`
+	rows, err := r.db.QueryContext(ctx, query, deviceID, from, to, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var history []*models.DeviceState
+	for rows.Next() {
+		var record models.DeviceState
+		var rawState []byte
+		if err := rows.Scan(&record.DeviceID, &rawState, &record.Timestamp); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(rawState, &record.State); err != nil {
+			return nil, 0, err
+		}
+		history = append(history, &record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM device_state_history WHERE device_id = $1 AND recorded_at BETWEEN $2 AND $3`
+	if err := r.db.QueryRowContext(ctx, countQuery, deviceID, from, to).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	return history, total, nil
 }
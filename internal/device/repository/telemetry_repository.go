@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// telemetryDocument is a reading as stored in the time-series collection.
+// Mongo buckets readings by meta, so that the readings of a metric of a
+// device are stored together.
+type telemetryDocument struct {
+	Timestamp time.Time     `bson:"timestamp"`
+	Meta      telemetryMeta `bson:"meta"`
+	Value     float64       `bson:"value"`
+}
+
+type telemetryMeta struct {
+	DeviceID string `bson:"device_id"`
+	Metric   string `bson:"metric"`
+}
+
+// telemetryBucketDocument is a bucket as computed by the aggregation, keyed
+// by its start in milliseconds since the Unix epoch
+type telemetryBucketDocument struct {
+	Start int64   `bson:"_id"`
+	Min   float64 `bson:"min"`
+	Max   float64 `bson:"max"`
+	Avg   float64 `bson:"avg"`
+	Count int64   `bson:"count"`
+}
+
+// MongoTelemetryRepository stores readings in a Mongo time-series collection
+type MongoTelemetryRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoTelemetryRepository(collection *mongo.Collection) *MongoTelemetryRepository {
+	return &MongoTelemetryRepository{collection: collection}
+}
+
+// EnsureCollection creates the time-series collection and its index, if they
+// are missing, and sets how long readings are kept. Mongo removes readings
+// older than retention; a zero retention keeps them forever. Time-series
+// collections need MongoDB 5.0.
+func (r *MongoTelemetryRepository) EnsureCollection(ctx context.Context, retention time.Duration) error {
+	timeSeries := options.TimeSeries().SetTimeField("timestamp").SetMetaField("meta").SetGranularity("seconds")
+	err := r.collection.Database().CreateCollection(ctx, r.collection.Name(), options.CreateCollection().SetTimeSeriesOptions(timeSeries))
+	var commandErr mongo.CommandError
+	if err != nil && !(errors.As(err, &commandErr) && commandErr.Name == "NamespaceExists") {
+		return err
+	}
+
+	var expireAfter interface{} = "off"
+	if retention > 0 {
+		expireAfter = int64(retention.Seconds())
+	}
+	err = r.collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: r.collection.Name()},
+		{Key: "expireAfterSeconds", Value: expireAfter},
+	}).Err()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "meta.device_id", Value: 1}, {Key: "meta.metric", Value: 1}, {Key: "timestamp", Value: 1}},
+	})
+	return err
+}
+
+// InsertTelemetry stores readings unordered, so that Mongo may write them in
+// parallel
+func (r *MongoTelemetryRepository) InsertTelemetry(ctx context.Context, points []*models.TelemetryPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, len(points))
+	for i, point := range points {
+		documents[i] = telemetryDocument{
+			Timestamp: point.Timestamp,
+			Meta:      telemetryMeta{DeviceID: point.DeviceID, Metric: point.Metric},
+			Value:     point.Value,
+		}
+	}
+	_, err := r.collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+func (r *MongoTelemetryRepository) ListTelemetry(ctx context.Context, series TelemetryRange, limit int) ([]*models.TelemetryPoint, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, telemetryFilter(series), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var points []*models.TelemetryPoint
+	for cursor.Next(ctx) {
+		var document telemetryDocument
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		points = append(points, &models.TelemetryPoint{
+			DeviceID:  document.Meta.DeviceID,
+			Metric:    document.Meta.Metric,
+			Value:     document.Value,
+			Timestamp: document.Timestamp.UTC(),
+		})
+	}
+	return points, cursor.Err()
+}
+
+func (r *MongoTelemetryRepository) AggregateTelemetry(ctx context.Context, series TelemetryRange, interval time.Duration) ([]*models.TelemetryBucket, error) {
+	cursor, err := r.collection.Aggregate(ctx, telemetryBucketPipeline(series, interval))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var buckets []*models.TelemetryBucket
+	for cursor.Next(ctx) {
+		var document telemetryBucketDocument
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, &models.TelemetryBucket{
+			Start: time.UnixMilli(document.Start).UTC(),
+			Min:   document.Min,
+			Max:   document.Max,
+			Avg:   document.Avg,
+			Count: document.Count,
+		})
+	}
+	return buckets, cursor.Err()
+}
+
+// telemetryFilter selects the readings of a TelemetryRange
+func telemetryFilter(series TelemetryRange) bson.M {
+	return bson.M{
+		"meta.device_id": series.DeviceID,
+		"meta.metric":    series.Metric,
+		"timestamp":      bson.M{"$gte": series.From, "$lt": series.To},
+	}
+}
+
+// telemetryBucketPipeline groups the readings of a TelemetryRange by the
+// interval they fall in. Timestamps are truncated to a multiple of interval in
+// milliseconds since the Unix epoch, so that buckets line up across queries.
+func telemetryBucketPipeline(series TelemetryRange, interval time.Duration) mongo.Pipeline {
+	millis := bson.M{"$toLong": "$timestamp"}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: telemetryFilter(series)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$subtract": bson.A{millis, bson.M{"$mod": bson.A{millis, interval.Milliseconds()}}}},
+			"min":   bson.M{"$min": "$value"},
+			"max":   bson.M{"$max": "$value"},
+			"avg":   bson.M{"$avg": "$value"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+}
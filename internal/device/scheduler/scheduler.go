@@ -0,0 +1,149 @@
+// Package scheduler executes device schedules when they fall due.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const (
+	// defaultLease bounds how long a claimed schedule stays locked if the
+	// instance executing it dies before recording the run
+	defaultLease     = time.Minute
+	defaultBatchSize = 100
+)
+
+// StateUpdater applies a state patch to a device. It is satisfied by
+// command.UpdateDeviceStateHandler.
+type StateUpdater interface {
+	Handle(ctx context.Context, cmd command.UpdateDeviceStateCommand) (*models.Device, error)
+}
+
+// Scheduler periodically claims due schedules, fires their action and records
+// the outcome. Several instances may run against the same database: claiming
+// locks schedules so that each run happens once.
+//
+// A schedule only fires while its owner may still control its device; a run
+// that is not allowed any more is recorded as failed.
+type Scheduler struct {
+	repo        repository.ScheduleRepository
+	devices     repository.CommandDeviceRepository
+	memberships membership.Source
+	updater     StateUpdater
+	interval    time.Duration
+	lease       time.Duration
+	batchSize   int
+	now         func() time.Time
+}
+
+// NewScheduler creates the scheduler. The households of schedule owners are
+// looked up in memberships; without memberships, schedules only fire on their
+// owner's own devices.
+func NewScheduler(repo repository.ScheduleRepository, devices repository.CommandDeviceRepository, memberships membership.Source, updater StateUpdater, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		repo:        repo,
+		devices:     devices,
+		memberships: memberships,
+		updater:     updater,
+		interval:    interval,
+		lease:       defaultLease,
+		batchSize:   defaultBatchSize,
+		now:         time.Now,
+	}
+}
+
+// Run executes due schedules every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Tick(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Scheduler tick failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick executes every schedule that is due now. A run that cannot be recorded
+// does not hold up the rest of the claimed batch: its schedule stays claimed
+// until the lease expires, and the failures are returned once the batch is
+// done.
+func (s *Scheduler) Tick(ctx context.Context) error {
+	var failed []error
+	for {
+		schedules, err := s.repo.ClaimDueSchedules(ctx, s.now(), s.lease, s.batchSize)
+		if err != nil {
+			return errors.Join(append(failed, err)...)
+		}
+		for _, schedule := range schedules {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.execute(ctx, schedule); err != nil {
+				failed = append(failed, fmt.Errorf("recording run of schedule %s: %w", schedule.ID, err))
+			}
+		}
+		if len(schedules) < s.batchSize {
+			return errors.Join(failed...)
+		}
+	}
+}
+
+// execute fires a single schedule and records the run. Recurring schedules are
+// moved to their next occurrence; one-shot schedules are disabled.
+func (s *Scheduler) execute(ctx context.Context, schedule *models.Schedule) error {
+	ranAt := s.now()
+	run := &models.ScheduleRun{
+		ScheduleID: schedule.ID,
+		DeviceID:   schedule.DeviceID,
+		RanAt:      ranAt,
+		Success:    true,
+	}
+	if err := s.fire(ctx, schedule); err != nil {
+		run.Success = false
+		run.Error = err.Error()
+	}
+
+	schedule.LastRunAt = &ranAt
+	if !schedule.Repeats() {
+		schedule.Enabled = false
+	}
+	next, err := command.NextRun(schedule, ranAt)
+	if err != nil {
+		// The schedule was valid when stored; stop firing it rather than
+		// retrying every tick
+		log.Printf("Disabling schedule %s: %v", schedule.ID, err)
+		schedule.Enabled = false
+	}
+	schedule.NextRunAt = next
+
+	return s.repo.CompleteScheduleRun(ctx, schedule, run)
+}
+
+// fire applies the schedule's action once its owner may still control the
+// device, as the schedule service checked when the schedule was saved
+func (s *Scheduler) fire(ctx context.Context, schedule *models.Schedule) error {
+	device, err := s.devices.LoadDevice(ctx, schedule.DeviceID)
+	if err != nil {
+		return err
+	}
+	if err := membership.Authorize(ctx, s.memberships, schedule.UserID, device, models.HouseholdRole.CanControlDevices); err != nil {
+		return err
+	}
+	cmd := command.UpdateDeviceStateCommand{ID: schedule.DeviceID, State: schedule.Action}
+	_, err = s.updater.Handle(ctx, cmd)
+	return err
+}
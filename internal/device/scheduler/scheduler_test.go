@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockScheduleRepository struct {
+	repository.ScheduleRepository
+	mock.Mock
+}
+
+func (m *mockScheduleRepository) ClaimDueSchedules(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.Schedule, error) {
+	args := m.Called(ctx, now, lease, limit)
+	return args.Get(0).([]*models.Schedule), args.Error(1)
+}
+
+func (m *mockScheduleRepository) CompleteScheduleRun(ctx context.Context, schedule *models.Schedule, run *models.ScheduleRun) error {
+	args := m.Called(ctx, schedule, run)
+	return args.Error(0)
+}
+
+type mockStateUpdater struct {
+	mock.Mock
+}
+
+func (m *mockStateUpdater) Handle(ctx context.Context, cmd command.UpdateDeviceStateCommand) (*models.Device, error) {
+	args := m.Called(ctx, cmd)
+	return args.Get(0).(*models.Device), args.Error(1)
+}
+
+// fakeDeviceRepository serves a fixed set of devices
+type fakeDeviceRepository struct {
+	repository.CommandDeviceRepository
+	devices map[string]*models.Device
+}
+
+func (r *fakeDeviceRepository) LoadDevice(ctx context.Context, id string) (*models.Device, error) {
+	device, ok := r.devices[id]
+	if !ok {
+		return nil, repository.ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+// fakeMemberships gives every user a fixed role in each of their households
+type fakeMemberships map[string]map[string]models.HouseholdRole
+
+func (f fakeMemberships) Households(ctx context.Context, userID string) (map[string]models.HouseholdRole, error) {
+	return f[userID], nil
+}
+
+var powerOn = map[string]models.StateValue{"power": {Type: models.ValueTypeBool, Bool: true}}
+
+// testDevices are the devices of alice, who shares the tv with bob
+var testDevices = &fakeDeviceRepository{devices: map[string]*models.Device{
+	"lamp": {ID: "lamp", UserID: "alice"},
+	"fan":  {ID: "fan", UserID: "alice"},
+	"tv":   {ID: "tv", UserID: "alice", HouseholdID: "home"},
+}}
+
+func newTestScheduler(repo *mockScheduleRepository, updater *mockStateUpdater, now time.Time) *Scheduler {
+	s := NewScheduler(repo, testDevices, fakeMemberships{"bob": {"home": models.HouseholdRoleGuest}}, updater, time.Minute)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestTickRecurringScheduleSkipsMissedRuns(t *testing.T) {
+	repo := new(mockScheduleRepository)
+	updater := new(mockStateUpdater)
+	// 07:00 daily in Sofia (UTC+3 in summer); the scheduler was down for two days
+	now := time.Date(2024, 7, 3, 5, 0, 30, 0, time.UTC)
+	missed := time.Date(2024, 7, 1, 4, 0, 0, 0, time.UTC)
+	schedule := &models.Schedule{
+		ID: "morning", UserID: "alice", DeviceID: "lamp", Action: powerOn,
+		Cron: "0 7 * * *", Timezone: "Europe/Sofia", Enabled: true, NextRunAt: &missed,
+	}
+
+	repo.On("ClaimDueSchedules", mock.Anything, now, defaultLease, defaultBatchSize).Return([]*models.Schedule{schedule}, nil)
+	updater.On("Handle", mock.Anything, command.UpdateDeviceStateCommand{ID: "lamp", State: powerOn}).Return(&models.Device{}, nil)
+	repo.On("CompleteScheduleRun", mock.Anything, schedule, mock.MatchedBy(func(run *models.ScheduleRun) bool {
+		return run.Success && run.ScheduleID == "morning" && run.RanAt.Equal(now)
+	})).Return(nil)
+
+	err := newTestScheduler(repo, updater, now).Tick(context.Background())
+
+	assert.NoError(t, err)
+	assert.True(t, schedule.Enabled)
+	assert.Equal(t, time.Date(2024, 7, 4, 4, 0, 0, 0, time.UTC), *schedule.NextRunAt)
+	assert.Equal(t, now, *schedule.LastRunAt)
+	repo.AssertExpectations(t)
+}
+
+func TestTickOneShotScheduleRecordsFailureAndDisables(t *testing.T) {
+	repo := new(mockScheduleRepository)
+	updater := new(mockStateUpdater)
+	now := time.Date(2024, 7, 3, 5, 0, 0, 0, time.UTC)
+	schedule := &models.Schedule{
+		ID: "once", UserID: "alice", DeviceID: "lamp", Action: powerOn,
+		Time: now, Timezone: "UTC", Enabled: true, NextRunAt: &now,
+	}
+
+	repo.On("ClaimDueSchedules", mock.Anything, now, defaultLease, defaultBatchSize).Return([]*models.Schedule{schedule}, nil)
+	updater.On("Handle", mock.Anything, mock.Anything).Return((*models.Device)(nil), errors.New("device offline"))
+	repo.On("CompleteScheduleRun", mock.Anything, schedule, mock.MatchedBy(func(run *models.ScheduleRun) bool {
+		return !run.Success && run.Error == "device offline"
+	})).Return(nil)
+
+	err := newTestScheduler(repo, updater, now).Tick(context.Background())
+
+	assert.NoError(t, err)
+	assert.False(t, schedule.Enabled)
+	assert.Nil(t, schedule.NextRunAt)
+	repo.AssertExpectations(t)
+}
+
+func TestTickContinuesAfterARunCannotBeRecorded(t *testing.T) {
+	repo := new(mockScheduleRepository)
+	updater := new(mockStateUpdater)
+	now := time.Date(2024, 7, 3, 5, 0, 0, 0, time.UTC)
+	first := &models.Schedule{ID: "first", UserID: "alice", DeviceID: "lamp", Action: powerOn, Time: now, Timezone: "UTC", Enabled: true, NextRunAt: &now}
+	second := &models.Schedule{ID: "second", UserID: "alice", DeviceID: "fan", Action: powerOn, Time: now, Timezone: "UTC", Enabled: true, NextRunAt: &now}
+
+	repo.On("ClaimDueSchedules", mock.Anything, now, defaultLease, defaultBatchSize).Return([]*models.Schedule{first, second}, nil)
+	updater.On("Handle", mock.Anything, mock.Anything).Return(&models.Device{}, nil)
+	repo.On("CompleteScheduleRun", mock.Anything, first, mock.Anything).Return(errors.New("connection reset"))
+	repo.On("CompleteScheduleRun", mock.Anything, second, mock.Anything).Return(nil)
+
+	err := newTestScheduler(repo, updater, now).Tick(context.Background())
+
+	assert.ErrorContains(t, err, "schedule first")
+	updater.AssertNumberOfCalls(t, "Handle", 2)
+	repo.AssertExpectations(t)
+}
+
+func TestTickRecordsRunsTheOwnerMayNoLongerMake(t *testing.T) {
+	repo := new(mockScheduleRepository)
+	updater := new(mockStateUpdater)
+	now := time.Date(2024, 7, 3, 5, 0, 0, 0, time.UTC)
+	// bob shares the tv with alice; carol left the household, and the lamp
+	// was never hers
+	shared := &models.Schedule{ID: "shared", UserID: "bob", DeviceID: "tv", Action: powerOn, Cron: "0 7 * * *", Timezone: "UTC", Enabled: true, NextRunAt: &now}
+	left := &models.Schedule{ID: "left", UserID: "carol", DeviceID: "tv", Action: powerOn, Cron: "0 7 * * *", Timezone: "UTC", Enabled: true, NextRunAt: &now}
+	foreign := &models.Schedule{ID: "foreign", UserID: "carol", DeviceID: "lamp", Action: powerOn, Cron: "0 7 * * *", Timezone: "UTC", Enabled: true, NextRunAt: &now}
+
+	repo.On("ClaimDueSchedules", mock.Anything, now, defaultLease, defaultBatchSize).Return([]*models.Schedule{shared, left, foreign}, nil)
+	updater.On("Handle", mock.Anything, command.UpdateDeviceStateCommand{ID: "tv", State: powerOn}).Return(&models.Device{}, nil).Once()
+	repo.On("CompleteScheduleRun", mock.Anything, shared, mock.MatchedBy(func(run *models.ScheduleRun) bool {
+		return run.Success
+	})).Return(nil)
+	repo.On("CompleteScheduleRun", mock.Anything, left, mock.MatchedBy(func(run *models.ScheduleRun) bool {
+		return !run.Success && run.Error == "not a member of household home"
+	})).Return(nil)
+	repo.On("CompleteScheduleRun", mock.Anything, foreign, mock.MatchedBy(func(run *models.ScheduleRun) bool {
+		return !run.Success && run.Error == "device lamp belongs to another user"
+	})).Return(nil)
+
+	err := newTestScheduler(repo, updater, now).Tick(context.Background())
+
+	assert.NoError(t, err)
+	// Recurring schedules stay enabled, so they fire again if access returns
+	assert.True(t, left.Enabled)
+	updater.AssertExpectations(t)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Permissions of a role on devices, as checked by checkDevice
+var (
+	canView    = models.HouseholdRole.Valid
+	canControl = models.HouseholdRole.CanControlDevices
+	canHistory = models.HouseholdRole.CanViewHistory
+	canManage  = models.HouseholdRole.CanManageDevices
+)
+
+// access authorizes the calls of the services on devices: users own the
+// devices outside of households, and share those of their households as far
+// as their role there allows, looked up in memberships. Without memberships,
+// users only have their own devices.
+type access struct {
+	devices     repository.CommandDeviceRepository
+	memberships membership.Source
+}
+
+// callerID returns the user a call was made on behalf of, as forwarded by the gateway
+func callerID(ctx context.Context) (string, error) {
+	userID := identity.UserIDFromIncomingContext(ctx)
+	if userID == "" {
+		return "", status.Error(codes.Unauthenticated, "call is not made on behalf of a user")
+	}
+	return userID, nil
+}
+
+// checkUser checks that the caller may act on the devices of userID
+func checkUser(callerID, userID string) error {
+	if userID != callerID {
+		return status.Error(codes.PermissionDenied, "devices of other users cannot be accessed")
+	}
+	return nil
+}
+
+// checkOwner checks that the caller owns a personal room, or a scene, rule or
+// schedule. These are never shared, not even within a household.
+func checkOwner(callerID, ownerID, kind, id string) error {
+	if ownerID != callerID {
+		return status.Errorf(codes.PermissionDenied, "%s %s belongs to another user", kind, id)
+	}
+	return nil
+}
+
+// checkRoom checks that the caller may access a room as allowed: a room of a
+// household by their role in it, any other room only if it is theirs
+func (a *access) checkRoom(ctx context.Context, callerID string, room *models.Room, allowed func(models.HouseholdRole) bool) error {
+	if room.HouseholdID == "" {
+		return checkOwner(callerID, room.UserID, "room", room.ID)
+	}
+	_, err := a.householdRole(ctx, callerID, room.HouseholdID, allowed)
+	return err
+}
+
+// ownerFor returns the user whose scenes, rules or schedules are
+// listed: the caller, unless the request names them
+func ownerFor(ctx context.Context, userID string) (string, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if userID != "" {
+		if err := checkUser(caller, userID); err != nil {
+			return "", err
+		}
+	}
+	return caller, nil
+}
+
+// households returns the caller's role in each of their households
+func (a *access) households(ctx context.Context, callerID string) (map[string]models.HouseholdRole, error) {
+	if a.memberships == nil {
+		return nil, nil
+	}
+	roles, err := a.memberships.Households(ctx, callerID)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "looking up households: %v", err)
+	}
+	return roles, nil
+}
+
+// householdRole returns the caller's role in a household, failing with
+// PermissionDenied unless the role is allowed
+func (a *access) householdRole(ctx context.Context, callerID, householdID string, allowed func(models.HouseholdRole) bool) (models.HouseholdRole, error) {
+	role, err := membership.Role(ctx, a.memberships, callerID, householdID, allowed)
+	if err != nil {
+		return "", accessStatusError(err)
+	}
+	return role, nil
+}
+
+// checkDevice checks that the caller may access a device as allowed, see
+// membership.Authorize
+func (a *access) checkDevice(ctx context.Context, callerID string, device *models.Device, allowed func(models.HouseholdRole) bool) error {
+	if err := membership.Authorize(ctx, a.memberships, callerID, device, allowed); err != nil {
+		return accessStatusError(err)
+	}
+	return nil
+}
+
+// accessStatusError maps the errors of package membership to gRPC status errors
+func accessStatusError(err error) error {
+	var denied *membership.DeniedError
+	if errors.As(err, &denied) {
+		return status.Error(codes.PermissionDenied, denied.Reason)
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// authorizeDevice checks that the caller may access the device with the
+// given ID as allowed before it is changed, and returns the device
+func (a *access) authorizeDevice(ctx context.Context, id string, allowed func(models.HouseholdRole) bool) (*models.Device, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	device, err := a.devices.LoadDevice(ctx, id)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := a.checkDevice(ctx, caller, device, allowed); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// ownersFor returns the owners of the devices or rooms the caller may list:
+// given a household, its own; given a user, those they own alone; otherwise
+// both the caller's own and those of their households
+func (a *access) ownersFor(ctx context.Context, callerID, userID, householdID string) (repository.Owners, error) {
+	switch {
+	case householdID != "":
+		if _, err := a.householdRole(ctx, callerID, householdID, canView); err != nil {
+			return repository.Owners{}, err
+		}
+		return repository.Owners{HouseholdIDs: []string{householdID}}, nil
+	case userID != "":
+		if err := checkUser(callerID, userID); err != nil {
+			return repository.Owners{}, err
+		}
+		return repository.Owners{UserID: userID}, nil
+	}
+
+	roles, err := a.households(ctx, callerID)
+	if err != nil {
+		return repository.Owners{}, err
+	}
+	owners := repository.Owners{UserID: callerID}
+	for householdID := range roles {
+		owners.HouseholdIDs = append(owners.HouseholdIDs, householdID)
+	}
+	slices.Sort(owners.HouseholdIDs)
+	return owners, nil
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxBatchUpdates is the largest number of updates in a batch
+	maxBatchUpdates = 100
+	// batchUpdateParallelism is the number of updates of a batch applied at once
+	batchUpdateParallelism = 8
+)
+
+// BatchUpdateDeviceStates applies many state updates in one call. Each
+// update is authorized and validated like UpdateDeviceState, and its outcome
+// is reported on its own instead of failing the call. Atomic batches are
+// applied in a single transaction: if any update fails, none is applied.
+//
+// Instead of listing updates, a batch may apply one state to the devices a
+// label selector selects.
+func (s *DeviceService) BatchUpdateDeviceStates(ctx context.Context, req *proto.BatchUpdateDeviceStatesRequest) (*proto.BatchUpdateDeviceStatesResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updates := req.Updates
+	switch {
+	case req.LabelSelector == "" && len(req.State) > 0:
+		return nil, status.Error(codes.InvalidArgument, "state is only applied with label_selector")
+	case req.LabelSelector != "" && len(req.Updates) > 0:
+		return nil, status.Error(codes.InvalidArgument, "updates and label_selector cannot be combined")
+	case req.LabelSelector != "" && len(req.State) == 0:
+		return nil, status.Error(codes.InvalidArgument, "label_selector needs a state to apply")
+	case req.LabelSelector != "":
+		if updates, err = s.selectedUpdates(ctx, caller, req.LabelSelector, req.State); err != nil {
+			return nil, err
+		}
+		if len(updates) == 0 {
+			return &proto.BatchUpdateDeviceStatesResponse{AllSucceeded: true}, nil
+		}
+	}
+	if err := validateBatch(updates); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]command.UpdateDeviceStateCommand, len(updates))
+	errs := make([]error, len(updates))
+	devices := make([]*models.Device, len(updates))
+	if req.Atomic {
+		// Nothing is written unless every update may be applied
+		forEachConcurrently(len(updates), func(i int) {
+			cmds[i], errs[i] = s.authorizeStateUpdate(ctx, updates[i])
+		})
+		if !hasError(errs) {
+			errs = s.applyAtomically(ctx, cmds, devices)
+		}
+		markNotApplied(errs)
+	} else {
+		forEachConcurrently(len(updates), func(i int) {
+			cmds[i], errs[i] = s.authorizeStateUpdate(ctx, updates[i])
+			if errs[i] == nil {
+				devices[i], errs[i] = s.updateDeviceHandler.Handle(ctx, cmds[i])
+			}
+		})
+	}
+
+	resp := &proto.BatchUpdateDeviceStatesResponse{AllSucceeded: true}
+	for i, update := range updates {
+		result := &proto.DeviceStateUpdateResult{DeviceId: update.Id, Status: batchStatus(errs[i])}
+		if errs[i] != nil {
+			result.Error = status.Convert(toStatusError(errs[i])).Message()
+			resp.AllSucceeded = false
+		} else {
+			result.Device = convertToProtoDevice(devices[i])
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+// selectedUpdates builds the updates that patch the devices of the caller
+// that a label selector selects with state. The devices are read from the
+// write model, so that labels just changed are taken into account.
+func (s *DeviceService) selectedUpdates(ctx context.Context, caller, selector string, state map[string]*proto.StateValue) ([]*proto.UpdateDeviceStateRequest, error) {
+	owners, err := s.ownersFor(ctx, caller, "", "")
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.listDevicesHandler.Handle(ctx, query.ListDevicesQuery{
+		Owners:        owners,
+		LabelSelector: selector,
+		Consistency:   repository.Consistency{Strong: true},
+		PageSize:      maxBatchUpdates,
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if list.NextPageToken != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "label selector %q selects more than %d devices", selector, maxBatchUpdates)
+	}
+
+	updates := make([]*proto.UpdateDeviceStateRequest, len(list.Devices))
+	for i, device := range list.Devices {
+		updates[i] = &proto.UpdateDeviceStateRequest{Id: device.ID, State: state}
+	}
+	return updates, nil
+}
+
+// validateBatch checks the size of a batch, and that it updates each device
+// at most once
+func validateBatch(updates []*proto.UpdateDeviceStateRequest) error {
+	if len(updates) == 0 {
+		return status.Error(codes.InvalidArgument, "a batch needs at least one update")
+	}
+	if len(updates) > maxBatchUpdates {
+		return status.Errorf(codes.InvalidArgument, "a batch holds at most %d updates, got %d", maxBatchUpdates, len(updates))
+	}
+	seen := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		if seen[update.Id] {
+			return status.Errorf(codes.InvalidArgument, "device %q is updated more than once", update.Id)
+		}
+		seen[update.Id] = true
+	}
+	return nil
+}
+
+// authorizeStateUpdate converts an update of a batch to its command, once the
+// caller is allowed to control the device
+func (s *DeviceService) authorizeStateUpdate(ctx context.Context, req *proto.UpdateDeviceStateRequest) (command.UpdateDeviceStateCommand, error) {
+	state, err := convertFromProtoState(req.State)
+	if err != nil {
+		return command.UpdateDeviceStateCommand{}, err
+	}
+	if _, err := s.authorizeDevice(ctx, req.Id, canControl); err != nil {
+		return command.UpdateDeviceStateCommand{}, err
+	}
+	return command.UpdateDeviceStateCommand{ID: req.Id, State: state, ExpectedVersion: req.ExpectedVersion}, nil
+}
+
+// applyAtomically applies every update of a batch, or none. It returns the
+// outcome of each; on failure only the update that failed has one.
+func (s *DeviceService) applyAtomically(ctx context.Context, cmds []command.UpdateDeviceStateCommand, devices []*models.Device) []error {
+	errs := make([]error, len(cmds))
+	updated, err := s.batchStateHandler.Handle(ctx, command.UpdateDeviceStatesCommand{Updates: cmds})
+	var updateErr *repository.StateUpdateError
+	switch {
+	case errors.As(err, &updateErr):
+		errs[updateErr.Index] = updateErr.Err
+	case err != nil:
+		for i := range errs {
+			errs[i] = err
+		}
+	default:
+		copy(devices, updated)
+	}
+	return errs
+}
+
+// markNotApplied marks the updates of a failed atomic batch that did not fail
+// themselves as not applied
+func markNotApplied(errs []error) {
+	if !hasError(errs) {
+		return
+	}
+	for i, err := range errs {
+		if err == nil {
+			errs[i] = command.ErrNotApplied
+		}
+	}
+}
+
+func hasError(errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// batchStatus names the outcome of an update of a batch
+func batchStatus(err error) string {
+	if errors.Is(err, command.ErrNotApplied) {
+		return "not_applied"
+	}
+	switch status.Code(toStatusError(err)) {
+	case codes.OK:
+		return "ok"
+	case codes.NotFound:
+		return "not_found"
+	case codes.PermissionDenied:
+		return "permission_denied"
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return "invalid_argument"
+	case codes.Aborted:
+		return "version_mismatch"
+	default:
+		return "failed"
+	}
+}
+
+// forEachConcurrently calls fn for every index below n, with at most
+// batchUpdateParallelism calls at once
+func forEachConcurrently(n int, fn func(i int)) {
+	slots := make(chan struct{}, batchUpdateParallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func powerOn(id string) *proto.UpdateDeviceStateRequest {
+	return &proto.UpdateDeviceStateRequest{Id: id, State: map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}}
+}
+
+func TestBatchUpdateDeviceStatesReportsEachDevice(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	lamp := &models.Device{ID: "lamp", Type: "switch", UserID: "user123", State: powerState(false)}
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(lamp, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "missing").Return((*models.Device)(nil), repository.ErrDeviceNotFound)
+	mockRepo.On("LoadDevice", mock.Anything, "their-lamp").Return(&models.Device{ID: "their-lamp", Type: "switch", UserID: "someone-else"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "heater").Return(&models.Device{ID: "heater", Type: "switch", UserID: "user123"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockRepo.On("UpdateDeviceState", mock.Anything, "lamp", powerState(true), int64(0)).Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user123", State: powerState(true)}, nil)
+
+	undeclared := &proto.UpdateDeviceStateRequest{Id: "heater", State: map[string]*proto.StateValue{"target": {Type: "int", IntValue: 21}}}
+	resp, err := service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{
+		Updates: []*proto.UpdateDeviceStateRequest{powerOn("lamp"), powerOn("missing"), powerOn("their-lamp"), undeclared},
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, resp.AllSucceeded)
+	var statuses []string
+	for _, result := range resp.Results {
+		statuses = append(statuses, result.Status)
+	}
+	assert.Equal(t, []string{"ok", "not_found", "permission_denied", "invalid_argument"}, statuses)
+	assert.True(t, resp.Results[0].Device.State["power"].BoolValue)
+	assert.Empty(t, resp.Results[0].Error)
+	assert.NotEmpty(t, resp.Results[1].Error)
+	mockRepo.AssertNumberOfCalls(t, "UpdateDeviceState", 1)
+}
+
+func TestAtomicBatchUpdateAppliesAllOrNothing(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user123"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "fan").Return(&models.Device{ID: "fan", Type: "switch", UserID: "user123", Version: 4}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "missing").Return((*models.Device)(nil), repository.ErrDeviceNotFound)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+
+	// A device that cannot be updated keeps the others from being written
+	resp, err := service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{
+		Updates: []*proto.UpdateDeviceStateRequest{powerOn("lamp"), powerOn("missing")},
+		Atomic:  true,
+	})
+	assert.NoError(t, err)
+	assert.False(t, resp.AllSucceeded)
+	assert.Equal(t, "not_applied", resp.Results[0].Status)
+	assert.Equal(t, "not_found", resp.Results[1].Status)
+	mockRepo.AssertNotCalled(t, "UpdateDeviceStates", mock.Anything, mock.Anything)
+
+	// Failures found while validating against the current devices are
+	// reported for the update they belong to
+	stale := powerOn("fan")
+	stale.ExpectedVersion = 3
+	resp, err = service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{
+		Updates: []*proto.UpdateDeviceStateRequest{powerOn("lamp"), stale},
+		Atomic:  true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "not_applied", resp.Results[0].Status)
+	assert.Equal(t, "version_mismatch", resp.Results[1].Status)
+	mockRepo.AssertNotCalled(t, "UpdateDeviceStates", mock.Anything, mock.Anything)
+
+	updates := []repository.DeviceStateUpdate{
+		{ID: "lamp", State: powerState(true)},
+		{ID: "fan", State: powerState(true), ExpectedVersion: 4},
+	}
+	mockRepo.On("UpdateDeviceStates", mock.Anything, updates).Return([]*models.Device{
+		{ID: "lamp", Type: "switch", UserID: "user123", State: powerState(true), Version: 1},
+		{ID: "fan", Type: "switch", UserID: "user123", State: powerState(true), Version: 5},
+	}, nil)
+
+	current := powerOn("fan")
+	current.ExpectedVersion = 4
+	resp, err = service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{
+		Updates: []*proto.UpdateDeviceStateRequest{powerOn("lamp"), current},
+		Atomic:  true,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.AllSucceeded)
+	assert.Equal(t, int64(5), resp.Results[1].Device.Version)
+	mockRepo.AssertNotCalled(t, "UpdateDeviceState", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBatchUpdateDeviceStatesBySelector(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	downstairs := []*models.Device{
+		{ID: "hall", Type: "switch", UserID: "user123", Labels: map[string]string{"floor": "0"}},
+		{ID: "porch", Type: "switch", UserID: "user123", Labels: map[string]string{"floor": "0"}},
+	}
+	filter := repository.DeviceFilter{
+		Owners: repository.Owners{UserID: "user123"},
+		Labels: labels.Selector{{Key: "floor", Operator: labels.Equals, Values: []string{"0"}}},
+	}
+	mockRepo.On("ListDevices", mock.Anything, filter, repository.DeviceSort{}, repository.Consistency{Strong: true}, repository.DevicePage{Limit: maxBatchUpdates + 1}).Return(downstairs, 0, nil)
+	for _, device := range downstairs {
+		mockRepo.On("LoadDevice", mock.Anything, device.ID).Return(device, nil)
+		mockRepo.On("UpdateDeviceState", mock.Anything, device.ID, powerState(false), int64(0)).Return(device, nil)
+	}
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+
+	resp, err := service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{
+		LabelSelector: "floor=0",
+		State:         map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: false}},
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.AllSucceeded)
+	assert.Equal(t, "hall", resp.Results[0].DeviceId)
+	assert.Equal(t, "porch", resp.Results[1].DeviceId)
+	mockRepo.AssertExpectations(t)
+
+	_, err = service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{LabelSelector: "floor=0"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{
+		LabelSelector: "floor=0",
+		State:         map[string]*proto.StateValue{"power": {Type: "bool"}},
+		Updates:       []*proto.UpdateDeviceStateRequest{powerOn("hall")},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestBatchUpdateDeviceStatesRejectsInvalidBatches(t *testing.T) {
+	service := NewDeviceService(new(MockRepository), new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+
+	tooMany := make([]*proto.UpdateDeviceStateRequest, maxBatchUpdates+1)
+	for i := range tooMany {
+		tooMany[i] = powerOn(string(rune('a' + i)))
+	}
+	tests := map[string][]*proto.UpdateDeviceStateRequest{
+		"empty":            nil,
+		"too many":         tooMany,
+		"duplicate device": {powerOn("lamp"), powerOn("lamp")},
+	}
+
+	for name, updates := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.BatchUpdateDeviceStates(asOwner(), &proto.BatchUpdateDeviceStatesRequest{Updates: updates})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
@@ -2,130 +2,468 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"slices"
 
 	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/watch"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
 
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-type DeviceRepository interface {
-	CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error)
-	GetDevice(ctx context.Context, id string) (*models.Device, error)
-	UpdateDeviceState(ctx context.Context, id string, state string) (*models.Device, error)
-	ListDevices(ctx context.Context, userID string, page, pageSize int) ([]*models.Device, int, error)
-}
-
 type DeviceService struct {
 	proto.UnimplementedDeviceServiceServer
-	repo                repository.DeviceRepository
-	createDeviceHandler *command.CreateDeviceHandler
-	updateDeviceHandler *command.UpdateDeviceStateHandler
-	getDeviceHandler    *query.GetDeviceHandler
-	listDevicesHandler  *query.ListDevicesHandler
+	access
+	repo                   repository.DeviceRepository
+	createDeviceHandler    *command.CreateDeviceHandler
+	updateHandler          *command.UpdateDeviceHandler
+	updateDeviceHandler    *command.UpdateDeviceStateHandler
+	batchStateHandler      *command.UpdateDeviceStatesHandler
+	deleteDeviceHandler    *command.DeleteDeviceHandler
+	heartbeatHandler       *command.RecordHeartbeatHandler
+	getDeviceHandler       *query.GetDeviceHandler
+	getRoomHandler         *query.GetRoomHandler
+	listDevicesHandler     *query.ListDevicesHandler
+	historyHandler         *query.GetDeviceStateHistoryHandler
+	ingestTelemetryHandler *command.IngestTelemetryHandler
+	telemetryHandler       *query.GetTelemetryHandler
+	hub                    *watch.Hub
 }
 
-func NewDeviceService(repo repository.DeviceRepository) *DeviceService {
+// NewDeviceService creates the device service. hub serves WatchDevices, and
+// sensor readings are stored in telemetryRepo.
+// Commands publish their domain events to publisher, which may be nil.
+//
+// Every call must be made on behalf of a user, forwarded by the gateway as
+// identity metadata. Users may touch their own devices, and those of their
+// households as far as their role there allows, looked up in memberships.
+// Without memberships, users only have their own devices.
+//
+// The page tokens of ListDevices are signed with pageTokenKey.
+func NewDeviceService(repo repository.DeviceRepository, typeRepo repository.DeviceTypeRepository, roomRepo repository.RoomRepository, telemetryRepo repository.TelemetryRepository, hub *watch.Hub, publisher events.Publisher, memberships membership.Source, pageTokenKey []byte) *DeviceService {
 	return &DeviceService{
-		createDeviceHandler: command.NewCreateDeviceHandler(repo),
-		updateDeviceHandler: command.NewUpdateDeviceStateHandler(repo),
-		getDeviceHandler:    query.NewGetDeviceHandler(repo),
-		listDevicesHandler:  query.NewListDevicesHandler(repo),
+		access:                 access{devices: repo, memberships: memberships},
+		repo:                   repo,
+		createDeviceHandler:    command.NewCreateDeviceHandler(repo, typeRepo, publisher),
+		updateHandler:          command.NewUpdateDeviceHandler(repo, typeRepo, roomRepo),
+		updateDeviceHandler:    command.NewUpdateDeviceStateHandler(repo, typeRepo, publisher),
+		batchStateHandler:      command.NewUpdateDeviceStatesHandler(repo, typeRepo, publisher),
+		deleteDeviceHandler:    command.NewDeleteDeviceHandler(repo, publisher),
+		heartbeatHandler:       command.NewRecordHeartbeatHandler(repo, publisher),
+		getDeviceHandler:       query.NewGetDeviceHandler(repo),
+		getRoomHandler:         query.NewGetRoomHandler(roomRepo),
+		listDevicesHandler:     query.NewListDevicesHandler(repo, query.NewPageTokens(pageTokenKey)),
+		historyHandler:         query.NewGetDeviceStateHistoryHandler(repo),
+		ingestTelemetryHandler: command.NewIngestTelemetryHandler(telemetryRepo),
+		telemetryHandler:       query.NewGetTelemetryHandler(telemetryRepo),
+		hub:                    hub,
 	}
 }
 
 func (s *DeviceService) CreateDevice(ctx context.Context, req *proto.CreateDeviceRequest) (*proto.Device, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Devices are created for the caller unless another user is named
+	userID := req.UserId
+	if userID == "" {
+		userID = caller
+	}
+	if err := checkUser(caller, userID); err != nil {
+		return nil, err
+	}
+	if req.HouseholdId != "" {
+		if _, err := s.householdRole(ctx, caller, req.HouseholdId, canManage); err != nil {
+			return nil, err
+		}
+	}
+
 	cmd := command.CreateDeviceCommand{
-		Name:   req.Name,
-		Type:   req.Type,
-		UserID: req.UserId,
+		Name:        req.Name,
+		Type:        req.Type,
+		UserID:      userID,
+		HouseholdID: req.HouseholdId,
+		Labels:      req.Labels,
 	}
 
 	device, err := s.createDeviceHandler.Handle(ctx, cmd)
 	if err != nil {
-		return nil, err
+		return nil, toStatusError(err)
 	}
 
-	return &proto.Device{
-		Id:     device.ID,
-		Name:   device.Name,
-		Type:   device.Type,
-		State:  device.State,
-		UserId: device.UserID,
-	}, nil
+	return convertToProtoDevice(device), nil
 }
 
 func (s *DeviceService) GetDevice(ctx context.Context, req *proto.GetDeviceRequest) (*proto.Device, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	consistency, err := readConsistency(req.Consistency, req.ConsistencyToken)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
 	query := query.GetDeviceQuery{
-		ID: req.Id,
+		ID:          req.Id,
+		Consistency: consistency,
 	}
 
 	device, err := s.getDeviceHandler.Handle(ctx, query)
 	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := s.checkDevice(ctx, caller, device, canView); err != nil {
 		return nil, err
 	}
 
-	return &proto.Device{
-		Id:     device.ID,
-		Name:   device.Name,
-		Type:   device.Type,
-		State:  device.State,
-		UserId: device.UserID,
-	}, nil
+	return convertToProtoDevice(device), nil
+}
+
+func (s *DeviceService) UpdateDevice(ctx context.Context, req *proto.UpdateDeviceRequest) (*proto.Device, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.authorizeDevice(ctx, req.Id, canManage); err != nil {
+		return nil, err
+	}
+	fields := req.GetUpdateMask().GetPaths()
+	// Moving a device into a household takes managing its devices
+	if slices.Contains(fields, command.DeviceFieldHouseholdID) && req.HouseholdId != "" {
+		if _, err := s.householdRole(ctx, caller, req.HouseholdId, canManage); err != nil {
+			return nil, err
+		}
+	}
+	// Household devices go in rooms of their household, which the caller
+	// must be allowed to manage
+	if req.RoomId != "" && (len(fields) == 0 || slices.Contains(fields, command.DeviceFieldRoomID)) {
+		room, err := s.getRoomHandler.Handle(ctx, query.GetRoomQuery{ID: req.RoomId})
+		if err != nil {
+			return nil, toStatusError(err)
+		}
+		if err := s.checkRoom(ctx, caller, room, canManage); err != nil {
+			return nil, err
+		}
+	}
+	cmd := command.UpdateDeviceCommand{
+		ID:              req.Id,
+		Name:            req.Name,
+		Type:            req.Type,
+		RoomID:          req.RoomId,
+		HouseholdID:     req.HouseholdId,
+		Labels:          req.Labels,
+		Fields:          fields,
+		ExpectedVersion: req.ExpectedVersion,
+	}
+
+	device, err := s.updateHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	return convertToProtoDevice(device), nil
 }
 
 func (s *DeviceService) UpdateDeviceState(ctx context.Context, req *proto.UpdateDeviceStateRequest) (*proto.Device, error) {
+	state, err := convertFromProtoState(req.State)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if _, err := s.authorizeDevice(ctx, req.Id, canControl); err != nil {
+		return nil, err
+	}
+
 	cmd := command.UpdateDeviceStateCommand{
-		ID:    req.Id,
-		State: req.State,
+		ID:              req.Id,
+		State:           state,
+		ExpectedVersion: req.ExpectedVersion,
 	}
 
 	device, err := s.updateDeviceHandler.Handle(ctx, cmd)
 	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	return convertToProtoDevice(device), nil
+}
+
+// ReportHeartbeat records that a device is alive and marks it online. A
+// device that stops reporting is marked offline by connectivity.Sweeper.
+func (s *DeviceService) ReportHeartbeat(ctx context.Context, req *proto.ReportHeartbeatRequest) (*proto.Device, error) {
+	if _, err := s.authorizeDevice(ctx, req.Id, canControl); err != nil {
 		return nil, err
 	}
+	device, err := s.heartbeatHandler.Handle(ctx, command.RecordHeartbeatCommand{ID: req.Id})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoDevice(device), nil
+}
 
-	return &proto.Device{
-		Id:     device.ID,
-		Name:   device.Name,
-		Type:   device.Type,
-		State:  device.State,
-		UserId: device.UserID,
-	}, nil
+func (s *DeviceService) DeleteDevice(ctx context.Context, req *proto.DeleteDeviceRequest) (*proto.DeleteDeviceResponse, error) {
+	if _, err := s.authorizeDevice(ctx, req.Id, canManage); err != nil {
+		return nil, err
+	}
+	device, err := s.deleteDeviceHandler.Handle(ctx, command.DeleteDeviceCommand{ID: req.Id})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &proto.DeleteDeviceResponse{Success: true, ConsistencyToken: consistencyToken(device)}, nil
 }
 
 func (s *DeviceService) ListDevices(ctx context.Context, req *proto.ListDevicesRequest) (*proto.ListDevicesResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := s.ownersFor(ctx, caller, req.UserId, req.HouseholdId)
+	if err != nil {
+		return nil, err
+	}
+	consistency, err := readConsistency(req.Consistency, req.ConsistencyToken)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	sort, err := query.ParseDeviceSort(req.OrderBy)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	var state map[string]models.StateValue
+	if len(req.State) > 0 {
+		if state, err = convertFromProtoState(req.State); err != nil {
+			return nil, toStatusError(err)
+		}
+	}
 	query := query.ListDevicesQuery{
-		UserID:   req.UserId,
-		Page:     int(req.Page),
-		PageSize: int(req.PageSize),
+		Owners:        owners,
+		RoomID:        req.RoomId,
+		Type:          req.Type,
+		NameContains:  req.NameContains,
+		State:         state,
+		LabelSelector: req.LabelSelector,
+		Sort:          sort,
+		Consistency:   consistency,
+		PageToken:     req.PageToken,
+		PageSize:      int(req.PageSize),
+		IncludeTotal:  req.IncludeTotal,
+		Page:          int(req.Page),
+	}
+	if req.CreatedAfter != nil {
+		query.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		query.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+	if req.UpdatedAfter != nil {
+		query.UpdatedAfter = req.UpdatedAfter.AsTime()
+	}
+	if req.UpdatedBefore != nil {
+		query.UpdatedBefore = req.UpdatedBefore.AsTime()
 	}
 
-	devices, count, err := s.listDevicesHandler.Handle(ctx, query)
+	list, err := s.listDevicesHandler.Handle(ctx, query)
 	if err != nil {
-		return nil, err
+		return nil, toStatusError(err)
 	}
-	protoDevices := make([]*proto.Device, len(devices))
-	for i, device := range devices {
+	protoDevices := make([]*proto.Device, len(list.Devices))
+	for i, device := range list.Devices {
 		protoDevices[i] = convertToProtoDevice(device)
 	}
 	return &proto.ListDevicesResponse{
-		Devices: protoDevices,
+		Devices:       protoDevices,
+		Total:         int32(list.Total),
+		NextPageToken: list.NextPageToken,
+	}, nil
+}
+
+func (s *DeviceService) GetDeviceStateHistory(ctx context.Context, req *proto.GetDeviceStateHistoryRequest) (*proto.GetDeviceStateHistoryResponse, error) {
+	if _, err := s.authorizeDevice(ctx, req.DeviceId, canHistory); err != nil {
+		return nil, err
+	}
+	query := query.GetDeviceStateHistoryQuery{
+		DeviceID: req.DeviceId,
+		Page:     int(req.Page),
+		PageSize: int(req.PageSize),
+	}
+	if req.From != nil {
+		query.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		query.To = req.To.AsTime()
+	}
+
+	history, count, err := s.historyHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	protoHistory := make([]*proto.DeviceState, len(history))
+	for i, record := range history {
+		protoHistory[i] = &proto.DeviceState{
+			DeviceId:  record.DeviceID,
+			State:     convertToProtoState(record.State),
+			Timestamp: timestamppb.New(record.Timestamp),
+		}
+	}
+	return &proto.GetDeviceStateHistoryResponse{
+		History: protoHistory,
 		Total:   int32(count),
 	}, nil
 }
 
+// WatchDevices streams the changes to the caller's devices, and those of their
+// households, until the client goes away
+func (s *DeviceService) WatchDevices(req *proto.WatchDevicesRequest, stream proto.DeviceService_WatchDevicesServer) error {
+	caller, err := callerID(stream.Context())
+	if err != nil {
+		return err
+	}
+	if req.UserId != "" {
+		if err := checkUser(caller, req.UserId); err != nil {
+			return err
+		}
+	}
+	// Households joined after the watch started are not included
+	owners, err := s.ownersFor(stream.Context(), caller, "", "")
+	if err != nil {
+		return err
+	}
+
+	if req.ResumeToken != "" {
+		if _, err := watch.ParseResumeToken(req.ResumeToken); err != nil {
+			return toStatusError(err)
+		}
+	}
+	// Sending headers right away tells clients the watch was accepted
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	filter := watch.Filter{Owners: owners, DeviceIDs: req.DeviceIds, Type: req.Type}
+	err = s.hub.Watch(stream.Context(), filter, req.ResumeToken, func(event *models.DeviceEvent) error {
+		return stream.Send(&proto.DeviceEvent{
+			Type:        string(event.Type),
+			Device:      convertToProtoDevice(&event.Device),
+			ResumeToken: watch.ResumeToken(event.Position),
+			OccurredAt:  timestamppb.New(event.OccurredAt),
+		})
+	})
+	return toStatusError(err)
+}
+
+// readConsistency reads the consistency of a device query: "eventual", the
+// default, or "strong", and the token of a write the read must reflect
+func readConsistency(level, token string) (repository.Consistency, error) {
+	var consistency repository.Consistency
+	switch level {
+	case "", "eventual":
+	case "strong":
+		consistency.Strong = true
+	default:
+		return consistency, fmt.Errorf("%w %q, expected eventual or strong", query.ErrInvalidConsistency, level)
+	}
+	if token != "" {
+		position, err := watch.ParseResumeToken(token)
+		if err != nil {
+			return consistency, fmt.Errorf("%w token", query.ErrInvalidConsistency)
+		}
+		consistency.After = &position
+	}
+	return consistency, nil
+}
+
+// consistencyToken returns the token of the write that returned a device.
+// Tokens are positions in the device event log, like resume tokens.
+func consistencyToken(device *models.Device) string {
+	if device.WritePosition == nil {
+		return ""
+	}
+	return watch.ResumeToken(*device.WritePosition)
+}
+
 func convertToProtoDevice(device *models.Device) *proto.Device {
-	return &proto.Device{
-		Id:        device.ID,
-		Name:      device.Name,
-		Type:      device.Type,
-		State:     device.State,
-		UserId:    device.UserID,
-		CreatedAt: timestamppb.New(device.CreatedAt),
-		UpdatedAt: timestamppb.New(device.UpdatedAt),
+	protoDevice := &proto.Device{
+		Id:               device.ID,
+		Name:             device.Name,
+		Type:             device.Type,
+		State:            convertToProtoState(device.State),
+		UserId:           device.UserID,
+		RoomId:           device.RoomID,
+		CreatedAt:        timestamppb.New(device.CreatedAt),
+		UpdatedAt:        timestamppb.New(device.UpdatedAt),
+		ConsistencyToken: consistencyToken(device),
+		Version:          device.Version,
+		HouseholdId:      device.HouseholdID,
+		Labels:           device.Labels,
+		Connectivity:     string(models.ConnectivityUnknown),
+	}
+	if device.LastSeenAt != nil {
+		protoDevice.LastSeenAt = timestamppb.New(*device.LastSeenAt)
+	}
+	if device.Connectivity != "" {
+		protoDevice.Connectivity = string(device.Connectivity)
+	}
+	return protoDevice
+}
+
+func convertToProtoState(state map[string]models.StateValue) map[string]*proto.StateValue {
+	protoState := make(map[string]*proto.StateValue, len(state))
+	for name, value := range state {
+		protoState[name] = convertToProtoStateValue(value)
+	}
+	return protoState
+}
+
+func convertToProtoStateValue(value models.StateValue) *proto.StateValue {
+	protoValue := &proto.StateValue{
+		Type:       string(value.Type),
+		BoolValue:  value.Bool,
+		IntValue:   value.Int,
+		FloatValue: value.Float,
+		EnumValue:  value.Enum,
+	}
+	if value.Color != nil {
+		protoValue.ColorValue = &proto.Color{
+			R: uint32(value.Color.R),
+			G: uint32(value.Color.G),
+			B: uint32(value.Color.B),
+		}
+	}
+	return protoValue
+}
+
+func convertFromProtoState(protoState map[string]*proto.StateValue) (map[string]models.StateValue, error) {
+	state := make(map[string]models.StateValue, len(protoState))
+	for name, protoValue := range protoState {
+		value, err := convertFromProtoStateValue(name, protoValue)
+		if err != nil {
+			return nil, err
+		}
+		state[name] = value
+	}
+	return state, nil
+}
+
+func convertFromProtoStateValue(name string, protoValue *proto.StateValue) (models.StateValue, error) {
+	value := models.StateValue{
+		Type:  models.ValueType(protoValue.GetType()),
+		Bool:  protoValue.GetBoolValue(),
+		Int:   protoValue.GetIntValue(),
+		Float: protoValue.GetFloatValue(),
+		Enum:  protoValue.GetEnumValue(),
+	}
+	if c := protoValue.GetColorValue(); c != nil {
+		if c.R > 255 || c.G > 255 || c.B > 255 {
+			return models.StateValue{}, fmt.Errorf("%w: attribute %q has a color component above 255", command.ErrInvalidState, name)
+		}
+		value.Color = &models.Color{R: uint8(c.R), G: uint8(c.G), B: uint8(c.B)}
 	}
+	return value, nil
 }
@@ -2,42 +2,95 @@ package service
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/labels"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// MockRepository is a mock implementation of the DeviceRepository interface
+// MockRepository is a mock implementation of repository.DeviceRepository
 type MockRepository struct {
 	mock.Mock
 }
 
+func (m *MockRepository) LoadDevice(ctx context.Context, id string) (*models.Device, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Device), args.Error(1)
+}
+
 func (m *MockRepository) CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
 	args := m.Called(ctx, device)
 	return args.Get(0).(*models.Device), args.Error(1)
 }
 
-func (m *MockRepository) GetDevice(ctx context.Context, id string) (*models.Device, error) {
-	args := m.Called(ctx, id)
+func (m *MockRepository) GetDevice(ctx context.Context, id string, consistency repository.Consistency) (*models.Device, error) {
+	args := m.Called(ctx, id, consistency)
+	return args.Get(0).(*models.Device), args.Error(1)
+}
+
+func (m *MockRepository) UpdateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
+	args := m.Called(ctx, device)
 	return args.Get(0).(*models.Device), args.Error(1)
 }
 
-func (m *MockRepository) UpdateDeviceState(ctx context.Context, id string, state string) (*models.Device, error) {
-	args := m.Called(ctx, id, state)
+func (m *MockRepository) UpdateDeviceState(ctx context.Context, id string, state map[string]models.StateValue, expectedVersion int64) (*models.Device, error) {
+	args := m.Called(ctx, id, state, expectedVersion)
+	return args.Get(0).(*models.Device), args.Error(1)
+}
+
+func (m *MockRepository) UpdateDeviceStates(ctx context.Context, updates []repository.DeviceStateUpdate) ([]*models.Device, error) {
+	args := m.Called(ctx, updates)
+	devices, _ := args.Get(0).([]*models.Device)
+	return devices, args.Error(1)
+}
+
+func (m *MockRepository) SetDeviceRoom(ctx context.Context, id string, roomID string) (*models.Device, error) {
+	args := m.Called(ctx, id, roomID)
 	return args.Get(0).(*models.Device), args.Error(1)
 }
 
-func (m *MockRepository) ListDevices(ctx context.Context, userID string, page, pageSize int) ([]*models.Device, int, error) {
-	args := m.Called(ctx, userID, page, pageSize)
+func (m *MockRepository) DeleteDevice(ctx context.Context, id string) (*models.Device, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Device), args.Error(1)
+}
+
+func (m *MockRepository) RecordHeartbeat(ctx context.Context, id string, at time.Time) (*models.Device, models.Connectivity, error) {
+	args := m.Called(ctx, id, at)
+	return args.Get(0).(*models.Device), args.Get(1).(models.Connectivity), args.Error(2)
+}
+
+func (m *MockRepository) ListDevices(ctx context.Context, filter repository.DeviceFilter, sort repository.DeviceSort, consistency repository.Consistency, page repository.DevicePage) ([]*models.Device, int, error) {
+	args := m.Called(ctx, filter, sort, consistency, page)
 	return args.Get(0).([]*models.Device), args.Int(1), args.Error(2)
 }
 
+func (m *MockRepository) ListDeviceStateHistory(ctx context.Context, deviceID string, from, to time.Time, page, pageSize int) ([]*models.DeviceState, int, error) {
+	args := m.Called(ctx, deviceID, from, to, page, pageSize)
+	return args.Get(0).([]*models.DeviceState), args.Int(1), args.Error(2)
+}
+
+// asOwner returns the context of a call made on behalf of user123, who owns
+// the devices of these tests
+func asOwner() context.Context {
+	return identity.NewIncomingContext(context.Background(), "user123")
+}
+
 func TestCreateDevice(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewDeviceService(mockRepo)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
 
 	req := &proto.CreateDeviceRequest{
 		Name:   "Test Device",
@@ -49,88 +102,129 @@ func TestCreateDevice(t *testing.T) {
 		ID:     "device123",
 		Name:   req.Name,
 		Type:   req.Type,
-		State:  "Off",
+		State:  powerState(false),
 		UserID: req.UserId,
 	}
 
-	mockRepo.On("CreateDevice", mock.Anything, mock.AnythingOfType("*models.Device")).Return(expectedDevice, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "Sensor").Return(switchType("Sensor"), nil)
+	mockRepo.On("CreateDevice", mock.Anything, mock.MatchedBy(func(device *models.Device) bool {
+		return assert.ObjectsAreEqual(powerState(false), device.State)
+	})).Return(expectedDevice, nil)
 
-	response, err := service.CreateDevice(context.Background(), req)
+	response, err := service.CreateDevice(asOwner(), req)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedDevice.ID, response.Id)
 	assert.Equal(t, expectedDevice.Name, response.Name)
 	assert.Equal(t, expectedDevice.Type, response.Type)
-	assert.Equal(t, expectedDevice.State, response.State)
+	assert.Equal(t, expectedDevice.State["power"].Bool, response.State["power"].BoolValue)
 	assert.Equal(t, expectedDevice.UserID, response.UserId)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetDevice(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewDeviceService(mockRepo)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
 
 	expectedDevice := &models.Device{
 		ID:     "device123",
 		Name:   "Test Device",
 		Type:   "Sensor",
-		State:  "Off",
+		State:  powerState(false),
 		UserID: "user123",
 	}
 
-	mockRepo.On("GetDevice", mock.Anything, "device123").Return(expectedDevice, nil)
+	mockRepo.On("GetDevice", mock.Anything, "device123", repository.Consistency{}).Return(expectedDevice, nil)
 
 	req := &proto.GetDeviceRequest{Id: "device123"}
-	response, err := service.GetDevice(context.Background(), req)
+	response, err := service.GetDevice(asOwner(), req)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedDevice.ID, response.Id)
 	assert.Equal(t, expectedDevice.Name, response.Name)
 	assert.Equal(t, expectedDevice.Type, response.Type)
-	assert.Equal(t, expectedDevice.State, response.State)
+	assert.Equal(t, expectedDevice.State["power"].Bool, response.State["power"].BoolValue)
 	assert.Equal(t, expectedDevice.UserID, response.UserId)
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetDeviceReadsYourWrites(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	written := models.EventPosition{TxID: 42, Seq: 3}
+	mockRepo.On("LoadDevice", mock.Anything, "device123").Return(&models.Device{ID: "device123", UserID: "user123"}, nil)
+	mockRepo.On("DeleteDevice", mock.Anything, "device123").Return(&models.Device{ID: "device123", WritePosition: &written}, nil)
+	mockRepo.On("GetDevice", mock.Anything, "device123", repository.Consistency{After: &written}).Return((*models.Device)(nil), repository.ErrDeviceNotFound)
+	mockRepo.On("GetDevice", mock.Anything, "device123", repository.Consistency{Strong: true}).Return((*models.Device)(nil), repository.ErrDeviceNotFound)
+
+	resp, err := service.DeleteDevice(asOwner(), &proto.DeleteDeviceRequest{Id: "device123"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.ConsistencyToken)
+
+	_, err = service.GetDevice(asOwner(), &proto.GetDeviceRequest{Id: "device123", ConsistencyToken: resp.ConsistencyToken})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = service.GetDevice(asOwner(), &proto.GetDeviceRequest{Id: "device123", Consistency: "strong"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = service.GetDevice(asOwner(), &proto.GetDeviceRequest{Id: "device123", Consistency: "linearizable"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.GetDevice(asOwner(), &proto.GetDeviceRequest{Id: "device123", ConsistencyToken: "not-a-token"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockRepo.AssertExpectations(t)
+}
+
 func TestUpdateDeviceState(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewDeviceService(mockRepo)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
 
 	updatedDevice := &models.Device{
 		ID:     "device123",
 		Name:   "Test Device",
 		Type:   "Sensor",
-		State:  "On",
+		State:  powerState(true),
 		UserID: "user123",
 	}
 
-	mockRepo.On("UpdateDeviceState", mock.Anything, "device123", "On").Return(updatedDevice, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "device123").Return(updatedDevice, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "Sensor").Return(switchType("Sensor"), nil)
+	mockRepo.On("UpdateDeviceState", mock.Anything, "device123", powerState(true), int64(0)).Return(updatedDevice, nil)
 
-	req := &proto.UpdateDeviceStateRequest{Id: "device123", State: "On"}
-	response, err := service.UpdateDeviceState(context.Background(), req)
+	req := &proto.UpdateDeviceStateRequest{
+		Id: "device123",
+		State: map[string]*proto.StateValue{
+			"power": {Type: "bool", BoolValue: true},
+		},
+	}
+	response, err := service.UpdateDeviceState(asOwner(), req)
 
 	assert.NoError(t, err)
 	assert.Equal(t, updatedDevice.ID, response.Id)
 	assert.Equal(t, updatedDevice.Name, response.Name)
 	assert.Equal(t, updatedDevice.Type, response.Type)
-	assert.Equal(t, "On", response.State)
+	assert.Equal(t, "bool", response.State["power"].Type)
+	assert.True(t, response.State["power"].BoolValue)
 	assert.Equal(t, updatedDevice.UserID, response.UserId)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestListDevices(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewDeviceService(mockRepo)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
 
 	devices := []*models.Device{
-		{ID: "device1", Name: "Device 1", Type: "Sensor", State: "Off", UserID: "user123"},
-		{ID: "device2", Name: "Device 2", Type: "Actuator", State: "On", UserID: "user123"},
+		{ID: "device1", Name: "Device 1", Type: "Sensor", State: powerState(false), UserID: "user123"},
+		{ID: "device2", Name: "Device 2", Type: "Actuator", State: powerState(true), UserID: "user123"},
 	}
 
-	mockRepo.On("ListDevices", mock.Anything, "user123", 1, 10).Return(devices, 2, nil)
+	mockRepo.On("ListDevices", mock.Anything, repository.DeviceFilter{Owners: repository.Owners{UserID: "user123"}}, repository.DeviceSort{}, repository.Consistency{}, repository.DevicePage{Limit: 11, CountTotal: true}).Return(devices, 2, nil)
 
 	req := &proto.ListDevicesRequest{UserId: "user123", Page: 1, PageSize: 10}
-	response, err := service.ListDevices(context.Background(), req)
+	response, err := service.ListDevices(asOwner(), req)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(response.Devices))
@@ -139,8 +233,537 @@ func TestListDevices(t *testing.T) {
 		assert.Equal(t, devices[i].ID, device.Id)
 		assert.Equal(t, devices[i].Name, device.Name)
 		assert.Equal(t, devices[i].Type, device.Type)
-		assert.Equal(t, devices[i].State, device.State)
+		assert.Equal(t, devices[i].State["power"].Bool, device.State["power"].BoolValue)
 		assert.Equal(t, devices[i].UserID, device.UserId)
 	}
 	mockRepo.AssertExpectations(t)
 }
+
+func TestGetDeviceStateHistory(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+
+	from := time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 2, 6, 0, 0, 0, time.UTC)
+	history := []*models.DeviceState{
+		{DeviceID: "garage", State: powerState(true), Timestamp: to.Add(-2 * time.Hour)},
+		{DeviceID: "garage", State: powerState(false), Timestamp: from.Add(time.Hour)},
+	}
+
+	// Unset paging falls back to the first page of the default size
+	mockRepo.On("LoadDevice", mock.Anything, "garage").Return(&models.Device{ID: "garage", UserID: "user123"}, nil)
+	mockRepo.On("ListDeviceStateHistory", mock.Anything, "garage", from, to, 1, 50).Return(history, 2, nil)
+
+	req := &proto.GetDeviceStateHistoryRequest{
+		DeviceId: "garage",
+		From:     timestamppb.New(from),
+		To:       timestamppb.New(to),
+	}
+	response, err := service.GetDeviceStateHistory(asOwner(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), response.Total)
+	assert.Len(t, response.History, 2)
+	assert.True(t, response.History[0].State["power"].BoolValue)
+	assert.Equal(t, history[0].Timestamp, response.History[0].Timestamp.AsTime())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetDeviceStateHistoryRejectsInvertedRange(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("LoadDevice", mock.Anything, "garage").Return(&models.Device{ID: "garage", UserID: "user123"}, nil)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+
+	now := time.Now()
+	req := &proto.GetDeviceStateHistoryRequest{
+		DeviceId: "garage",
+		From:     timestamppb.New(now),
+		To:       timestamppb.New(now.Add(-time.Hour)),
+	}
+	_, err := service.GetDeviceStateHistory(asOwner(), req)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func switchType(id string) *models.DeviceType {
+	return &models.DeviceType{
+		ID:   id,
+		Name: id,
+		Capabilities: []models.Capability{
+			{Name: "power", Type: models.ValueTypeBool, Default: &models.StateValue{Type: models.ValueTypeBool}},
+		},
+	}
+}
+
+func powerState(on bool) map[string]models.StateValue {
+	return map[string]models.StateValue{
+		"power": {Type: models.ValueTypeBool, Bool: on},
+	}
+}
+
+func TestUpdateDeviceStateRejectsInvalidState(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	mockRepo.On("LoadDevice", mock.Anything, "device123").Return(&models.Device{ID: "device123", Type: "switch", UserID: "user123"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	tests := map[string]map[string]*proto.StateValue{
+		"empty patch":     {},
+		"unknown type":    {"power": {Type: "string"}},
+		"bad name":        {"Power.On": {Type: "bool", BoolValue: true}},
+		"missing color":   {"color": {Type: "color"}},
+		"color too large": {"color": {Type: "color", ColorValue: &proto.Color{R: 256}}},
+	}
+
+	for name, state := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &proto.UpdateDeviceStateRequest{Id: "device123", State: state}
+			_, err := service.UpdateDeviceState(asOwner(), req)
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+	mockRepo.AssertNotCalled(t, "UpdateDeviceState", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateDeviceRejectsUnknownType(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "lgiht").Return((*models.DeviceType)(nil), repository.ErrDeviceTypeNotFound)
+
+	req := &proto.CreateDeviceRequest{Name: "Desk lamp", Type: "lgiht", UserId: "user123"}
+	_, err := service.CreateDevice(asOwner(), req)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockRepo.AssertNotCalled(t, "CreateDevice", mock.Anything, mock.Anything)
+}
+
+func TestUpdateDeviceStateValidatesAgainstType(t *testing.T) {
+	minBrightness, maxBrightness := 0.0, 100.0
+	dimmer := &models.DeviceType{
+		ID:   "dimmer",
+		Name: "Dimmable light",
+		Capabilities: []models.Capability{
+			{Name: "power", Type: models.ValueTypeBool},
+			{Name: "brightness", Type: models.ValueTypeInt, Min: &minBrightness, Max: &maxBrightness},
+			{Name: "effect", Type: models.ValueTypeEnum, Values: []string{"none", "candle"}},
+		},
+	}
+	device := &models.Device{ID: "device123", Type: "dimmer", State: powerState(false), UserID: "user123"}
+
+	tests := map[string]map[string]*proto.StateValue{
+		"undeclared attribute": {"color": {Type: "color", ColorValue: &proto.Color{R: 1}}},
+		"wrong type":           {"power": {Type: "enum", EnumValue: "onn"}},
+		"above max":            {"brightness": {Type: "int", IntValue: 150}},
+		"below min":            {"brightness": {Type: "int", IntValue: -1}},
+		"unknown enum value":   {"effect": {Type: "enum", EnumValue: "strobe"}},
+	}
+
+	for name, state := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockTypeRepo := new(MockDeviceTypeRepository)
+			service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+			mockRepo.On("LoadDevice", mock.Anything, "device123").Return(device, nil)
+			mockTypeRepo.On("GetDeviceType", mock.Anything, "dimmer").Return(dimmer, nil)
+
+			req := &proto.UpdateDeviceStateRequest{Id: "device123", State: state}
+			_, err := service.UpdateDeviceState(asOwner(), req)
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			mockRepo.AssertNotCalled(t, "UpdateDeviceState", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateDeviceStateNotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "missing").Return((*models.Device)(nil), repository.ErrDeviceNotFound)
+
+	req := &proto.UpdateDeviceStateRequest{Id: "missing", State: map[string]*proto.StateValue{"power": {Type: "bool"}}}
+	_, err := service.UpdateDeviceState(asOwner(), req)
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUpdateDeviceStateRejectsStaleVersion(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "device123").Return(&models.Device{ID: "device123", Type: "switch", UserID: "user123", Version: 3}, nil)
+
+	req := &proto.UpdateDeviceStateRequest{Id: "device123", State: map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}, ExpectedVersion: 2}
+	_, err := service.UpdateDeviceState(asOwner(), req)
+
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	mockRepo.AssertNotCalled(t, "UpdateDeviceState", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteDevice(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "device123").Return(&models.Device{ID: "device123", UserID: "user123"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "missing").Return((*models.Device)(nil), repository.ErrDeviceNotFound)
+	mockRepo.On("DeleteDevice", mock.Anything, "device123").Return(&models.Device{ID: "device123"}, nil)
+
+	resp, err := service.DeleteDevice(asOwner(), &proto.DeleteDeviceRequest{Id: "device123"})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	_, err = service.DeleteDevice(asOwner(), &proto.DeleteDeviceRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReportHeartbeatPublishesOnlyChangesOfConnectivity(t *testing.T) {
+	mockRepo := new(MockRepository)
+	bus := events.NewBus()
+	var changes []events.Event
+	bus.Subscribe("connectivity", events.HandlerFunc(func(ctx context.Context, event events.Event) error {
+		changes = append(changes, event)
+		return nil
+	}), events.TypeDeviceConnectivityChanged)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, bus, nil, nil)
+
+	seen := time.Date(2024, 7, 3, 5, 0, 0, 0, time.UTC)
+	lamp := &models.Device{ID: "lamp", UserID: "user123", Connectivity: models.ConnectivityOnline, LastSeenAt: &seen}
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(lamp, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "their-lamp").Return(&models.Device{ID: "their-lamp", UserID: "someone-else"}, nil)
+	mockRepo.On("RecordHeartbeat", mock.Anything, "lamp", mock.Anything).Return(lamp, models.ConnectivityOffline, nil).Once()
+	mockRepo.On("RecordHeartbeat", mock.Anything, "lamp", mock.Anything).Return(lamp, models.ConnectivityOnline, nil).Once()
+
+	for i := 0; i < 2; i++ {
+		resp, err := service.ReportHeartbeat(asOwner(), &proto.ReportHeartbeatRequest{Id: "lamp"})
+		assert.NoError(t, err)
+		assert.Equal(t, "online", resp.Connectivity)
+		assert.Equal(t, seen, resp.LastSeenAt.AsTime())
+	}
+	assert.Equal(t, []events.Event{events.DeviceConnectivityChanged{Device: lamp, Previous: models.ConnectivityOffline}}, changes)
+
+	_, err := service.ReportHeartbeat(asOwner(), &proto.ReportHeartbeatRequest{Id: "their-lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDevicesWithoutHeartbeatsHaveUnknownConnectivity(t *testing.T) {
+	device := convertToProtoDevice(&models.Device{ID: "lamp"})
+	assert.Equal(t, "unknown", device.Connectivity)
+	assert.Nil(t, device.LastSeenAt)
+}
+
+func TestUpdateDeviceOnlyChangesMaskedFields(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	stored := &models.Device{ID: "lamp", Name: "Lamp", Type: "switch", State: powerState(true), UserID: "user123", RoomID: "living"}
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(stored, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, mock.MatchedBy(func(device *models.Device) bool {
+		return device.Name == "Desk lamp" && device.Type == "switch" && device.RoomID == "living" && !device.UpdatedAt.IsZero()
+	})).Return(stored, nil)
+
+	// type is set in the request but not in the mask, so it is left alone
+	req := &proto.UpdateDeviceRequest{
+		Id:         "lamp",
+		Name:       "Desk lamp",
+		Type:       "dimmer",
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+	}
+	_, err := service.UpdateDevice(asOwner(), req)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockTypeRepo.AssertNotCalled(t, "GetDeviceType", mock.Anything, mock.Anything)
+}
+
+func TestUpdateDeviceReplacesLabels(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+
+	stored := &models.Device{ID: "lamp", Name: "Lamp", Type: "switch", UserID: "user123", Labels: map[string]string{"floor": "1", "kind": "indoor"}}
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(stored, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, mock.MatchedBy(func(device *models.Device) bool {
+		return assert.ObjectsAreEqual(map[string]string{"floor": "2"}, device.Labels) && device.Name == "Lamp"
+	})).Return(stored, nil)
+
+	_, err := service.UpdateDevice(asOwner(), &proto.UpdateDeviceRequest{
+		Id:         "lamp",
+		Labels:     map[string]string{"floor": "2"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels"}},
+	})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateDeviceTypeCarriesOverState(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceService(mockRepo, mockTypeRepo, nil, nil, nil, nil, nil, nil)
+
+	brightnessDefault := models.StateValue{Type: models.ValueTypeInt, Int: 100}
+	dimmer := &models.DeviceType{ID: "dimmer", Capabilities: []models.Capability{
+		{Name: "power", Type: models.ValueTypeBool},
+		{Name: "brightness", Type: models.ValueTypeInt, Default: &brightnessDefault},
+	}}
+	stored := &models.Device{ID: "lamp", Name: "Lamp", Type: "switch", State: powerState(true), UserID: "user123"}
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(stored, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "dimmer").Return(dimmer, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, mock.MatchedBy(func(device *models.Device) bool {
+		return device.Type == "dimmer" && device.State["power"].Bool && device.State["brightness"].Int == 100
+	})).Return(stored, nil)
+
+	_, err := service.UpdateDevice(asOwner(), &proto.UpdateDeviceRequest{Id: "lamp", Type: "dimmer"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateDeviceRejectsInvalidMasks(t *testing.T) {
+	tests := map[string]*proto.UpdateDeviceRequest{
+		"unknown field": {Id: "lamp", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"user_id"}}},
+		"empty name":    {Id: "lamp", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}}},
+		"nothing set":   {Id: "lamp"},
+		"invalid label": {Id: "lamp", Labels: map[string]string{"site.zone": "a"}},
+	}
+
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", UserID: "user123"}, nil)
+			service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+
+			_, err := service.UpdateDevice(asOwner(), req)
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			mockRepo.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateDeviceRejectsRoomOfAnotherUser(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRoomRepo := new(MockRoomRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), mockRoomRepo, nil, nil, nil, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", UserID: "user123"}, nil)
+	mockRoomRepo.On("GetRoom", mock.Anything, "kitchen").Return(&models.Room{ID: "kitchen", UserID: "someone-else"}, nil)
+
+	_, err := service.UpdateDevice(asOwner(), &proto.UpdateDeviceRequest{Id: "lamp", RoomId: "kitchen"})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	mockRepo.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+}
+
+func TestUpdateDeviceMovesHouseholdDeviceIntoHouseholdRoom(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRoomRepo := new(MockRoomRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleAdult}}
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), mockRoomRepo, nil, nil, nil, memberships, nil)
+
+	// The lamp and the study were added to the household by another member
+	lamp := &models.Device{ID: "lamp", UserID: "someone-else", HouseholdID: "home"}
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(lamp, nil)
+	mockRoomRepo.On("GetRoom", mock.Anything, "study").Return(&models.Room{ID: "study", UserID: "someone-else", HouseholdID: "home"}, nil)
+	mockRoomRepo.On("GetRoom", mock.Anything, "den").Return(&models.Room{ID: "den", UserID: "user123"}, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, mock.MatchedBy(func(device *models.Device) bool {
+		return device.RoomID == "study"
+	})).Return(lamp, nil)
+
+	_, err := service.UpdateDevice(asOwner(), &proto.UpdateDeviceRequest{Id: "lamp", RoomId: "study"})
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+
+	// The caller's own rooms cannot hold the devices of their household
+	lamp.RoomID = ""
+	_, err = service.UpdateDevice(asOwner(), &proto.UpdateDeviceRequest{Id: "lamp", RoomId: "den"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestDevicesOfOtherUsersAreDenied(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+
+	theirs := &models.Device{ID: "their-lamp", Type: "switch", UserID: "someone-else"}
+	mockRepo.On("LoadDevice", mock.Anything, "their-lamp").Return(theirs, nil)
+	mockRepo.On("GetDevice", mock.Anything, "their-lamp", repository.Consistency{}).Return(theirs, nil)
+
+	_, err := service.GetDevice(asOwner(), &proto.GetDeviceRequest{Id: "their-lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.UpdateDeviceState(asOwner(), &proto.UpdateDeviceStateRequest{Id: "their-lamp", State: map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.UpdateDevice(asOwner(), &proto.UpdateDeviceRequest{Id: "their-lamp", Name: "Mine now"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.DeleteDevice(asOwner(), &proto.DeleteDeviceRequest{Id: "their-lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.GetDeviceStateHistory(asOwner(), &proto.GetDeviceStateHistoryRequest{DeviceId: "their-lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListDevices(asOwner(), &proto.ListDevicesRequest{UserId: "someone-else"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.CreateDevice(asOwner(), &proto.CreateDeviceRequest{Name: "Lamp", Type: "switch", UserId: "someone-else"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Calls that are not made on behalf of a user are turned away
+	_, err = service.GetDevice(context.Background(), &proto.GetDeviceRequest{Id: "their-lamp"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	mockRepo.AssertNotCalled(t, "UpdateDeviceState", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteDevice", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "ListDevices", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListDevicesDefaultsToTheCaller(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+	mockRepo.On("ListDevices", mock.Anything, repository.DeviceFilter{Owners: repository.Owners{UserID: "user123"}, RoomID: "kitchen"}, repository.DeviceSort{}, repository.Consistency{}, repository.DevicePage{Limit: 11, CountTotal: true}).Return([]*models.Device{}, 0, nil)
+
+	_, err := service.ListDevices(asOwner(), &proto.ListDevicesRequest{RoomId: "kitchen", Page: 1, PageSize: 10})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+// fakeMemberships gives every user a fixed role in each of their households
+type fakeMemberships map[string]map[string]models.HouseholdRole
+
+func (f fakeMemberships) Households(ctx context.Context, userID string) (map[string]models.HouseholdRole, error) {
+	return f[userID], nil
+}
+
+func TestHouseholdDevicesAreSharedByRole(t *testing.T) {
+	mockRepo := new(MockRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleGuest, "cabin": models.HouseholdRoleAdult}}
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, memberships, nil)
+
+	lamp := &models.Device{ID: "lamp", Type: "switch", UserID: "someone-else", HouseholdID: "home"}
+	heater := &models.Device{ID: "heater", Type: "switch", UserID: "someone-else", HouseholdID: "cabin"}
+	theirs := &models.Device{ID: "tv", Type: "switch", UserID: "someone-else", HouseholdID: "their-home"}
+	for _, device := range []*models.Device{lamp, heater, theirs} {
+		mockRepo.On("LoadDevice", mock.Anything, device.ID).Return(device, nil)
+		mockRepo.On("GetDevice", mock.Anything, device.ID, repository.Consistency{}).Return(device, nil)
+	}
+	mockRepo.On("DeleteDevice", mock.Anything, "heater").Return(heater, nil)
+
+	// Guests may see the devices of their household, but not manage them or read their history
+	resp, err := service.GetDevice(asOwner(), &proto.GetDeviceRequest{Id: "lamp"})
+	assert.NoError(t, err)
+	assert.Equal(t, "home", resp.HouseholdId)
+	_, err = service.DeleteDevice(asOwner(), &proto.DeleteDeviceRequest{Id: "lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.GetDeviceStateHistory(asOwner(), &proto.GetDeviceStateHistoryRequest{DeviceId: "lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.CreateDevice(asOwner(), &proto.CreateDeviceRequest{Name: "Lamp", Type: "switch", HouseholdId: "home"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Adults manage them
+	_, err = service.DeleteDevice(asOwner(), &proto.DeleteDeviceRequest{Id: "heater"})
+	assert.NoError(t, err)
+
+	// Households the caller is not a member of are closed to them
+	_, err = service.GetDevice(asOwner(), &proto.GetDeviceRequest{Id: "tv"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListDevices(asOwner(), &proto.ListDevicesRequest{HouseholdId: "their-home"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	mockRepo.AssertNotCalled(t, "DeleteDevice", mock.Anything, "lamp")
+}
+
+func TestListDevicesIncludesTheCallersHouseholds(t *testing.T) {
+	mockRepo := new(MockRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleChild, "cabin": models.HouseholdRoleOwner}}
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, memberships, nil)
+	all := repository.DeviceFilter{Owners: repository.Owners{UserID: "user123", HouseholdIDs: []string{"cabin", "home"}}}
+	home := repository.DeviceFilter{Owners: repository.Owners{HouseholdIDs: []string{"home"}}}
+	mockRepo.On("ListDevices", mock.Anything, all, repository.DeviceSort{}, repository.Consistency{}, repository.DevicePage{Limit: 11, CountTotal: true}).Return([]*models.Device{}, 0, nil)
+	mockRepo.On("ListDevices", mock.Anything, home, repository.DeviceSort{}, repository.Consistency{}, repository.DevicePage{Limit: 11, CountTotal: true}).Return([]*models.Device{}, 0, nil)
+
+	_, err := service.ListDevices(asOwner(), &proto.ListDevicesRequest{Page: 1, PageSize: 10})
+	assert.NoError(t, err)
+	_, err = service.ListDevices(asOwner(), &proto.ListDevicesRequest{HouseholdId: "home", Page: 1, PageSize: 10})
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListDevicesFiltersAndSorts(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, nil)
+	updatedAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := repository.DeviceFilter{
+		Owners:       repository.Owners{UserID: "user123"},
+		Type:         "light",
+		NameContains: "kitchen",
+		State:        map[string]models.StateValue{"power": {Type: models.ValueTypeBool, Bool: true}},
+		Labels:       labels.Selector{{Key: "floor", Operator: labels.In, Values: []string{"1", "2"}}},
+		UpdatedAfter: updatedAfter,
+	}
+	sort := repository.DeviceSort{Field: repository.SortByName, Descending: true}
+	mockRepo.On("ListDevices", mock.Anything, filter, sort, repository.Consistency{}, repository.DevicePage{Limit: 51}).Return([]*models.Device{}, 0, nil)
+
+	_, err := service.ListDevices(asOwner(), &proto.ListDevicesRequest{
+		Type:          "light",
+		NameContains:  "kitchen",
+		State:         map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}},
+		LabelSelector: "floor in (1, 2)",
+		UpdatedAfter:  timestamppb.New(updatedAfter),
+		OrderBy:       "name desc",
+	})
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+
+	for _, req := range []*proto.ListDevicesRequest{
+		{OrderBy: "state"},
+		{OrderBy: "name sideways"},
+		{State: map[string]*proto.StateValue{"power.bool": {Type: "bool"}}},
+		{LabelSelector: "floor.level=2"},
+		{CreatedAfter: timestamppb.New(updatedAfter), CreatedBefore: timestamppb.New(updatedAfter.Add(-time.Hour))},
+	} {
+		_, err := service.ListDevices(asOwner(), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "request %v", req)
+	}
+}
+
+func TestListDevicesPagesWithTokens(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, nil, nil, nil, nil, []byte("secret"))
+	filter := repository.DeviceFilter{Owners: repository.Owners{UserID: "user123"}, Type: "light"}
+	sort := repository.DeviceSort{Field: repository.SortByName}
+	first := []*models.Device{{ID: "a", Name: "Desk"}, {ID: "b", Name: "Hall"}, {ID: "c", Name: "Porch"}}
+	mockRepo.On("ListDevices", mock.Anything, filter, sort, repository.Consistency{}, repository.DevicePage{Limit: 3}).Return(first, 0, nil)
+	after := repository.DeviceCursor{Name: "Hall", ID: "b"}
+	mockRepo.On("ListDevices", mock.Anything, filter, sort, repository.Consistency{}, repository.DevicePage{After: &after, Limit: 3}).Return(first[2:], 0, nil)
+
+	// The page after the last device of the first one follows its token
+	resp, err := service.ListDevices(asOwner(), &proto.ListDevicesRequest{Type: "light", OrderBy: "name", PageSize: 2})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Devices, 2)
+	assert.NotEmpty(t, resp.NextPageToken)
+
+	next, err := service.ListDevices(asOwner(), &proto.ListDevicesRequest{Type: "light", OrderBy: "name", PageSize: 2, PageToken: resp.NextPageToken})
+	assert.NoError(t, err)
+	assert.Equal(t, "c", next.Devices[0].Id)
+	assert.Empty(t, next.NextPageToken)
+	mockRepo.AssertExpectations(t)
+
+	// Tokens are only accepted unchanged, for the query they were issued for
+	for _, req := range []*proto.ListDevicesRequest{
+		{Type: "light", OrderBy: "name", PageToken: resp.NextPageToken + "x"},
+		{Type: "light", OrderBy: "name", PageToken: "e30." + strings.SplitN(resp.NextPageToken, ".", 2)[1]},
+		{Type: "switch", OrderBy: "name", PageToken: resp.NextPageToken},
+		{Type: "light", OrderBy: "name", PageToken: resp.NextPageToken, Page: 2},
+	} {
+		_, err := service.ListDevices(asOwner(), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
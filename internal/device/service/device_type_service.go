@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+)
+
+type DeviceTypeService struct {
+	proto.UnimplementedDeviceTypeServiceServer
+	createDeviceTypeHandler *command.CreateDeviceTypeHandler
+	updateDeviceTypeHandler *command.UpdateDeviceTypeHandler
+	deleteDeviceTypeHandler *command.DeleteDeviceTypeHandler
+	getDeviceTypeHandler    *query.GetDeviceTypeHandler
+	listDeviceTypesHandler  *query.ListDeviceTypesHandler
+}
+
+func NewDeviceTypeService(repo repository.DeviceTypeRepository) *DeviceTypeService {
+	return &DeviceTypeService{
+		createDeviceTypeHandler: command.NewCreateDeviceTypeHandler(repo),
+		updateDeviceTypeHandler: command.NewUpdateDeviceTypeHandler(repo),
+		deleteDeviceTypeHandler: command.NewDeleteDeviceTypeHandler(repo),
+		getDeviceTypeHandler:    query.NewGetDeviceTypeHandler(repo),
+		listDeviceTypesHandler:  query.NewListDeviceTypesHandler(repo),
+	}
+}
+
+func (s *DeviceTypeService) CreateDeviceType(ctx context.Context, req *proto.CreateDeviceTypeRequest) (*proto.DeviceType, error) {
+	capabilities, err := convertFromProtoCapabilities(req.Capabilities)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	cmd := command.CreateDeviceTypeCommand{
+		ID:               req.Id,
+		Name:             req.Name,
+		Description:      req.Description,
+		Capabilities:     capabilities,
+		HeartbeatTimeout: time.Duration(req.HeartbeatTimeoutSeconds) * time.Second,
+	}
+
+	deviceType, err := s.createDeviceTypeHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoDeviceType(deviceType), nil
+}
+
+func (s *DeviceTypeService) GetDeviceType(ctx context.Context, req *proto.GetDeviceTypeRequest) (*proto.DeviceType, error) {
+	deviceType, err := s.getDeviceTypeHandler.Handle(ctx, query.GetDeviceTypeQuery{ID: req.Id})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoDeviceType(deviceType), nil
+}
+
+func (s *DeviceTypeService) UpdateDeviceType(ctx context.Context, req *proto.UpdateDeviceTypeRequest) (*proto.DeviceType, error) {
+	capabilities, err := convertFromProtoCapabilities(req.Capabilities)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	cmd := command.UpdateDeviceTypeCommand{
+		ID:               req.Id,
+		Name:             req.Name,
+		Description:      req.Description,
+		Capabilities:     capabilities,
+		HeartbeatTimeout: time.Duration(req.HeartbeatTimeoutSeconds) * time.Second,
+	}
+
+	deviceType, err := s.updateDeviceTypeHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoDeviceType(deviceType), nil
+}
+
+func (s *DeviceTypeService) DeleteDeviceType(ctx context.Context, req *proto.DeleteDeviceTypeRequest) (*proto.DeleteDeviceTypeResponse, error) {
+	if err := s.deleteDeviceTypeHandler.Handle(ctx, command.DeleteDeviceTypeCommand{ID: req.Id}); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &proto.DeleteDeviceTypeResponse{Success: true}, nil
+}
+
+func (s *DeviceTypeService) ListDeviceTypes(ctx context.Context, _ *proto.ListDeviceTypesRequest) (*proto.ListDeviceTypesResponse, error) {
+	deviceTypes, err := s.listDeviceTypesHandler.Handle(ctx, query.ListDeviceTypesQuery{})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	protoDeviceTypes := make([]*proto.DeviceType, len(deviceTypes))
+	for i, deviceType := range deviceTypes {
+		protoDeviceTypes[i] = convertToProtoDeviceType(deviceType)
+	}
+	return &proto.ListDeviceTypesResponse{DeviceTypes: protoDeviceTypes}, nil
+}
+
+func convertToProtoDeviceType(deviceType *models.DeviceType) *proto.DeviceType {
+	capabilities := make([]*proto.Capability, len(deviceType.Capabilities))
+	for i, capability := range deviceType.Capabilities {
+		protoCapability := &proto.Capability{
+			Name:   capability.Name,
+			Type:   string(capability.Type),
+			Min:    capability.Min,
+			Max:    capability.Max,
+			Values: capability.Values,
+		}
+		if capability.Default != nil {
+			protoCapability.DefaultValue = convertToProtoStateValue(*capability.Default)
+		}
+		capabilities[i] = protoCapability
+	}
+
+	return &proto.DeviceType{
+		Id:                      deviceType.ID,
+		Name:                    deviceType.Name,
+		Description:             deviceType.Description,
+		Capabilities:            capabilities,
+		HeartbeatTimeoutSeconds: int32(deviceType.HeartbeatTimeout / time.Second),
+	}
+}
+
+func convertFromProtoCapabilities(protoCapabilities []*proto.Capability) ([]models.Capability, error) {
+	capabilities := make([]models.Capability, len(protoCapabilities))
+	for i, protoCapability := range protoCapabilities {
+		capability := models.Capability{
+			Name:   protoCapability.GetName(),
+			Type:   models.ValueType(protoCapability.GetType()),
+			Min:    protoCapability.Min,
+			Max:    protoCapability.Max,
+			Values: protoCapability.GetValues(),
+		}
+		if protoCapability.DefaultValue != nil {
+			value, err := convertFromProtoStateValue(capability.Name, protoCapability.DefaultValue)
+			if err != nil {
+				return nil, err
+			}
+			capability.Default = &value
+		}
+		capabilities[i] = capability
+	}
+	return capabilities, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MockDeviceTypeRepository is a mock implementation of the DeviceTypeRepository interface
+type MockDeviceTypeRepository struct {
+	mock.Mock
+}
+
+func (m *MockDeviceTypeRepository) CreateDeviceType(ctx context.Context, deviceType *models.DeviceType) error {
+	args := m.Called(ctx, deviceType)
+	return args.Error(0)
+}
+
+func (m *MockDeviceTypeRepository) GetDeviceType(ctx context.Context, id string) (*models.DeviceType, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.DeviceType), args.Error(1)
+}
+
+func (m *MockDeviceTypeRepository) UpdateDeviceType(ctx context.Context, deviceType *models.DeviceType) error {
+	args := m.Called(ctx, deviceType)
+	return args.Error(0)
+}
+
+func (m *MockDeviceTypeRepository) DeleteDeviceType(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockDeviceTypeRepository) ListDeviceTypes(ctx context.Context) ([]*models.DeviceType, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*models.DeviceType), args.Error(1)
+}
+
+func TestCreateDeviceType(t *testing.T) {
+	mockRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceTypeService(mockRepo)
+
+	minSetpoint, maxSetpoint := 5.0, 35.0
+	req := &proto.CreateDeviceTypeRequest{
+		Id:   "thermostat",
+		Name: "Thermostat",
+		Capabilities: []*proto.Capability{
+			{Name: "setpoint", Type: "float", Min: &minSetpoint, Max: &maxSetpoint, DefaultValue: &proto.StateValue{Type: "float", FloatValue: 21}},
+			{Name: "mode", Type: "enum", Values: []string{"heat", "cool"}},
+		},
+	}
+
+	mockRepo.On("CreateDeviceType", mock.Anything, mock.AnythingOfType("*models.DeviceType")).Return(nil)
+
+	response, err := service.CreateDeviceType(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "thermostat", response.Id)
+	assert.Len(t, response.Capabilities, 2)
+	assert.Equal(t, 21.0, response.Capabilities[0].DefaultValue.FloatValue)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateDeviceTypeRejectsInvalidSchema(t *testing.T) {
+	low, high := 10.0, 1.0
+	tests := map[string]*proto.CreateDeviceTypeRequest{
+		"bad id":                     {Id: "Light Bulb", Name: "Light"},
+		"missing name":               {Id: "light"},
+		"unknown type":               {Id: "light", Name: "Light", Capabilities: []*proto.Capability{{Name: "power", Type: "string"}}},
+		"duplicate":                  {Id: "light", Name: "Light", Capabilities: []*proto.Capability{{Name: "power", Type: "bool"}, {Name: "power", Type: "bool"}}},
+		"empty enum":                 {Id: "light", Name: "Light", Capabilities: []*proto.Capability{{Name: "mode", Type: "enum"}}},
+		"min above max":              {Id: "light", Name: "Light", Capabilities: []*proto.Capability{{Name: "level", Type: "int", Min: &low, Max: &high}}},
+		"default mismatch":           {Id: "light", Name: "Light", Capabilities: []*proto.Capability{{Name: "power", Type: "bool", DefaultValue: &proto.StateValue{Type: "int"}}}},
+		"negative heartbeat timeout": {Id: "light", Name: "Light", HeartbeatTimeoutSeconds: -1},
+	}
+
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockDeviceTypeRepository)
+			service := NewDeviceTypeService(mockRepo)
+
+			_, err := service.CreateDeviceType(context.Background(), req)
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			mockRepo.AssertNotCalled(t, "CreateDeviceType", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestDeleteDeviceTypeInUse(t *testing.T) {
+	mockRepo := new(MockDeviceTypeRepository)
+	service := NewDeviceTypeService(mockRepo)
+
+	mockRepo.On("DeleteDeviceType", mock.Anything, "dimmer").Return(repository.ErrDeviceTypeInUse)
+
+	_, err := service.DeleteDeviceType(context.Background(), &proto.DeleteDeviceTypeRequest{Id: "dimmer"})
+
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	mockRepo.AssertExpectations(t)
+}
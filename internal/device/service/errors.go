@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/watch"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatusError maps domain errors from the command and query handlers to gRPC status errors
+func toStatusError(err error) error {
+	switch {
+	case errors.Is(err, command.ErrInvalidState),
+		errors.Is(err, command.ErrInvalidDevice),
+		errors.Is(err, command.ErrUnknownDeviceType),
+		errors.Is(err, command.ErrInvalidDeviceType),
+		errors.Is(err, command.ErrInvalidRoom),
+		errors.Is(err, command.ErrInvalidSchedule),
+		errors.Is(err, command.ErrInvalidScene),
+		errors.Is(err, command.ErrInvalidRule),
+		errors.Is(err, command.ErrInvalidTelemetry),
+		errors.Is(err, query.ErrInvalidTimeRange),
+		errors.Is(err, query.ErrInvalidConsistency),
+		errors.Is(err, query.ErrInvalidDeviceFilter),
+		errors.Is(err, query.ErrInvalidPageToken),
+		errors.Is(err, query.ErrInvalidTelemetryQuery),
+		errors.Is(err, watch.ErrInvalidResumeToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrDeviceNotFound),
+		errors.Is(err, repository.ErrDeviceTypeNotFound),
+		errors.Is(err, repository.ErrRoomNotFound),
+		errors.Is(err, repository.ErrScheduleNotFound),
+		errors.Is(err, repository.ErrSceneNotFound),
+		errors.Is(err, repository.ErrRuleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrDeviceTypeExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, repository.ErrDeviceTypeInUse),
+		errors.Is(err, command.ErrDeviceNotInRoom),
+		errors.Is(err, command.ErrRoomHouseholdMismatch),
+		errors.Is(err, command.ErrRuleOwnerMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, watch.ErrSubscriberLagged),
+		errors.Is(err, watch.ErrHubClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type RoomService struct {
+	proto.UnimplementedRoomServiceServer
+	access
+	createRoomHandler     *command.CreateRoomHandler
+	updateRoomHandler     *command.UpdateRoomHandler
+	deleteRoomHandler     *command.DeleteRoomHandler
+	assignDeviceHandler   *command.AssignDeviceHandler
+	unassignDeviceHandler *command.UnassignDeviceHandler
+	getRoomHandler        *query.GetRoomHandler
+	listRoomsHandler      *query.ListRoomsHandler
+}
+
+// NewRoomService creates the room service. A room belongs to the caller or to
+// one of their households, as looked up in memberships. Members may view the
+// rooms of their household, and those who may manage its devices may change
+// them and put its devices in them.
+func NewRoomService(repo repository.RoomRepository, deviceRepo repository.CommandDeviceRepository, memberships membership.Source) *RoomService {
+	return &RoomService{
+		access:                access{devices: deviceRepo, memberships: memberships},
+		createRoomHandler:     command.NewCreateRoomHandler(repo),
+		updateRoomHandler:     command.NewUpdateRoomHandler(repo),
+		deleteRoomHandler:     command.NewDeleteRoomHandler(repo, deviceRepo),
+		assignDeviceHandler:   command.NewAssignDeviceHandler(repo, deviceRepo),
+		unassignDeviceHandler: command.NewUnassignDeviceHandler(repo, deviceRepo),
+		getRoomHandler:        query.NewGetRoomHandler(repo),
+		listRoomsHandler:      query.NewListRoomsHandler(repo),
+	}
+}
+
+func (s *RoomService) CreateRoom(ctx context.Context, req *proto.CreateRoomRequest) (*proto.Room, error) {
+	userID, err := ownerFor(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if req.HouseholdId != "" {
+		if _, err := s.householdRole(ctx, userID, req.HouseholdId, canManage); err != nil {
+			return nil, err
+		}
+	}
+	cmd := command.CreateRoomCommand{
+		Name:        req.Name,
+		UserID:      userID,
+		HouseholdID: req.HouseholdId,
+	}
+
+	room, err := s.createRoomHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoRoom(room), nil
+}
+
+func (s *RoomService) GetRoom(ctx context.Context, req *proto.GetRoomRequest) (*proto.Room, error) {
+	room, err := s.authorizeRoom(ctx, req.Id, canView)
+	if err != nil {
+		return nil, err
+	}
+	return convertToProtoRoom(room), nil
+}
+
+func (s *RoomService) UpdateRoom(ctx context.Context, req *proto.UpdateRoomRequest) (*proto.Room, error) {
+	if _, err := s.authorizeRoom(ctx, req.Id, canManage); err != nil {
+		return nil, err
+	}
+	cmd := command.UpdateRoomCommand{
+		ID:   req.Id,
+		Name: req.Name,
+	}
+
+	room, err := s.updateRoomHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoRoom(room), nil
+}
+
+func (s *RoomService) DeleteRoom(ctx context.Context, req *proto.DeleteRoomRequest) (*proto.DeleteRoomResponse, error) {
+	if _, err := s.authorizeRoom(ctx, req.Id, canManage); err != nil {
+		return nil, err
+	}
+	if err := s.deleteRoomHandler.Handle(ctx, command.DeleteRoomCommand{ID: req.Id}); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &proto.DeleteRoomResponse{Success: true}, nil
+}
+
+func (s *RoomService) ListRooms(ctx context.Context, req *proto.ListRoomsRequest) (*proto.ListRoomsResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := s.ownersFor(ctx, caller, req.UserId, req.HouseholdId)
+	if err != nil {
+		return nil, err
+	}
+	rooms, err := s.listRoomsHandler.Handle(ctx, query.ListRoomsQuery{Owners: owners})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	protoRooms := make([]*proto.Room, len(rooms))
+	for i, room := range rooms {
+		protoRooms[i] = convertToProtoRoom(room)
+	}
+	return &proto.ListRoomsResponse{Rooms: protoRooms}, nil
+}
+
+func (s *RoomService) AssignDevice(ctx context.Context, req *proto.AssignDeviceRequest) (*proto.Room, error) {
+	if _, err := s.authorizeRoom(ctx, req.RoomId, canManage); err != nil {
+		return nil, err
+	}
+	if _, err := s.authorizeDevice(ctx, req.DeviceId, canManage); err != nil {
+		return nil, err
+	}
+	cmd := command.AssignDeviceCommand{
+		RoomID:   req.RoomId,
+		DeviceID: req.DeviceId,
+	}
+
+	room, err := s.assignDeviceHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoRoom(room), nil
+}
+
+func (s *RoomService) UnassignDevice(ctx context.Context, req *proto.UnassignDeviceRequest) (*proto.Room, error) {
+	if _, err := s.authorizeRoom(ctx, req.RoomId, canManage); err != nil {
+		return nil, err
+	}
+	if _, err := s.authorizeDevice(ctx, req.DeviceId, canManage); err != nil {
+		return nil, err
+	}
+	cmd := command.UnassignDeviceCommand{
+		RoomID:   req.RoomId,
+		DeviceID: req.DeviceId,
+	}
+
+	room, err := s.unassignDeviceHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoRoom(room), nil
+}
+
+// authorizeRoom loads a room the caller may access as allowed
+func (s *RoomService) authorizeRoom(ctx context.Context, id string, allowed func(models.HouseholdRole) bool) (*models.Room, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	room, err := s.getRoomHandler.Handle(ctx, query.GetRoomQuery{ID: id})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := s.checkRoom(ctx, caller, room, allowed); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func convertToProtoRoom(room *models.Room) *proto.Room {
+	return &proto.Room{
+		Id:          room.ID,
+		Name:        room.Name,
+		UserId:      room.UserID,
+		HouseholdId: room.HouseholdID,
+		DeviceIds:   room.DeviceIDs,
+		CreatedAt:   timestamppb.New(room.CreatedAt),
+		UpdatedAt:   timestamppb.New(room.UpdatedAt),
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MockRoomRepository is a mock implementation of the RoomRepository interface
+type MockRoomRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoomRepository) CreateRoom(ctx context.Context, room *models.Room) error {
+	args := m.Called(ctx, room)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) GetRoom(ctx context.Context, id string) (*models.Room, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Room), args.Error(1)
+}
+
+func (m *MockRoomRepository) UpdateRoom(ctx context.Context, room *models.Room) error {
+	args := m.Called(ctx, room)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) DeleteRoom(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) ListRooms(ctx context.Context, owners repository.Owners) ([]*models.Room, error) {
+	args := m.Called(ctx, owners)
+	return args.Get(0).([]*models.Room), args.Error(1)
+}
+
+func TestAssignDevice(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	mockRepo := new(MockRepository)
+	service := NewRoomService(mockRoomRepo, mockRepo, nil)
+
+	room := &models.Room{ID: "living", Name: "Living room", UserID: "user123", DeviceIDs: []string{}}
+	device := &models.Device{ID: "lamp", UserID: "user123", RoomID: "kitchen"}
+	updatedRoom := &models.Room{ID: "living", Name: "Living room", UserID: "user123", DeviceIDs: []string{"lamp"}}
+
+	mockRoomRepo.On("GetRoom", mock.Anything, "living").Return(room, nil).Twice()
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(device, nil)
+	// The device moves out of the kitchen instead of belonging to two rooms
+	mockRepo.On("SetDeviceRoom", mock.Anything, "lamp", "living").Return(device, nil)
+	mockRoomRepo.On("GetRoom", mock.Anything, "living").Return(updatedRoom, nil).Once()
+
+	response, err := service.AssignDevice(asOwner(), &proto.AssignDeviceRequest{RoomId: "living", DeviceId: "lamp"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lamp"}, response.DeviceIds)
+	mockRoomRepo.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAssignDeviceOfAnotherUser(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	mockRepo := new(MockRepository)
+	service := NewRoomService(mockRoomRepo, mockRepo, nil)
+
+	mockRoomRepo.On("GetRoom", mock.Anything, "living").Return(&models.Room{ID: "living", UserID: "user123"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", UserID: "user456"}, nil)
+
+	_, err := service.AssignDevice(asOwner(), &proto.AssignDeviceRequest{RoomId: "living", DeviceId: "lamp"})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	mockRepo.AssertNotCalled(t, "SetDeviceRoom", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUnassignDeviceNotInRoom(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	mockRepo := new(MockRepository)
+	service := NewRoomService(mockRoomRepo, mockRepo, nil)
+
+	mockRoomRepo.On("GetRoom", mock.Anything, "living").Return(&models.Room{ID: "living", UserID: "user123"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", UserID: "user123", RoomID: "kitchen"}, nil)
+
+	_, err := service.UnassignDevice(asOwner(), &proto.UnassignDeviceRequest{RoomId: "living", DeviceId: "lamp"})
+
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	mockRepo.AssertNotCalled(t, "SetDeviceRoom", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteRoomReleasesDevices(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	mockRepo := new(MockRepository)
+	service := NewRoomService(mockRoomRepo, mockRepo, nil)
+
+	room := &models.Room{ID: "living", UserID: "user123", DeviceIDs: []string{"lamp", "tv"}}
+	mockRoomRepo.On("GetRoom", mock.Anything, "living").Return(room, nil)
+	mockRepo.On("SetDeviceRoom", mock.Anything, "lamp", "").Return(&models.Device{}, nil)
+	mockRepo.On("SetDeviceRoom", mock.Anything, "tv", "").Return(&models.Device{}, nil)
+	mockRoomRepo.On("DeleteRoom", mock.Anything, "living").Return(nil)
+
+	_, err := service.DeleteRoom(asOwner(), &proto.DeleteRoomRequest{Id: "living"})
+
+	assert.NoError(t, err)
+	mockRoomRepo.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetRoomNotFound(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	service := NewRoomService(mockRoomRepo, new(MockRepository), nil)
+
+	mockRoomRepo.On("GetRoom", mock.Anything, "missing").Return((*models.Room)(nil), repository.ErrRoomNotFound)
+
+	_, err := service.GetRoom(asOwner(), &proto.GetRoomRequest{Id: "missing"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestRoomsOfAnotherUserAreDenied(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	mockRepo := new(MockRepository)
+	service := NewRoomService(mockRoomRepo, mockRepo, nil)
+
+	mockRoomRepo.On("GetRoom", mock.Anything, "their-room").Return(&models.Room{ID: "their-room", UserID: "user456", DeviceIDs: []string{"lamp"}}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", UserID: "user123", RoomID: "their-room"}, nil)
+
+	_, err := service.GetRoom(asOwner(), &proto.GetRoomRequest{Id: "their-room"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.UpdateRoom(asOwner(), &proto.UpdateRoomRequest{Id: "their-room", Name: "Mine now"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.DeleteRoom(asOwner(), &proto.DeleteRoomRequest{Id: "their-room"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.AssignDevice(asOwner(), &proto.AssignDeviceRequest{RoomId: "their-room", DeviceId: "lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.UnassignDevice(asOwner(), &proto.UnassignDeviceRequest{RoomId: "their-room", DeviceId: "lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListRooms(asOwner(), &proto.ListRoomsRequest{UserId: "user456"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	mockRoomRepo.AssertNotCalled(t, "UpdateRoom", mock.Anything, mock.Anything)
+	mockRoomRepo.AssertNotCalled(t, "DeleteRoom", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "SetDeviceRoom", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAssignHouseholdDeviceTakesManaging(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	mockRepo := new(MockRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleGuest}}
+	service := NewRoomService(mockRoomRepo, mockRepo, memberships)
+
+	mockRoomRepo.On("GetRoom", mock.Anything, "living").Return(&models.Room{ID: "living", UserID: "user456", HouseholdID: "home"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", UserID: "user456", HouseholdID: "home"}, nil)
+
+	// Guests see the rooms of the household but do not arrange them
+	_, err := service.GetRoom(asOwner(), &proto.GetRoomRequest{Id: "living"})
+	assert.NoError(t, err)
+	_, err = service.AssignDevice(asOwner(), &proto.AssignDeviceRequest{RoomId: "living", DeviceId: "lamp"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	mockRepo.AssertNotCalled(t, "SetDeviceRoom", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHouseholdRoomsAreShared(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	mockRepo := new(MockRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleAdult}}
+	service := NewRoomService(mockRoomRepo, mockRepo, memberships)
+
+	// Another member created the room and added the lamp to the household
+	living := &models.Room{ID: "living", UserID: "user456", HouseholdID: "home", DeviceIDs: []string{}}
+	lamp := &models.Device{ID: "lamp", UserID: "user456", HouseholdID: "home"}
+	mine := &models.Device{ID: "fan", UserID: "user123"}
+	mockRoomRepo.On("GetRoom", mock.Anything, "living").Return(living, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(lamp, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "fan").Return(mine, nil)
+	mockRepo.On("SetDeviceRoom", mock.Anything, "lamp", "living").Return(lamp, nil)
+	mockRoomRepo.On("ListRooms", mock.Anything, repository.Owners{HouseholdIDs: []string{"home"}}).Return([]*models.Room{living}, nil)
+
+	_, err := service.AssignDevice(asOwner(), &proto.AssignDeviceRequest{RoomId: "living", DeviceId: "lamp"})
+	assert.NoError(t, err)
+	rooms, err := service.ListRooms(asOwner(), &proto.ListRoomsRequest{HouseholdId: "home"})
+	assert.NoError(t, err)
+	assert.Len(t, rooms.Rooms, 1)
+	assert.Equal(t, "home", rooms.Rooms[0].HouseholdId)
+
+	// A device the caller owns alone stays out of the household's rooms
+	_, err = service.AssignDevice(asOwner(), &proto.AssignDeviceRequest{RoomId: "living", DeviceId: "fan"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	mockRepo.AssertNotCalled(t, "SetDeviceRoom", mock.Anything, "fan", mock.Anything)
+}
+
+func TestCreateHouseholdRoomTakesManaging(t *testing.T) {
+	mockRoomRepo := new(MockRoomRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleChild}}
+	service := NewRoomService(mockRoomRepo, new(MockRepository), memberships)
+
+	_, err := service.CreateRoom(asOwner(), &proto.CreateRoomRequest{Name: "Kitchen", HouseholdId: "home"})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	mockRoomRepo.AssertNotCalled(t, "CreateRoom", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type RuleService struct {
+	proto.UnimplementedRuleServiceServer
+	access
+	createRuleHandler         *command.CreateRuleHandler
+	updateRuleHandler         *command.UpdateRuleHandler
+	deleteRuleHandler         *command.DeleteRuleHandler
+	setRuleEnabledHandler     *command.SetRuleEnabledHandler
+	getRuleHandler            *query.GetRuleHandler
+	listRulesHandler          *query.ListRulesHandler
+	listRuleExecutionsHandler *query.ListRuleExecutionsHandler
+}
+
+// NewRuleService creates the rule service. Rules belong to the caller. They
+// may watch the devices the caller may view and act on those the caller may
+// control, as looked up in memberships for household devices.
+func NewRuleService(repo repository.RuleRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, memberships membership.Source) *RuleService {
+	return &RuleService{
+		access:                    access{devices: deviceRepo, memberships: memberships},
+		createRuleHandler:         command.NewCreateRuleHandler(repo, deviceRepo, typeRepo),
+		updateRuleHandler:         command.NewUpdateRuleHandler(repo, deviceRepo, typeRepo),
+		deleteRuleHandler:         command.NewDeleteRuleHandler(repo),
+		setRuleEnabledHandler:     command.NewSetRuleEnabledHandler(repo),
+		getRuleHandler:            query.NewGetRuleHandler(repo),
+		listRulesHandler:          query.NewListRulesHandler(repo),
+		listRuleExecutionsHandler: query.NewListRuleExecutionsHandler(repo),
+	}
+}
+
+func (s *RuleService) CreateRule(ctx context.Context, req *proto.CreateRuleRequest) (*proto.Rule, error) {
+	userID, err := ownerFor(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	trigger, conditions, actions, err := convertFromProtoRuleParts(req.Trigger, req.Conditions, req.Actions)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := s.authorizeRuleDevices(ctx, trigger, conditions, actions); err != nil {
+		return nil, err
+	}
+
+	cmd := command.CreateRuleCommand{
+		Name:       req.Name,
+		UserID:     userID,
+		Trigger:    trigger,
+		Conditions: conditions,
+		Actions:    actions,
+	}
+
+	rule, err := s.createRuleHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoRule(rule), nil
+}
+
+func (s *RuleService) GetRule(ctx context.Context, req *proto.GetRuleRequest) (*proto.Rule, error) {
+	rule, err := s.ownedRule(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return convertToProtoRule(rule), nil
+}
+
+func (s *RuleService) UpdateRule(ctx context.Context, req *proto.UpdateRuleRequest) (*proto.Rule, error) {
+	if _, err := s.ownedRule(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	trigger, conditions, actions, err := convertFromProtoRuleParts(req.Trigger, req.Conditions, req.Actions)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := s.authorizeRuleDevices(ctx, trigger, conditions, actions); err != nil {
+		return nil, err
+	}
+
+	cmd := command.UpdateRuleCommand{
+		ID:         req.Id,
+		Name:       req.Name,
+		Trigger:    trigger,
+		Conditions: conditions,
+		Actions:    actions,
+	}
+
+	rule, err := s.updateRuleHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoRule(rule), nil
+}
+
+func (s *RuleService) DeleteRule(ctx context.Context, req *proto.DeleteRuleRequest) (*proto.DeleteRuleResponse, error) {
+	if _, err := s.ownedRule(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	if err := s.deleteRuleHandler.Handle(ctx, command.DeleteRuleCommand{ID: req.Id}); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &proto.DeleteRuleResponse{Success: true}, nil
+}
+
+func (s *RuleService) ListRules(ctx context.Context, req *proto.ListRulesRequest) (*proto.ListRulesResponse, error) {
+	userID, err := ownerFor(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.listRulesHandler.Handle(ctx, query.ListRulesQuery{UserID: userID})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	protoRules := make([]*proto.Rule, len(rules))
+	for i, rule := range rules {
+		protoRules[i] = convertToProtoRule(rule)
+	}
+	return &proto.ListRulesResponse{Rules: protoRules}, nil
+}
+
+func (s *RuleService) SetRuleEnabled(ctx context.Context, req *proto.SetRuleEnabledRequest) (*proto.Rule, error) {
+	if _, err := s.ownedRule(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	rule, err := s.setRuleEnabledHandler.Handle(ctx, command.SetRuleEnabledCommand{ID: req.Id, Enabled: req.Enabled})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoRule(rule), nil
+}
+
+func (s *RuleService) ListRuleExecutions(ctx context.Context, req *proto.ListRuleExecutionsRequest) (*proto.ListRuleExecutionsResponse, error) {
+	if _, err := s.ownedRule(ctx, req.RuleId); err != nil {
+		return nil, err
+	}
+	q := query.ListRuleExecutionsQuery{
+		RuleID:   req.RuleId,
+		Page:     int(req.Page),
+		PageSize: int(req.PageSize),
+	}
+
+	executions, total, err := s.listRuleExecutionsHandler.Handle(ctx, q)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	protoExecutions := make([]*proto.RuleExecution, len(executions))
+	for i, execution := range executions {
+		protoExecutions[i] = &proto.RuleExecution{
+			Id:         execution.ID,
+			RuleId:     execution.RuleID,
+			Trigger:    execution.Trigger,
+			Depth:      int32(execution.Depth),
+			Status:     string(execution.Status),
+			Error:      execution.Error,
+			ExecutedAt: timestamppb.New(execution.ExecutedAt),
+		}
+	}
+	return &proto.ListRuleExecutionsResponse{Executions: protoExecutions, Total: int32(total)}, nil
+}
+
+// ownedRule loads a rule of the caller
+func (s *RuleService) ownedRule(ctx context.Context, id string) (*models.Rule, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.getRuleHandler.Handle(ctx, query.GetRuleQuery{ID: id})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := checkOwner(caller, rule.UserID, "rule", rule.ID); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// authorizeRuleDevices checks that the caller may view the devices a rule
+// watches and control those it acts on
+func (s *RuleService) authorizeRuleDevices(ctx context.Context, trigger models.RuleTrigger, conditions []models.RuleCondition, actions []models.RuleAction) error {
+	if trigger.Type == models.TriggerDeviceState {
+		if _, err := s.authorizeDevice(ctx, trigger.DeviceID, canView); err != nil {
+			return err
+		}
+	}
+	for _, condition := range conditions {
+		if _, err := s.authorizeDevice(ctx, condition.DeviceID, canView); err != nil {
+			return err
+		}
+	}
+	for _, action := range actions {
+		if _, err := s.authorizeDevice(ctx, action.DeviceID, canControl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convertToProtoRule(rule *models.Rule) *proto.Rule {
+	protoTrigger := &proto.RuleTrigger{
+		Type:      string(rule.Trigger.Type),
+		DeviceId:  rule.Trigger.DeviceID,
+		Attribute: rule.Trigger.Attribute,
+		Cron:      rule.Trigger.Cron,
+		Timezone:  rule.Trigger.Timezone,
+		RuleId:    rule.Trigger.RuleID,
+	}
+	if rule.Trigger.Value != nil {
+		protoTrigger.Value = convertToProtoStateValue(*rule.Trigger.Value)
+	}
+
+	conditions := make([]*proto.RuleCondition, len(rule.Conditions))
+	for i, condition := range rule.Conditions {
+		conditions[i] = &proto.RuleCondition{
+			DeviceId:  condition.DeviceID,
+			Attribute: condition.Attribute,
+			Operator:  string(condition.Operator),
+			Value:     convertToProtoStateValue(condition.Value),
+		}
+	}
+
+	actions := make([]*proto.RuleAction, len(rule.Actions))
+	for i, action := range rule.Actions {
+		actions[i] = &proto.RuleAction{
+			DeviceId: action.DeviceID,
+			State:    convertToProtoState(action.State),
+		}
+	}
+
+	protoRule := &proto.Rule{
+		Id:         rule.ID,
+		Name:       rule.Name,
+		UserId:     rule.UserID,
+		Enabled:    rule.Enabled,
+		Trigger:    protoTrigger,
+		Conditions: conditions,
+		Actions:    actions,
+		CreatedAt:  timestamppb.New(rule.CreatedAt),
+		UpdatedAt:  timestamppb.New(rule.UpdatedAt),
+	}
+	if rule.NextRunAt != nil {
+		protoRule.NextRunAt = timestamppb.New(*rule.NextRunAt)
+	}
+	return protoRule
+}
+
+func convertFromProtoRuleParts(protoTrigger *proto.RuleTrigger, protoConditions []*proto.RuleCondition, protoActions []*proto.RuleAction) (models.RuleTrigger, []models.RuleCondition, []models.RuleAction, error) {
+	trigger := models.RuleTrigger{
+		Type:      models.TriggerType(protoTrigger.GetType()),
+		DeviceID:  protoTrigger.GetDeviceId(),
+		Attribute: protoTrigger.GetAttribute(),
+		Cron:      protoTrigger.GetCron(),
+		Timezone:  protoTrigger.GetTimezone(),
+		RuleID:    protoTrigger.GetRuleId(),
+	}
+	if protoTrigger.GetValue() != nil {
+		value, err := convertFromProtoStateValue(trigger.Attribute, protoTrigger.GetValue())
+		if err != nil {
+			return models.RuleTrigger{}, nil, nil, err
+		}
+		trigger.Value = &value
+	}
+
+	conditions := make([]models.RuleCondition, len(protoConditions))
+	for i, protoCondition := range protoConditions {
+		value, err := convertFromProtoStateValue(protoCondition.Attribute, protoCondition.Value)
+		if err != nil {
+			return models.RuleTrigger{}, nil, nil, err
+		}
+		conditions[i] = models.RuleCondition{
+			DeviceID:  protoCondition.DeviceId,
+			Attribute: protoCondition.Attribute,
+			Operator:  models.ConditionOperator(protoCondition.Operator),
+			Value:     value,
+		}
+	}
+
+	actions := make([]models.RuleAction, len(protoActions))
+	for i, protoAction := range protoActions {
+		state, err := convertFromProtoState(protoAction.State)
+		if err != nil {
+			return models.RuleTrigger{}, nil, nil, err
+		}
+		actions[i] = models.RuleAction{DeviceID: protoAction.DeviceId, State: state}
+	}
+
+	return trigger, conditions, actions, nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MockRuleRepository is a mock implementation of the RuleRepository interface
+type MockRuleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRuleRepository) CreateRule(ctx context.Context, rule *models.Rule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockRuleRepository) GetRule(ctx context.Context, id string) (*models.Rule, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Rule), args.Error(1)
+}
+
+func (m *MockRuleRepository) UpdateRule(ctx context.Context, rule *models.Rule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockRuleRepository) DeleteRule(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRuleRepository) ListRules(ctx context.Context, userID string) ([]*models.Rule, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*models.Rule), args.Error(1)
+}
+
+func (m *MockRuleRepository) ListTriggeredRules(ctx context.Context, triggerType models.TriggerType, sourceID string) ([]*models.Rule, error) {
+	args := m.Called(ctx, triggerType, sourceID)
+	return args.Get(0).([]*models.Rule), args.Error(1)
+}
+
+func (m *MockRuleRepository) ListDueRules(ctx context.Context, now time.Time) ([]*models.Rule, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).([]*models.Rule), args.Error(1)
+}
+
+func (m *MockRuleRepository) AdvanceRuleNextRun(ctx context.Context, id string, previous time.Time, next *time.Time) (bool, error) {
+	args := m.Called(ctx, id, previous, next)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRuleRepository) RecordRuleExecution(ctx context.Context, execution *models.RuleExecution) error {
+	args := m.Called(ctx, execution)
+	return args.Error(0)
+}
+
+func (m *MockRuleRepository) ListRuleExecutions(ctx context.Context, ruleID string, page, pageSize int) ([]*models.RuleExecution, int, error) {
+	args := m.Called(ctx, ruleID, page, pageSize)
+	return args.Get(0).([]*models.RuleExecution), args.Int(1), args.Error(2)
+}
+
+func protoPower(on bool) *proto.StateValue {
+	return &proto.StateValue{Type: "bool", BoolValue: on}
+}
+
+// lightsOnWithMotion turns the lamp on when the sensor reports power while
+// the heater is off
+func lightsOnWithMotion() *proto.CreateRuleRequest {
+	return &proto.CreateRuleRequest{
+		Name:       "Lights on",
+		Trigger:    &proto.RuleTrigger{Type: "device_state", DeviceId: "sensor", Attribute: "power", Value: protoPower(true)},
+		Conditions: []*proto.RuleCondition{{DeviceId: "heater", Attribute: "power", Operator: "eq", Value: protoPower(false)}},
+		Actions:    []*proto.RuleAction{{DeviceId: "lamp", State: map[string]*proto.StateValue{"power": protoPower(true)}}},
+	}
+}
+
+func TestCreateRule(t *testing.T) {
+	mockRuleRepo := new(MockRuleRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleGuest}}
+	service := NewRuleService(mockRuleRepo, mockRepo, mockTypeRepo, memberships)
+
+	// The sensor is shared with the caller by their household
+	mockRepo.On("LoadDevice", mock.Anything, "sensor").Return(&models.Device{ID: "sensor", Type: "switch", UserID: "user456", HouseholdID: "home"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "heater").Return(&models.Device{ID: "heater", Type: "switch", UserID: "user123"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user123"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockRuleRepo.On("CreateRule", mock.Anything, mock.AnythingOfType("*models.Rule")).Return(nil)
+
+	response, err := service.CreateRule(asOwner(), lightsOnWithMotion())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", response.UserId)
+	assert.True(t, response.Enabled)
+	assert.Equal(t, "sensor", response.Trigger.DeviceId)
+	assert.Nil(t, response.NextRunAt)
+	mockRuleRepo.AssertExpectations(t)
+}
+
+func TestCreateRuleRejectsInvalidRules(t *testing.T) {
+	mockRuleRepo := new(MockRuleRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewRuleService(mockRuleRepo, mockRepo, mockTypeRepo, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, mock.Anything).Return(&models.Device{Type: "switch", UserID: "user123"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+
+	noActions := lightsOnWithMotion()
+	noActions.Actions = nil
+	unknownAttribute := lightsOnWithMotion()
+	unknownAttribute.Trigger.Attribute = "brightness"
+	badOperator := lightsOnWithMotion()
+	badOperator.Conditions[0].Operator = "gt"
+	badCron := lightsOnWithMotion()
+	badCron.Trigger = &proto.RuleTrigger{Type: "time", Cron: "every morning"}
+
+	for name, req := range map[string]*proto.CreateRuleRequest{
+		"no actions":        noActions,
+		"unknown attribute": unknownAttribute,
+		"bad operator":      badOperator,
+		"bad cron":          badCron,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.CreateRule(asOwner(), req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+	mockRuleRepo.AssertNotCalled(t, "CreateRule", mock.Anything, mock.Anything)
+}
+
+func TestUpdateRule(t *testing.T) {
+	mockRuleRepo := new(MockRuleRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewRuleService(mockRuleRepo, mockRepo, mockTypeRepo, nil)
+
+	rule := &models.Rule{ID: "morning", Name: "Morning", UserID: "user123", Enabled: true}
+	mockRuleRepo.On("GetRule", mock.Anything, "morning").Return(rule, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user123"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockRuleRepo.On("UpdateRule", mock.Anything, mock.AnythingOfType("*models.Rule")).Return(nil)
+
+	req := &proto.UpdateRuleRequest{
+		Id:      "morning",
+		Name:    "Wake up",
+		Trigger: &proto.RuleTrigger{Type: "time", Cron: "0 7 * * *", Timezone: "Europe/Sofia"},
+		Actions: []*proto.RuleAction{{DeviceId: "lamp", State: map[string]*proto.StateValue{"power": protoPower(true)}}},
+	}
+	response, err := service.UpdateRule(asOwner(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Wake up", response.Name)
+	assert.Equal(t, "0 7 * * *", response.Trigger.Cron)
+	// Time-triggered rules are scheduled as soon as they are saved
+	assert.NotNil(t, response.NextRunAt)
+	mockRuleRepo.AssertExpectations(t)
+}
+
+func TestSetRuleEnabled(t *testing.T) {
+	mockRuleRepo := new(MockRuleRepository)
+	service := NewRuleService(mockRuleRepo, new(MockRepository), new(MockDeviceTypeRepository), nil)
+
+	nextRun := time.Now().Add(time.Hour)
+	rule := &models.Rule{
+		ID:        "morning",
+		UserID:    "user123",
+		Enabled:   true,
+		Trigger:   models.RuleTrigger{Type: models.TriggerTime, Cron: "0 7 * * *", Timezone: "UTC"},
+		NextRunAt: &nextRun,
+	}
+	mockRuleRepo.On("GetRule", mock.Anything, "morning").Return(rule, nil)
+	mockRuleRepo.On("UpdateRule", mock.Anything, rule).Return(nil)
+
+	response, err := service.SetRuleEnabled(asOwner(), &proto.SetRuleEnabledRequest{Id: "morning", Enabled: false})
+	assert.NoError(t, err)
+	assert.False(t, response.Enabled)
+	assert.Nil(t, response.NextRunAt)
+
+	response, err = service.SetRuleEnabled(asOwner(), &proto.SetRuleEnabledRequest{Id: "morning", Enabled: true})
+	assert.NoError(t, err)
+	assert.True(t, response.Enabled)
+	assert.NotNil(t, response.NextRunAt)
+
+	// Enabling an enabled rule changes nothing
+	_, err = service.SetRuleEnabled(asOwner(), &proto.SetRuleEnabledRequest{Id: "morning", Enabled: true})
+	assert.NoError(t, err)
+	mockRuleRepo.AssertNumberOfCalls(t, "UpdateRule", 2)
+}
+
+func TestListRuleExecutions(t *testing.T) {
+	mockRuleRepo := new(MockRuleRepository)
+	service := NewRuleService(mockRuleRepo, new(MockRepository), new(MockDeviceTypeRepository), nil)
+
+	executedAt := time.Date(2024, 7, 3, 7, 0, 0, 0, time.UTC)
+	mockRuleRepo.On("GetRule", mock.Anything, "morning").Return(&models.Rule{ID: "morning", UserID: "user123"}, nil)
+	// Page sizes beyond the maximum are capped
+	mockRuleRepo.On("ListRuleExecutions", mock.Anything, "morning", 2, 500).Return([]*models.RuleExecution{
+		{ID: "run-1", RuleID: "morning", Trigger: "time", Status: models.RuleExecutionFailed, Error: "device lamp: device not found", ExecutedAt: executedAt},
+	}, 501, nil)
+
+	response, err := service.ListRuleExecutions(asOwner(), &proto.ListRuleExecutionsRequest{RuleId: "morning", Page: 2, PageSize: 1000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(501), response.Total)
+	assert.Len(t, response.Executions, 1)
+	assert.Equal(t, "failed", response.Executions[0].Status)
+	assert.True(t, executedAt.Equal(response.Executions[0].ExecutedAt.AsTime()))
+	mockRuleRepo.AssertExpectations(t)
+}
+
+func TestRulesOfAnotherUserAreDenied(t *testing.T) {
+	mockRuleRepo := new(MockRuleRepository)
+	mockRepo := new(MockRepository)
+	service := NewRuleService(mockRuleRepo, mockRepo, new(MockDeviceTypeRepository), nil)
+
+	mockRuleRepo.On("GetRule", mock.Anything, "their-rule").Return(&models.Rule{ID: "their-rule", UserID: "user456", Enabled: true}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "sensor").Return(&models.Device{ID: "sensor", Type: "switch", UserID: "user123"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "heater").Return(&models.Device{ID: "heater", Type: "switch", UserID: "user123"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user456"}, nil)
+
+	_, err := service.GetRule(asOwner(), &proto.GetRuleRequest{Id: "their-rule"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.UpdateRule(asOwner(), &proto.UpdateRuleRequest{Id: "their-rule", Name: "Mine now"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.DeleteRule(asOwner(), &proto.DeleteRuleRequest{Id: "their-rule"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.SetRuleEnabled(asOwner(), &proto.SetRuleEnabledRequest{Id: "their-rule", Enabled: false})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListRuleExecutions(asOwner(), &proto.ListRuleExecutionsRequest{RuleId: "their-rule"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListRules(asOwner(), &proto.ListRulesRequest{UserId: "user456"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Rules cannot act on the devices of other users
+	_, err = service.CreateRule(asOwner(), lightsOnWithMotion())
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	mockRuleRepo.AssertNotCalled(t, "CreateRule", mock.Anything, mock.Anything)
+	mockRuleRepo.AssertNotCalled(t, "UpdateRule", mock.Anything, mock.Anything)
+	mockRuleRepo.AssertNotCalled(t, "DeleteRule", mock.Anything, mock.Anything)
+	mockRuleRepo.AssertNotCalled(t, "ListRuleExecutions", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/events"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type SceneService struct {
+	proto.UnimplementedSceneServiceServer
+	access
+	createSceneHandler   *command.CreateSceneHandler
+	updateSceneHandler   *command.UpdateSceneHandler
+	deleteSceneHandler   *command.DeleteSceneHandler
+	activateSceneHandler *command.ActivateSceneHandler
+	getSceneHandler      *query.GetSceneHandler
+	listScenesHandler    *query.ListScenesHandler
+}
+
+// NewSceneService creates the scene service. Device updates made by an
+// activation publish their domain events to publisher, which may be nil.
+//
+// Scenes belong to the caller. Their targets are the devices the caller may
+// control, as looked up in memberships for household devices.
+func NewSceneService(repo repository.SceneRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, publisher events.Publisher, memberships membership.Source) *SceneService {
+	return &SceneService{
+		access:               access{devices: deviceRepo, memberships: memberships},
+		createSceneHandler:   command.NewCreateSceneHandler(repo, deviceRepo, typeRepo),
+		updateSceneHandler:   command.NewUpdateSceneHandler(repo, deviceRepo, typeRepo),
+		deleteSceneHandler:   command.NewDeleteSceneHandler(repo),
+		activateSceneHandler: command.NewActivateSceneHandler(repo, deviceRepo, typeRepo, publisher),
+		getSceneHandler:      query.NewGetSceneHandler(repo),
+		listScenesHandler:    query.NewListScenesHandler(repo),
+	}
+}
+
+func (s *SceneService) CreateScene(ctx context.Context, req *proto.CreateSceneRequest) (*proto.Scene, error) {
+	userID, err := ownerFor(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := convertFromProtoSceneTargets(req.Targets)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := s.authorizeTargets(ctx, targets); err != nil {
+		return nil, err
+	}
+
+	cmd := command.CreateSceneCommand{
+		Name:    req.Name,
+		UserID:  userID,
+		Targets: targets,
+	}
+
+	scene, err := s.createSceneHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoScene(scene), nil
+}
+
+func (s *SceneService) GetScene(ctx context.Context, req *proto.GetSceneRequest) (*proto.Scene, error) {
+	scene, err := s.ownedScene(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return convertToProtoScene(scene), nil
+}
+
+func (s *SceneService) UpdateScene(ctx context.Context, req *proto.UpdateSceneRequest) (*proto.Scene, error) {
+	if _, err := s.ownedScene(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	targets, err := convertFromProtoSceneTargets(req.Targets)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := s.authorizeTargets(ctx, targets); err != nil {
+		return nil, err
+	}
+
+	cmd := command.UpdateSceneCommand{
+		ID:      req.Id,
+		Name:    req.Name,
+		Targets: targets,
+	}
+
+	scene, err := s.updateSceneHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoScene(scene), nil
+}
+
+func (s *SceneService) DeleteScene(ctx context.Context, req *proto.DeleteSceneRequest) (*proto.DeleteSceneResponse, error) {
+	if _, err := s.ownedScene(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	if err := s.deleteSceneHandler.Handle(ctx, command.DeleteSceneCommand{ID: req.Id}); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &proto.DeleteSceneResponse{Success: true}, nil
+}
+
+func (s *SceneService) ListScenes(ctx context.Context, req *proto.ListScenesRequest) (*proto.ListScenesResponse, error) {
+	userID, err := ownerFor(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	scenes, err := s.listScenesHandler.Handle(ctx, query.ListScenesQuery{UserID: userID})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	protoScenes := make([]*proto.Scene, len(scenes))
+	for i, scene := range scenes {
+		protoScenes[i] = convertToProtoScene(scene)
+	}
+	return &proto.ListScenesResponse{Scenes: protoScenes}, nil
+}
+
+// ActivateScene only fails as a whole when the scene cannot be loaded.
+// Failures of individual devices are reported in the per-target results,
+// including devices the caller may no longer control.
+func (s *SceneService) ActivateScene(ctx context.Context, req *proto.ActivateSceneRequest) (*proto.ActivateSceneResponse, error) {
+	scene, err := s.ownedScene(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	denied := make(map[string]error)
+	for _, target := range scene.Targets {
+		if _, err := s.authorizeDevice(ctx, target.DeviceID, canControl); err != nil {
+			denied[target.DeviceID] = err
+		}
+	}
+
+	results, err := s.activateSceneHandler.Handle(ctx, command.ActivateSceneCommand{ID: req.Id, Denied: denied})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	response := &proto.ActivateSceneResponse{
+		SceneId:      req.Id,
+		Results:      make([]*proto.SceneTargetResult, len(results)),
+		AllSucceeded: true,
+	}
+	for i, result := range results {
+		protoResult := &proto.SceneTargetResult{DeviceId: result.DeviceID, Success: result.Err == nil}
+		if result.Err != nil {
+			protoResult.Error = status.Convert(result.Err).Message()
+			response.AllSucceeded = false
+		} else {
+			protoResult.Device = convertToProtoDevice(result.Device)
+		}
+		response.Results[i] = protoResult
+	}
+	return response, nil
+}
+
+// ownedScene loads a scene of the caller
+func (s *SceneService) ownedScene(ctx context.Context, id string) (*models.Scene, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scene, err := s.getSceneHandler.Handle(ctx, query.GetSceneQuery{ID: id})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := checkOwner(caller, scene.UserID, "scene", scene.ID); err != nil {
+		return nil, err
+	}
+	return scene, nil
+}
+
+// authorizeTargets checks that the caller may control every device of a scene
+func (s *SceneService) authorizeTargets(ctx context.Context, targets []models.SceneTarget) error {
+	for _, target := range targets {
+		if _, err := s.authorizeDevice(ctx, target.DeviceID, canControl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convertToProtoScene(scene *models.Scene) *proto.Scene {
+	targets := make([]*proto.SceneTarget, len(scene.Targets))
+	for i, target := range scene.Targets {
+		targets[i] = &proto.SceneTarget{
+			DeviceId: target.DeviceID,
+			State:    convertToProtoState(target.State),
+		}
+	}
+	return &proto.Scene{
+		Id:        scene.ID,
+		Name:      scene.Name,
+		UserId:    scene.UserID,
+		Targets:   targets,
+		CreatedAt: timestamppb.New(scene.CreatedAt),
+		UpdatedAt: timestamppb.New(scene.UpdatedAt),
+	}
+}
+
+func convertFromProtoSceneTargets(protoTargets []*proto.SceneTarget) ([]models.SceneTarget, error) {
+	targets := make([]models.SceneTarget, len(protoTargets))
+	for i, protoTarget := range protoTargets {
+		state, err := convertFromProtoState(protoTarget.State)
+		if err != nil {
+			return nil, err
+		}
+		targets[i] = models.SceneTarget{DeviceID: protoTarget.DeviceId, State: state}
+	}
+	return targets, nil
+}
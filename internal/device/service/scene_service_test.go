@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MockSceneRepository is a mock implementation of the SceneRepository interface
+type MockSceneRepository struct {
+	mock.Mock
+}
+
+func (m *MockSceneRepository) CreateScene(ctx context.Context, scene *models.Scene) error {
+	args := m.Called(ctx, scene)
+	return args.Error(0)
+}
+
+func (m *MockSceneRepository) GetScene(ctx context.Context, id string) (*models.Scene, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Scene), args.Error(1)
+}
+
+func (m *MockSceneRepository) UpdateScene(ctx context.Context, scene *models.Scene) error {
+	args := m.Called(ctx, scene)
+	return args.Error(0)
+}
+
+func (m *MockSceneRepository) DeleteScene(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSceneRepository) ListScenes(ctx context.Context, userID string) ([]*models.Scene, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*models.Scene), args.Error(1)
+}
+
+func TestActivateSceneReportsPerDeviceResults(t *testing.T) {
+	mockSceneRepo := new(MockSceneRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewSceneService(mockSceneRepo, mockRepo, mockTypeRepo, nil, nil)
+
+	scene := &models.Scene{
+		ID:     "movie-night",
+		UserID: "user123",
+		Targets: []models.SceneTarget{
+			{DeviceID: "tv", State: powerState(true)},
+			{DeviceID: "lamp", State: powerState(false)},
+		},
+	}
+	tv := &models.Device{ID: "tv", Type: "switch", UserID: "user123", State: powerState(true)}
+
+	mockSceneRepo.On("GetScene", mock.Anything, "movie-night").Return(scene, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockRepo.On("LoadDevice", mock.Anything, "tv").Return(tv, nil)
+	mockRepo.On("UpdateDeviceState", mock.Anything, "tv", powerState(true), int64(0)).Return(tv, nil)
+	// The lamp was deleted after the scene was defined
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return((*models.Device)(nil), repository.ErrDeviceNotFound)
+
+	response, err := service.ActivateScene(asOwner(), &proto.ActivateSceneRequest{Id: "movie-night"})
+
+	assert.NoError(t, err)
+	assert.False(t, response.AllSucceeded)
+	assert.Len(t, response.Results, 2)
+	assert.True(t, response.Results[0].Success)
+	assert.Equal(t, "tv", response.Results[0].Device.Id)
+	assert.Equal(t, "lamp", response.Results[1].DeviceId)
+	assert.False(t, response.Results[1].Success)
+	assert.Equal(t, repository.ErrDeviceNotFound.Error(), response.Results[1].Error)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateSceneRejectsDeviceOfAnotherUser(t *testing.T) {
+	mockSceneRepo := new(MockSceneRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewSceneService(mockSceneRepo, mockRepo, mockTypeRepo, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "tv").Return(&models.Device{ID: "tv", Type: "switch", UserID: "user456"}, nil)
+
+	req := &proto.CreateSceneRequest{
+		Name:   "Movie night",
+		UserId: "user123",
+		Targets: []*proto.SceneTarget{
+			{DeviceId: "tv", State: map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}},
+		},
+	}
+	_, err := service.CreateScene(asOwner(), req)
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	mockSceneRepo.AssertNotCalled(t, "CreateScene", mock.Anything, mock.Anything)
+}
+
+func TestCreateSceneRejectsDuplicateTargets(t *testing.T) {
+	mockSceneRepo := new(MockSceneRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewSceneService(mockSceneRepo, mockRepo, mockTypeRepo, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "tv").Return(&models.Device{ID: "tv", Type: "switch", UserID: "user123"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+
+	target := &proto.SceneTarget{DeviceId: "tv", State: map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}}
+	req := &proto.CreateSceneRequest{Name: "Movie night", UserId: "user123", Targets: []*proto.SceneTarget{target, target}}
+	_, err := service.CreateScene(asOwner(), req)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestScenesOfAnotherUserAreDenied(t *testing.T) {
+	mockSceneRepo := new(MockSceneRepository)
+	mockRepo := new(MockRepository)
+	service := NewSceneService(mockSceneRepo, mockRepo, new(MockDeviceTypeRepository), nil, nil)
+
+	scene := &models.Scene{ID: "their-scene", UserID: "user456", Targets: []models.SceneTarget{{DeviceID: "tv", State: powerState(true)}}}
+	mockSceneRepo.On("GetScene", mock.Anything, "their-scene").Return(scene, nil)
+
+	_, err := service.GetScene(asOwner(), &proto.GetSceneRequest{Id: "their-scene"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.UpdateScene(asOwner(), &proto.UpdateSceneRequest{Id: "their-scene", Name: "Mine now"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.DeleteScene(asOwner(), &proto.DeleteSceneRequest{Id: "their-scene"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ActivateScene(asOwner(), &proto.ActivateSceneRequest{Id: "their-scene"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListScenes(asOwner(), &proto.ListScenesRequest{UserId: "user456"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.GetScene(context.Background(), &proto.GetSceneRequest{Id: "their-scene"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	mockSceneRepo.AssertNotCalled(t, "UpdateScene", mock.Anything, mock.Anything)
+	mockSceneRepo.AssertNotCalled(t, "DeleteScene", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateDeviceState", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestActivateSceneSkipsDevicesTheCallerCannotControl(t *testing.T) {
+	mockSceneRepo := new(MockSceneRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleGuest}}
+	service := NewSceneService(mockSceneRepo, mockRepo, mockTypeRepo, nil, memberships)
+
+	scene := &models.Scene{
+		ID:     "movie-night",
+		UserID: "user123",
+		Targets: []models.SceneTarget{
+			{DeviceID: "tv", State: powerState(true)},
+			{DeviceID: "heater", State: powerState(false)},
+		},
+	}
+	// Guests control the devices of their household; the caller left the cabin
+	tv := &models.Device{ID: "tv", Type: "switch", UserID: "user456", HouseholdID: "home", State: powerState(true)}
+	heater := &models.Device{ID: "heater", Type: "switch", UserID: "user456", HouseholdID: "cabin"}
+
+	mockSceneRepo.On("GetScene", mock.Anything, "movie-night").Return(scene, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockRepo.On("LoadDevice", mock.Anything, "tv").Return(tv, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "heater").Return(heater, nil)
+	mockRepo.On("UpdateDeviceState", mock.Anything, "tv", powerState(true), int64(0)).Return(tv, nil)
+
+	response, err := service.ActivateScene(asOwner(), &proto.ActivateSceneRequest{Id: "movie-night"})
+
+	assert.NoError(t, err)
+	assert.False(t, response.AllSucceeded)
+	assert.True(t, response.Results[0].Success)
+	assert.False(t, response.Results[1].Success)
+	assert.Equal(t, "not a member of household cabin", response.Results[1].Error)
+	mockRepo.AssertNotCalled(t, "UpdateDeviceState", mock.Anything, "heater", mock.Anything, mock.Anything)
+}
+
+func TestCreateSceneTargetsHouseholdDevices(t *testing.T) {
+	mockSceneRepo := new(MockSceneRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleChild}}
+	service := NewSceneService(mockSceneRepo, mockRepo, mockTypeRepo, nil, memberships)
+
+	// The tv was added to the household by another member
+	mockRepo.On("LoadDevice", mock.Anything, "tv").Return(&models.Device{ID: "tv", Type: "switch", UserID: "user456", HouseholdID: "home"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockSceneRepo.On("CreateScene", mock.Anything, mock.AnythingOfType("*models.Scene")).Return(nil)
+
+	req := &proto.CreateSceneRequest{
+		Name:    "Movie night",
+		Targets: []*proto.SceneTarget{{DeviceId: "tv", State: map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}}},
+	}
+	response, err := service.CreateScene(asOwner(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", response.UserId)
+	mockSceneRepo.AssertExpectations(t)
+}
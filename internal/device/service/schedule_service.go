@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/membership"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type ScheduleService struct {
+	proto.UnimplementedScheduleServiceServer
+	access
+	createScheduleHandler   *command.CreateScheduleHandler
+	updateScheduleHandler   *command.UpdateScheduleHandler
+	deleteScheduleHandler   *command.DeleteScheduleHandler
+	getScheduleHandler      *query.GetScheduleHandler
+	listSchedulesHandler    *query.ListSchedulesHandler
+	listScheduleRunsHandler *query.ListScheduleRunsHandler
+}
+
+// NewScheduleService creates the schedule service. Schedules belong to the
+// caller and target a device the caller may control, as looked up in
+// memberships for household devices.
+func NewScheduleService(repo repository.ScheduleRepository, deviceRepo repository.CommandDeviceRepository, typeRepo repository.DeviceTypeRepository, memberships membership.Source) *ScheduleService {
+	return &ScheduleService{
+		access:                  access{devices: deviceRepo, memberships: memberships},
+		createScheduleHandler:   command.NewCreateScheduleHandler(repo, deviceRepo, typeRepo),
+		updateScheduleHandler:   command.NewUpdateScheduleHandler(repo, deviceRepo, typeRepo),
+		deleteScheduleHandler:   command.NewDeleteScheduleHandler(repo),
+		getScheduleHandler:      query.NewGetScheduleHandler(repo),
+		listSchedulesHandler:    query.NewListSchedulesHandler(repo),
+		listScheduleRunsHandler: query.NewListScheduleRunsHandler(repo),
+	}
+}
+
+func (s *ScheduleService) CreateSchedule(ctx context.Context, req *proto.CreateScheduleRequest) (*proto.Schedule, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	action, err := convertFromProtoState(req.Action)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if _, err := s.authorizeDevice(ctx, req.DeviceId, canControl); err != nil {
+		return nil, err
+	}
+
+	cmd := command.CreateScheduleCommand{
+		UserID:   caller,
+		DeviceID: req.DeviceId,
+		Action:   action,
+		Cron:     req.Cron,
+		RunAt:    req.RunAt,
+		Timezone: req.Timezone,
+	}
+
+	schedule, err := s.createScheduleHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoSchedule(schedule), nil
+}
+
+func (s *ScheduleService) GetSchedule(ctx context.Context, req *proto.GetScheduleRequest) (*proto.Schedule, error) {
+	schedule, err := s.ownedSchedule(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return convertToProtoSchedule(schedule), nil
+}
+
+func (s *ScheduleService) UpdateSchedule(ctx context.Context, req *proto.UpdateScheduleRequest) (*proto.Schedule, error) {
+	current, err := s.ownedSchedule(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	action, err := convertFromProtoState(req.Action)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if _, err := s.authorizeDevice(ctx, current.DeviceID, canControl); err != nil {
+		return nil, err
+	}
+
+	cmd := command.UpdateScheduleCommand{
+		ID:       req.Id,
+		Action:   action,
+		Cron:     req.Cron,
+		RunAt:    req.RunAt,
+		Timezone: req.Timezone,
+		Enabled:  req.Enabled,
+	}
+
+	schedule, err := s.updateScheduleHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return convertToProtoSchedule(schedule), nil
+}
+
+func (s *ScheduleService) DeleteSchedule(ctx context.Context, req *proto.DeleteScheduleRequest) (*proto.DeleteScheduleResponse, error) {
+	if _, err := s.ownedSchedule(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	if err := s.deleteScheduleHandler.Handle(ctx, command.DeleteScheduleCommand{ID: req.Id}); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &proto.DeleteScheduleResponse{Success: true}, nil
+}
+
+func (s *ScheduleService) ListSchedules(ctx context.Context, req *proto.ListSchedulesRequest) (*proto.ListSchedulesResponse, error) {
+	userID, err := ownerFor(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	q := query.ListSchedulesQuery{
+		UserID:   userID,
+		DeviceID: req.DeviceId,
+	}
+
+	schedules, err := s.listSchedulesHandler.Handle(ctx, q)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	protoSchedules := make([]*proto.Schedule, len(schedules))
+	for i, schedule := range schedules {
+		protoSchedules[i] = convertToProtoSchedule(schedule)
+	}
+	return &proto.ListSchedulesResponse{Schedules: protoSchedules}, nil
+}
+
+func (s *ScheduleService) ListScheduleRuns(ctx context.Context, req *proto.ListScheduleRunsRequest) (*proto.ListScheduleRunsResponse, error) {
+	if _, err := s.ownedSchedule(ctx, req.ScheduleId); err != nil {
+		return nil, err
+	}
+	q := query.ListScheduleRunsQuery{
+		ScheduleID: req.ScheduleId,
+		Page:       int(req.Page),
+		PageSize:   int(req.PageSize),
+	}
+
+	runs, total, err := s.listScheduleRunsHandler.Handle(ctx, q)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	protoRuns := make([]*proto.ScheduleRun, len(runs))
+	for i, run := range runs {
+		protoRuns[i] = &proto.ScheduleRun{
+			Id:         run.ID,
+			ScheduleId: run.ScheduleID,
+			DeviceId:   run.DeviceID,
+			RanAt:      timestamppb.New(run.RanAt),
+			Success:    run.Success,
+			Error:      run.Error,
+		}
+	}
+	return &proto.ListScheduleRunsResponse{Runs: protoRuns, Total: int32(total)}, nil
+}
+
+// ownedSchedule loads a schedule of the caller
+func (s *ScheduleService) ownedSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := s.getScheduleHandler.Handle(ctx, query.GetScheduleQuery{ID: id})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if err := checkOwner(caller, schedule.UserID, "schedule", schedule.ID); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func convertToProtoSchedule(schedule *models.Schedule) *proto.Schedule {
+	protoSchedule := &proto.Schedule{
+		Id:        schedule.ID,
+		DeviceId:  schedule.DeviceID,
+		UserId:    schedule.UserID,
+		Action:    convertToProtoState(schedule.Action),
+		Cron:      schedule.Cron,
+		Timezone:  schedule.Timezone,
+		Enabled:   schedule.Enabled,
+		CreatedAt: timestamppb.New(schedule.CreatedAt),
+		UpdatedAt: timestamppb.New(schedule.UpdatedAt),
+	}
+	if !schedule.Repeats() && !schedule.Time.IsZero() {
+		// One-shot times are reported back in the schedule's own timezone
+		runAt := schedule.Time
+		if loc, err := time.LoadLocation(schedule.Timezone); err == nil {
+			runAt = runAt.In(loc)
+		}
+		protoSchedule.RunAt = runAt.Format(command.RunAtLayout)
+	}
+	if schedule.NextRunAt != nil {
+		protoSchedule.NextRunAt = timestamppb.New(*schedule.NextRunAt)
+	}
+	if schedule.LastRunAt != nil {
+		protoSchedule.LastRunAt = timestamppb.New(*schedule.LastRunAt)
+	}
+	return protoSchedule
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MockScheduleRepository is a mock implementation of the ScheduleRepository interface
+type MockScheduleRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduleRepository) CreateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	args := m.Called(ctx, schedule)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) UpdateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	args := m.Called(ctx, schedule)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) DeleteSchedule(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) ListSchedules(ctx context.Context, filter repository.ScheduleFilter) ([]*models.Schedule, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) ClaimDueSchedules(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.Schedule, error) {
+	args := m.Called(ctx, now, lease, limit)
+	return args.Get(0).([]*models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) CompleteScheduleRun(ctx context.Context, schedule *models.Schedule, run *models.ScheduleRun) error {
+	args := m.Called(ctx, schedule, run)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) ListScheduleRuns(ctx context.Context, scheduleID string, page, pageSize int) ([]*models.ScheduleRun, int, error) {
+	args := m.Called(ctx, scheduleID, page, pageSize)
+	return args.Get(0).([]*models.ScheduleRun), args.Int(1), args.Error(2)
+}
+
+func protoPowerAction(on bool) map[string]*proto.StateValue {
+	return map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: on}}
+}
+
+func TestCreateScheduleOneShotInTimezone(t *testing.T) {
+	mockScheduleRepo := new(MockScheduleRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	service := NewScheduleService(mockScheduleRepo, mockRepo, mockTypeRepo, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user123"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockScheduleRepo.On("CreateSchedule", mock.Anything, mock.AnythingOfType("*models.Schedule")).Return(nil)
+
+	runAt := time.Now().AddDate(0, 0, 1).Format("2006-01-02") + "T07:30:00"
+	req := &proto.CreateScheduleRequest{
+		DeviceId: "lamp",
+		Action:   protoPowerAction(true),
+		RunAt:    runAt,
+		Timezone: "Europe/Sofia",
+	}
+
+	response, err := service.CreateSchedule(asOwner(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", response.UserId)
+	assert.Equal(t, runAt, response.RunAt)
+	assert.True(t, response.Enabled)
+
+	loc, _ := time.LoadLocation("Europe/Sofia")
+	expected, _ := time.ParseInLocation("2006-01-02T15:04:05", runAt, loc)
+	assert.True(t, expected.Equal(response.NextRunAt.AsTime()))
+	mockScheduleRepo.AssertExpectations(t)
+}
+
+func TestCreateScheduleRejectsInvalidTiming(t *testing.T) {
+	tests := map[string]*proto.CreateScheduleRequest{
+		"no timing":        {},
+		"both timings":     {Cron: "0 7 * * *", RunAt: "2999-01-01T07:00:00"},
+		"bad cron":         {Cron: "every morning"},
+		"tz prefix":        {Cron: "CRON_TZ=Europe/Sofia 0 7 * * *"},
+		"unknown timezone": {Cron: "0 7 * * *", Timezone: "Mars/Olympus"},
+		"past run_at":      {RunAt: "2000-01-01T07:00:00"},
+		"bad run_at":       {RunAt: "tomorrow"},
+	}
+
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockScheduleRepo := new(MockScheduleRepository)
+			mockRepo := new(MockRepository)
+			mockTypeRepo := new(MockDeviceTypeRepository)
+			service := NewScheduleService(mockScheduleRepo, mockRepo, mockTypeRepo, nil)
+
+			mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user123"}, nil)
+			mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+
+			req.DeviceId = "lamp"
+			req.Action = protoPowerAction(true)
+			_, err := service.CreateSchedule(asOwner(), req)
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			mockScheduleRepo.AssertNotCalled(t, "CreateSchedule", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateScheduleBelongsToTheCaller(t *testing.T) {
+	mockScheduleRepo := new(MockScheduleRepository)
+	mockRepo := new(MockRepository)
+	mockTypeRepo := new(MockDeviceTypeRepository)
+	memberships := fakeMemberships{"user123": {"home": models.HouseholdRoleAdult}}
+	service := NewScheduleService(mockScheduleRepo, mockRepo, mockTypeRepo, memberships)
+
+	// The lamp was added to the household by another member
+	mockRepo.On("LoadDevice", mock.Anything, "lamp").Return(&models.Device{ID: "lamp", Type: "switch", UserID: "user456", HouseholdID: "home"}, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "their-lamp").Return(&models.Device{ID: "their-lamp", Type: "switch", UserID: "user456"}, nil)
+	mockTypeRepo.On("GetDeviceType", mock.Anything, "switch").Return(switchType("switch"), nil)
+	mockScheduleRepo.On("CreateSchedule", mock.Anything, mock.AnythingOfType("*models.Schedule")).Return(nil)
+
+	response, err := service.CreateSchedule(asOwner(), &proto.CreateScheduleRequest{DeviceId: "lamp", Action: protoPowerAction(true), Cron: "0 7 * * *"})
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", response.UserId)
+
+	_, err = service.CreateSchedule(asOwner(), &proto.CreateScheduleRequest{DeviceId: "their-lamp", Action: protoPowerAction(true), Cron: "0 7 * * *"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	mockScheduleRepo.AssertNumberOfCalls(t, "CreateSchedule", 1)
+}
+
+func TestSchedulesOfAnotherUserAreDenied(t *testing.T) {
+	mockScheduleRepo := new(MockScheduleRepository)
+	service := NewScheduleService(mockScheduleRepo, new(MockRepository), new(MockDeviceTypeRepository), nil)
+
+	mockScheduleRepo.On("GetSchedule", mock.Anything, "their-schedule").Return(&models.Schedule{ID: "their-schedule", DeviceID: "lamp", UserID: "user456"}, nil)
+
+	_, err := service.GetSchedule(asOwner(), &proto.GetScheduleRequest{Id: "their-schedule"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.UpdateSchedule(asOwner(), &proto.UpdateScheduleRequest{Id: "their-schedule", Action: protoPowerAction(false), Cron: "0 8 * * *"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.DeleteSchedule(asOwner(), &proto.DeleteScheduleRequest{Id: "their-schedule"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListScheduleRuns(asOwner(), &proto.ListScheduleRunsRequest{ScheduleId: "their-schedule"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = service.ListSchedules(asOwner(), &proto.ListSchedulesRequest{UserId: "user456"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	mockScheduleRepo.AssertNotCalled(t, "UpdateSchedule", mock.Anything, mock.Anything)
+	mockScheduleRepo.AssertNotCalled(t, "DeleteSchedule", mock.Anything, mock.Anything)
+	mockScheduleRepo.AssertNotCalled(t, "ListScheduleRuns", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
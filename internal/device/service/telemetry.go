@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/command"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/query"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// IngestTelemetry stores readings of devices the caller may control
+func (s *DeviceService) IngestTelemetry(ctx context.Context, req *proto.IngestTelemetryRequest) (*proto.IngestTelemetryResponse, error) {
+	accepted, err := s.ingestTelemetry(ctx, req.Points, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	return &proto.IngestTelemetryResponse{Accepted: accepted}, nil
+}
+
+// StreamTelemetry stores the readings of every message of a stream as it
+// arrives, and reports how many were stored once the client closes the
+// stream. A message that cannot be stored ends the stream; the messages
+// before it stay stored. Devices are authorized once per stream.
+func (s *DeviceService) StreamTelemetry(stream proto.DeviceService_StreamTelemetryServer) error {
+	authorized := make(map[string]bool)
+	var accepted int64
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&proto.IngestTelemetryResponse{Accepted: accepted})
+		}
+		if err != nil {
+			return err
+		}
+		n, err := s.ingestTelemetry(stream.Context(), req.Points, authorized)
+		if err != nil {
+			return err
+		}
+		accepted += n
+	}
+}
+
+// ingestTelemetry stores readings once the caller is allowed to control their
+// devices. The devices already authorized are skipped, and added to authorized.
+func (s *DeviceService) ingestTelemetry(ctx context.Context, points []*proto.TelemetryPoint, authorized map[string]bool) (int64, error) {
+	// Checked before the devices are looked up one by one
+	if len(points) > command.MaxTelemetryPoints {
+		return 0, status.Errorf(codes.InvalidArgument, "at most %d readings are ingested at once, got %d", command.MaxTelemetryPoints, len(points))
+	}
+	converted := make([]*models.TelemetryPoint, len(points))
+	for i, point := range points {
+		if !authorized[point.DeviceId] {
+			if _, err := s.authorizeDevice(ctx, point.DeviceId, canControl); err != nil {
+				return 0, err
+			}
+			authorized[point.DeviceId] = true
+		}
+		converted[i] = convertFromProtoTelemetryPoint(point)
+	}
+
+	stored, err := s.ingestTelemetryHandler.Handle(ctx, command.IngestTelemetryCommand{Points: converted})
+	if err != nil {
+		return 0, toStatusError(err)
+	}
+	return int64(len(stored)), nil
+}
+
+// QueryTelemetry reads the readings of a metric of a device, or summarizes
+// them per interval for charts
+func (s *DeviceService) QueryTelemetry(ctx context.Context, req *proto.QueryTelemetryRequest) (*proto.QueryTelemetryResponse, error) {
+	if _, err := s.authorizeDevice(ctx, req.DeviceId, canHistory); err != nil {
+		return nil, err
+	}
+	query := query.GetTelemetryQuery{
+		DeviceID: req.DeviceId,
+		Metric:   req.Metric,
+		Interval: time.Duration(req.IntervalSeconds) * time.Second,
+		Limit:    int(req.Limit),
+	}
+	if req.From != nil {
+		query.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		query.To = req.To.AsTime()
+	}
+
+	result, err := s.telemetryHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	resp := &proto.QueryTelemetryResponse{Truncated: result.Truncated}
+	for _, point := range result.Points {
+		resp.Points = append(resp.Points, &proto.TelemetryPoint{
+			DeviceId:  point.DeviceID,
+			Metric:    point.Metric,
+			Value:     point.Value,
+			Timestamp: timestamppb.New(point.Timestamp),
+		})
+	}
+	for _, bucket := range result.Buckets {
+		resp.Buckets = append(resp.Buckets, &proto.TelemetryBucket{
+			Start: timestamppb.New(bucket.Start),
+			Min:   bucket.Min,
+			Max:   bucket.Max,
+			Avg:   bucket.Avg,
+			Count: bucket.Count,
+		})
+	}
+	return resp, nil
+}
+
+func convertFromProtoTelemetryPoint(point *proto.TelemetryPoint) *models.TelemetryPoint {
+	converted := &models.TelemetryPoint{
+		DeviceID: point.DeviceId,
+		Metric:   point.Metric,
+		Value:    point.Value,
+	}
+	if point.Timestamp != nil {
+		converted.Timestamp = point.Timestamp.AsTime()
+	}
+	return converted
+}
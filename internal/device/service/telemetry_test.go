@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MockTelemetryRepository is a mock implementation of the TelemetryRepository interface
+type MockTelemetryRepository struct {
+	mock.Mock
+}
+
+func (m *MockTelemetryRepository) InsertTelemetry(ctx context.Context, points []*models.TelemetryPoint) error {
+	args := m.Called(ctx, points)
+	return args.Error(0)
+}
+
+func (m *MockTelemetryRepository) ListTelemetry(ctx context.Context, series repository.TelemetryRange, limit int) ([]*models.TelemetryPoint, error) {
+	args := m.Called(ctx, series, limit)
+	return args.Get(0).([]*models.TelemetryPoint), args.Error(1)
+}
+
+func (m *MockTelemetryRepository) AggregateTelemetry(ctx context.Context, series repository.TelemetryRange, interval time.Duration) ([]*models.TelemetryBucket, error) {
+	args := m.Called(ctx, series, interval)
+	return args.Get(0).([]*models.TelemetryBucket), args.Error(1)
+}
+
+// telemetryStream is the server side of a StreamTelemetry call
+type telemetryStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	requests []*proto.IngestTelemetryRequest
+	response *proto.IngestTelemetryResponse
+}
+
+func (s *telemetryStream) Context() context.Context { return s.ctx }
+
+func (s *telemetryStream) Recv() (*proto.IngestTelemetryRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *telemetryStream) SendAndClose(resp *proto.IngestTelemetryResponse) error {
+	s.response = resp
+	return nil
+}
+
+func reading(deviceID, metric string, value float64) *proto.TelemetryPoint {
+	return &proto.TelemetryPoint{DeviceId: deviceID, Metric: metric, Value: value}
+}
+
+func TestIngestTelemetryAuthorizesEachDeviceOnce(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTelemetryRepo := new(MockTelemetryRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, mockTelemetryRepo, nil, nil, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "thermometer").Return(&models.Device{ID: "thermometer", UserID: "user123"}, nil).Once()
+	mockRepo.On("LoadDevice", mock.Anything, "plug").Return(&models.Device{ID: "plug", UserID: "user123"}, nil).Once()
+	mockRepo.On("LoadDevice", mock.Anything, "their-plug").Return(&models.Device{ID: "their-plug", UserID: "someone-else"}, nil)
+	taken := time.Date(2024, 7, 3, 5, 0, 0, 0, time.UTC)
+	mockTelemetryRepo.On("InsertTelemetry", mock.Anything, mock.MatchedBy(func(points []*models.TelemetryPoint) bool {
+		// Readings without a timestamp were taken when they were received
+		return len(points) == 3 && points[0].Timestamp.Equal(taken) && !points[1].Timestamp.IsZero() && points[2].Metric == "power"
+	})).Return(nil)
+
+	first := reading("thermometer", "temperature", 21.5)
+	first.Timestamp = timestamppb.New(taken)
+	resp, err := service.IngestTelemetry(asOwner(), &proto.IngestTelemetryRequest{Points: []*proto.TelemetryPoint{
+		first, reading("thermometer", "humidity", 40), reading("plug", "power", 12.5),
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), resp.Accepted)
+	mockTelemetryRepo.AssertExpectations(t)
+
+	_, err = service.IngestTelemetry(asOwner(), &proto.IngestTelemetryRequest{Points: []*proto.TelemetryPoint{reading("their-plug", "power", 1)}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestIngestTelemetryRejectsInvalidReadings(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTelemetryRepo := new(MockTelemetryRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, mockTelemetryRepo, nil, nil, nil, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "thermometer").Return(&models.Device{ID: "thermometer", UserID: "user123"}, nil)
+
+	future := reading("thermometer", "temperature", 21)
+	future.Timestamp = timestamppb.New(time.Now().Add(time.Hour))
+	tooMany := make([]*proto.TelemetryPoint, 1001)
+	for i := range tooMany {
+		tooMany[i] = reading("thermometer", "temperature", 21)
+	}
+	tests := map[string][]*proto.TelemetryPoint{
+		"empty":        nil,
+		"too many":     tooMany,
+		"bad metric":   {reading("thermometer", "Temperature °C", 21)},
+		"not a number": {reading("thermometer", "temperature", math.NaN())},
+		"future":       {future},
+	}
+
+	for name, points := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.IngestTelemetry(asOwner(), &proto.IngestTelemetryRequest{Points: points})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+	mockTelemetryRepo.AssertNotCalled(t, "InsertTelemetry", mock.Anything, mock.Anything)
+}
+
+func TestStreamTelemetryStoresEveryMessage(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTelemetryRepo := new(MockTelemetryRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, mockTelemetryRepo, nil, nil, nil, nil)
+
+	mockRepo.On("LoadDevice", mock.Anything, "thermometer").Return(&models.Device{ID: "thermometer", UserID: "user123"}, nil).Once()
+	mockTelemetryRepo.On("InsertTelemetry", mock.Anything, mock.Anything).Return(nil).Twice()
+
+	stream := &telemetryStream{ctx: asOwner(), requests: []*proto.IngestTelemetryRequest{
+		{Points: []*proto.TelemetryPoint{reading("thermometer", "temperature", 21), reading("thermometer", "temperature", 21.1)}},
+		{Points: []*proto.TelemetryPoint{reading("thermometer", "temperature", 21.2)}},
+	}}
+	err := service.StreamTelemetry(stream)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), stream.response.Accepted)
+	mockRepo.AssertExpectations(t)
+	mockTelemetryRepo.AssertExpectations(t)
+}
+
+func TestQueryTelemetry(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockTelemetryRepo := new(MockTelemetryRepository)
+	service := NewDeviceService(mockRepo, new(MockDeviceTypeRepository), nil, mockTelemetryRepo, nil, nil, nil, nil)
+	mockRepo.On("LoadDevice", mock.Anything, "thermometer").Return(&models.Device{ID: "thermometer", UserID: "user123"}, nil)
+
+	from := time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	series := repository.TelemetryRange{DeviceID: "thermometer", Metric: "temperature", From: from, To: to}
+	mockTelemetryRepo.On("AggregateTelemetry", mock.Anything, series, 15*time.Minute).Return([]*models.TelemetryBucket{
+		{Start: from, Min: 20, Max: 22, Avg: 21, Count: 15},
+	}, nil)
+	mockTelemetryRepo.On("ListTelemetry", mock.Anything, series, 3).Return([]*models.TelemetryPoint{
+		{DeviceID: "thermometer", Metric: "temperature", Value: 20, Timestamp: from},
+		{DeviceID: "thermometer", Metric: "temperature", Value: 21, Timestamp: from.Add(time.Minute)},
+		{DeviceID: "thermometer", Metric: "temperature", Value: 22, Timestamp: from.Add(2 * time.Minute)},
+	}, nil)
+
+	req := &proto.QueryTelemetryRequest{DeviceId: "thermometer", Metric: "temperature", From: timestamppb.New(from), To: timestamppb.New(to), IntervalSeconds: 900}
+	resp, err := service.QueryTelemetry(asOwner(), req)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Buckets, 1)
+	assert.Equal(t, 21.0, resp.Buckets[0].Avg)
+	assert.Equal(t, int64(15), resp.Buckets[0].Count)
+
+	// One reading beyond the limit is read to tell that there are more
+	req.IntervalSeconds, req.Limit = 0, 2
+	resp, err = service.QueryTelemetry(asOwner(), req)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Points, 2)
+	assert.True(t, resp.Truncated)
+
+	for _, invalid := range []*proto.QueryTelemetryRequest{
+		{DeviceId: "thermometer"},
+		{DeviceId: "thermometer", Metric: "temperature", IntervalSeconds: -60},
+		{DeviceId: "thermometer", Metric: "temperature", From: timestamppb.New(to), To: timestamppb.New(from)},
+		{DeviceId: "thermometer", Metric: "temperature", From: timestamppb.New(from.AddDate(-1, 0, 0)), To: timestamppb.New(to), IntervalSeconds: 1},
+	} {
+		_, err = service.QueryTelemetry(asOwner(), invalid)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
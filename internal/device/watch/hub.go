@@ -0,0 +1,307 @@
+// Package watch streams device changes from the device event log to clients.
+package watch
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+)
+
+const (
+	defaultBatchSize = 500
+	// subscriberBuffer is how many events a watcher may fall behind before it
+	// is dropped and has to resume
+	subscriberBuffer = 256
+)
+
+var (
+	// ErrInvalidResumeToken is returned for a resume token that was not issued by Watch
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+	// ErrSubscriberLagged is returned when a watcher could not keep up with the
+	// event rate. It can resume from the last token it received.
+	ErrSubscriberLagged = errors.New("watch fell behind, resume from the last token")
+	// ErrHubClosed is returned to watchers when the service shuts down
+	ErrHubClosed = errors.New("watch is shutting down, resume from the last token")
+)
+
+// Filter selects the events a watcher receives. Empty fields match everything.
+type Filter struct {
+	Owners    repository.Owners
+	DeviceIDs []string
+	Type      string
+}
+
+func (f Filter) matches(event *models.DeviceEvent) bool {
+	device := event.Device
+	if !f.Owners.Owns(&device) {
+		return false
+	}
+	if len(f.DeviceIDs) > 0 && !slices.Contains(f.DeviceIDs, device.ID) {
+		return false
+	}
+	return f.Type == "" || device.Type == f.Type
+}
+
+// ResumeToken encodes the position of an event for a client to resume after it
+func ResumeToken(position models.EventPosition) string {
+	raw := strconv.FormatUint(position.TxID, 10) + "." + strconv.FormatInt(position.Seq, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseResumeToken decodes a token returned by ResumeToken
+func ParseResumeToken(token string) (models.EventPosition, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.EventPosition{}, ErrInvalidResumeToken
+	}
+	txID, seq, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return models.EventPosition{}, ErrInvalidResumeToken
+	}
+	var position models.EventPosition
+	if position.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+		return models.EventPosition{}, ErrInvalidResumeToken
+	}
+	if position.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil {
+		return models.EventPosition{}, ErrInvalidResumeToken
+	}
+	return position, nil
+}
+
+// Hub follows the device event log and fans new events out to watchers. Each
+// instance of the service runs its own hub; since the log lives in the
+// database, watchers see the changes made through every instance.
+type Hub struct {
+	repo      repository.DeviceEventRepository
+	interval  time.Duration
+	batchSize int
+
+	mu          sync.Mutex
+	started     bool
+	closed      bool
+	position    models.EventPosition
+	subscribers map[*subscription]struct{}
+}
+
+func NewHub(repo repository.DeviceEventRepository, interval time.Duration) *Hub {
+	return &Hub{
+		repo:        repo,
+		interval:    interval,
+		batchSize:   defaultBatchSize,
+		subscribers: make(map[*subscription]struct{}),
+	}
+}
+
+// Run polls the event log every interval until ctx is cancelled
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.Tick(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Device watch poll failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick delivers the events written since the previous tick
+func (h *Hub) Tick(ctx context.Context) error {
+	h.mu.Lock()
+	err := h.start(ctx)
+	position := h.position
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for {
+		events, err := h.repo.ListDeviceEvents(ctx, position, repository.Owners{}, h.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			position = events[len(events)-1].Position
+			h.publish(events, position)
+		}
+		if len(events) < h.batchSize {
+			return nil
+		}
+	}
+}
+
+// start positions a hub that has not polled yet at the head of the log.
+// Callers must hold h.mu.
+func (h *Hub) start(ctx context.Context) error {
+	if h.started {
+		return nil
+	}
+	head, err := h.repo.DeviceEventHead(ctx)
+	if err != nil {
+		return err
+	}
+	h.position = head
+	h.started = true
+	return nil
+}
+
+func (h *Hub) publish(events []*models.DeviceEvent, position models.EventPosition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, event := range events {
+		for sub := range h.subscribers {
+			if !sub.filter.matches(event) {
+				continue
+			}
+			select {
+			case sub.events <- event:
+			default:
+				// Dropping a slow watcher keeps it from holding up the others
+				h.drop(sub, ErrSubscriberLagged)
+			}
+		}
+	}
+	h.position = position
+}
+
+type subscription struct {
+	filter Filter
+	events chan *models.DeviceEvent
+	// from is the hub's position when subscribing: the subscription receives
+	// every matching event after it
+	from models.EventPosition
+	// err tells why events was closed
+	err error
+}
+
+func (h *Hub) subscribe(ctx context.Context, filter Filter) (*subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	if err := h.start(ctx); err != nil {
+		return nil, err
+	}
+	sub := &subscription{
+		filter: filter,
+		events: make(chan *models.DeviceEvent, subscriberBuffer),
+		from:   h.position,
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub, nil
+}
+
+func (h *Hub) unsubscribe(sub *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		h.drop(sub, nil)
+	}
+}
+
+// drop ends a subscription. Callers must hold h.mu.
+func (h *Hub) drop(sub *subscription, err error) {
+	delete(h.subscribers, sub)
+	sub.err = err
+	close(sub.events)
+}
+
+// Close ends every watch, so that a graceful stop of the server does not wait
+// for clients to hang up. Watchers are expected to resume on another instance.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		h.drop(sub, ErrHubClosed)
+	}
+}
+
+// Watch sends the events matching filter to send until ctx is cancelled or
+// send fails. With a resume token, the events after it are replayed first;
+// without one, only changes from now on are sent. Every event is sent at most
+// once and in log order.
+func (h *Hub) Watch(ctx context.Context, filter Filter, resumeToken string, send func(*models.DeviceEvent) error) error {
+	var resume *models.EventPosition
+	if resumeToken != "" {
+		position, err := ParseResumeToken(resumeToken)
+		if err != nil {
+			return err
+		}
+		resume = &position
+	}
+
+	sub, err := h.subscribe(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer h.unsubscribe(sub)
+
+	last := sub.from
+	if resume != nil {
+		if resume.After(sub.from) {
+			// The token was issued by an instance whose hub is ahead of ours
+			last = *resume
+		} else if err := h.replay(ctx, filter, *resume, sub.from, send); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-sub.events:
+			if !ok {
+				return sub.err
+			}
+			if !event.Position.After(last) {
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+			last = event.Position
+		}
+	}
+}
+
+// replay sends the matching events in (from, until] from the log
+func (h *Hub) replay(ctx context.Context, filter Filter, from, until models.EventPosition, send func(*models.DeviceEvent) error) error {
+	position := from
+	for {
+		events, err := h.repo.ListDeviceEvents(ctx, position, filter.Owners, h.batchSize)
+		if err != nil {
+			return fmt.Errorf("replaying device events: %w", err)
+		}
+		for _, event := range events {
+			if event.Position.After(until) {
+				return nil
+			}
+			if filter.matches(event) {
+				if err := send(event); err != nil {
+					return err
+				}
+			}
+			position = event.Position
+		}
+		if len(events) < h.batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEventRepository is an in-memory device event log
+type fakeEventRepository struct {
+	mu     sync.Mutex
+	events []*models.DeviceEvent
+}
+
+func (r *fakeEventRepository) append(eventType models.DeviceEventType, device models.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seq := int64(len(r.events) + 1)
+	r.events = append(r.events, &models.DeviceEvent{
+		Position: models.EventPosition{TxID: uint64(seq), Seq: seq},
+		Type:     eventType,
+		Device:   device,
+	})
+}
+
+func (r *fakeEventRepository) DeviceEventHead(ctx context.Context) (models.EventPosition, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) == 0 {
+		return models.EventPosition{}, nil
+	}
+	return r.events[len(r.events)-1].Position, nil
+}
+
+func (r *fakeEventRepository) ListDeviceEvents(ctx context.Context, after models.EventPosition, owners repository.Owners, limit int) ([]*models.DeviceEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*models.DeviceEvent
+	for _, event := range r.events {
+		if event.Position.After(after) && (owners.IsZero() || owners.Owns(&event.Device)) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// watchInBackground runs a watch and collects what it sends
+func watchInBackground(hub *Hub, filter Filter, resumeToken string) (context.CancelFunc, *sync.WaitGroup, *[]*models.DeviceEvent, *error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var received []*models.DeviceEvent
+	var watchErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watchErr = hub.Watch(ctx, filter, resumeToken, func(event *models.DeviceEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, event)
+			return nil
+		})
+	}()
+	return cancel, &wg, &received, &watchErr
+}
+
+func waitForSubscribers(t *testing.T, hub *Hub, n int) {
+	require.Eventually(t, func() bool {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		return len(hub.subscribers) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestWatchDeliversMatchingEvents(t *testing.T) {
+	repo := &fakeEventRepository{}
+	repo.append(models.DeviceEventCreated, models.Device{ID: "old", UserID: "alice"})
+	hub := NewHub(repo, time.Minute)
+
+	cancel, wg, received, _ := watchInBackground(hub, Filter{Owners: repository.Owners{UserID: "alice"}, Type: "switch"}, "")
+	waitForSubscribers(t, hub, 1)
+
+	repo.append(models.DeviceEventCreated, models.Device{ID: "lamp", UserID: "alice", Type: "switch"})
+	repo.append(models.DeviceEventCreated, models.Device{ID: "sensor", UserID: "alice", Type: "thermometer"})
+	repo.append(models.DeviceEventCreated, models.Device{ID: "tv", UserID: "bob", Type: "switch"})
+	repo.append(models.DeviceEventDeleted, models.Device{ID: "lamp", UserID: "alice", Type: "switch"})
+	require.NoError(t, hub.Tick(context.Background()))
+
+	hub.Close()
+	wg.Wait()
+	cancel()
+
+	var got []string
+	for _, event := range *received {
+		got = append(got, string(event.Type)+" "+event.Device.ID)
+	}
+	assert.Equal(t, []string{"created lamp", "deleted lamp"}, got)
+}
+
+func TestWatchResumesWithoutGapsOrDuplicates(t *testing.T) {
+	repo := &fakeEventRepository{}
+	hub := NewHub(repo, time.Minute)
+	require.NoError(t, hub.Tick(context.Background()))
+
+	lamp := models.Device{ID: "lamp", UserID: "alice"}
+	repo.append(models.DeviceEventCreated, lamp)
+	require.NoError(t, hub.Tick(context.Background()))
+	token := ResumeToken(repo.events[0].Position)
+
+	// Changes while the client is away, of which the hub has seen the first
+	repo.append(models.DeviceEventUpdated, lamp)
+	require.NoError(t, hub.Tick(context.Background()))
+	repo.append(models.DeviceEventDeleted, lamp)
+
+	cancel, wg, received, watchErr := watchInBackground(hub, Filter{Owners: repository.Owners{UserID: "alice"}}, token)
+	waitForSubscribers(t, hub, 1)
+	require.NoError(t, hub.Tick(context.Background()))
+
+	hub.Close()
+	wg.Wait()
+	cancel()
+
+	require.Len(t, *received, 2)
+	assert.Equal(t, models.DeviceEventUpdated, (*received)[0].Type)
+	assert.Equal(t, models.DeviceEventDeleted, (*received)[1].Type)
+	assert.True(t, errors.Is(*watchErr, ErrHubClosed))
+}
+
+func TestResumeTokenRoundTrip(t *testing.T) {
+	position := models.EventPosition{TxID: 7421, Seq: 98}
+
+	parsed, err := ParseResumeToken(ResumeToken(position))
+	require.NoError(t, err)
+	assert.Equal(t, position, parsed)
+
+	_, err = ParseResumeToken("not-a-token")
+	assert.ErrorIs(t, err, ErrInvalidResumeToken)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strings"
 
@@ -9,8 +10,6 @@ import (
 	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 
@@ -39,6 +38,32 @@ func (h *DeviceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		default:
 			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		}
+	case strings.HasSuffix(path, "/history"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/history")
+		switch r.Method {
+		case http.MethodGet:
+			h.GetDeviceStateHistory(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasSuffix(path, "/telemetry"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/telemetry")
+		switch r.Method {
+		case http.MethodGet:
+			h.QueryTelemetry(w, r, id)
+		case http.MethodPost:
+			h.IngestTelemetry(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasSuffix(path, "/heartbeat"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/heartbeat")
+		switch r.Method {
+		case http.MethodPost:
+			h.ReportHeartbeat(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
 	case strings.HasPrefix(path, "/"):
 		id := strings.TrimPrefix(path, "/")
 		switch r.Method {
@@ -46,6 +71,10 @@ func (h *DeviceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			h.GetDevice(w,r,id)
 		case http.MethodPut:
 			h.UpdateDeviceState(w,r,id)
+		case http.MethodPatch:
+			h.UpdateDevice(w, r, id)
+		case http.MethodDelete:
+			h.DeleteDevice(w, r, id)
 		default: 
 			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		}
@@ -55,15 +84,23 @@ func (h *DeviceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 
+// GetDevices lists the devices of the authenticated user. The filters can be
+// given as query parameters, see deviceListParams, or in the optional body;
+// a user_id must be the authenticated user's.
 func (h *DeviceHandler) GetDevices(w http.ResponseWriter, r *http.Request) {
 	var req proto.ListDevicesRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil{
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	if err := deviceListParams(r.URL.Query(), &req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	consistencyParams(r.URL.Query(), &req.Consistency, &req.ConsistencyToken)
 	resp, err := h.client.ListDevices(r.Context(), &req)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get devices")
+		respondWithStatusError(w, err, "Failed to get devices")
 		return
 	}
 	utils.RespondWithJSON(w, http.StatusOK, resp)
@@ -79,24 +116,24 @@ func (h *DeviceHandler) CreateDevice(w http.ResponseWriter, r *http.Request) {
 
 	device, err := h.client.CreateDevice(r.Context(), &req)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create device")
+		respondWithStatusError(w, err, "Failed to create device")
 		return
 	}
 
+	setDeviceETag(w, device.Version)
 	utils.RespondWithJSON(w, http.StatusOK, device)
 }
 
 func (h *DeviceHandler) GetDevice(w http.ResponseWriter, r *http.Request, id string) {
-	device, err := h.client.GetDevice(r.Context(), &proto.GetDeviceRequest{Id: id})
+	req := proto.GetDeviceRequest{Id: id}
+	consistencyParams(r.URL.Query(), &req.Consistency, &req.ConsistencyToken)
+	device, err := h.client.GetDevice(r.Context(), &req)
 	if err != nil {
-		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
-			utils.RespondWithError(w, http.StatusNotFound, "User not found")
-			return
-		}
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get device")
+		respondWithStatusError(w, err, "Failed to get device")
 		return
 	}
 
+	setDeviceETag(w, device.Version)
 	utils.RespondWithJSON(w, http.StatusOK, device)
 }
 
@@ -107,23 +144,134 @@ func (h *DeviceHandler) UpdateDeviceState(w http.ResponseWriter, r *http.Request
 		return
 	}
 	req.Id = id
+	if !expectVersion(w, r, &req.ExpectedVersion) {
+		return
+	}
 
 	device, err := h.client.UpdateDeviceState(r.Context(), &req)
 	if err != nil {
-		if st, ok := status.FromError(err); ok {
-			switch st.Code() {
-			case codes.NotFound:
-				utils.RespondWithError(w, http.StatusNotFound, "Device not found")
-			case codes.InvalidArgument:
-				utils.RespondWithError(w, http.StatusBadRequest, st.Message())
-			default:
-				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update device")
-			}
-		} else {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update device")
-		}
+		respondWithStatusError(w, err, "Failed to update device")
+		return
+	}
+
+	setDeviceETag(w, device.Version)
+	utils.RespondWithJSON(w, http.StatusOK, device)
+}
+
+// BatchUpdateDeviceStates serves POST /api/v1/devices:batchUpdate. The
+// outcome of every update is in the response, which is OK unless the batch
+// itself is rejected.
+func (h *DeviceHandler) BatchUpdateDeviceStates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var req proto.BatchUpdateDeviceStatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	resp, err := h.client.BatchUpdateDeviceStates(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update devices")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// expectVersion takes the expected version of an update from the If-Match
+// header, which overrides expected_version in the body. It responds with an
+// error and returns false if the header is invalid.
+func expectVersion(w http.ResponseWriter, r *http.Request, expected *int64) bool {
+	version, err := ifMatchVersion(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if version != 0 {
+		*expected = version
+	}
+	return true
+}
+
+// UpdateDevice serves PATCH /api/v1/devices/{id}. The fields to change are
+// taken from the update_mask query parameter or the body's update_mask; without
+// either, every non-empty field in the body is changed.
+func (h *DeviceHandler) UpdateDevice(w http.ResponseWriter, r *http.Request, id string) {
+	var req proto.UpdateDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Id = id
+	if mask := fieldMaskParam(r.URL.Query(), "update_mask"); mask != nil {
+		req.UpdateMask = mask
+	}
+	if !expectVersion(w, r, &req.ExpectedVersion) {
+		return
+	}
+
+	device, err := h.client.UpdateDevice(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update device")
+		return
+	}
+	setDeviceETag(w, device.Version)
+	utils.RespondWithJSON(w, http.StatusOK, device)
+}
+
+func (h *DeviceHandler) DeleteDevice(w http.ResponseWriter, r *http.Request, id string) {
+	resp, err := h.client.DeleteDevice(r.Context(), &proto.DeleteDeviceRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to delete device")
 		return
 	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{
+		"message":           "Device deleted successfully",
+		"consistency_token": resp.ConsistencyToken,
+	})
+}
 
+// ReportHeartbeat serves POST /api/v1/devices/{id}/heartbeat, sent by a
+// device to stay online
+func (h *DeviceHandler) ReportHeartbeat(w http.ResponseWriter, r *http.Request, id string) {
+	device, err := h.client.ReportHeartbeat(r.Context(), &proto.ReportHeartbeatRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to report heartbeat")
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusOK, device)
-}
\ No newline at end of file
+}
+
+// GetDeviceStateHistory serves GET /api/v1/devices/{id}/history?from=&to=&page=&page_size=
+// with from and to given as RFC 3339 timestamps.
+func (h *DeviceHandler) GetDeviceStateHistory(w http.ResponseWriter, r *http.Request, id string) {
+	req := proto.GetDeviceStateHistoryRequest{DeviceId: id}
+	params := r.URL.Query()
+
+	var err error
+	if req.From, err = timestampParam(params, "from"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.To, err = timestampParam(params, "to"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Page, err = int32Param(params, "page"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.PageSize, err = int32Param(params, "page_size"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := h.client.GetDeviceStateHistory(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to get device history")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
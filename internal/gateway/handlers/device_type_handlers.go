@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type DeviceTypeHandler struct {
+	client proto.DeviceTypeServiceClient
+}
+
+func NewDeviceTypeHandler(conn *grpc.ClientConn) *DeviceTypeHandler {
+	return &DeviceTypeHandler{
+		client: proto.NewDeviceTypeServiceClient(conn),
+	}
+}
+
+func (h *DeviceTypeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/device-types")
+
+	switch {
+	case path == "" || path == "/":
+		switch r.Method {
+		case http.MethodGet:
+			h.ListDeviceTypes(w, r)
+		case http.MethodPost:
+			h.CreateDeviceType(w, r)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasPrefix(path, "/"):
+		id := strings.TrimPrefix(path, "/")
+		switch r.Method {
+		case http.MethodGet:
+			h.GetDeviceType(w, r, id)
+		case http.MethodPut:
+			h.UpdateDeviceType(w, r, id)
+		case http.MethodDelete:
+			h.DeleteDeviceType(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (h *DeviceTypeHandler) ListDeviceTypes(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.ListDeviceTypes(r.Context(), &proto.ListDeviceTypesRequest{})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list device types")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *DeviceTypeHandler) CreateDeviceType(w http.ResponseWriter, r *http.Request) {
+	var req proto.CreateDeviceTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	deviceType, err := h.client.CreateDeviceType(r.Context(), &req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			switch st.Code() {
+			case codes.InvalidArgument:
+				utils.RespondWithError(w, http.StatusBadRequest, st.Message())
+			case codes.AlreadyExists:
+				utils.RespondWithError(w, http.StatusConflict, "Device type already exists")
+			default:
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create device type")
+			}
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create device type")
+		}
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, deviceType)
+}
+
+func (h *DeviceTypeHandler) GetDeviceType(w http.ResponseWriter, r *http.Request, id string) {
+	deviceType, err := h.client.GetDeviceType(r.Context(), &proto.GetDeviceTypeRequest{Id: id})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Device type not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get device type")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, deviceType)
+}
+
+func (h *DeviceTypeHandler) UpdateDeviceType(w http.ResponseWriter, r *http.Request, id string) {
+	var req proto.UpdateDeviceTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Id = id
+
+	deviceType, err := h.client.UpdateDeviceType(r.Context(), &req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			switch st.Code() {
+			case codes.NotFound:
+				utils.RespondWithError(w, http.StatusNotFound, "Device type not found")
+			case codes.InvalidArgument:
+				utils.RespondWithError(w, http.StatusBadRequest, st.Message())
+			default:
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update device type")
+			}
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update device type")
+		}
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, deviceType)
+}
+
+func (h *DeviceTypeHandler) DeleteDeviceType(w http.ResponseWriter, r *http.Request, id string) {
+	_, err := h.client.DeleteDeviceType(r.Context(), &proto.DeleteDeviceTypeRequest{Id: id})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			switch st.Code() {
+			case codes.NotFound:
+				utils.RespondWithError(w, http.StatusNotFound, "Device type not found")
+			case codes.FailedPrecondition:
+				utils.RespondWithError(w, http.StatusConflict, "Device type is still used by devices")
+			default:
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete device type")
+			}
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete device type")
+		}
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Device type deleted successfully"})
+}
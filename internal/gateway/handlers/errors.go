@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// respondWithStatusError translates a gRPC status error into an HTTP error
+// response, falling back to a 500 with the given message
+func respondWithStatusError(w http.ResponseWriter, err error, message string) {
+	st, ok := status.FromError(err)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, message)
+		return
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		utils.RespondWithError(w, http.StatusNotFound, st.Message())
+	case codes.InvalidArgument:
+		utils.RespondWithError(w, http.StatusBadRequest, st.Message())
+	case codes.Unauthenticated:
+		utils.RespondWithError(w, http.StatusUnauthorized, st.Message())
+	case codes.PermissionDenied:
+		utils.RespondWithError(w, http.StatusForbidden, st.Message())
+	case codes.FailedPrecondition, codes.Aborted, codes.AlreadyExists:
+		utils.RespondWithError(w, http.StatusConflict, st.Message())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/middleware"
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/gorilla/websocket"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultHeartbeatInterval = 15 * time.Second
+	// sseRetry is the reconnection delay suggested to EventSource clients
+	sseRetry = 3 * time.Second
+	// wsWriteTimeout bounds how long a write to a WebSocket may block
+	wsWriteTimeout = 10 * time.Second
+)
+
+// EventFrame is a single message on /api/v1/events. Type is "created",
+// "updated" or "deleted" for device changes, "heartbeat" for keep-alives and
+// "error" before the server ends the stream.
+type EventFrame struct {
+	Type        string        `json:"type"`
+	Device      *proto.Device `json:"device,omitempty"`
+	ResumeToken string        `json:"resume_token,omitempty"`
+	OccurredAt  *time.Time    `json:"occurred_at,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Time        *time.Time    `json:"time,omitempty"`
+}
+
+// EventHandler serves live device changes for the authenticated user, as
+// Server-Sent Events or over a WebSocket
+type EventHandler struct {
+	client    proto.DeviceServiceClient
+	heartbeat time.Duration
+	upgrader  websocket.Upgrader
+}
+
+// NewEventHandler creates the handler of /api/v1/events. WebSockets may be
+// opened by pages of the gateway's own origin and of allowedOrigins, as
+// browsers do not apply the same-origin policy to them.
+func NewEventHandler(conn *grpc.ClientConn, allowedOrigins []string) *EventHandler {
+	return newEventHandler(proto.NewDeviceServiceClient(conn), allowedOrigins)
+}
+
+func newEventHandler(client proto.DeviceServiceClient, allowedOrigins []string) *EventHandler {
+	return &EventHandler{
+		client:    client,
+		heartbeat: defaultHeartbeatInterval,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return checkOrigin(r, allowedOrigins)
+			},
+		},
+	}
+}
+
+// checkOrigin accepts WebSocket requests without an Origin header, which do
+// not come from browsers, and those from the gateway's own origin or one of
+// allowedOrigins. Pages of other origins could otherwise open streams with a
+// token they got hold of, e.g. from the access_token of a logged URL.
+func checkOrigin(r *http.Request, allowedOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves GET /api/v1/events?device_id=&type=&resume_token=. Requests
+// asking for a WebSocket upgrade get one, all others an event stream. A client
+// that reconnects passes the resume_token of the last frame it received, or,
+// for EventSource, gets it sent as Last-Event-ID automatically.
+func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	params := r.URL.Query()
+	req := &proto.WatchDevicesRequest{
+		UserId:      middleware.UserIDFromContext(r.Context()),
+		DeviceIds:   params["device_id"],
+		Type:        params.Get("type"),
+		ResumeToken: params.Get("resume_token"),
+	}
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		req.ResumeToken = lastEventID
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r, req)
+		return
+	}
+	h.serveSSE(w, r, req)
+}
+
+func (h *EventHandler) serveSSE(w http.ResponseWriter, r *http.Request, req *proto.WatchDevicesRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stream, err := watchDevices(ctx, h.client, req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to watch devices")
+		return
+	}
+	frames, errs := receiveFrames(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	flusher.Flush()
+
+	write := func(frame EventFrame) error {
+		data, err := json.Marshal(frame)
+		if err != nil {
+			return err
+		}
+		if frame.ResumeToken != "" {
+			fmt.Fprintf(w, "id: %s\n", frame.ResumeToken)
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", frame.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	h.pump(ctx, frames, errs, write)
+}
+
+func (h *EventHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, req *proto.WatchDevicesRequest) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered the request
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Clients only send control frames; reading them answers pings and
+	// notices when the client goes away
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	stream, err := watchDevices(ctx, h.client, req)
+	if err != nil {
+		conn.WriteJSON(EventFrame{Type: "error", Error: status.Convert(err).Message()})
+		return
+	}
+	frames, errs := receiveFrames(stream)
+
+	write := func(frame EventFrame) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if frame.Type == "heartbeat" {
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return err
+			}
+		}
+		return conn.WriteJSON(frame)
+	}
+	closeCode := websocket.CloseNormalClosure
+	if err := h.pump(ctx, frames, errs, write); err != nil {
+		closeCode = websocket.CloseTryAgainLater
+	}
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, ""))
+}
+
+// pump writes frames and heartbeats until the stream ends or the client goes
+// away. A stream that fails is reported with an error frame, and its error
+// returned.
+func (h *EventHandler) pump(ctx context.Context, frames <-chan EventFrame, errs <-chan error, write func(EventFrame) error) error {
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case frame := <-frames:
+			if err := write(frame); err != nil {
+				return nil
+			}
+		case now := <-ticker.C:
+			if err := write(EventFrame{Type: "heartbeat", Time: &now}); err != nil {
+				return nil
+			}
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			write(EventFrame{Type: "error", Error: status.Convert(err).Message()})
+			return err
+		}
+	}
+}
+
+// watchDevices opens a watch and waits until the device service accepted it,
+// so that a refused watch can still be answered with an HTTP error
+func watchDevices(ctx context.Context, client proto.DeviceServiceClient, req *proto.WatchDevicesRequest) (proto.DeviceService_WatchDevicesClient, error) {
+	stream, err := client.WatchDevices(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		// The stream ended without headers; its status says why
+		if _, err := stream.Recv(); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, status.Error(codes.Unavailable, "event stream ended")
+	}
+	return stream, nil
+}
+
+// receiveFrames reads a watch stream in the background. The error channel
+// receives why the stream ended.
+func receiveFrames(stream proto.DeviceService_WatchDevicesClient) (<-chan EventFrame, <-chan error) {
+	frames := make(chan EventFrame)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = status.Error(codes.Unavailable, "event stream ended")
+				}
+				errs <- err
+				return
+			}
+			occurredAt := event.OccurredAt.AsTime()
+			frame := EventFrame{
+				Type:        event.Type,
+				Device:      event.Device,
+				ResumeToken: event.ResumeToken,
+				OccurredAt:  &occurredAt,
+			}
+			select {
+			case frames <- frame:
+			case <-stream.Context().Done():
+				errs <- stream.Context().Err()
+				return
+			}
+		}
+	}()
+	return frames, errs
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeDeviceClient serves WatchDevices from a channel of events and records
+// the requests it was called with
+type fakeDeviceClient struct {
+	proto.DeviceServiceClient
+	events   chan *proto.DeviceEvent
+	requests chan *proto.WatchDevicesRequest
+}
+
+func newFakeDeviceClient() *fakeDeviceClient {
+	return &fakeDeviceClient{
+		events:   make(chan *proto.DeviceEvent, 10),
+		requests: make(chan *proto.WatchDevicesRequest, 10),
+	}
+}
+
+func (c *fakeDeviceClient) WatchDevices(ctx context.Context, req *proto.WatchDevicesRequest, opts ...grpc.CallOption) (proto.DeviceService_WatchDevicesClient, error) {
+	c.requests <- req
+	return &fakeWatchStream{ctx: ctx, events: c.events}, nil
+}
+
+type fakeWatchStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	events chan *proto.DeviceEvent
+}
+
+func (s *fakeWatchStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *fakeWatchStream) Context() context.Context     { return s.ctx }
+
+func (s *fakeWatchStream) Recv() (*proto.DeviceEvent, error) {
+	select {
+	case event := <-s.events:
+		return event, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+// readEvents reads the event stream of a response, sending the type and id
+// of each event
+func readEvents(resp *http.Response) <-chan [2]string {
+	events := make(chan [2]string)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var id, event string
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case line == "" && event != "":
+				events <- [2]string{event, id}
+				id, event = "", ""
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan [2]string) [2]string {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return [2]string{}
+	}
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	client := newFakeDeviceClient()
+	handler := newEventHandler(client, nil)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client.events <- &proto.DeviceEvent{
+		Type:        "updated",
+		Device:      &proto.Device{Id: "lamp"},
+		ResumeToken: "token-6",
+		OccurredAt:  timestamppb.Now(),
+	}
+
+	// EventSource sends the id of the last event it received when it
+	// reconnects, which takes precedence over the URL it was opened with
+	req, err := http.NewRequest(http.MethodGet, server.URL+"?resume_token=token-1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "token-5")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "token-5", (<-client.requests).ResumeToken)
+	assert.Equal(t, [2]string{"updated", "token-6"}, nextEvent(t, readEvents(resp)))
+}
+
+func TestEventStreamSendsHeartbeats(t *testing.T) {
+	client := newFakeDeviceClient()
+	handler := newEventHandler(client, nil)
+	handler.heartbeat = 10 * time.Millisecond
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// Heartbeats carry no id, so they do not move the resume position
+	events := readEvents(resp)
+	assert.Equal(t, [2]string{"heartbeat", ""}, nextEvent(t, events))
+	assert.Equal(t, [2]string{"heartbeat", ""}, nextEvent(t, events))
+}
+
+func TestWebSocketsOnlyOpenFromAllowedOrigins(t *testing.T) {
+	client := newFakeDeviceClient()
+	server := httptest.NewServer(newEventHandler(client, []string{"https://app.example.com"}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	for origin, allowed := range map[string]bool{
+		"":                         true,
+		server.URL:                 true,
+		"https://app.example.com":  true,
+		"https://evil.example.com": false,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if allowed {
+			if assert.NoError(t, err, "origin %q", origin) {
+				conn.Close()
+			}
+		} else if assert.Error(t, err, "origin %q", origin) {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc"
+)
+
+type HouseholdHandler struct {
+	client proto.HouseholdServiceClient
+}
+
+func NewHouseholdHandler(conn *grpc.ClientConn) *HouseholdHandler {
+	return &HouseholdHandler{
+		client: proto.NewHouseholdServiceClient(conn),
+	}
+}
+
+func (h *HouseholdHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/households")
+
+	switch {
+	case path == "" || path == "/":
+		switch r.Method {
+		case http.MethodGet:
+			h.ListHouseholds(w, r)
+		case http.MethodPost:
+			h.CreateHousehold(w, r)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasSuffix(path, "/invitations"):
+		// /{householdId}/invitations
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/invitations")
+		if r.Method != http.MethodPost {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		h.InviteMember(w, r, id)
+	case strings.Contains(path, "/members/"):
+		// /{householdId}/members/{userId}
+		id, userID, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/members/")
+		switch r.Method {
+		case http.MethodPatch:
+			h.UpdateMemberRole(w, r, id, userID)
+		case http.MethodDelete:
+			h.RemoveMember(w, r, id, userID)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasPrefix(path, "/"):
+		id := strings.TrimPrefix(path, "/")
+		if r.Method != http.MethodGet {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		h.GetHousehold(w, r, id)
+	default:
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// ServeInvitations serves the invitations of the authenticated user under
+// /api/v1/invitations
+func (h *HouseholdHandler) ServeInvitations(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/invitations")
+
+	switch {
+	case path == "" || path == "/":
+		if r.Method != http.MethodGet {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		h.ListInvitations(w, r)
+	case strings.HasSuffix(path, "/accept"), strings.HasSuffix(path, "/decline"):
+		id, action, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+		if r.Method != http.MethodPost {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		if action == "accept" {
+			h.AcceptInvitation(w, r, id)
+		} else {
+			h.DeclineInvitation(w, r, id)
+		}
+	default:
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (h *HouseholdHandler) ListHouseholds(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.ListHouseholds(r.Context(), &proto.ListHouseholdsRequest{})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to list households")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *HouseholdHandler) CreateHousehold(w http.ResponseWriter, r *http.Request) {
+	var req proto.CreateHouseholdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	household, err := h.client.CreateHousehold(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to create household")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, household)
+}
+
+func (h *HouseholdHandler) GetHousehold(w http.ResponseWriter, r *http.Request, id string) {
+	household, err := h.client.GetHousehold(r.Context(), &proto.GetHouseholdRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to get household")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, household)
+}
+
+func (h *HouseholdHandler) InviteMember(w http.ResponseWriter, r *http.Request, id string) {
+	var req proto.InviteMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.HouseholdId = id
+
+	invitation, err := h.client.InviteMember(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to invite member")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, invitation)
+}
+
+func (h *HouseholdHandler) UpdateMemberRole(w http.ResponseWriter, r *http.Request, id, userID string) {
+	var req proto.UpdateMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.HouseholdId = id
+	req.UserId = userID
+
+	member, err := h.client.UpdateMemberRole(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update member")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, member)
+}
+
+func (h *HouseholdHandler) RemoveMember(w http.ResponseWriter, r *http.Request, id, userID string) {
+	_, err := h.client.RemoveMember(r.Context(), &proto.RemoveMemberRequest{HouseholdId: id, UserId: userID})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to remove member")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Member removed successfully"})
+}
+
+func (h *HouseholdHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.ListInvitations(r.Context(), &proto.ListInvitationsRequest{})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to list invitations")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *HouseholdHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request, id string) {
+	household, err := h.client.AcceptInvitation(r.Context(), &proto.RespondToInvitationRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to accept invitation")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, household)
+}
+
+func (h *HouseholdHandler) DeclineInvitation(w http.ResponseWriter, r *http.Request, id string) {
+	invitation, err := h.client.DeclineInvitation(r.Context(), &proto.RespondToInvitationRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to decline invitation")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, invitation)
+}
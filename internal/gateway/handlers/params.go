@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// timestampParam parses an optional RFC 3339 query parameter
+func timestampParam(params url.Values, name string) (*timestamppb.Timestamp, error) {
+	value := params.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected an RFC 3339 timestamp", name)
+	}
+	return timestamppb.New(t), nil
+}
+
+// int32Param parses an optional integer query parameter
+func int32Param(params url.Values, name string) (int32, error) {
+	value := params.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: expected an integer", name)
+	}
+	return int32(n), nil
+}
+
+// fieldMaskParam parses an optional comma-separated list of field paths, e.g.
+// ?update_mask=name,room_id
+func fieldMaskParam(params url.Values, name string) *fieldmaskpb.FieldMask {
+	value := params.Get(name)
+	if value == "" {
+		return nil
+	}
+	var paths []string
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return &fieldmaskpb.FieldMask{Paths: paths}
+}
+
+// consistencyParams copies the optional ?consistency=strong and
+// ?consistency_token= query parameters into a read request
+func consistencyParams(params url.Values, consistency, token *string) {
+	if value := params.Get("consistency"); value != "" {
+		*consistency = value
+	}
+	if value := params.Get("consistency_token"); value != "" {
+		*token = value
+	}
+}
+
+// ifMatchVersion parses an If-Match header holding a device version as entity
+// tag, as sent by setDeviceETag. Without the header, or with "*", no version
+// is expected and 0 is returned.
+func ifMatchVersion(header http.Header) (int64, error) {
+	value := strings.TrimSpace(header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match: expected a single device version")
+	}
+	return version, nil
+}
+
+// setDeviceETag sets the version of a device as the response's entity tag
+func setDeviceETag(w http.ResponseWriter, version int64) {
+	if version > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+	}
+}
+
+// deviceListParams copies the optional filter, order and page query parameters
+// of GET /api/v1/devices into a list request, e.g.
+// ?type=light&q=kitchen&state.power=true&updated_after=2024-01-01T00:00:00Z&order_by=name&page_token=...
+func deviceListParams(params url.Values, req *proto.ListDevicesRequest) error {
+	for name, field := range map[string]*string{
+		"user_id":        &req.UserId,
+		"household_id":   &req.HouseholdId,
+		"room_id":        &req.RoomId,
+		"type":           &req.Type,
+		"q":              &req.NameContains,
+		"label_selector": &req.LabelSelector,
+		"order_by":       &req.OrderBy,
+		"page_token":     &req.PageToken,
+	} {
+		if value := params.Get(name); value != "" {
+			*field = value
+		}
+	}
+	for name, field := range map[string]**timestamppb.Timestamp{
+		"created_after":  &req.CreatedAfter,
+		"created_before": &req.CreatedBefore,
+		"updated_after":  &req.UpdatedAfter,
+		"updated_before": &req.UpdatedBefore,
+	} {
+		t, err := timestampParam(params, name)
+		if err != nil {
+			return err
+		}
+		if t != nil {
+			*field = t
+		}
+	}
+	for name, field := range map[string]*int32{"page": &req.Page, "page_size": &req.PageSize} {
+		n, err := int32Param(params, name)
+		if err != nil {
+			return err
+		}
+		if n != 0 {
+			*field = n
+		}
+	}
+	if value := params.Get("include_total"); value != "" {
+		includeTotal, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid include_total: expected true or false")
+		}
+		req.IncludeTotal = includeTotal
+	}
+	for name := range params {
+		attribute, ok := strings.CutPrefix(name, "state.")
+		if !ok {
+			continue
+		}
+		if req.State == nil {
+			req.State = make(map[string]*proto.StateValue)
+		}
+		req.State[attribute] = stateParam(params.Get(name))
+	}
+	return nil
+}
+
+// stateParam parses the value of a state.<attribute> query parameter. Its type
+// is inferred: true and false are booleans, integers are int values, other
+// numbers float values and anything else an enum value. A float attribute
+// that holds a whole number is therefore matched as e.g. 21.0.
+func stateParam(value string) *proto.StateValue {
+	if value == "true" || value == "false" {
+		return &proto.StateValue{Type: "bool", BoolValue: value == "true"}
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return &proto.StateValue{Type: "int", IntValue: n}
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return &proto.StateValue{Type: "float", FloatValue: f}
+	}
+	return &proto.StateValue{Type: "enum", EnumValue: value}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RoomHandler struct {
+	client proto.RoomServiceClient
+}
+
+func NewRoomHandler(conn *grpc.ClientConn) *RoomHandler {
+	return &RoomHandler{
+		client: proto.NewRoomServiceClient(conn),
+	}
+}
+
+func (h *RoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/rooms")
+
+	switch {
+	case path == "" || path == "/":
+		switch r.Method {
+		case http.MethodGet:
+			h.ListRooms(w, r)
+		case http.MethodPost:
+			h.CreateRoom(w, r)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.Contains(path, "/devices/"):
+		// /{roomId}/devices/{deviceId}
+		roomID, deviceID, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/devices/")
+		switch r.Method {
+		case http.MethodPut:
+			h.AssignDevice(w, r, roomID, deviceID)
+		case http.MethodDelete:
+			h.UnassignDevice(w, r, roomID, deviceID)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasPrefix(path, "/"):
+		id := strings.TrimPrefix(path, "/")
+		switch r.Method {
+		case http.MethodGet:
+			h.GetRoom(w, r, id)
+		case http.MethodPut:
+			h.UpdateRoom(w, r, id)
+		case http.MethodDelete:
+			h.DeleteRoom(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (h *RoomHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.ListRooms(r.Context(), &proto.ListRoomsRequest{
+		UserId:      r.URL.Query().Get("user_id"),
+		HouseholdId: r.URL.Query().Get("household_id"),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list rooms")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req proto.CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	room, err := h.client.CreateRoom(r.Context(), &req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.InvalidArgument {
+			utils.RespondWithError(w, http.StatusBadRequest, st.Message())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create room")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, room)
+}
+
+func (h *RoomHandler) GetRoom(w http.ResponseWriter, r *http.Request, id string) {
+	room, err := h.client.GetRoom(r.Context(), &proto.GetRoomRequest{Id: id})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Room not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get room")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, room)
+}
+
+func (h *RoomHandler) UpdateRoom(w http.ResponseWriter, r *http.Request, id string) {
+	var req proto.UpdateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Id = id
+
+	room, err := h.client.UpdateRoom(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update room")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, room)
+}
+
+func (h *RoomHandler) DeleteRoom(w http.ResponseWriter, r *http.Request, id string) {
+	_, err := h.client.DeleteRoom(r.Context(), &proto.DeleteRoomRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to delete room")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Room deleted successfully"})
+}
+
+func (h *RoomHandler) AssignDevice(w http.ResponseWriter, r *http.Request, roomID, deviceID string) {
+	room, err := h.client.AssignDevice(r.Context(), &proto.AssignDeviceRequest{RoomId: roomID, DeviceId: deviceID})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to assign device")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, room)
+}
+
+func (h *RoomHandler) UnassignDevice(w http.ResponseWriter, r *http.Request, roomID, deviceID string) {
+	room, err := h.client.UnassignDevice(r.Context(), &proto.UnassignDeviceRequest{RoomId: roomID, DeviceId: deviceID})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to unassign device")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, room)
+}
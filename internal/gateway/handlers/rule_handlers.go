@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc"
+)
+
+type RuleHandler struct {
+	client proto.RuleServiceClient
+}
+
+func NewRuleHandler(conn *grpc.ClientConn) *RuleHandler {
+	return &RuleHandler{
+		client: proto.NewRuleServiceClient(conn),
+	}
+}
+
+func (h *RuleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/rules")
+
+	switch {
+	case path == "" || path == "/":
+		switch r.Method {
+		case http.MethodGet:
+			h.ListRules(w, r)
+		case http.MethodPost:
+			h.CreateRule(w, r)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasSuffix(path, "/enable"), strings.HasSuffix(path, "/disable"):
+		id, action, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+		if r.Method != http.MethodPost {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		h.SetRuleEnabled(w, r, id, action == "enable")
+	case strings.HasSuffix(path, "/executions"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/executions")
+		if r.Method != http.MethodGet {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		h.ListRuleExecutions(w, r, id)
+	case strings.HasPrefix(path, "/"):
+		id := strings.TrimPrefix(path, "/")
+		switch r.Method {
+		case http.MethodGet:
+			h.GetRule(w, r, id)
+		case http.MethodPut:
+			h.UpdateRule(w, r, id)
+		case http.MethodDelete:
+			h.DeleteRule(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (h *RuleHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.ListRules(r.Context(), &proto.ListRulesRequest{UserId: r.URL.Query().Get("user_id")})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to list rules")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *RuleHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req proto.CreateRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	rule, err := h.client.CreateRule(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to create rule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, rule)
+}
+
+func (h *RuleHandler) GetRule(w http.ResponseWriter, r *http.Request, id string) {
+	rule, err := h.client.GetRule(r.Context(), &proto.GetRuleRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to get rule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, rule)
+}
+
+func (h *RuleHandler) UpdateRule(w http.ResponseWriter, r *http.Request, id string) {
+	var req proto.UpdateRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Id = id
+
+	rule, err := h.client.UpdateRule(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update rule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, rule)
+}
+
+func (h *RuleHandler) DeleteRule(w http.ResponseWriter, r *http.Request, id string) {
+	_, err := h.client.DeleteRule(r.Context(), &proto.DeleteRuleRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to delete rule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Rule deleted successfully"})
+}
+
+func (h *RuleHandler) SetRuleEnabled(w http.ResponseWriter, r *http.Request, id string, enabled bool) {
+	rule, err := h.client.SetRuleEnabled(r.Context(), &proto.SetRuleEnabledRequest{Id: id, Enabled: enabled})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update rule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, rule)
+}
+
+func (h *RuleHandler) ListRuleExecutions(w http.ResponseWriter, r *http.Request, id string) {
+	params := r.URL.Query()
+	page, err := int32Param(params, "page")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pageSize, err := int32Param(params, "page_size")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req := &proto.ListRuleExecutionsRequest{RuleId: id, Page: page, PageSize: pageSize}
+	resp, err := h.client.ListRuleExecutions(r.Context(), req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to list rule executions")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc"
+)
+
+type SceneHandler struct {
+	client proto.SceneServiceClient
+}
+
+func NewSceneHandler(conn *grpc.ClientConn) *SceneHandler {
+	return &SceneHandler{
+		client: proto.NewSceneServiceClient(conn),
+	}
+}
+
+func (h *SceneHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/scenes")
+
+	switch {
+	case path == "" || path == "/":
+		switch r.Method {
+		case http.MethodGet:
+			h.ListScenes(w, r)
+		case http.MethodPost:
+			h.CreateScene(w, r)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasSuffix(path, "/activate"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/activate")
+		if r.Method != http.MethodPost {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		h.ActivateScene(w, r, id)
+	case strings.HasPrefix(path, "/"):
+		id := strings.TrimPrefix(path, "/")
+		switch r.Method {
+		case http.MethodGet:
+			h.GetScene(w, r, id)
+		case http.MethodPut:
+			h.UpdateScene(w, r, id)
+		case http.MethodDelete:
+			h.DeleteScene(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (h *SceneHandler) ListScenes(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.ListScenes(r.Context(), &proto.ListScenesRequest{UserId: r.URL.Query().Get("user_id")})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to list scenes")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *SceneHandler) CreateScene(w http.ResponseWriter, r *http.Request) {
+	var req proto.CreateSceneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	scene, err := h.client.CreateScene(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to create scene")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, scene)
+}
+
+func (h *SceneHandler) GetScene(w http.ResponseWriter, r *http.Request, id string) {
+	scene, err := h.client.GetScene(r.Context(), &proto.GetSceneRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to get scene")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, scene)
+}
+
+func (h *SceneHandler) UpdateScene(w http.ResponseWriter, r *http.Request, id string) {
+	var req proto.UpdateSceneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Id = id
+
+	scene, err := h.client.UpdateScene(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update scene")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, scene)
+}
+
+func (h *SceneHandler) DeleteScene(w http.ResponseWriter, r *http.Request, id string) {
+	_, err := h.client.DeleteScene(r.Context(), &proto.DeleteSceneRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to delete scene")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Scene deleted successfully"})
+}
+
+// ActivateScene responds with 207 Multi-Status when only some of the scene's
+// devices could be updated, so clients can tell a partial activation apart
+func (h *SceneHandler) ActivateScene(w http.ResponseWriter, r *http.Request, id string) {
+	resp, err := h.client.ActivateScene(r.Context(), &proto.ActivateSceneRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to activate scene")
+		return
+	}
+	code := http.StatusOK
+	if !resp.AllSucceeded {
+		code = http.StatusMultiStatus
+	}
+	utils.RespondWithJSON(w, code, resp)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc"
+)
+
+type ScheduleHandler struct {
+	client proto.ScheduleServiceClient
+}
+
+func NewScheduleHandler(conn *grpc.ClientConn) *ScheduleHandler {
+	return &ScheduleHandler{
+		client: proto.NewScheduleServiceClient(conn),
+	}
+}
+
+func (h *ScheduleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/schedules")
+
+	switch {
+	case path == "" || path == "/":
+		switch r.Method {
+		case http.MethodGet:
+			h.ListSchedules(w, r)
+		case http.MethodPost:
+			h.CreateSchedule(w, r)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case strings.HasSuffix(path, "/runs"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/runs")
+		if r.Method != http.MethodGet {
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		h.ListScheduleRuns(w, r, id)
+	case strings.HasPrefix(path, "/"):
+		id := strings.TrimPrefix(path, "/")
+		switch r.Method {
+		case http.MethodGet:
+			h.GetSchedule(w, r, id)
+		case http.MethodPut:
+			h.UpdateSchedule(w, r, id)
+		case http.MethodDelete:
+			h.DeleteSchedule(w, r, id)
+		default:
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	default:
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	req := &proto.ListSchedulesRequest{
+		UserId:   params.Get("user_id"),
+		DeviceId: params.Get("device_id"),
+	}
+
+	resp, err := h.client.ListSchedules(r.Context(), req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to list schedules")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req proto.CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	schedule, err := h.client.CreateSchedule(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to create schedule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, schedule)
+}
+
+func (h *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request, id string) {
+	schedule, err := h.client.GetSchedule(r.Context(), &proto.GetScheduleRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to get schedule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, schedule)
+}
+
+func (h *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request, id string) {
+	var req proto.UpdateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Id = id
+
+	schedule, err := h.client.UpdateSchedule(r.Context(), &req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to update schedule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, schedule)
+}
+
+func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request, id string) {
+	_, err := h.client.DeleteSchedule(r.Context(), &proto.DeleteScheduleRequest{Id: id})
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to delete schedule")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Schedule deleted successfully"})
+}
+
+func (h *ScheduleHandler) ListScheduleRuns(w http.ResponseWriter, r *http.Request, id string) {
+	params := r.URL.Query()
+	page, err := int32Param(params, "page")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pageSize, err := int32Param(params, "page_size")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req := &proto.ListScheduleRunsRequest{ScheduleId: id, Page: page, PageSize: pageSize}
+	resp, err := h.client.ListScheduleRuns(r.Context(), req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to list schedule runs")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
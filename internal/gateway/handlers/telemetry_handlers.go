@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/gateway/utils"
+	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TelemetryReading is a sensor reading as posted to
+// /api/v1/devices/{id}/telemetry. Readings without a timestamp were taken
+// when they are received.
+type TelemetryReading struct {
+	Metric    string     `json:"metric"`
+	Value     float64    `json:"value"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// IngestTelemetry serves POST /api/v1/devices/{id}/telemetry with a body of
+// {"readings": [{"metric": "temperature", "value": 21.5, "timestamp": "..."}]}
+func (h *DeviceHandler) IngestTelemetry(w http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		Readings []TelemetryReading `json:"readings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req := &proto.IngestTelemetryRequest{Points: make([]*proto.TelemetryPoint, len(body.Readings))}
+	for i, reading := range body.Readings {
+		req.Points[i] = &proto.TelemetryPoint{DeviceId: id, Metric: reading.Metric, Value: reading.Value}
+		if reading.Timestamp != nil {
+			req.Points[i].Timestamp = timestamppb.New(*reading.Timestamp)
+		}
+	}
+	resp, err := h.client.IngestTelemetry(r.Context(), req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to ingest telemetry")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// QueryTelemetry serves GET /api/v1/devices/{id}/telemetry?metric=&from=&to=&interval_seconds=&limit=
+// with from and to given as RFC 3339 timestamps
+func (h *DeviceHandler) QueryTelemetry(w http.ResponseWriter, r *http.Request, id string) {
+	params := r.URL.Query()
+	req := &proto.QueryTelemetryRequest{DeviceId: id, Metric: params.Get("metric")}
+
+	var err error
+	if req.From, err = timestampParam(params, "from"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.To, err = timestampParam(params, "to"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.IntervalSeconds, err = int32Param(params, "interval_seconds"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Limit, err = int32Param(params, "limit"); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := h.client.QueryTelemetry(r.Context(), req)
+	if err != nil {
+		respondWithStatusError(w, err, "Failed to query telemetry")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, resp)
+}
@@ -39,7 +39,7 @@ func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
 			h.GetUser(w,r,id)
-		case http.MethodPut:
+		case http.MethodPut, http.MethodPatch:
 			h.UpdateUser(w,r,id)
 		case http.MethodDelete:
 			h.DeleteUser(w,r,id)
@@ -60,7 +60,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.client.CreateUser(r.Context(), &req)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		respondWithStatusError(w, err, "Failed to create user")
 		return
 	}
 	utils.RespondWithJSON(w, http.StatusCreated, resp)
@@ -86,6 +86,9 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request, id stri
 		return
 	}
 	req.Id = id
+	if mask := fieldMaskParam(r.URL.Query(), "update_mask"); mask != nil {
+		req.UpdateMask = mask
+	}
 
 	user, err := h.client.UpdateUser(r.Context(), &req)
 	if err != nil {
@@ -95,6 +98,8 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request, id stri
 				utils.RespondWithError(w, http.StatusNotFound, "User not found")
 			case codes.InvalidArgument:
 				utils.RespondWithError(w, http.StatusBadRequest, st.Message())
+			case codes.AlreadyExists:
+				utils.RespondWithError(w, http.StatusConflict, st.Message())
 			default:
 				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
 			}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+// AdminWrites lets every authenticated user read, but only the users in
+// adminIDs create, change and delete. It goes inside Auth, which
+// authenticates the user.
+func AdminWrites(adminIDs []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				if !slices.Contains(adminIDs, UserIDFromContext(r.Context())) {
+					http.Error(w, "Only administrators may change this", http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminWrites(t *testing.T) {
+	handler := AdminWrites([]string{"admin"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range []struct {
+		method, userID string
+		code           int
+	}{
+		{http.MethodGet, "alice", http.StatusOK},
+		{http.MethodPost, "alice", http.StatusForbidden},
+		{http.MethodPut, "alice", http.StatusForbidden},
+		{http.MethodDelete, "alice", http.StatusForbidden},
+		{http.MethodPost, "admin", http.StatusOK},
+		{http.MethodDelete, "admin", http.StatusOK},
+	} {
+		r := httptest.NewRequest(tt.method, "/api/v1/device-types/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), userIDKey{}, tt.userID))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, tt.code, w.Code, "%s by %s", tt.method, tt.userID)
+	}
+}
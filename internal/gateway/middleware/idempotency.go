@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/common/idempotency"
+	"google.golang.org/grpc/metadata"
+)
+
+// IdempotencyKeyHeader is the header clients put a key in to retry a request
+// without it taking effect twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKey forwards the Idempotency-Key header of a request to the gRPC
+// services it calls, which replay the original response to retries
+func IdempotencyKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > idempotency.MaxKeyLength {
+			http.Error(w, fmt.Sprintf("%s must not be longer than %d characters", IdempotencyKeyHeader, idempotency.MaxKeyLength), http.StatusBadRequest)
+			return
+		}
+		ctx := metadata.AppendToOutgoingContext(r.Context(), idempotency.MetadataKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
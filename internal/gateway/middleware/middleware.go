@@ -1,10 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
 	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/time/rate"
 )
@@ -30,14 +32,15 @@ func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
 			
 			// Handle preflight requests
 			if r.Method == http.MethodOptions {
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, Idempotency-Key")
 				w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -56,23 +59,50 @@ func RateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// userIDKey carries the ID of the authenticated user, taken from the "sub"
+// claim of the token
+type userIDKey struct{}
+
+// UserIDFromContext returns the ID of the user authenticated by Auth or StreamAuth
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
 // Auth middleware
 func Auth(jwtSecret string) func(http.Handler) http.Handler {
+	return authenticate(jwtSecret, false)
+}
+
+// StreamAuth is Auth for streaming endpoints. Browsers cannot set headers on
+// EventSource and WebSocket connections, so the token may also be passed in
+// the access_token query parameter.
+func StreamAuth(jwtSecret string) func(http.Handler) http.Handler {
+	return authenticate(jwtSecret, true)
+}
+
+func authenticate(jwtSecret string, allowQueryToken bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tokenString string
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			switch {
+			case authHeader != "":
+				bearerToken := strings.Split(authHeader, " ")
+				if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+					http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+					return
+				}
+				tokenString = bearerToken[1]
+			case allowQueryToken && r.URL.Query().Get("access_token") != "":
+				tokenString = r.URL.Query().Get("access_token")
+			default:
 				http.Error(w, "Missing authorization header", http.StatusUnauthorized)
-				return 
-			}
-
-			bearerToken := strings.Split(authHeader, " ")
-			if len(bearerToken) != 2 && bearerToken[0] != "Bearer"{
-				http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+				return
 			}
 
-			token, err := jwt.Parse(bearerToken[1], func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 					return nil, jwt.NewValidationError("unexpected signing method", jwt.ValidationErrorSignatureInvalid)
 				}
 				return []byte(jwtSecret), nil
@@ -87,7 +117,19 @@ func Auth(jwtSecret string) func(http.Handler) http.Handler {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
-			next.ServeHTTP(w, r)
+
+			claims, _ := token.Claims.(jwt.MapClaims)
+			userID, _ := claims["sub"].(string)
+			if userID == "" {
+				http.Error(w, "Token has no subject", http.StatusUnauthorized)
+				return
+			}
+
+			// The services called act on behalf of the user, whatever user
+			// IDs the request itself names
+			ctx := context.WithValue(r.Context(), userIDKey{}, userID)
+			ctx = identity.NewOutgoingContext(ctx, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
-}
\ No newline at end of file
+}
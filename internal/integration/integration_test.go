@@ -6,27 +6,34 @@ package integration
 import (
 	"context"
 	"database/sql"
-	"log"
+	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"github.com/MichaelGenchev/smart-home-system/internal/device/outbox"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/repository"
 	"github.com/MichaelGenchev/smart-home-system/internal/device/service"
-	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 	"github.com/MichaelGenchev/smart-home-system/pkg/proto"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type IntegrationTestSuite struct {
 	suite.Suite
 	sqlDB       *sql.DB
 	mongoClient *mongo.Client
+	devices     *mongo.Collection
 	service     *service.DeviceService
+	relay       *outbox.Relay
 }
 
 func (suite *IntegrationTestSuite) SetupSuite() {
@@ -58,11 +65,21 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 		suite.T().Fatal("MONGO_DB is not set")
 	}
 	sqlRepo := repository.NewSQLRepository(sqlDB)
-	mongoRepo := repository.NewMongoRepository(mongoClient.Database(mongoDB).Collection("devices"))
-	combinedRepo := repository.NewCombinedRepository(sqlRepo, mongoRepo)
+	suite.devices = mongoClient.Database(mongoDB).Collection("devices")
+	mongoRepo := repository.NewMongoRepository(suite.devices)
+	outboxCheckpointRepo := repository.NewSQLOutboxCheckpointRepository(sqlDB)
+	combinedRepo := repository.NewCombinedRepository(sqlRepo, mongoRepo, outboxCheckpointRepo)
+	deviceTypeRepo := repository.NewSQLDeviceTypeRepository(sqlDB)
+	roomRepo := repository.NewSQLRoomRepository(sqlDB)
+	deviceEventRepo := repository.NewSQLDeviceEventRepository(sqlDB)
+	telemetryRepo := repository.NewMongoTelemetryRepository(mongoClient.Database(mongoDB).Collection("telemetry"))
+	if err := telemetryRepo.EnsureCollection(context.Background(), 0); err != nil {
+		suite.T().Fatalf("Could not create the telemetry collection: %v", err)
+	}
 
 	// Initialize service
-	suite.service = service.NewDeviceService(combinedRepo)
+	suite.service = service.NewDeviceService(combinedRepo, deviceTypeRepo, roomRepo, telemetryRepo, nil, nil, nil, nil)
+	suite.relay = outbox.NewRelay(deviceEventRepo, outboxCheckpointRepo, mongoRepo, time.Second)
 
 	// Wait for databases to be ready
 	suite.waitForDatabases()
@@ -97,12 +114,12 @@ func (suite *IntegrationTestSuite) TearDownSuite() {
 }
 
 func (suite *IntegrationTestSuite) TestCreateAndGetDevice() {
-	ctx := context.Background()
+	ctx := identity.NewIncomingContext(context.Background(), "user123")
 
 	// Create a device
 	createReq := &proto.CreateDeviceRequest{
 		Name:   "Test Device",
-		Type:   "Sensor",
+		Type:   "switch",
 		UserId: "user123",
 	}
 	createResp, err := suite.service.CreateDevice(ctx, createReq)
@@ -122,12 +139,12 @@ func (suite *IntegrationTestSuite) TestCreateAndGetDevice() {
 }
 
 func (suite *IntegrationTestSuite) TestUpdateDeviceState() {
-	ctx := context.Background()
+	ctx := identity.NewIncomingContext(context.Background(), "user456")
 
 	// Create a device
 	createReq := &proto.CreateDeviceRequest{
 		Name:   "Test Device for Update",
-		Type:   "Actuator",
+		Type:   "dimmer",
 		UserId: "user456",
 	}
 	createResp, err := suite.service.CreateDevice(ctx, createReq)
@@ -135,12 +152,16 @@ func (suite *IntegrationTestSuite) TestUpdateDeviceState() {
 
 	// Update the device state
 	updateReq := &proto.UpdateDeviceStateRequest{
-		Id:    createResp.Id,
-		State: "On",
+		Id: createResp.Id,
+		State: map[string]*proto.StateValue{
+			"power":      {Type: "bool", BoolValue: true},
+			"brightness": {Type: "int", IntValue: 20},
+		},
 	}
 	updateResp, err := suite.service.UpdateDeviceState(ctx, updateReq)
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), "On", updateResp.State)
+	assert.True(suite.T(), updateResp.State["power"].BoolValue)
+	assert.Equal(suite.T(), int64(20), updateResp.State["brightness"].IntValue)
 
 	// Get the updated device
 	getReq := &proto.GetDeviceRequest{
@@ -148,23 +169,135 @@ func (suite *IntegrationTestSuite) TestUpdateDeviceState() {
 	}
 	getResp, err := suite.service.GetDevice(ctx, getReq)
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), "On", getResp.State)
+	assert.True(suite.T(), getResp.State["power"].BoolValue)
+	assert.Equal(suite.T(), int64(20), getResp.State["brightness"].IntValue)
+}
+
+func (suite *IntegrationTestSuite) TestUpdateDeviceStateWithStaleVersion() {
+	ctx := identity.NewIncomingContext(context.Background(), "user456")
+
+	createResp, err := suite.service.CreateDevice(ctx, &proto.CreateDeviceRequest{Name: "Versioned Device", Type: "switch", UserId: "user456"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), createResp.Version)
+
+	on := map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}
+	updateResp, err := suite.service.UpdateDeviceState(ctx, &proto.UpdateDeviceStateRequest{Id: createResp.Id, State: on, ExpectedVersion: 1})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), updateResp.Version)
+
+	// A second writer that read version 1 loses
+	_, err = suite.service.UpdateDeviceState(ctx, &proto.UpdateDeviceStateRequest{Id: createResp.Id, State: on, ExpectedVersion: 1})
+	assert.Equal(suite.T(), codes.Aborted, status.Code(err))
+}
+
+func (suite *IntegrationTestSuite) TestAtomicBatchUpdate() {
+	ctx := identity.NewIncomingContext(context.Background(), "user457")
+
+	var updates []*proto.UpdateDeviceStateRequest
+	for i := 0; i < 3; i++ {
+		device, err := suite.service.CreateDevice(ctx, &proto.CreateDeviceRequest{Name: fmt.Sprintf("Downstairs Light %d", i), Type: "switch"})
+		assert.NoError(suite.T(), err)
+		updates = append(updates, &proto.UpdateDeviceStateRequest{Id: device.Id, State: map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: true}}})
+	}
+
+	// One stale update keeps the whole batch from being applied
+	updates[2].ExpectedVersion = 7
+	resp, err := suite.service.BatchUpdateDeviceStates(ctx, &proto.BatchUpdateDeviceStatesRequest{Updates: updates, Atomic: true})
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), resp.AllSucceeded)
+	assert.Equal(suite.T(), "version_mismatch", resp.Results[2].Status)
+	device, err := suite.service.GetDevice(ctx, &proto.GetDeviceRequest{Id: updates[0].Id})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), device.Version)
+
+	updates[2].ExpectedVersion = 0
+	resp, err = suite.service.BatchUpdateDeviceStates(ctx, &proto.BatchUpdateDeviceStatesRequest{Updates: updates, Atomic: true})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), resp.AllSucceeded)
+	for _, result := range resp.Results {
+		assert.True(suite.T(), result.Device.State["power"].BoolValue)
+		assert.Equal(suite.T(), int64(2), result.Device.Version)
+	}
+}
+
+func (suite *IntegrationTestSuite) TestHeartbeatsAndOfflineDevices() {
+	ctx := identity.NewIncomingContext(context.Background(), "user458")
+	created, err := suite.service.CreateDevice(ctx, &proto.CreateDeviceRequest{Name: "Porch Sensor", Type: "switch"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "unknown", created.Connectivity)
+
+	device, err := suite.service.ReportHeartbeat(ctx, &proto.ReportHeartbeatRequest{Id: created.Id})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "online", device.Connectivity)
+	assert.NotNil(suite.T(), device.LastSeenAt)
+	assert.Equal(suite.T(), created.Version, device.Version)
+
+	// An hour later the device is past the default timeout, which need not
+	// be whole seconds
+	repo := repository.NewSQLRepository(suite.sqlDB)
+	offline, err := repo.MarkDevicesOffline(context.Background(), time.Now().Add(time.Hour), 90500*time.Millisecond, 1000)
+	assert.NoError(suite.T(), err)
+	var ids []string
+	for _, device := range offline {
+		ids = append(ids, device.ID)
+	}
+	assert.Contains(suite.T(), ids, created.Id)
+
+	device, err = suite.service.GetDevice(ctx, &proto.GetDeviceRequest{Id: created.Id, Consistency: "strong"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "offline", device.Connectivity)
+}
+
+func (suite *IntegrationTestSuite) TestTelemetryBuckets() {
+	ctx := identity.NewIncomingContext(context.Background(), "user459")
+	device, err := suite.service.CreateDevice(ctx, &proto.CreateDeviceRequest{Name: "Bedroom Thermometer", Type: "switch"})
+	assert.NoError(suite.T(), err)
+
+	from := time.Now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	var points []*proto.TelemetryPoint
+	for minute := 0; minute < 30; minute++ {
+		points = append(points, &proto.TelemetryPoint{
+			DeviceId:  device.Id,
+			Metric:    "temperature",
+			Value:     float64(20 + minute%3),
+			Timestamp: timestamppb.New(from.Add(time.Duration(minute) * time.Minute)),
+		})
+	}
+	ingested, err := suite.service.IngestTelemetry(ctx, &proto.IngestTelemetryRequest{Points: points})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(30), ingested.Accepted)
+
+	resp, err := suite.service.QueryTelemetry(ctx, &proto.QueryTelemetryRequest{
+		DeviceId: device.Id, Metric: "temperature",
+		From: timestamppb.New(from), To: timestamppb.New(from.Add(time.Hour)), IntervalSeconds: 900,
+	})
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), resp.Buckets, 2) {
+		assert.Equal(suite.T(), from, resp.Buckets[0].Start.AsTime())
+		assert.Equal(suite.T(), int64(15), resp.Buckets[0].Count)
+		assert.Equal(suite.T(), 20.0, resp.Buckets[0].Min)
+		assert.Equal(suite.T(), 22.0, resp.Buckets[0].Max)
+		assert.Equal(suite.T(), 21.0, resp.Buckets[0].Avg)
+	}
 }
 
 func (suite *IntegrationTestSuite) TestListDevices() {
-	ctx := context.Background()
+	ctx := identity.NewIncomingContext(context.Background(), "user789")
 
 	// Create multiple devices
 	for i := 0; i < 5; i++ {
 		createReq := &proto.CreateDeviceRequest{
 			Name:   fmt.Sprintf("List Test Device %d", i),
-			Type:   "Sensor",
+			Type:   "switch",
 			UserId: "user789",
 		}
 		_, err := suite.service.CreateDevice(ctx, createReq)
 		assert.NoError(suite.T(), err)
 	}
 
+	// Apply the new devices to the read model
+	assert.NoError(suite.T(), suite.relay.Tick(ctx))
+
 	// List devices
 	listReq := &proto.ListDevicesRequest{
 		UserId:   "user789",
@@ -177,6 +310,94 @@ func (suite *IntegrationTestSuite) TestListDevices() {
 	assert.GreaterOrEqual(suite.T(), int(listResp.Total), 5)
 }
 
+func (suite *IntegrationTestSuite) TestListDevicesPagesWithTokens() {
+	ctx := identity.NewIncomingContext(context.Background(), "user790")
+
+	for i := 0; i < 5; i++ {
+		_, err := suite.service.CreateDevice(ctx, &proto.CreateDeviceRequest{Name: fmt.Sprintf("Paged Device %d", i), Type: "switch"})
+		assert.NoError(suite.T(), err)
+	}
+	assert.NoError(suite.T(), suite.relay.Tick(ctx))
+
+	// Every device is listed once across the pages
+	seen := make(map[string]bool)
+	req := &proto.ListDevicesRequest{PageSize: 2, OrderBy: "name desc"}
+	for {
+		resp, err := suite.service.ListDevices(ctx, req)
+		assert.NoError(suite.T(), err)
+		for _, device := range resp.Devices {
+			assert.False(suite.T(), seen[device.Id], "device %s listed twice", device.Id)
+			seen[device.Id] = true
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	assert.Len(suite.T(), seen, 5)
+}
+
+func (suite *IntegrationTestSuite) TestListDevicesByLabelSelector() {
+	ctx := identity.NewIncomingContext(context.Background(), "user791")
+
+	for _, labels := range []map[string]string{
+		{"floor": "1", "kind": "indoor"},
+		{"floor": "2", "kind": "outdoor"},
+		{"floor": "2"},
+		nil,
+	} {
+		_, err := suite.service.CreateDevice(ctx, &proto.CreateDeviceRequest{Name: "Labeled Device", Type: "switch", Labels: labels})
+		assert.NoError(suite.T(), err)
+	}
+	assert.NoError(suite.T(), suite.relay.Tick(ctx))
+
+	// Both models select the same devices
+	for _, consistency := range []string{"eventual", "strong"} {
+		for selector, want := range map[string]int{
+			"floor=2":                    2,
+			"floor=2,kind!=outdoor":      1,
+			"floor in (1,2),kind":        2,
+			"!floor":                     1,
+			"kind notin (indoor)":        3,
+			"floor notin (1),kind=other": 0,
+		} {
+			resp, err := suite.service.ListDevices(ctx, &proto.ListDevicesRequest{LabelSelector: selector, Consistency: consistency})
+			assert.NoError(suite.T(), err)
+			assert.Len(suite.T(), resp.Devices, want, "%s read of %q", consistency, selector)
+		}
+	}
+}
+
+func (suite *IntegrationTestSuite) TestListDevicesByStateOfMigratedRows() {
+	ctx := identity.NewIncomingContext(context.Background(), "user792")
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		resp, err := suite.service.CreateDevice(ctx, &proto.CreateDeviceRequest{Name: "Migrated Device", Type: "switch"})
+		assert.NoError(suite.T(), err)
+		ids = append(ids, resp.Id)
+	}
+	assert.NoError(suite.T(), suite.relay.Tick(ctx))
+
+	// Rows written before zero fields were omitted hold them explicitly
+	_, err := suite.sqlDB.ExecContext(ctx,
+		`UPDATE devices SET state = '{"power":{"type":"bool","bool":false}}' WHERE id = $1`, ids[0])
+	assert.NoError(suite.T(), err)
+	_, err = suite.devices.UpdateOne(ctx, bson.M{"_id": ids[0]}, bson.M{"$set": bson.M{"state.power.bool": false}})
+	assert.NoError(suite.T(), err)
+
+	for _, consistency := range []string{"eventual", "strong"} {
+		for on, want := range map[bool]int{false: 2, true: 0} {
+			resp, err := suite.service.ListDevices(ctx, &proto.ListDevicesRequest{
+				State:       map[string]*proto.StateValue{"power": {Type: "bool", BoolValue: on}},
+				Consistency: consistency,
+			})
+			assert.NoError(suite.T(), err)
+			assert.Len(suite.T(), resp.Devices, want, "%s read of power=%t", consistency, on)
+		}
+	}
+}
+
 func TestIntegrationSuite(t *testing.T) {
 	suite.Run(t, new(IntegrationTestSuite))
 }
\ No newline at end of file
@@ -0,0 +1,212 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
+	"github.com/MichaelGenchev/smart-home-system/internal/user/service"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	pb "github.com/MichaelGenchev/smart-home-system/pkg/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// HouseholdGRPCServer serves HouseholdService on behalf of the user forwarded
+// by the gateway
+type HouseholdGRPCServer struct {
+	pb.UnimplementedHouseholdServiceServer
+	service service.HouseholdService
+}
+
+func NewHouseholdGRPCServer(service service.HouseholdService) *HouseholdGRPCServer {
+	return &HouseholdGRPCServer{service: service}
+}
+
+func (s *HouseholdGRPCServer) CreateHousehold(ctx context.Context, req *pb.CreateHouseholdRequest) (*pb.Household, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	household, err := s.service.CreateHousehold(ctx, callerID, req.Name)
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	return convertHouseholdToPb(household, callerID), nil
+}
+
+func (s *HouseholdGRPCServer) GetHousehold(ctx context.Context, req *pb.GetHouseholdRequest) (*pb.Household, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	household, err := s.service.GetHousehold(ctx, callerID, req.Id)
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	return convertHouseholdToPb(household, callerID), nil
+}
+
+func (s *HouseholdGRPCServer) ListHouseholds(ctx context.Context, req *pb.ListHouseholdsRequest) (*pb.ListHouseholdsResponse, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	households, err := s.service.ListHouseholds(ctx, callerID)
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	resp := &pb.ListHouseholdsResponse{Households: make([]*pb.Household, len(households))}
+	for i, household := range households {
+		resp.Households[i] = convertHouseholdToPb(household, callerID)
+	}
+	return resp, nil
+}
+
+func (s *HouseholdGRPCServer) InviteMember(ctx context.Context, req *pb.InviteMemberRequest) (*pb.HouseholdInvitation, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invitation, err := s.service.InviteMember(ctx, callerID, req.HouseholdId, req.Email, models.HouseholdRole(req.Role))
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	return convertInvitationToPb(invitation), nil
+}
+
+func (s *HouseholdGRPCServer) ListInvitations(ctx context.Context, req *pb.ListInvitationsRequest) (*pb.ListInvitationsResponse, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invitations, err := s.service.ListInvitations(ctx, callerID)
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	resp := &pb.ListInvitationsResponse{Invitations: make([]*pb.HouseholdInvitation, len(invitations))}
+	for i, invitation := range invitations {
+		resp.Invitations[i] = convertInvitationToPb(invitation)
+	}
+	return resp, nil
+}
+
+func (s *HouseholdGRPCServer) AcceptInvitation(ctx context.Context, req *pb.RespondToInvitationRequest) (*pb.Household, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	household, err := s.service.AcceptInvitation(ctx, callerID, req.Id)
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	return convertHouseholdToPb(household, callerID), nil
+}
+
+func (s *HouseholdGRPCServer) DeclineInvitation(ctx context.Context, req *pb.RespondToInvitationRequest) (*pb.HouseholdInvitation, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invitation, err := s.service.DeclineInvitation(ctx, callerID, req.Id)
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	return convertInvitationToPb(invitation), nil
+}
+
+func (s *HouseholdGRPCServer) UpdateMemberRole(ctx context.Context, req *pb.UpdateMemberRoleRequest) (*pb.HouseholdMember, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.service.UpdateMemberRole(ctx, callerID, req.HouseholdId, req.UserId, models.HouseholdRole(req.Role))
+	if err != nil {
+		return nil, householdStatusError(err)
+	}
+	return convertMemberToPb(*member), nil
+}
+
+func (s *HouseholdGRPCServer) RemoveMember(ctx context.Context, req *pb.RemoveMemberRequest) (*pb.RemoveMemberResponse, error) {
+	callerID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.service.RemoveMember(ctx, callerID, req.HouseholdId, req.UserId); err != nil {
+		return nil, householdStatusError(err)
+	}
+	return &pb.RemoveMemberResponse{Success: true}, nil
+}
+
+// callerID returns the user a call was made on behalf of, as forwarded by the gateway
+func callerID(ctx context.Context) (string, error) {
+	userID := identity.UserIDFromIncomingContext(ctx)
+	if userID == "" {
+		return "", status.Error(codes.Unauthenticated, "call is not made on behalf of a user")
+	}
+	return userID, nil
+}
+
+// householdStatusError maps errors of the household service to gRPC status errors
+func householdStatusError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, repository.ErrHouseholdNotFound),
+		errors.Is(err, repository.ErrMemberNotFound),
+		errors.Is(err, repository.ErrInvitationNotFound),
+		errors.Is(err, repository.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrInvitationExists),
+		errors.Is(err, repository.ErrAlreadyMember):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, repository.ErrLastOwner),
+		errors.Is(err, repository.ErrInvitationClosed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "household request failed: %v", err)
+	}
+}
+
+func convertHouseholdToPb(household *models.Household, callerID string) *pb.Household {
+	role, _ := service.RoleOf(household, callerID)
+	members := make([]*pb.HouseholdMember, len(household.Members))
+	for i, member := range household.Members {
+		members[i] = convertMemberToPb(member)
+	}
+	return &pb.Household{
+		Id:        household.ID,
+		Name:      household.Name,
+		Members:   members,
+		Role:      string(role),
+		CreatedAt: timestamppb.New(household.CreatedAt),
+	}
+}
+
+func convertMemberToPb(member models.HouseholdMember) *pb.HouseholdMember {
+	return &pb.HouseholdMember{
+		HouseholdId: member.HouseholdID,
+		UserId:      member.UserID,
+		Role:        string(member.Role),
+		JoinedAt:    timestamppb.New(member.JoinedAt),
+	}
+}
+
+func convertInvitationToPb(invitation *models.HouseholdInvitation) *pb.HouseholdInvitation {
+	return &pb.HouseholdInvitation{
+		Id:            invitation.ID,
+		HouseholdId:   invitation.HouseholdID,
+		HouseholdName: invitation.HouseholdName,
+		Email:         invitation.Email,
+		Role:          string(invitation.Role),
+		InvitedBy:     invitation.InvitedBy,
+		Status:        string(invitation.Status),
+		CreatedAt:     timestamppb.New(invitation.CreatedAt),
+		ExpiresAt:     timestamppb.New(invitation.ExpiresAt),
+	}
+}
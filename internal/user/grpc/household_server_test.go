@@ -0,0 +1,46 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/common/identity"
+	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
+	"github.com/MichaelGenchev/smart-home-system/internal/user/service"
+	pb "github.com/MichaelGenchev/smart-home-system/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHouseholdStatusError(t *testing.T) {
+	for err, code := range map[error]codes.Code{
+		fmt.Errorf("%w: bad", repository.ErrInvalidInput): codes.InvalidArgument,
+		service.ErrPermissionDenied:                       codes.PermissionDenied,
+		repository.ErrHouseholdNotFound:                   codes.NotFound,
+		repository.ErrInvitationNotFound:                  codes.NotFound,
+		repository.ErrInvitationExists:                    codes.AlreadyExists,
+		repository.ErrAlreadyMember:                       codes.AlreadyExists,
+		repository.ErrLastOwner:                           codes.FailedPrecondition,
+		repository.ErrInvitationClosed:                    codes.FailedPrecondition,
+	} {
+		assert.Equal(t, code, status.Code(householdStatusError(err)), err.Error())
+	}
+}
+
+func TestMalformedHouseholdIDsAreInvalidArguments(t *testing.T) {
+	// The IDs are checked before the repositories are used
+	server := NewHouseholdGRPCServer(service.NewHouseholdService(nil, nil))
+	ctx := identity.NewIncomingContext(context.Background(), "alice")
+
+	_, err := server.GetHousehold(ctx, &pb.GetHouseholdRequest{Id: "not-a-uuid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = server.RemoveMember(ctx, &pb.RemoveMemberRequest{HouseholdId: "not-a-uuid", UserId: "alice"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = server.AcceptInvitation(ctx, &pb.RespondToInvitationRequest{Id: "not-a-uuid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.GetHousehold(context.Background(), &pb.GetHouseholdRequest{Id: "not-a-uuid"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
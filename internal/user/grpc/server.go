@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"errors"
 
 	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
 	"github.com/MichaelGenchev/smart-home-system/internal/user/service"
@@ -25,6 +26,9 @@ func NewGRPCServer(service service.UserService) *GRPCServer {
 func (s *GRPCServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
 	user, err := s.service.CreateUser(ctx, req.Name, req.Email, req.Password)
 	if err != nil {
+		if err == repository.ErrUserExists {
+			return nil, status.Errorf(codes.AlreadyExists, "user already exists")
+		}
 		return nil, status.Errorf(codes.Internal, "failed to create user: %v", err)
 	}
 	return convertUserToPb(user), nil
@@ -42,10 +46,15 @@ func (s *GRPCServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.U
 }
 
 func (s *GRPCServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
-	user, err := s.service.UpdateUser(ctx, req.Id, req.Name, req.Email)
+	user, err := s.service.UpdateUser(ctx, req.Id, req.Name, req.Email, req.GetUpdateMask().GetPaths())
 	if err != nil {
-		if err == repository.ErrUserNotFound {
+		switch {
+		case err == repository.ErrUserNotFound:
 			return nil, status.Errorf(codes.NotFound, "user not found")
+		case errors.Is(err, repository.ErrInvalidInput):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case err == repository.ErrUserExists:
+			return nil, status.Errorf(codes.AlreadyExists, "user already exists")
 		}
 		return nil, status.Errorf(codes.Internal, "failed to update user: %v", err)
 	}
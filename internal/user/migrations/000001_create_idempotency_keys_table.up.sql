@@ -0,0 +1,15 @@
+-- idempotency_keys holds the responses of calls made with an idempotency key,
+-- so that retries of those calls get the same response. A NULL response marks
+-- a call that is still running; token identifies the attempt running it.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope       TEXT NOT NULL,
+    key         TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    token       TEXT NOT NULL,
+    response    BYTEA,
+    claimed_at  TIMESTAMPTZ NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,34 @@
+-- A household is a home whose members share its devices. The devices
+-- themselves live in the device service and refer to households by ID.
+CREATE TABLE IF NOT EXISTS households (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS household_members (
+    household_id UUID NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL,
+    role         TEXT NOT NULL CHECK (role IN ('owner', 'adult', 'child', 'guest')),
+    joined_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (household_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_household_members_user_id ON household_members (user_id);
+
+-- Invitations are addressed to an email, so that users can be invited before
+-- they sign up. At most one invitation per household and email is pending.
+CREATE TABLE IF NOT EXISTS household_invitations (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    household_id UUID NOT NULL REFERENCES households (id) ON DELETE CASCADE,
+    email        TEXT NOT NULL,
+    role         TEXT NOT NULL CHECK (role IN ('owner', 'adult', 'child', 'guest')),
+    invited_by   TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_household_invitations_pending
+    ON household_invitations (household_id, lower(email)) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_household_invitations_email ON household_invitations (lower(email)) WHERE status = 'pending';
@@ -0,0 +1,415 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/lib/pq"
+)
+
+type HouseholdRepository interface {
+	// CreateHousehold creates a household with owner as its first member
+	CreateHousehold(ctx context.Context, household *models.Household, owner models.HouseholdMember) error
+	// GetHousehold returns a household with its members
+	GetHousehold(ctx context.Context, id string) (*models.Household, error)
+	// ListHouseholds returns the households userID is a member of, with their members
+	ListHouseholds(ctx context.Context, userID string) ([]*models.Household, error)
+	GetMember(ctx context.Context, householdID, userID string) (*models.HouseholdMember, error)
+	// UpdateMemberRole changes the role of a member. It fails with
+	// ErrLastOwner rather than leave a household without an owner.
+	UpdateMemberRole(ctx context.Context, householdID, userID string, role models.HouseholdRole) (*models.HouseholdMember, error)
+	// RemoveMember removes a member. It fails with ErrLastOwner rather than
+	// leave a household without an owner.
+	RemoveMember(ctx context.Context, householdID, userID string) error
+	// CreateInvitation stores a pending invitation. It fails with
+	// ErrInvitationExists while another one to the same email is pending.
+	CreateInvitation(ctx context.Context, invitation *models.HouseholdInvitation) error
+	GetInvitation(ctx context.Context, id string) (*models.HouseholdInvitation, error)
+	// ListPendingInvitations returns the unexpired pending invitations to an email
+	ListPendingInvitations(ctx context.Context, email string) ([]*models.HouseholdInvitation, error)
+	// AcceptInvitation marks a pending invitation accepted and adds userID
+	// to its household with the invited role
+	AcceptInvitation(ctx context.Context, id, userID string) (*models.HouseholdMember, error)
+	// DeclineInvitation marks a pending invitation declined
+	DeclineInvitation(ctx context.Context, id string) (*models.HouseholdInvitation, error)
+}
+
+var (
+	ErrHouseholdNotFound  = errors.New("household not found")
+	ErrMemberNotFound     = errors.New("household member not found")
+	ErrAlreadyMember      = errors.New("user is already a member of the household")
+	ErrLastOwner          = errors.New("household must keep an owner")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationExists   = errors.New("an invitation to this email is already pending")
+	// ErrInvitationClosed is returned when an invitation was already answered or has expired
+	ErrInvitationClosed = errors.New("invitation is no longer pending")
+)
+
+type postgresHouseholdRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresHouseholdRepository(db *sql.DB) HouseholdRepository {
+	return &postgresHouseholdRepository{db: db}
+}
+
+// invitationColumns lists the columns read by scanInvitation, in order. Queries
+// join households as h and household_invitations as i.
+const invitationColumns = `i.id, i.household_id, h.name, i.email, i.role, i.invited_by, i.status, i.created_at, i.expires_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInvitation(row rowScanner) (*models.HouseholdInvitation, error) {
+	var invitation models.HouseholdInvitation
+	err := row.Scan(
+		&invitation.ID, &invitation.HouseholdID, &invitation.HouseholdName, &invitation.Email, &invitation.Role,
+		&invitation.InvitedBy, &invitation.Status, &invitation.CreatedAt, &invitation.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *postgresHouseholdRepository) CreateHousehold(ctx context.Context, household *models.Household, owner models.HouseholdMember) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO households (name, created_at) VALUES ($1, $2) RETURNING id`
+	if err := tx.QueryRowContext(ctx, query, household.Name, household.CreatedAt).Scan(&household.ID); err != nil {
+		return err
+	}
+	owner.HouseholdID = household.ID
+	query = `INSERT INTO household_members (household_id, user_id, role, joined_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, query, owner.HouseholdID, owner.UserID, owner.Role, owner.JoinedAt); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	household.Members = []models.HouseholdMember{owner}
+	return nil
+}
+
+func (r *postgresHouseholdRepository) GetHousehold(ctx context.Context, id string) (*models.Household, error) {
+	var household models.Household
+	query := `SELECT id, name, created_at FROM households WHERE id = $1`
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&household.ID, &household.Name, &household.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrHouseholdNotFound
+		}
+		return nil, err
+	}
+	members, err := r.listMembers(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	household.Members = members[id]
+	return &household, nil
+}
+
+func (r *postgresHouseholdRepository) ListHouseholds(ctx context.Context, userID string) ([]*models.Household, error) {
+	query := `
+		SELECT h.id, h.name, h.created_at
+		FROM households h JOIN household_members m ON m.household_id = h.id
+		WHERE m.user_id = $1
+		ORDER BY h.created_at, h.id
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var households []*models.Household
+	var ids []string
+	for rows.Next() {
+		var household models.Household
+		if err := rows.Scan(&household.ID, &household.Name, &household.CreatedAt); err != nil {
+			return nil, err
+		}
+		households = append(households, &household)
+		ids = append(ids, household.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := r.listMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, household := range households {
+		household.Members = members[household.ID]
+	}
+	return households, nil
+}
+
+// listMembers returns the members of the given households, by household
+func (r *postgresHouseholdRepository) listMembers(ctx context.Context, householdIDs []string) (map[string][]models.HouseholdMember, error) {
+	members := make(map[string][]models.HouseholdMember)
+	if len(householdIDs) == 0 {
+		return members, nil
+	}
+	query := `
+		SELECT household_id, user_id, role, joined_at FROM household_members
+		WHERE household_id = ANY($1::uuid[])
+		ORDER BY joined_at, user_id
+	`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(householdIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member models.HouseholdMember
+		if err := rows.Scan(&member.HouseholdID, &member.UserID, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members[member.HouseholdID] = append(members[member.HouseholdID], member)
+	}
+	return members, rows.Err()
+}
+
+// memberQuery reads a member, given the household and user ID, for scanMember
+const memberQuery = `SELECT household_id, user_id, role, joined_at FROM household_members WHERE household_id = $1 AND user_id = $2`
+
+func scanMember(row rowScanner) (*models.HouseholdMember, error) {
+	var member models.HouseholdMember
+	err := row.Scan(&member.HouseholdID, &member.UserID, &member.Role, &member.JoinedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *postgresHouseholdRepository) GetMember(ctx context.Context, householdID, userID string) (*models.HouseholdMember, error) {
+	return scanMember(r.db.QueryRowContext(ctx, memberQuery, householdID, userID))
+}
+
+func (r *postgresHouseholdRepository) UpdateMemberRole(ctx context.Context, householdID, userID string, role models.HouseholdRole) (*models.HouseholdMember, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	member, err := lockMember(ctx, tx, householdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role == models.HouseholdRoleOwner && role != models.HouseholdRoleOwner {
+		if err := checkOtherOwner(ctx, tx, householdID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `UPDATE household_members SET role = $3 WHERE household_id = $1 AND user_id = $2`
+	if _, err := tx.ExecContext(ctx, query, householdID, userID, role); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	member.Role = role
+	return member, nil
+}
+
+func (r *postgresHouseholdRepository) RemoveMember(ctx context.Context, householdID, userID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	member, err := lockMember(ctx, tx, householdID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == models.HouseholdRoleOwner {
+		if err := checkOtherOwner(ctx, tx, householdID); err != nil {
+			return err
+		}
+	}
+
+	query := `DELETE FROM household_members WHERE household_id = $1 AND user_id = $2`
+	if _, err := tx.ExecContext(ctx, query, householdID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockMember locks a household, so that concurrent changes to its members
+// cannot both take away its last owner, and reads one of its members
+func lockMember(ctx context.Context, tx *sql.Tx, householdID, userID string) (*models.HouseholdMember, error) {
+	var id string
+	err := tx.QueryRowContext(ctx, `SELECT id FROM households WHERE id = $1 FOR UPDATE`, householdID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrHouseholdNotFound
+		}
+		return nil, err
+	}
+	return scanMember(tx.QueryRowContext(ctx, memberQuery, householdID, userID))
+}
+
+// checkOtherOwner fails with ErrLastOwner unless a household has more than one owner
+func checkOtherOwner(ctx context.Context, tx *sql.Tx, householdID string) error {
+	var owners int
+	query := `SELECT COUNT(*) FROM household_members WHERE household_id = $1 AND role = $2`
+	if err := tx.QueryRowContext(ctx, query, householdID, models.HouseholdRoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners < 2 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (r *postgresHouseholdRepository) CreateInvitation(ctx context.Context, invitation *models.HouseholdInvitation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// An expired invitation does not stand in the way of a new one
+	query := `
+		DELETE FROM household_invitations
+		WHERE household_id = $1 AND lower(email) = lower($2) AND status = 'pending' AND expires_at < NOW()
+	`
+	if _, err := tx.ExecContext(ctx, query, invitation.HouseholdID, invitation.Email); err != nil {
+		return err
+	}
+	query = `
+		INSERT INTO household_invitations (household_id, email, role, invited_by, status, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	err = tx.QueryRowContext(ctx, query,
+		invitation.HouseholdID, invitation.Email, invitation.Role, invitation.InvitedBy,
+		invitation.Status, invitation.CreatedAt, invitation.ExpiresAt,
+	).Scan(&invitation.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrInvitationExists
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrHouseholdNotFound
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *postgresHouseholdRepository) GetInvitation(ctx context.Context, id string) (*models.HouseholdInvitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM household_invitations i JOIN households h ON h.id = i.household_id WHERE i.id = $1`
+	return scanInvitation(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *postgresHouseholdRepository) ListPendingInvitations(ctx context.Context, email string) ([]*models.HouseholdInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM household_invitations i JOIN households h ON h.id = i.household_id
+		WHERE lower(i.email) = lower($1) AND i.status = 'pending' AND i.expires_at > NOW()
+		ORDER BY i.created_at, i.id
+	`
+	rows, err := r.db.QueryContext(ctx, query, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []*models.HouseholdInvitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+func (r *postgresHouseholdRepository) AcceptInvitation(ctx context.Context, id, userID string) (*models.HouseholdMember, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	invitation, err := closeInvitation(ctx, tx, id, models.InvitationStatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	member := models.HouseholdMember{HouseholdID: invitation.HouseholdID, UserID: userID, Role: invitation.Role}
+	query := `
+		INSERT INTO household_members (household_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (household_id, user_id) DO NOTHING
+		RETURNING joined_at
+	`
+	err = tx.QueryRowContext(ctx, query, member.HouseholdID, member.UserID, member.Role).Scan(&member.JoinedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAlreadyMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *postgresHouseholdRepository) DeclineInvitation(ctx context.Context, id string) (*models.HouseholdInvitation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	invitation, err := closeInvitation(ctx, tx, id, models.InvitationStatusDeclined)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// closeInvitation moves a pending, unexpired invitation to status
+func closeInvitation(ctx context.Context, tx *sql.Tx, id string, status models.InvitationStatus) (*models.HouseholdInvitation, error) {
+	query := `
+		UPDATE household_invitations i SET status = $2
+		FROM households h
+		WHERE h.id = i.household_id AND i.id = $1 AND i.status = 'pending' AND i.expires_at > NOW()
+		RETURNING ` + invitationColumns
+	invitation, err := scanInvitation(tx.QueryRowContext(ctx, query, id, status))
+	if err != ErrInvitationNotFound {
+		return invitation, err
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM household_invitations WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrInvitationClosed
+	}
+	return nil, ErrInvitationNotFound
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"time"
 
 	"github.com/MichaelGenchev/smart-home-system/pkg/models"
 	"github.com/lib/pq"
@@ -72,8 +71,12 @@ func (r *postgresRepository) UpdateUser(ctx context.Context, user *models.User)
 		SET name = $2, email = $3, updated_at = $4
 		WHERE id = $1
 	`
-	result, err := r.db.ExecContext(ctx, query, user.ID, user.Name, user.Email, time.Now())
+	result, err := r.db.ExecContext(ctx, query, user.ID, user.Name, user.Email, user.UpdatedAt)
 	if err != nil {
+		// Check for unique constraint violation on email
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrUserExists
+		}
 		return err
 	}
 	rowsAffected, err := result.RowsAffected()
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/google/uuid"
+)
+
+// invitationTTL is how long an invitation can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+// ErrPermissionDenied is returned when the caller's role in a household does
+// not allow what they asked for, or they are not a member at all
+var ErrPermissionDenied = errors.New("permission denied")
+
+// HouseholdService manages households, their members and invitations. Every
+// method acts on behalf of callerID.
+type HouseholdService interface {
+	// CreateHousehold creates a household with the caller as its owner
+	CreateHousehold(ctx context.Context, callerID, name string) (*models.Household, error)
+	// GetHousehold returns a household the caller is a member of
+	GetHousehold(ctx context.Context, callerID, id string) (*models.Household, error)
+	// ListHouseholds returns the households the caller is a member of
+	ListHouseholds(ctx context.Context, callerID string) ([]*models.Household, error)
+	// InviteMember invites the user with an email to join a household
+	InviteMember(ctx context.Context, callerID, householdID, email string, role models.HouseholdRole) (*models.HouseholdInvitation, error)
+	// ListInvitations returns the pending invitations to the caller's email
+	ListInvitations(ctx context.Context, callerID string) ([]*models.HouseholdInvitation, error)
+	// AcceptInvitation makes the caller a member of the household they were invited to
+	AcceptInvitation(ctx context.Context, callerID, id string) (*models.Household, error)
+	DeclineInvitation(ctx context.Context, callerID, id string) (*models.HouseholdInvitation, error)
+	UpdateMemberRole(ctx context.Context, callerID, householdID, userID string, role models.HouseholdRole) (*models.HouseholdMember, error)
+	// RemoveMember removes a member of a household. Members may always remove
+	// themselves.
+	RemoveMember(ctx context.Context, callerID, householdID, userID string) error
+}
+
+type householdService struct {
+	repo  repository.HouseholdRepository
+	users repository.Repository
+}
+
+func NewHouseholdService(repo repository.HouseholdRepository, users repository.Repository) HouseholdService {
+	return &householdService{repo: repo, users: users}
+}
+
+func (s *householdService) CreateHousehold(ctx context.Context, callerID, name string) (*models.Household, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: name must not be empty", repository.ErrInvalidInput)
+	}
+	now := time.Now()
+	household := &models.Household{Name: name, CreatedAt: now}
+	owner := models.HouseholdMember{UserID: callerID, Role: models.HouseholdRoleOwner, JoinedAt: now}
+	if err := s.repo.CreateHousehold(ctx, household, owner); err != nil {
+		return nil, err
+	}
+	return household, nil
+}
+
+func (s *householdService) GetHousehold(ctx context.Context, callerID, id string) (*models.Household, error) {
+	if err := checkID("household", id); err != nil {
+		return nil, err
+	}
+	household, err := s.repo.GetHousehold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := RoleOf(household, callerID); !ok {
+		return nil, ErrPermissionDenied
+	}
+	return household, nil
+}
+
+func (s *householdService) ListHouseholds(ctx context.Context, callerID string) ([]*models.Household, error) {
+	return s.repo.ListHouseholds(ctx, callerID)
+}
+
+func (s *householdService) InviteMember(ctx context.Context, callerID, householdID, email string, role models.HouseholdRole) (*models.HouseholdInvitation, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("%w: a valid email is required", repository.ErrInvalidInput)
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", repository.ErrInvalidInput, role)
+	}
+	caller, err := s.member(ctx, householdID, callerID)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Role.CanInvite(role) {
+		return nil, ErrPermissionDenied
+	}
+
+	now := time.Now()
+	invitation := &models.HouseholdInvitation{
+		HouseholdID: householdID,
+		Email:       email,
+		Role:        role,
+		InvitedBy:   callerID,
+		Status:      models.InvitationStatusPending,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(invitationTTL),
+	}
+	if err := s.repo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+	return s.repo.GetInvitation(ctx, invitation.ID)
+}
+
+func (s *householdService) ListInvitations(ctx context.Context, callerID string) ([]*models.HouseholdInvitation, error) {
+	user, err := s.users.GetUser(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListPendingInvitations(ctx, user.Email)
+}
+
+func (s *householdService) AcceptInvitation(ctx context.Context, callerID, id string) (*models.Household, error) {
+	if err := checkID("invitation", id); err != nil {
+		return nil, err
+	}
+	if _, err := s.invitationFor(ctx, callerID, id); err != nil {
+		return nil, err
+	}
+	member, err := s.repo.AcceptInvitation(ctx, id, callerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetHousehold(ctx, member.HouseholdID)
+}
+
+func (s *householdService) DeclineInvitation(ctx context.Context, callerID, id string) (*models.HouseholdInvitation, error) {
+	if err := checkID("invitation", id); err != nil {
+		return nil, err
+	}
+	if _, err := s.invitationFor(ctx, callerID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.DeclineInvitation(ctx, id)
+}
+
+// invitationFor returns an invitation if it was sent to the caller's email
+func (s *householdService) invitationFor(ctx context.Context, callerID, id string) (*models.HouseholdInvitation, error) {
+	invitation, err := s.repo.GetInvitation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetUser(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		// Invitations to others are not revealed
+		return nil, repository.ErrInvitationNotFound
+	}
+	return invitation, nil
+}
+
+func (s *householdService) UpdateMemberRole(ctx context.Context, callerID, householdID, userID string, role models.HouseholdRole) (*models.HouseholdMember, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", repository.ErrInvalidInput, role)
+	}
+	caller, err := s.member(ctx, householdID, callerID)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Role.CanManageMembers() {
+		return nil, ErrPermissionDenied
+	}
+	return s.repo.UpdateMemberRole(ctx, householdID, userID, role)
+}
+
+func (s *householdService) RemoveMember(ctx context.Context, callerID, householdID, userID string) error {
+	caller, err := s.member(ctx, householdID, callerID)
+	if err != nil {
+		return err
+	}
+	if userID != callerID && !caller.Role.CanManageMembers() {
+		return ErrPermissionDenied
+	}
+	return s.repo.RemoveMember(ctx, householdID, userID)
+}
+
+// member returns the caller's membership of a household, failing with
+// ErrPermissionDenied if they are not a member
+func (s *householdService) member(ctx context.Context, householdID, callerID string) (*models.HouseholdMember, error) {
+	if err := checkID("household", householdID); err != nil {
+		return nil, err
+	}
+	member, err := s.repo.GetMember(ctx, householdID, callerID)
+	if errors.Is(err, repository.ErrMemberNotFound) {
+		return nil, ErrPermissionDenied
+	}
+	return member, err
+}
+
+// checkID fails with ErrInvalidInput unless id is a UUID, as the IDs of
+// households and invitations are
+func checkID(kind, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%w: %s id %q is not a UUID", repository.ErrInvalidInput, kind, id)
+	}
+	return nil
+}
+
+// RoleOf returns the role of a user in a household, if they are a member
+func RoleOf(household *models.Household, userID string) (models.HouseholdRole, bool) {
+	for _, member := range household.Members {
+		if member.UserID == userID {
+			return member.Role, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockHouseholdRepository is a mock implementation of the HouseholdRepository interface
+type MockHouseholdRepository struct {
+	mock.Mock
+}
+
+func (m *MockHouseholdRepository) CreateHousehold(ctx context.Context, household *models.Household, owner models.HouseholdMember) error {
+	args := m.Called(ctx, household, owner)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepository) GetHousehold(ctx context.Context, id string) (*models.Household, error) {
+	args := m.Called(ctx, id)
+	household, _ := args.Get(0).(*models.Household)
+	return household, args.Error(1)
+}
+
+func (m *MockHouseholdRepository) ListHouseholds(ctx context.Context, userID string) ([]*models.Household, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*models.Household), args.Error(1)
+}
+
+func (m *MockHouseholdRepository) GetMember(ctx context.Context, householdID, userID string) (*models.HouseholdMember, error) {
+	args := m.Called(ctx, householdID, userID)
+	member, _ := args.Get(0).(*models.HouseholdMember)
+	return member, args.Error(1)
+}
+
+func (m *MockHouseholdRepository) UpdateMemberRole(ctx context.Context, householdID, userID string, role models.HouseholdRole) (*models.HouseholdMember, error) {
+	args := m.Called(ctx, householdID, userID, role)
+	member, _ := args.Get(0).(*models.HouseholdMember)
+	return member, args.Error(1)
+}
+
+func (m *MockHouseholdRepository) RemoveMember(ctx context.Context, householdID, userID string) error {
+	args := m.Called(ctx, householdID, userID)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepository) CreateInvitation(ctx context.Context, invitation *models.HouseholdInvitation) error {
+	args := m.Called(ctx, invitation)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepository) GetInvitation(ctx context.Context, id string) (*models.HouseholdInvitation, error) {
+	args := m.Called(ctx, id)
+	invitation, _ := args.Get(0).(*models.HouseholdInvitation)
+	return invitation, args.Error(1)
+}
+
+func (m *MockHouseholdRepository) ListPendingInvitations(ctx context.Context, email string) ([]*models.HouseholdInvitation, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).([]*models.HouseholdInvitation), args.Error(1)
+}
+
+func (m *MockHouseholdRepository) AcceptInvitation(ctx context.Context, id, userID string) (*models.HouseholdMember, error) {
+	args := m.Called(ctx, id, userID)
+	member, _ := args.Get(0).(*models.HouseholdMember)
+	return member, args.Error(1)
+}
+
+func (m *MockHouseholdRepository) DeclineInvitation(ctx context.Context, id string) (*models.HouseholdInvitation, error) {
+	args := m.Called(ctx, id)
+	invitation, _ := args.Get(0).(*models.HouseholdInvitation)
+	return invitation, args.Error(1)
+}
+
+// MockUserRepository is a mock implementation of the Repository interface
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	args := m.Called(ctx, id)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DeleteUser(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+const (
+	homeID       = "8f14e45f-ceea-4e7a-9b1d-6c0a3d1e2f40"
+	invitationID = "c9f0f895-fb98-4b9d-8c1a-2e4b6d8f0a12"
+)
+
+// asMember makes GetMember return userID's membership of home with role
+func asMember(repo *MockHouseholdRepository, userID string, role models.HouseholdRole) {
+	member := &models.HouseholdMember{HouseholdID: homeID, UserID: userID, Role: role}
+	repo.On("GetMember", mock.Anything, homeID, userID).Return(member, nil)
+}
+
+func TestCreateHouseholdMakesTheCallerOwner(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	service := NewHouseholdService(repo, nil)
+
+	repo.On("CreateHousehold", mock.Anything, mock.Anything, mock.MatchedBy(func(owner models.HouseholdMember) bool {
+		return owner.UserID == "alice" && owner.Role == models.HouseholdRoleOwner
+	})).Return(nil)
+
+	household, err := service.CreateHousehold(context.Background(), "alice", "Home")
+	assert.NoError(t, err)
+	assert.Equal(t, "Home", household.Name)
+
+	_, err = service.CreateHousehold(context.Background(), "alice", " ")
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	repo.AssertNumberOfCalls(t, "CreateHousehold", 1)
+}
+
+func TestGetHouseholdTakesMembership(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	service := NewHouseholdService(repo, nil)
+
+	household := &models.Household{ID: homeID, Members: []models.HouseholdMember{{UserID: "alice", Role: models.HouseholdRoleOwner}}}
+	repo.On("GetHousehold", mock.Anything, homeID).Return(household, nil)
+
+	_, err := service.GetHousehold(context.Background(), "alice", homeID)
+	assert.NoError(t, err)
+	_, err = service.GetHousehold(context.Background(), "mallory", homeID)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+}
+
+func TestInviteMemberByRole(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	service := NewHouseholdService(repo, nil)
+
+	asMember(repo, "alice", models.HouseholdRoleOwner)
+	asMember(repo, "bob", models.HouseholdRoleAdult)
+	asMember(repo, "carol", models.HouseholdRoleChild)
+	repo.On("GetMember", mock.Anything, homeID, "mallory").Return(nil, repository.ErrMemberNotFound)
+	repo.On("CreateInvitation", mock.Anything, mock.Anything).Return(nil)
+	repo.On("GetInvitation", mock.Anything, mock.Anything).Return(&models.HouseholdInvitation{ID: invitationID}, nil)
+
+	for _, tt := range []struct {
+		caller string
+		role   models.HouseholdRole
+		err    error
+	}{
+		{"alice", models.HouseholdRoleOwner, nil},
+		{"bob", models.HouseholdRoleGuest, nil},
+		// Only owners make other owners
+		{"bob", models.HouseholdRoleOwner, ErrPermissionDenied},
+		{"carol", models.HouseholdRoleGuest, ErrPermissionDenied},
+		{"mallory", models.HouseholdRoleGuest, ErrPermissionDenied},
+		{"alice", models.HouseholdRole("butler"), repository.ErrInvalidInput},
+	} {
+		_, err := service.InviteMember(context.Background(), tt.caller, homeID, "dave@example.com", tt.role)
+		if tt.err == nil {
+			assert.NoError(t, err, "%s inviting a %s", tt.caller, tt.role)
+		} else {
+			assert.ErrorIs(t, err, tt.err, "%s inviting a %s", tt.caller, tt.role)
+		}
+	}
+	repo.AssertNumberOfCalls(t, "CreateInvitation", 2)
+
+	_, err := service.InviteMember(context.Background(), "alice", homeID, "not an email", models.HouseholdRoleGuest)
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	users := new(MockUserRepository)
+	service := NewHouseholdService(repo, users)
+
+	invitation := &models.HouseholdInvitation{ID: invitationID, HouseholdID: homeID, Email: "dave@example.com", Role: models.HouseholdRoleGuest}
+	repo.On("GetInvitation", mock.Anything, invitationID).Return(invitation, nil)
+	users.On("GetUser", mock.Anything, "dave").Return(&models.User{ID: "dave", Email: "Dave@Example.com"}, nil)
+	users.On("GetUser", mock.Anything, "mallory").Return(&models.User{ID: "mallory", Email: "mallory@example.com"}, nil)
+	repo.On("AcceptInvitation", mock.Anything, invitationID, "dave").Return(&models.HouseholdMember{HouseholdID: homeID, UserID: "dave", Role: models.HouseholdRoleGuest}, nil)
+	repo.On("GetHousehold", mock.Anything, homeID).Return(&models.Household{ID: homeID}, nil)
+
+	// Invitations are only answered by the user they were sent to
+	_, err := service.AcceptInvitation(context.Background(), "mallory", invitationID)
+	assert.ErrorIs(t, err, repository.ErrInvitationNotFound)
+	repo.AssertNotCalled(t, "AcceptInvitation", mock.Anything, mock.Anything, mock.Anything)
+
+	household, err := service.AcceptInvitation(context.Background(), "dave", invitationID)
+	assert.NoError(t, err)
+	assert.Equal(t, homeID, household.ID)
+}
+
+func TestDeclineInvitation(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	users := new(MockUserRepository)
+	service := NewHouseholdService(repo, users)
+
+	invitation := &models.HouseholdInvitation{ID: invitationID, HouseholdID: homeID, Email: "dave@example.com", Role: models.HouseholdRoleGuest}
+	repo.On("GetInvitation", mock.Anything, invitationID).Return(invitation, nil)
+	users.On("GetUser", mock.Anything, "dave").Return(&models.User{ID: "dave", Email: "dave@example.com"}, nil)
+	repo.On("DeclineInvitation", mock.Anything, invitationID).Return(nil, repository.ErrInvitationClosed).Once()
+	declined := &models.HouseholdInvitation{ID: invitationID, Status: models.InvitationStatusDeclined}
+	repo.On("DeclineInvitation", mock.Anything, invitationID).Return(declined, nil)
+
+	// An invitation that was answered already cannot be declined
+	_, err := service.DeclineInvitation(context.Background(), "dave", invitationID)
+	assert.ErrorIs(t, err, repository.ErrInvitationClosed)
+
+	result, err := service.DeclineInvitation(context.Background(), "dave", invitationID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.InvitationStatusDeclined, result.Status)
+}
+
+func TestUpdateMemberRoleTakesOwner(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	service := NewHouseholdService(repo, nil)
+
+	asMember(repo, "alice", models.HouseholdRoleOwner)
+	asMember(repo, "bob", models.HouseholdRoleAdult)
+	repo.On("UpdateMemberRole", mock.Anything, homeID, "bob", models.HouseholdRoleChild).Return(&models.HouseholdMember{UserID: "bob", Role: models.HouseholdRoleChild}, nil)
+	repo.On("UpdateMemberRole", mock.Anything, homeID, "alice", models.HouseholdRoleAdult).Return(nil, repository.ErrLastOwner)
+
+	_, err := service.UpdateMemberRole(context.Background(), "bob", homeID, "bob", models.HouseholdRoleOwner)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+
+	member, err := service.UpdateMemberRole(context.Background(), "alice", homeID, "bob", models.HouseholdRoleChild)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HouseholdRoleChild, member.Role)
+
+	// The only owner cannot step down
+	_, err = service.UpdateMemberRole(context.Background(), "alice", homeID, "alice", models.HouseholdRoleAdult)
+	assert.ErrorIs(t, err, repository.ErrLastOwner)
+}
+
+func TestLeaveHousehold(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	service := NewHouseholdService(repo, nil)
+
+	asMember(repo, "alice", models.HouseholdRoleOwner)
+	asMember(repo, "gina", models.HouseholdRoleGuest)
+	repo.On("RemoveMember", mock.Anything, homeID, "gina").Return(nil)
+	repo.On("RemoveMember", mock.Anything, homeID, "alice").Return(repository.ErrLastOwner)
+
+	// Members may leave, but only owners remove others
+	assert.ErrorIs(t, service.RemoveMember(context.Background(), "gina", homeID, "alice"), ErrPermissionDenied)
+	assert.NoError(t, service.RemoveMember(context.Background(), "gina", homeID, "gina"))
+	assert.ErrorIs(t, service.RemoveMember(context.Background(), "alice", homeID, "alice"), repository.ErrLastOwner)
+	repo.AssertNumberOfCalls(t, "RemoveMember", 2)
+}
+
+func TestHouseholdIDsMustBeUUIDs(t *testing.T) {
+	repo := new(MockHouseholdRepository)
+	service := NewHouseholdService(repo, nil)
+
+	_, err := service.GetHousehold(context.Background(), "alice", "home")
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	_, err = service.InviteMember(context.Background(), "alice", "home", "dave@example.com", models.HouseholdRoleGuest)
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	_, err = service.UpdateMemberRole(context.Background(), "alice", "home", "bob", models.HouseholdRoleGuest)
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	assert.ErrorIs(t, service.RemoveMember(context.Background(), "alice", "home", "alice"), repository.ErrInvalidInput)
+	_, err = service.AcceptInvitation(context.Background(), "dave", "1")
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	_, err = service.DeclineInvitation(context.Background(), "dave", "1")
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+
+	// Malformed IDs never reach the database
+	assert.Empty(t, repo.Calls)
+}
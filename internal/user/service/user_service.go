@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
@@ -13,7 +15,9 @@ import (
 type UserService interface {
 	CreateUser(ctx context.Context, name, email, password string) (*models.User, error)
 	GetUser(ctx context.Context, id string) (*models.User, error)
-	UpdateUser(ctx context.Context, id, name, email string) (*models.User, error)
+	// UpdateUser changes the fields listed in fields ("name", "email"), or
+	// every non-empty one when fields is empty
+	UpdateUser(ctx context.Context, id, name, email string, fields []string) (*models.User, error)
 	DeleteUser(ctx context.Context, id string) error
 }
 
@@ -50,14 +54,42 @@ func (s *service) GetUser(ctx context.Context, id string) (*models.User, error)
 	return s.repo.GetUser(ctx, id)
 }
 
-func (s *service) UpdateUser(ctx context.Context, id, name, email string) (*models.User, error) {
+func (s *service) UpdateUser(ctx context.Context, id, name, email string, fields []string) (*models.User, error) {
+	updateName, updateEmail := name != "", email != ""
+	if len(fields) > 0 {
+		updateName, updateEmail = false, false
+		for _, field := range fields {
+			switch field {
+			case "name":
+				updateName = true
+			case "email":
+				updateEmail = true
+			default:
+				return nil, fmt.Errorf("%w: unknown field %q", repository.ErrInvalidInput, field)
+			}
+		}
+	}
+	if !updateName && !updateEmail {
+		return nil, fmt.Errorf("%w: no fields to update", repository.ErrInvalidInput)
+	}
+	if updateName && strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: name must not be empty", repository.ErrInvalidInput)
+	}
+	if updateEmail && strings.TrimSpace(email) == "" {
+		return nil, fmt.Errorf("%w: email must not be empty", repository.ErrInvalidInput)
+	}
+
 	user, err := s.repo.GetUser(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	user.Name = name
-	user.Email = email
+	if updateName {
+		user.Name = name
+	}
+	if updateEmail {
+		user.Email = email
+	}
 	user.UpdatedAt = time.Now()
 
 	if err := s.repo.UpdateUser(ctx, user); err != nil {
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MichaelGenchev/smart-home-system/internal/user/repository"
+	"github.com/MichaelGenchev/smart-home-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func existingUser() *models.User {
+	return &models.User{ID: "alice", Name: "Alice", Email: "alice@example.com"}
+}
+
+func TestUpdateUserWithoutMaskChangesNonEmptyFields(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUser", mock.Anything, "alice").Return(existingUser(), nil)
+	repo.On("UpdateUser", mock.Anything, mock.Anything).Return(nil)
+
+	user, err := service.UpdateUser(context.Background(), "alice", "Alicia", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Alicia", user.Name)
+	assert.Equal(t, "alice@example.com", user.Email)
+
+	// Without a mask, there has to be something to change
+	_, err = service.UpdateUser(context.Background(), "alice", "", "", nil)
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	repo.AssertNumberOfCalls(t, "UpdateUser", 1)
+}
+
+func TestUpdateUserChangesMaskedFieldsOnly(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUser", mock.Anything, "alice").Return(existingUser(), nil)
+	repo.On("UpdateUser", mock.Anything, mock.Anything).Return(nil)
+
+	user, err := service.UpdateUser(context.Background(), "alice", "Alicia", "alicia@example.com", []string{"email"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", user.Name)
+	assert.Equal(t, "alicia@example.com", user.Email)
+}
+
+func TestUpdateUserRejectsUnknownPaths(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	_, err := service.UpdateUser(context.Background(), "alice", "Alicia", "", []string{"name", "password"})
+
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	assert.Contains(t, err.Error(), `"password"`)
+	repo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+}
+
+func TestUpdateUserCannotClearFields(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	// A masked field with an empty value would clear it, but users always
+	// have a name and an email
+	for _, field := range []string{"name", "email"} {
+		_, err := service.UpdateUser(context.Background(), "alice", "", "", []string{field})
+		assert.ErrorIs(t, err, repository.ErrInvalidInput, field)
+	}
+	repo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+}
@@ -2,18 +2,75 @@
 package models
 
 import (
+	"regexp"
 	"time"
 )
 
 // Device represents a smart home device
 type Device struct {
-	ID        string    `bson:"_id,omitempty" json:"id"`
-	Name      string    `bson:"name" json:"name"`
-	Type      string    `bson:"type" json:"type"`
-	State     string    `bson:"state" json:"state"`
-	UserID    string    `bson:"user_id" json:"user_id"`
-	CreatedAt time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	ID        string                `bson:"_id,omitempty" json:"id"`
+	Name      string                `bson:"name" json:"name"`
+	Type      string                `bson:"type" json:"type"`
+	State     map[string]StateValue `bson:"state" json:"state"`
+	UserID    string                `bson:"user_id" json:"user_id"`
+	RoomID    string                `bson:"room_id,omitempty" json:"room_id,omitempty"`
+	CreatedAt time.Time             `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time             `bson:"updated_at" json:"updated_at"`
+	// HouseholdID is the household that owns the device, whose members share
+	// it. A device without one belongs to UserID alone; otherwise UserID is
+	// the user who created it.
+	HouseholdID string `bson:"household_id,omitempty" json:"household_id,omitempty"`
+	// Labels are key/value pairs that group devices, e.g. floor=2
+	Labels map[string]string `bson:"labels,omitempty" json:"labels,omitempty"`
+	// LastSeenAt is when the device last reported a heartbeat, nil if it never did
+	LastSeenAt *time.Time `bson:"last_seen_at,omitempty" json:"last_seen_at,omitempty"`
+	// Connectivity tells whether the device is reachable, judged by its heartbeats
+	Connectivity Connectivity `bson:"connectivity,omitempty" json:"connectivity,omitempty"`
+	// Version is incremented by every write to the device
+	Version int64 `bson:"version" json:"version"`
+	// WritePosition is the position in the device event log of the write
+	// that returned the device. It is not stored.
+	WritePosition *EventPosition `bson:"-" json:"-"`
+}
+
+// Connectivity tells whether a device is reachable
+type Connectivity string
+
+const (
+	// ConnectivityUnknown is the connectivity of devices that never reported a heartbeat
+	ConnectivityUnknown Connectivity = "unknown"
+	ConnectivityOnline  Connectivity = "online"
+	// ConnectivityOffline is the connectivity of devices whose heartbeats
+	// stopped for longer than the heartbeat timeout of their type
+	ConnectivityOffline Connectivity = "offline"
+)
+
+// ValueType identifies the kind of value held by a state attribute
+type ValueType string
+
+const (
+	ValueTypeBool  ValueType = "bool"
+	ValueTypeInt   ValueType = "int"
+	ValueTypeFloat ValueType = "float"
+	ValueTypeEnum  ValueType = "enum"
+	ValueTypeColor ValueType = "color"
+)
+
+// StateValue is a single typed attribute of a device's state, e.g. a dimmer's brightness
+type StateValue struct {
+	Type  ValueType `bson:"type" json:"type"`
+	Bool  bool      `bson:"bool,omitempty" json:"bool,omitempty"`
+	Int   int64     `bson:"int,omitempty" json:"int,omitempty"`
+	Float float64   `bson:"float,omitempty" json:"float,omitempty"`
+	Enum  string    `bson:"enum,omitempty" json:"enum,omitempty"`
+	Color *Color    `bson:"color,omitempty" json:"color,omitempty"`
+}
+
+// Color represents an RGB color value
+type Color struct {
+	R uint8 `bson:"r" json:"r"`
+	G uint8 `bson:"g" json:"g"`
+	B uint8 `bson:"b" json:"b"`
 }
 
 // User represents a user of the smart home system
@@ -26,32 +83,332 @@ type User struct {
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
+// HouseholdRole is the role of a member in a household, which decides what
+// they may do with its devices and members
+type HouseholdRole string
+
+const (
+	// HouseholdRoleOwner manages the household and its members
+	HouseholdRoleOwner HouseholdRole = "owner"
+	// HouseholdRoleAdult manages devices and invites members
+	HouseholdRoleAdult HouseholdRole = "adult"
+	// HouseholdRoleChild controls devices and sees their history
+	HouseholdRoleChild HouseholdRole = "child"
+	// HouseholdRoleGuest controls devices
+	HouseholdRoleGuest HouseholdRole = "guest"
+)
+
+// Valid reports whether r is one of the known roles
+func (r HouseholdRole) Valid() bool {
+	switch r {
+	case HouseholdRoleOwner, HouseholdRoleAdult, HouseholdRoleChild, HouseholdRoleGuest:
+		return true
+	}
+	return false
+}
+
+// CanControlDevices reports whether the role may change the state of devices
+func (r HouseholdRole) CanControlDevices() bool {
+	return r.Valid()
+}
+
+// CanViewHistory reports whether the role may read the state history of devices
+func (r HouseholdRole) CanViewHistory() bool {
+	return r == HouseholdRoleOwner || r == HouseholdRoleAdult || r == HouseholdRoleChild
+}
+
+// CanManageDevices reports whether the role may add, change and remove devices
+func (r HouseholdRole) CanManageDevices() bool {
+	return r == HouseholdRoleOwner || r == HouseholdRoleAdult
+}
+
+// CanInvite reports whether the role may invite members with the given role.
+// Only owners make other owners.
+func (r HouseholdRole) CanInvite(role HouseholdRole) bool {
+	if role == HouseholdRoleOwner {
+		return r == HouseholdRoleOwner
+	}
+	return r == HouseholdRoleOwner || r == HouseholdRoleAdult
+}
+
+// CanManageMembers reports whether the role may change the roles of members and remove them
+func (r HouseholdRole) CanManageMembers() bool {
+	return r == HouseholdRoleOwner
+}
+
+// Household is a home whose members share its devices
+type Household struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Members   []HouseholdMember `json:"members,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// HouseholdMember is a user's membership in a household
+type HouseholdMember struct {
+	HouseholdID string        `json:"household_id"`
+	UserID      string        `json:"user_id"`
+	Role        HouseholdRole `json:"role"`
+	JoinedAt    time.Time     `json:"joined_at"`
+}
+
+// InvitationStatus tells whether an invitation was answered
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "pending"
+	InvitationStatusAccepted InvitationStatus = "accepted"
+	InvitationStatusDeclined InvitationStatus = "declined"
+)
+
+// HouseholdInvitation invites whoever has a user with Email to join a
+// household with Role
+type HouseholdInvitation struct {
+	ID            string           `json:"id"`
+	HouseholdID   string           `json:"household_id"`
+	HouseholdName string           `json:"household_name"`
+	Email         string           `json:"email"`
+	Role          HouseholdRole    `json:"role"`
+	InvitedBy     string           `json:"invited_by"`
+	Status        InvitationStatus `json:"status"`
+	CreatedAt     time.Time        `json:"created_at"`
+	ExpiresAt     time.Time        `json:"expires_at"`
+}
+
 // DeviceState represents the current state of a device
 type DeviceState struct {
+	DeviceID  string                `bson:"device_id" json:"device_id"`
+	State     map[string]StateValue `bson:"state" json:"state"`
+	Timestamp time.Time             `bson:"timestamp" json:"timestamp"`
+}
+
+// TelemetryPoint is a numeric reading of a sensor, such as a temperature or
+// the power drawn. Readings are kept apart from the device state, which holds
+// what a device is set to rather than what it measures.
+type TelemetryPoint struct {
 	DeviceID  string    `bson:"device_id" json:"device_id"`
-	State     string    `bson:"state" json:"state"`
+	Metric    string    `bson:"metric" json:"metric"`
+	Value     float64   `bson:"value" json:"value"`
 	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
 }
 
-// DeviceType represents a type of smart home device
+// TelemetryBucket summarizes the readings of a metric in the interval that
+// starts at Start
+type TelemetryBucket struct {
+	Start time.Time `bson:"start" json:"start"`
+	Min   float64   `bson:"min" json:"min"`
+	Max   float64   `bson:"max" json:"max"`
+	Avg   float64   `bson:"avg" json:"avg"`
+	Count int64     `bson:"count" json:"count"`
+}
+
+// DeviceType represents a type of smart home device. Devices refer to their
+// type by ID, e.g. "dimmer".
 type DeviceType struct {
-	ID          string `bson:"_id,omitempty" json:"id"`
-	Name        string `bson:"name" json:"name"`
-	Description string `bson:"description" json:"description"`
+	ID           string       `bson:"_id,omitempty" json:"id"`
+	Name         string       `bson:"name" json:"name"`
+	Description  string       `bson:"description" json:"description"`
+	Capabilities []Capability `bson:"capabilities" json:"capabilities"`
+	// HeartbeatTimeout is how long devices of the type may go without a
+	// heartbeat before they are considered offline. Zero means the default
+	// of the device service.
+	HeartbeatTimeout time.Duration `bson:"heartbeat_timeout,omitempty" json:"heartbeat_timeout,omitempty"`
 }
 
-// Room represents a room in the smart home
+// AttributeNamePattern matches the names of state attributes and telemetry
+// metrics. It keeps them safe to use as Mongo field paths and JSON keys in SQL.
+var AttributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Capability declares a state attribute supported by a device type and the values it accepts
+type Capability struct {
+	Name    string      `bson:"name" json:"name"`
+	Type    ValueType   `bson:"type" json:"type"`
+	Min     *float64    `bson:"min,omitempty" json:"min,omitempty"`         // int and float only
+	Max     *float64    `bson:"max,omitempty" json:"max,omitempty"`         // int and float only
+	Values  []string    `bson:"values,omitempty" json:"values,omitempty"`   // enum only
+	Default *StateValue `bson:"default,omitempty" json:"default,omitempty"` // initial value for new devices
+}
+
+// Room represents a room in the smart home. A device belongs to at most one
+// room, and only to a room of its own household. Rooms of a household are
+// shared by its members; other rooms belong to their user alone.
 type Room struct {
-	ID        string   `bson:"_id,omitempty" json:"id"`
-	Name      string   `bson:"name" json:"name"`
-	DeviceIDs []string `bson:"device_ids" json:"device_ids"`
+	ID          string    `bson:"_id,omitempty" json:"id"`
+	Name        string    `bson:"name" json:"name"`
+	UserID      string    `bson:"user_id" json:"user_id"`
+	HouseholdID string    `bson:"household_id,omitempty" json:"household_id,omitempty"`
+	DeviceIDs   []string  `bson:"device_ids" json:"device_ids"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Scene is a named set of device states that are applied together, e.g.
+// "Movie night" dimming the lights and closing the blinds
+type Scene struct {
+	ID        string        `bson:"_id,omitempty" json:"id"`
+	Name      string        `bson:"name" json:"name"`
+	UserID    string        `bson:"user_id" json:"user_id"`
+	Targets   []SceneTarget `bson:"targets" json:"targets"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+// SceneTarget is the state patch a scene applies to one device
+type SceneTarget struct {
+	DeviceID string                `bson:"device_id" json:"device_id"`
+	State    map[string]StateValue `bson:"state" json:"state"`
 }
 
-// Schedule represents a scheduled action for a device
+// Schedule represents a scheduled action for a device. A schedule with a Cron
+// expression repeats; without one it fires once at Time. Both are interpreted
+// in the schedule's Timezone.
 type Schedule struct {
-	ID       string    `bson:"_id,omitempty" json:"id"`
-	DeviceID string    `bson:"device_id" json:"device_id"`
-	Action   string    `bson:"action" json:"action"`
-	Time     time.Time `bson:"time" json:"time"`
-	Repeat   bool      `bson:"repeat" json:"repeat"`
+	ID        string                `bson:"_id,omitempty" json:"id"`
+	DeviceID  string                `bson:"device_id" json:"device_id"`
+	UserID    string                `bson:"user_id" json:"user_id"`
+	Action    map[string]StateValue `bson:"action" json:"action"` // state patch applied to the device
+	Time      time.Time             `bson:"time" json:"time"`
+	Cron      string                `bson:"cron,omitempty" json:"cron,omitempty"`
+	Timezone  string                `bson:"timezone" json:"timezone"`
+	Enabled   bool                  `bson:"enabled" json:"enabled"`
+	NextRunAt *time.Time            `bson:"next_run_at,omitempty" json:"next_run_at,omitempty"`
+	LastRunAt *time.Time            `bson:"last_run_at,omitempty" json:"last_run_at,omitempty"`
+	CreatedAt time.Time             `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time             `bson:"updated_at" json:"updated_at"`
+}
+
+// Repeats reports whether the schedule fires on a recurring basis
+func (s *Schedule) Repeats() bool {
+	return s.Cron != ""
+}
+
+// ScheduleRun records the outcome of a single execution of a schedule
+type ScheduleRun struct {
+	ID         string    `bson:"_id,omitempty" json:"id"`
+	ScheduleID string    `bson:"schedule_id" json:"schedule_id"`
+	DeviceID   string    `bson:"device_id" json:"device_id"`
+	RanAt      time.Time `bson:"ran_at" json:"ran_at"`
+	Success    bool      `bson:"success" json:"success"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// TriggerType identifies what causes an automation rule to fire
+type TriggerType string
+
+const (
+	TriggerDeviceState TriggerType = "device_state" // a device attribute changes
+	TriggerTime        TriggerType = "time"         // a cron expression falls due
+	TriggerRule        TriggerType = "rule"         // another rule executed successfully
+)
+
+// ConditionOperator compares a device attribute with a condition's value
+type ConditionOperator string
+
+const (
+	OperatorEqual          ConditionOperator = "eq"
+	OperatorNotEqual       ConditionOperator = "ne"
+	OperatorGreater        ConditionOperator = "gt"
+	OperatorGreaterOrEqual ConditionOperator = "gte"
+	OperatorLess           ConditionOperator = "lt"
+	OperatorLessOrEqual    ConditionOperator = "lte"
+)
+
+// Rule is an automation: when its trigger fires and all conditions hold, its
+// actions are applied
+type Rule struct {
+	ID         string          `bson:"_id,omitempty" json:"id"`
+	Name       string          `bson:"name" json:"name"`
+	UserID     string          `bson:"user_id" json:"user_id"`
+	Enabled    bool            `bson:"enabled" json:"enabled"`
+	Trigger    RuleTrigger     `bson:"trigger" json:"trigger"`
+	Conditions []RuleCondition `bson:"conditions" json:"conditions"`
+	Actions    []RuleAction    `bson:"actions" json:"actions"`
+	NextRunAt  *time.Time      `bson:"next_run_at,omitempty" json:"next_run_at,omitempty"` // time triggers only
+	CreatedAt  time.Time       `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time       `bson:"updated_at" json:"updated_at"`
+}
+
+// RuleTrigger describes when a rule fires. Which fields apply depends on Type.
+type RuleTrigger struct {
+	Type TriggerType `bson:"type" json:"type"`
+	// device_state: fires when Attribute of DeviceID changes to Value. An
+	// empty Attribute matches any attribute and a nil Value any new value.
+	DeviceID  string      `bson:"device_id,omitempty" json:"device_id,omitempty"`
+	Attribute string      `bson:"attribute,omitempty" json:"attribute,omitempty"`
+	Value     *StateValue `bson:"value,omitempty" json:"value,omitempty"`
+	// time
+	Cron     string `bson:"cron,omitempty" json:"cron,omitempty"`
+	Timezone string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	// rule
+	RuleID string `bson:"rule_id,omitempty" json:"rule_id,omitempty"`
+}
+
+// RuleCondition must hold for a triggered rule to apply its actions
+type RuleCondition struct {
+	DeviceID  string            `bson:"device_id" json:"device_id"`
+	Attribute string            `bson:"attribute" json:"attribute"`
+	Operator  ConditionOperator `bson:"operator" json:"operator"`
+	Value     StateValue        `bson:"value" json:"value"`
+}
+
+// RuleAction is the state patch a rule applies to one device
+type RuleAction struct {
+	DeviceID string                `bson:"device_id" json:"device_id"`
+	State    map[string]StateValue `bson:"state" json:"state"`
+}
+
+// RuleExecutionStatus is the outcome of a triggered rule
+type RuleExecutionStatus string
+
+const (
+	RuleExecutionSucceeded       RuleExecutionStatus = "succeeded"
+	RuleExecutionFailed          RuleExecutionStatus = "failed"
+	RuleExecutionConditionsUnmet RuleExecutionStatus = "conditions_not_met"
+	RuleExecutionLoopPrevented   RuleExecutionStatus = "loop_prevented"
+)
+
+// RuleExecution records a single time a rule was triggered
+type RuleExecution struct {
+	ID         string              `bson:"_id,omitempty" json:"id"`
+	RuleID     string              `bson:"rule_id" json:"rule_id"`
+	Trigger    string              `bson:"trigger" json:"trigger"` // what fired the rule, e.g. "device 42 state"
+	Depth      int                 `bson:"depth" json:"depth"`     // number of rules that ran before it in the same chain
+	Status     RuleExecutionStatus `bson:"status" json:"status"`
+	Error      string              `bson:"error,omitempty" json:"error,omitempty"`
+	ExecutedAt time.Time           `bson:"executed_at" json:"executed_at"`
+}
+
+// DeviceEventType is the kind of change recorded in the device event log
+type DeviceEventType string
+
+const (
+	DeviceEventCreated DeviceEventType = "created"
+	DeviceEventUpdated DeviceEventType = "updated"
+	DeviceEventDeleted DeviceEventType = "deleted"
+	// DeviceEventConnectivityChanged records a device going online or offline
+	DeviceEventConnectivityChanged DeviceEventType = "connectivity_changed"
+)
+
+// EventPosition locates an event in the device event log. Events are ordered
+// by the transaction that wrote them, then by sequence number.
+type EventPosition struct {
+	TxID uint64 `bson:"tx_id" json:"tx_id"`
+	Seq  int64  `bson:"seq" json:"seq"`
+}
+
+// After reports whether p comes later in the log than other
+func (p EventPosition) After(other EventPosition) bool {
+	if p.TxID != other.TxID {
+		return p.TxID > other.TxID
+	}
+	return p.Seq > other.Seq
+}
+
+// DeviceEvent records a change to a device. Device is the device after the
+// change, or as it was before being deleted.
+type DeviceEvent struct {
+	Position   EventPosition   `bson:"position" json:"position"`
+	Type       DeviceEventType `bson:"type" json:"type"`
+	Device     Device          `bson:"device" json:"device"`
+	OccurredAt time.Time       `bson:"occurred_at" json:"occurred_at"`
 }